@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/redis"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	redispkg "github.com/SaveljevRoman/go-layout-project/pkg/redis"
+	"log/slog"
+)
+
+// caches - реализации кешей, выбранные в cfg.CacheDriver
+type caches struct {
+	users      service.UserCache
+	products   service.ProductCache
+	purchases  service.PurchaseCache
+	categories service.CategoryCache
+	// coPurchases и reports - тот же кеш покупок: он хранит и совместные покупки, и готовые отчеты
+	coPurchases service.CoPurchaseCache
+	reports     service.ReportCache
+	close       func() error
+}
+
+func newCaches(cfg *config.Config) (*caches, error) {
+	switch cfg.CacheDriver {
+	case "redis":
+		client, err := redispkg.NewConnection(cfg.Redis)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := redispkg.Ping(context.Background(), client); err != nil {
+			slog.Warn("Redis is not available, continuing without cache", "error", err)
+		}
+
+		purchases := redis.NewPurchaseCache(client)
+		return &caches{
+			users:       redis.NewUserCache(client),
+			products:    redis.NewProductCache(client),
+			purchases:   purchases,
+			categories:  redis.NewCategoryCache(client),
+			coPurchases: purchases,
+			reports:     purchases,
+			close:       client.Close,
+		}, nil
+	case "memory":
+		purchases := memory.NewPurchaseCache()
+		return &caches{
+			users:       memory.NewUserCache(),
+			products:    memory.NewProductCache(),
+			purchases:   purchases,
+			categories:  memory.NewCategoryCache(),
+			coPurchases: purchases,
+			reports:     purchases,
+			close:       func() error { return nil },
+		}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный кеш: %s", cfg.CacheDriver)
+	}
+}
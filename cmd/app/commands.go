@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/exchange"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// services - сервисы, с которыми работают команды
+type services struct {
+	users     *service.UserService
+	products  *service.ProductService
+	purchases *service.PurchaseService
+	rollups   *service.RollupService
+}
+
+// runCommand выполняет команду из аргументов вместо запуска сервера:
+//
+//	app export -entity products -format csv -out products.csv
+//	app import -format csv -key name -map name=Название -dry-run products.csv
+//	app rollup-backfill -from 2026-01-01 -to 2026-01-31
+func runCommand(ctx context.Context, args []string, svc *services) error {
+	switch args[0] {
+	case "export":
+		return runExport(ctx, args[1:], svc)
+	case "import":
+		return runImport(ctx, args[1:], svc)
+	case "rollup-backfill":
+		return runRollupBackfill(ctx, args[1:], svc)
+	default:
+		return fmt.Errorf("неизвестная команда: %s (доступны export, import и rollup-backfill)", args[0])
+	}
+}
+
+func runExport(ctx context.Context, args []string, svc *services) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	entity := flags.String("entity", "products", "что выгрузить: users, products или purchases")
+	formatName := flags.String("format", exchange.FormatCSV, "формат файла: csv или jsonl")
+	out := flags.String("out", "", "файл для выгрузки (по умолчанию stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	format, err := exchange.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var dst io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		dst = file
+	}
+	w := bufio.NewWriter(dst)
+
+	switch *entity {
+	case "users":
+		err = exportTo(ctx, w, format, svc.users.ExportUsers)
+	case "products":
+		err = exportTo(ctx, w, format, svc.products.ExportProducts)
+	case "purchases":
+		err = exportTo(ctx, w, format, svc.purchases.ExportPurchases)
+	default:
+		return fmt.Errorf("неизвестная сущность: %s (ожидается users, products или purchases)", *entity)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func exportTo[T any](ctx context.Context, w io.Writer, format string,
+	export func(ctx context.Context, fn func(item *T) error) error,
+) error {
+	encoder, err := exchange.NewEncoder[T](w, format)
+	if err != nil {
+		return err
+	}
+	if err := export(ctx, encoder.Encode); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func runImport(ctx context.Context, args []string, svc *services) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flags.String("format", exchange.FormatCSV, "формат файла: csv или jsonl")
+	key := flags.String("key", exchange.KeyName, "естественный ключ товара: name или id")
+	dryRun := flags.Bool("dry-run", false, "только проверить файл, ничего не записывая")
+	var mapping []string
+	flags.Func("map", "сопоставление поле=столбец (можно повторять)", func(value string) error {
+		mapping = append(mapping, value)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	format, err := exchange.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var src io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		src = file
+	}
+
+	report, err := exchange.ImportProducts(ctx, svc.products, src, exchange.ImportOptions{
+		Format:  format,
+		Key:     strings.ToLower(*key),
+		Mapping: mapping,
+		DryRun:  *dryRun,
+	})
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("строк с ошибками: %d", report.Failed)
+	}
+	return nil
+}
+
+// runRollupBackfill пересчитывает дневные агрегаты продаж за диапазон дат по таблице покупок
+func runRollupBackfill(ctx context.Context, args []string, svc *services) error {
+	flags := flag.NewFlagSet("rollup-backfill", flag.ContinueOnError)
+	fromValue := flags.String("from", "", "первый день, YYYY-MM-DD (по умолчанию - вся история)")
+	toValue := flags.String("to", "", "последний день включительно, YYYY-MM-DD (по умолчанию - сегодня)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var from time.Time
+	to := time.Now().UTC()
+	var err error
+	if *fromValue != "" {
+		if from, err = time.Parse(models.ReportDateLayout, *fromValue); err != nil {
+			return errors.New("from: ожидается дата в формате ГГГГ-ММ-ДД")
+		}
+	}
+	if *toValue != "" {
+		if to, err = time.Parse(models.ReportDateLayout, *toValue); err != nil {
+			return errors.New("to: ожидается дата в формате ГГГГ-ММ-ДД")
+		}
+	}
+	if from.After(to) {
+		return errors.New("from: дата начала позже даты окончания")
+	}
+
+	count, err := svc.rollups.Backfill(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	slog.Info("Sales rollups rebuilt", "purchases", count)
+	return nil
+}
@@ -2,14 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/SaveljevRoman/go-layout-project/internal/api"
 	"github.com/SaveljevRoman/go-layout-project/internal/config"
 	"github.com/SaveljevRoman/go-layout-project/internal/repository/mysql"
-	"github.com/SaveljevRoman/go-layout-project/internal/repository/redis"
 	"github.com/SaveljevRoman/go-layout-project/internal/service"
-	mysqlpkg "github.com/SaveljevRoman/go-layout-project/pkg/mysql"
-	redispkg "github.com/SaveljevRoman/go-layout-project/pkg/redis"
-	"log"
+	"github.com/SaveljevRoman/go-layout-project/pkg/logger"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,38 +17,85 @@ import (
 )
 
 func main() {
+	configPath := flag.String("config", envOr("APP_CONFIG", config.DefaultPath), "путь к файлу конфигурации (JSON или YAML)")
+	flag.Parse()
 
 	// Загрузка конфигурации
-	cfg, err := config.Load()
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
 	}
 
-	// Инициализация подключений к БД
-	mysqlDB, err := mysqlpkg.NewConnection(cfg.MySQL)
+	logLevel, err := logger.Setup(cfg.LogLevel)
 	if err != nil {
-		log.Fatalf("Failed to connect to MySQL: %v", err)
+		fatal("Failed to configure logger", err)
 	}
-	defer mysqlDB.Close()
 
-	redisClient, err := redispkg.NewConnection(cfg.Redis)
+	// Инициализация подключений к БД; SIGINT/SIGTERM прерывает ожидание недоступной БД
+	connectCtx, stopConnect := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	store, err := newStorage(connectCtx, cfg)
+	stopConnect()
 	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		fatal("Failed to connect to database", err)
 	}
-	defer redisClient.Close()
+	defer store.Close()
 
-	// Инициализация репозиториев
-	userRepo := mysql.NewUserRepository(mysqlDB)
-	userCache := redis.NewUserCache(redisClient)
-	productRepo := mysql.NewProductRepository(mysqlDB)
-	productCache := redis.NewProductCache(redisClient)
-	purchaseRepo := mysql.NewPurchaseRepository(mysqlDB)
-	purchaseCache := redis.NewPurchaseCache(redisClient)
+	cache, err := newCaches(cfg)
+	if err != nil {
+		fatal("Failed to connect to cache", err)
+	}
+	defer cache.close()
 
 	// Инициализация сервисов
-	userService := service.NewUserService(userRepo, userCache)
-	productService := service.NewProductService(productRepo, productCache)
-	purchaseService := service.NewPurchaseService(purchaseRepo, purchaseCache, userService, productService)
+	userService := service.NewUserService(store.users, cache.users, store.txManager)
+	productService := service.NewProductService(store.products, cache.products, store.txManager)
+	categoryService := service.NewCategoryService(store.categories, store.tags, cache.categories, productService, store.txManager)
+	variantService := service.NewVariantService(store.variants, productService, store.txManager)
+	rollupService := service.NewRollupService(store.purchases, store.rollups, store.txManager)
+	promotionService := service.NewPromotionService(store.promotions, categoryService, store.txManager)
+	purchaseService := service.NewPurchaseService(store.purchases, cache.purchases, userService, productService, variantService, promotionService, rollupService, store.txManager)
+	recommendationService := service.NewRecommendationService(store.purchases, cache.coPurchases, userService, productService, variantService)
+	reportService := service.NewReportService(store.rollups, cache.reports, userService, productService)
+	priceService := service.NewPriceService(store.prices, productService, store.txManager)
+
+	// Команда из аргументов (export, import, rollup-backfill) выполняется вместо запуска сервера
+	if flag.NArg() > 0 {
+		svc := &services{users: userService, products: productService, purchases: purchaseService, rollups: rollupService}
+		if err := runCommand(context.Background(), flag.Args(), svc); err != nil {
+			fatal("Command failed", err, "command", flag.Arg(0))
+		}
+		return
+	}
+
+	rateLimiter := api.NewRateLimiter(cfg.RateLimit.Enabled, cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+	features := config.NewFeatures(cfg.Features)
+
+	// Применение параметров, которые можно менять без перезапуска
+	applyConfig := func(cfg *config.Config) {
+		if err := logger.SetLevel(logLevel, cfg.LogLevel); err != nil {
+			slog.Warn("Failed to set log level", "error", err)
+		}
+
+		ttl := time.Duration(cfg.CacheTTL) * time.Second
+		refreshTTL := time.Duration(cfg.CacheRefreshTTL) * time.Second
+		interval := time.Duration(cfg.CacheUpdateInterval) * time.Second
+		userService.SetCacheTTL(ttl, refreshTTL)
+		userService.SetCacheUpdateInterval(interval)
+		productService.SetCacheTTL(ttl, refreshTTL)
+		productService.SetCacheUpdateInterval(interval)
+		purchaseService.SetCacheTTL(ttl, refreshTTL)
+		purchaseService.SetCacheUpdateInterval(interval)
+		categoryService.SetCacheTTL(ttl, refreshTTL)
+		reportService.SetCacheTTL(ttl, refreshTTL)
+		productService.SetSearchSettings(cfg.Search.Stemming, time.Duration(cfg.Search.CacheTTL)*time.Second)
+		productService.SetSuggestRebuildInterval(time.Duration(cfg.Search.SuggestRebuildInterval) * time.Second)
+		recommendationService.SetRebuildInterval(time.Duration(cfg.RecommendRebuildInterval) * time.Second)
+		priceService.SetScheduleInterval(time.Duration(cfg.PriceScheduleInterval) * time.Second)
+
+		rateLimiter.SetLimit(cfg.RateLimit.Enabled, cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+		features.Set(cfg.Features)
+	}
+	applyConfig(cfg)
 
 	// Запуск фоновых горутин для обновления кеша
 	ctx, cancel := context.WithCancel(context.Background())
@@ -58,31 +104,47 @@ func main() {
 	go userService.StartCacheUpdater(ctx, time.Duration(cfg.CacheUpdateInterval)*time.Second)
 	go productService.StartCacheUpdater(ctx, time.Duration(cfg.CacheUpdateInterval)*time.Second)
 	go purchaseService.StartCacheUpdater(ctx, time.Duration(cfg.CacheUpdateInterval)*time.Second)
+	go productService.StartSuggestRebuilder(ctx, time.Duration(cfg.Search.SuggestRebuildInterval)*time.Second)
+	go func() {
+		// Дальше рейтинг популярных товаров обновляется при каждой смене статуса покупки
+		if err := purchaseService.RebuildSales(ctx); err != nil {
+			slog.Error("Failed to rebuild product sales", "error", err)
+		}
+	}()
+	go recommendationService.StartRebuilder(ctx, time.Duration(cfg.RecommendRebuildInterval)*time.Second)
+	go priceService.StartScheduler(ctx, time.Duration(cfg.PriceScheduleInterval)*time.Second)
+	go store.background(ctx)
+
+	// Перезагрузка конфигурации по SIGHUP и при изменении файла
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	watcher := config.NewWatcher(*configPath, cfg, applyConfig)
+	go watcher.Run(ctx, reload, time.Duration(cfg.ConfigWatchInterval)*time.Second)
 
 	// Инициализация роутера и хендлеров
-	router := api.NewRouter(userService, productService, purchaseService)
+	router := api.NewRouter(userService, productService, purchaseService, categoryService, variantService, recommendationService, reportService, priceService, promotionService)
 
 	// Запуск HTTP сервера
 	server := &http.Server{
 		Addr:    cfg.ServerAddress,
-		Handler: router,
+		Handler: rateLimiter.Middleware(readYourWrites(router)),
 	}
 
 	// Graceful shutdown
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
 
-	log.Printf("Server started on %s", cfg.ServerAddress)
+	slog.Info("Server started", "address", cfg.ServerAddress)
 
 	// Обработка сигналов для graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server...")
 
 	// Завершение контекста для остановки фоновых задач
 	cancel()
@@ -92,8 +154,30 @@ func main() {
 	defer shutdownCancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Server shutdown failed: %v", err)
+		fatal("Server shutdown failed", err)
+	}
+
+	slog.Info("Server exited properly")
+}
+
+// fatal логирует ошибку запуска и завершает процесс: в отличие от log.Fatalf сообщение
+// пишется с уровнем error и не теряется при log_level=error
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
+}
+
+// envOr возвращает значение переменной окружения или значение по умолчанию
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
 	}
+	return fallback
+}
 
-	log.Println("Server exited properly")
+// readYourWrites открывает для каждого запроса сессию, в которой чтения после записи идут в primary
+func readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(mysql.WithSession(r.Context())))
+	})
 }
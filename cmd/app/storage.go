@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/mysql"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/postgres"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqlite"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	mysqlpkg "github.com/SaveljevRoman/go-layout-project/pkg/mysql"
+	postgrespkg "github.com/SaveljevRoman/go-layout-project/pkg/postgres"
+	sqlitepkg "github.com/SaveljevRoman/go-layout-project/pkg/sqlite"
+	"time"
+)
+
+// storage - репозитории и менеджер транзакций хранилища, выбранного в cfg.Driver
+type storage struct {
+	users      service.UserRepository
+	products   service.ProductRepository
+	purchases  service.PurchaseRepository
+	categories service.CategoryRepository
+	tags       service.TagRepository
+	variants   service.VariantRepository
+	rollups    service.RollupRepository
+	prices     service.PriceRepository
+	promotions service.PromotionRepository
+	txManager  service.TxManager
+
+	// background запускает фоновые задачи хранилища (например, проверку реплик)
+	background func(ctx context.Context)
+	closers    []func() error
+}
+
+// newStorage подключается к хранилищу; отмена ctx прерывает повторные попытки подключения
+func newStorage(ctx context.Context, cfg *config.Config) (*storage, error) {
+	switch cfg.Driver {
+	case "mysql":
+		return newMySQLStorage(ctx, cfg.MySQL)
+	case "postgres":
+		return newPostgresStorage(ctx, cfg.Postgres)
+	case "sqlite":
+		return newSQLiteStorage(cfg.SQLite)
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище: %s", cfg.Driver)
+	}
+}
+
+func newMySQLStorage(ctx context.Context, cfg config.MySQLConfig) (*storage, error) {
+	primary, err := mysqlpkg.NewConnection(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("MySQL: %w", err)
+	}
+
+	replicas, err := mysqlpkg.NewReplicaConnections(cfg)
+	if err != nil {
+		primary.Close()
+		return nil, fmt.Errorf("MySQL replicas: %w", err)
+	}
+
+	s := &storage{closers: []func() error{primary.Close}}
+	for _, replica := range replicas {
+		s.closers = append(s.closers, replica.Close)
+	}
+
+	db := mysql.NewDB(primary).WithReplicas(time.Duration(cfg.ReplicaMaxLag)*time.Second, replicas...)
+	s.users = mysql.NewUserRepository(db)
+	s.products = mysql.NewProductRepository(db)
+	s.purchases = mysql.NewPurchaseRepository(db)
+	s.categories = mysql.NewCategoryRepository(db)
+	s.tags = mysql.NewTagRepository(db)
+	s.variants = mysql.NewVariantRepository(db)
+	s.rollups = mysql.NewRollupRepository(db)
+	s.prices = mysql.NewPriceRepository(db)
+	s.promotions = mysql.NewPromotionRepository(db)
+	s.txManager = mysql.NewTxManager(db, cfg.TxMaxRetries)
+	s.background = func(ctx context.Context) {
+		db.StartHealthChecker(ctx, time.Duration(cfg.ReplicaHealthCheckInterval)*time.Second)
+	}
+
+	return s, nil
+}
+
+func newPostgresStorage(ctx context.Context, cfg config.PostgresConfig) (*storage, error) {
+	conn, err := postgrespkg.NewConnection(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL: %w", err)
+	}
+
+	db := postgres.NewDB(conn)
+	return &storage{
+		users:      postgres.NewUserRepository(db),
+		products:   postgres.NewProductRepository(db),
+		purchases:  postgres.NewPurchaseRepository(db),
+		categories: postgres.NewCategoryRepository(db),
+		tags:       postgres.NewTagRepository(db),
+		variants:   postgres.NewVariantRepository(db),
+		rollups:    postgres.NewRollupRepository(db),
+		prices:     postgres.NewPriceRepository(db),
+		promotions: postgres.NewPromotionRepository(db),
+		txManager:  postgres.NewTxManager(db, cfg.TxMaxRetries),
+		background: func(ctx context.Context) {},
+		closers:    []func() error{conn.Close},
+	}, nil
+}
+
+func newSQLiteStorage(cfg config.SQLiteConfig) (*storage, error) {
+	conn, err := sqlitepkg.NewConnection(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("SQLite: %w", err)
+	}
+
+	if err := sqlite.Migrate(context.Background(), conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SQLite migration: %w", err)
+	}
+
+	db := sqlite.NewDB(conn)
+	return &storage{
+		users:      sqlite.NewUserRepository(db),
+		products:   sqlite.NewProductRepository(db),
+		purchases:  sqlite.NewPurchaseRepository(db),
+		categories: sqlite.NewCategoryRepository(db),
+		tags:       sqlite.NewTagRepository(db),
+		variants:   sqlite.NewVariantRepository(db),
+		rollups:    sqlite.NewRollupRepository(db),
+		prices:     sqlite.NewPriceRepository(db),
+		promotions: sqlite.NewPromotionRepository(db),
+		txManager:  sqlite.NewTxManager(db, cfg.TxMaxRetries),
+		background: func(ctx context.Context) {},
+		closers:    []func() error{conn.Close},
+	}, nil
+}
+
+func (s *storage) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+}
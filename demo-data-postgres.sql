@@ -0,0 +1,281 @@
+-- Схема для PostgreSQL, эквивалентная demo-data-sql.sql (MySQL)
+
+-- Функция для автоматического обновления updated_at (аналог ON UPDATE CURRENT_TIMESTAMP)
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS
+$$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Создание таблицы пользователей
+CREATE TABLE IF NOT EXISTS users
+(
+    id         BIGSERIAL PRIMARY KEY,
+    username   VARCHAR(50)  NOT NULL UNIQUE,
+    email      VARCHAR(100) NOT NULL UNIQUE,
+    version    BIGINT       NOT NULL DEFAULT 1,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER users_updated_at
+    BEFORE UPDATE
+    ON users
+    FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+-- Добавление демо-данных
+INSERT INTO users (username, email)
+VALUES ('user1', 'user1@example.com'),
+       ('user2', 'user2@example.com'),
+       ('user3', 'user3@example.com'),
+       ('john_doe', 'john@example.com'),
+       ('jane_smith', 'jane@example.com'),
+       ('tech_guru', 'tech@example.com'),
+       ('marketing_pro', 'marketing@example.com'),
+       ('design_master', 'design@example.com'),
+       ('dev_ninja', 'developer@example.com'),
+       ('data_scientist', 'data@example.com');
+
+-- ----------------------------------------------------------------------------------------------------------------------
+-- Создаем таблицу для продуктов
+CREATE TABLE IF NOT EXISTS products
+(
+    id          BIGSERIAL PRIMARY KEY,
+    name        VARCHAR(255)   NOT NULL,
+    description TEXT           NOT NULL DEFAULT '',
+    price       NUMERIC(10, 2) NOT NULL,
+    quantity    INT            NOT NULL DEFAULT 0,
+    version     BIGINT         NOT NULL DEFAULT 1,
+    created_at  TIMESTAMP      NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_name ON products (name);
+CREATE INDEX IF NOT EXISTS idx_price ON products (price);
+-- Полнотекстовый поиск (GET /api/products/search); выражение совпадает с productSearchVector в репозитории
+CREATE INDEX IF NOT EXISTS idx_products_search ON products
+    USING GIN ((setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', description), 'B')));
+
+CREATE TRIGGER products_updated_at
+    BEFORE UPDATE
+    ON products
+    FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+-- История цен: триггер пишет цену нового товара и каждое изменение цены (GET /api/products/{id}/prices)
+CREATE TABLE IF NOT EXISTS product_prices
+(
+    id         BIGSERIAL PRIMARY KEY,
+    product_id BIGINT         NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price      NUMERIC(10, 2) NOT NULL,
+    changed_at TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_prices_product_id_changed_at ON product_prices (product_id, changed_at);
+
+CREATE OR REPLACE FUNCTION record_product_price() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.price <> OLD.price THEN
+        INSERT INTO product_prices (product_id, price) VALUES (NEW.id, NEW.price);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_price_history
+    AFTER INSERT OR UPDATE OF price
+    ON products
+    FOR EACH ROW
+EXECUTE FUNCTION record_product_price();
+
+-- Запланированные цены: фоновая задача приложения применяет их в effective_at и проставляет applied_at
+CREATE TABLE IF NOT EXISTS scheduled_prices
+(
+    id           BIGSERIAL PRIMARY KEY,
+    product_id   BIGINT         NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price        NUMERIC(10, 2) NOT NULL,
+    effective_at TIMESTAMP      NOT NULL,
+    created_at   TIMESTAMP      NOT NULL DEFAULT NOW(),
+    applied_at   TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_product_id ON scheduled_prices (product_id);
+-- Непримененные цены по времени вступления в силу
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_pending ON scheduled_prices (effective_at) WHERE applied_at IS NULL;
+
+INSERT INTO products (name, description, price, quantity)
+VALUES ('Смартфон Super Phone X5', 'Флагманский смартфон с 6.5" AMOLED экраном, 8 ГБ RAM и 128 ГБ ROM', 59999.99, 15),
+       ('Ноутбук ProBook 15', '15.6" ноутбук для профессионалов с Intel Core i7, 16 ГБ RAM, SSD 512 ГБ', 89999.50, 8),
+       ('Беспроводные наушники SoundBuds', 'Bluetooth наушники с активным шумоподавлением и 24 часами работы', 4999.90, 25),
+       ('Умные часы FitTrack Pro', 'Смарт-часы с мониторингом пульса, сна и GPS-трекером', 7499.00, 12),
+       ('Планшет TabMax', '10.1" планшет с разрешением 2K, 6 ГБ RAM и батареей 8000 mAh', 24999.00, 10);
+
+-- Представление для быстрого доступа к товарам в наличии
+CREATE OR REPLACE VIEW products_in_stock AS
+SELECT id, name, description, price, quantity
+FROM products
+WHERE quantity > 0
+ORDER BY name;
+
+-- ----------------------------------------------------------------------------------------------------------------------
+
+-- Варианты товара со своим артикулом, ценой и остатком
+CREATE TABLE IF NOT EXISTS product_variants
+(
+    id         BIGSERIAL PRIMARY KEY,
+    product_id BIGINT         NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    sku        VARCHAR(64)    NOT NULL UNIQUE,
+    attributes JSONB          NOT NULL DEFAULT '{}',
+    price      NUMERIC(10, 2) NOT NULL,
+    quantity   INT            NOT NULL DEFAULT 0,
+    version    BIGINT         NOT NULL DEFAULT 1,
+    created_at TIMESTAMP      NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id ON product_variants (product_id);
+
+CREATE TRIGGER product_variants_updated_at
+    BEFORE UPDATE
+    ON product_variants
+    FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS purchases
+(
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    product_id  BIGINT         NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    -- Покупка варианта товара; для существующей таблицы:
+    -- ALTER TABLE purchases ADD COLUMN variant_id BIGINT REFERENCES product_variants (id) ON DELETE SET NULL
+    variant_id  BIGINT REFERENCES product_variants (id) ON DELETE SET NULL,
+    quantity    INT            NOT NULL,
+    total_price NUMERIC(10, 2) NOT NULL,
+    status      VARCHAR(20)    NOT NULL,
+    version     BIGINT         NOT NULL DEFAULT 1,
+    created_at  TIMESTAMP      NOT NULL,
+    updated_at  TIMESTAMP      NOT NULL,
+    discounts   JSONB          NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchases_user_id ON purchases (user_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_product_id ON purchases (product_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_status ON purchases (status);
+-- Завершенные покупки за окно рейтинга популярных товаров
+CREATE INDEX IF NOT EXISTS idx_purchases_status_updated_at ON purchases (status, updated_at);
+-- Отчеты о продажах выбирают покупки по дате создания
+CREATE INDEX IF NOT EXISTS idx_purchases_created_at ON purchases (created_at);
+
+-- Дневные агрегаты покупок по товарам и пользователям для отчетов о продажах. День - дата создания
+-- покупки (UTC); приложение обновляет строки при создании покупки и смене статуса, пересчет - rollup-backfill
+CREATE TABLE IF NOT EXISTS sales_daily_products
+(
+    day        DATE           NOT NULL,
+    product_id BIGINT         NOT NULL,
+    orders     BIGINT         NOT NULL DEFAULT 0,
+    completed  BIGINT         NOT NULL DEFAULT 0,
+    cancelled  BIGINT         NOT NULL DEFAULT 0,
+    units      BIGINT         NOT NULL DEFAULT 0,
+    revenue    NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS sales_daily_users
+(
+    day       DATE           NOT NULL,
+    user_id   BIGINT         NOT NULL,
+    orders    BIGINT         NOT NULL DEFAULT 0,
+    completed BIGINT         NOT NULL DEFAULT 0,
+    cancelled BIGINT         NOT NULL DEFAULT 0,
+    units     BIGINT         NOT NULL DEFAULT 0,
+    revenue   NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, user_id)
+);
+
+-- ----------------------------------------------------------------------------------------------------------------------
+-- Дерево категорий: категорию с подкатегориями удалить нельзя
+CREATE TABLE IF NOT EXISTS categories
+(
+    id         BIGSERIAL PRIMARY KEY,
+    parent_id  BIGINT       REFERENCES categories (id) ON DELETE RESTRICT,
+    name       VARCHAR(100) NOT NULL,
+    version    BIGINT       NOT NULL DEFAULT 1,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+
+CREATE TRIGGER categories_updated_at
+    BEFORE UPDATE
+    ON categories
+    FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS product_categories
+(
+    product_id  BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    category_id BIGINT NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_categories_category_id ON product_categories (category_id);
+
+CREATE TABLE IF NOT EXISTS tags
+(
+    id   BIGSERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS product_tags
+(
+    product_id BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    tag_id     BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_tags_tag_id ON product_tags (tag_id);
+
+-- Промокоды: uses и coupon_uses.uses увеличиваются условным UPDATE, поэтому параллельные покупки
+-- не превышают max_uses и per_user_limit. Отмена покупки возвращает использование (AdjustCouponUses)
+CREATE TABLE IF NOT EXISTS coupons
+(
+    id              BIGSERIAL PRIMARY KEY,
+    code            VARCHAR(32)    NOT NULL UNIQUE,
+    type            VARCHAR(10)    NOT NULL,
+    value           NUMERIC(10, 2) NOT NULL,
+    max_uses        INT,
+    per_user_limit  INT,
+    min_order_value NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    expires_at      TIMESTAMP,
+    uses            INT            NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS coupon_uses
+(
+    coupon_id BIGINT NOT NULL REFERENCES coupons (id) ON DELETE CASCADE,
+    user_id   BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    uses      INT    NOT NULL DEFAULT 0,
+    PRIMARY KEY (coupon_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_uses_user_id ON coupon_uses (user_id);
+
+-- Автоматические скидки: от min_quantity единиц товара из категории (с подкатегориями), без категории - любого товара
+CREATE TABLE IF NOT EXISTS promotion_rules
+(
+    id           BIGSERIAL PRIMARY KEY,
+    name         VARCHAR(100)   NOT NULL,
+    category_id  BIGINT REFERENCES categories (id) ON DELETE CASCADE,
+    min_quantity INT            NOT NULL DEFAULT 1,
+    type         VARCHAR(10)    NOT NULL,
+    value        NUMERIC(10, 2) NOT NULL,
+    created_at   TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_rules_category_id ON promotion_rules (category_id);
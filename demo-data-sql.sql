@@ -4,6 +4,7 @@ CREATE TABLE IF NOT EXISTS users
     id         BIGINT PRIMARY KEY AUTO_INCREMENT,
     username   VARCHAR(50)  NOT NULL UNIQUE,
     email      VARCHAR(100) NOT NULL UNIQUE,
+    version    BIGINT       NOT NULL DEFAULT 1,
     created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
@@ -39,9 +40,51 @@ CREATE TABLE IF NOT EXISTS products
     description TEXT,
     price       DECIMAL(10, 2) NOT NULL,
     quantity    INT            NOT NULL DEFAULT 0,
+    version     BIGINT         NOT NULL DEFAULT 1,
     created_at  TIMESTAMP               DEFAULT CURRENT_TIMESTAMP,
     updated_at  TIMESTAMP               DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    INDEX idx_name (name)
+    INDEX idx_name (name),
+    -- Полнотекстовый поиск (GET /api/products/search); для существующей таблицы:
+    -- ALTER TABLE products ADD FULLTEXT INDEX ft_products_search (name, description)
+    FULLTEXT INDEX ft_products_search (name, description)
+);
+
+-- История цен: триггеры пишут цену нового товара и каждое изменение цены (GET /api/products/{id}/prices)
+CREATE TABLE IF NOT EXISTS product_prices
+(
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    product_id BIGINT         NOT NULL,
+    price      DECIMAL(10, 2) NOT NULL,
+    changed_at TIMESTAMP      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    INDEX (product_id, changed_at)
+);
+
+CREATE TRIGGER products_price_insert
+    AFTER INSERT
+    ON products
+    FOR EACH ROW
+    INSERT INTO product_prices (product_id, price) VALUES (NEW.id, NEW.price);
+
+CREATE TRIGGER products_price_update
+    AFTER UPDATE
+    ON products
+    FOR EACH ROW
+    INSERT INTO product_prices (product_id, price)
+    SELECT NEW.id, NEW.price FROM DUAL WHERE NEW.price <> OLD.price;
+
+-- Запланированные цены: фоновая задача приложения применяет их в effective_at и проставляет applied_at
+CREATE TABLE IF NOT EXISTS scheduled_prices
+(
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    product_id   BIGINT         NOT NULL,
+    price        DECIMAL(10, 2) NOT NULL,
+    effective_at TIMESTAMP      NOT NULL,
+    created_at   TIMESTAMP      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    applied_at   TIMESTAMP      NULL,
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    INDEX (product_id),
+    INDEX (applied_at, effective_at)
 );
 
 -- Добавляем демонстрационные данные
@@ -100,28 +143,154 @@ FROM products
 WHERE quantity > 0
 ORDER BY name;
 
--- Представление для быстрого доступа к популярным товарам (на основе цены, в будущем можно изменить на основе продаж)
-CREATE OR REPLACE VIEW popular_products AS
-SELECT id, name, description, price, quantity
-FROM products
-WHERE quantity > 0
-ORDER BY price DESC
-LIMIT 10;
-
 ########################################################################################################################
 
+-- Варианты товара со своим артикулом, ценой и остатком
+CREATE TABLE product_variants (
+                                  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                                  product_id BIGINT NOT NULL,
+                                  sku VARCHAR(64) NOT NULL UNIQUE,
+                                  attributes JSON NOT NULL,
+                                  price DECIMAL(10, 2) NOT NULL,
+                                  quantity INT NOT NULL DEFAULT 0,
+                                  version BIGINT NOT NULL DEFAULT 1,
+                                  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+                                  FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+                                  INDEX (product_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
 CREATE TABLE purchases (
                            id BIGINT AUTO_INCREMENT PRIMARY KEY,
                            user_id BIGINT NOT NULL,
                            product_id BIGINT NOT NULL,
+                           -- Покупка варианта товара; для существующей таблицы:
+                           -- ALTER TABLE purchases ADD COLUMN variant_id BIGINT NULL AFTER product_id,
+                           --     ADD FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE SET NULL
+                           variant_id BIGINT NULL,
                            quantity INT NOT NULL,
                            total_price DECIMAL(10, 2) NOT NULL,
                            status VARCHAR(20) NOT NULL,
+                           version BIGINT NOT NULL DEFAULT 1,
                            created_at TIMESTAMP NOT NULL,
                            updated_at TIMESTAMP NOT NULL,
+                           discounts JSON NOT NULL DEFAULT (JSON_ARRAY()),
                            FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
                            FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+                           FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE SET NULL,
                            INDEX (user_id),
                            INDEX (product_id),
-                           INDEX (status)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
\ No newline at end of file
+                           INDEX (status, updated_at),
+                           INDEX (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Популярные товары: продажи по завершенным покупкам за последние 30 дней.
+-- Приложение держит тот же рейтинг в Redis (GET /api/products/popular)
+CREATE OR REPLACE VIEW popular_products AS
+SELECT p.id, p.name, p.description, p.price, p.quantity,
+       SUM(pu.quantity) AS units, SUM(pu.total_price) AS revenue
+FROM products p
+         JOIN purchases pu ON pu.product_id = p.id
+WHERE pu.status = 'completed'
+  AND pu.updated_at >= UTC_DATE() - INTERVAL 30 DAY
+GROUP BY p.id, p.name, p.description, p.price, p.quantity
+ORDER BY units DESC, revenue DESC
+LIMIT 10;
+
+-- Дневные агрегаты покупок по товарам и пользователям для отчетов о продажах. День - дата создания
+-- покупки (UTC); приложение обновляет строки при создании покупки и смене статуса, пересчет - rollup-backfill
+CREATE TABLE sales_daily_products (
+                                      day DATE NOT NULL,
+                                      product_id BIGINT NOT NULL,
+                                      orders BIGINT NOT NULL DEFAULT 0,
+                                      completed BIGINT NOT NULL DEFAULT 0,
+                                      cancelled BIGINT NOT NULL DEFAULT 0,
+                                      units BIGINT NOT NULL DEFAULT 0,
+                                      revenue DECIMAL(14, 2) NOT NULL DEFAULT 0,
+                                      PRIMARY KEY (day, product_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE sales_daily_users (
+                                   day DATE NOT NULL,
+                                   user_id BIGINT NOT NULL,
+                                   orders BIGINT NOT NULL DEFAULT 0,
+                                   completed BIGINT NOT NULL DEFAULT 0,
+                                   cancelled BIGINT NOT NULL DEFAULT 0,
+                                   units BIGINT NOT NULL DEFAULT 0,
+                                   revenue DECIMAL(14, 2) NOT NULL DEFAULT 0,
+                                   PRIMARY KEY (day, user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+########################################################################################################################
+
+-- Дерево категорий: категорию с подкатегориями удалить нельзя
+CREATE TABLE categories (
+                            id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                            parent_id BIGINT NULL,
+                            name VARCHAR(100) NOT NULL,
+                            version BIGINT NOT NULL DEFAULT 1,
+                            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                            updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+                            FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE RESTRICT,
+                            INDEX idx_categories_parent_id (parent_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE product_categories (
+                                    product_id BIGINT NOT NULL,
+                                    category_id BIGINT NOT NULL,
+                                    PRIMARY KEY (product_id, category_id),
+                                    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+                                    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+                                    INDEX (category_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE tags (
+                      id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                      name VARCHAR(50) NOT NULL UNIQUE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE product_tags (
+                              product_id BIGINT NOT NULL,
+                              tag_id BIGINT NOT NULL,
+                              PRIMARY KEY (product_id, tag_id),
+                              FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+                              FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE,
+                              INDEX (tag_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Промокоды: uses и coupon_uses.uses увеличиваются условным UPDATE, поэтому параллельные покупки
+-- не превышают max_uses и per_user_limit. Отмена покупки возвращает использование (AdjustCouponUses)
+CREATE TABLE coupons (
+                         id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                         code VARCHAR(32) NOT NULL UNIQUE,
+                         type VARCHAR(10) NOT NULL,
+                         value DECIMAL(10, 2) NOT NULL,
+                         max_uses INT NULL,
+                         per_user_limit INT NULL,
+                         min_order_value DECIMAL(10, 2) NOT NULL DEFAULT 0,
+                         expires_at TIMESTAMP NULL,
+                         uses INT NOT NULL DEFAULT 0,
+                         created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE coupon_uses (
+                             coupon_id BIGINT NOT NULL,
+                             user_id BIGINT NOT NULL,
+                             uses INT NOT NULL DEFAULT 0,
+                             PRIMARY KEY (coupon_id, user_id),
+                             FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE CASCADE,
+                             FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+                             INDEX (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Автоматические скидки: от min_quantity единиц товара из категории (с подкатегориями), без категории - любого товара
+CREATE TABLE promotion_rules (
+                                 id BIGINT AUTO_INCREMENT PRIMARY KEY,
+                                 name VARCHAR(100) NOT NULL,
+                                 category_id BIGINT NULL,
+                                 min_quantity INT NOT NULL DEFAULT 1,
+                                 type VARCHAR(10) NOT NULL,
+                                 value DECIMAL(10, 2) NOT NULL,
+                                 created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                 FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+                                 INDEX (category_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
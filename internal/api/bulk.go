@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"net/http"
+)
+
+// serveBulk выполняет пакетную операцию и отвечает результатом по каждому элементу.
+// build проверяет i-й элемент запроса и превращает его в модель; невалидные элементы получают 400
+// и в хранилище не передаются, а в атомарном режиме отменяют весь пакет. run - метод сервиса,
+// result - идентификатор и версия записанной модели для ответа.
+func serveBulk[M any](w http.ResponseWriter, r *http.Request, mode string, n int, success int,
+	build func(i int) (M, error),
+	run func(ctx context.Context, items []M, atomic bool) ([]error, error),
+	result func(item M) (id, version int64),
+) {
+	atomic := mode == models.BulkAtomic
+	results := make([]models.BulkResult, n)
+	valid := make([]M, 0, n)
+	indexes := make([]int, 0, n)
+	for i := range results {
+		results[i].Index = i
+		item, err := build(i)
+		if err != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, item)
+		indexes = append(indexes, i)
+	}
+
+	errs := make([]error, len(valid))
+	switch {
+	case atomic && len(valid) < n:
+		for k := range errs {
+			errs[k] = models.ErrBulkAborted
+		}
+	case len(valid) > 0:
+		var err error
+		errs, err = run(r.Context(), valid, atomic)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	for k, i := range indexes {
+		if errs[k] != nil {
+			results[i].Status = bulkStatus(errs[k])
+			results[i].Error = errs[k].Error()
+			continue
+		}
+		results[i].Status = success
+		results[i].ID, results[i].Version = result(valid[k])
+	}
+
+	response := models.BulkResponse{Mode: mode, Results: results}
+	for _, res := range results {
+		if res.Error == "" {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	// Откаченный атомарный пакет - конфликт: ни один элемент не записан
+	status := http.StatusOK
+	if atomic && response.Failed > 0 {
+		status = http.StatusConflict
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// bulkStatus - статус элемента пакета с ошибкой: тот же, что получил бы одиночный запрос
+func bulkStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, models.ErrBulkAborted):
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
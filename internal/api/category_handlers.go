@@ -0,0 +1,281 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type CategoryHandlers struct {
+	categoryService *service.CategoryService
+}
+
+func NewCategoryHandlers(categoryService *service.CategoryService) *CategoryHandlers {
+	return &CategoryHandlers{
+		categoryService: categoryService,
+	}
+}
+
+// GetCategoryTree возвращает дерево категорий
+func (h *CategoryHandlers) GetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.categoryService.GetTree(r.Context())
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondComputed(w, r, tree)
+}
+
+func (h *CategoryHandlers) GetCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	category, err := h.categoryService.GetCategory(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if category == nil {
+		RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	if notModified(w, r, etag(category.Version)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *CategoryHandlers) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req models.CategoryRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	category := models.Category{Name: *req.Name, ParentID: req.ParentID}
+	if err := h.categoryService.CreateCategory(r.Context(), &category); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(category.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// currentVersion возвращает текущую версию категории для If-Match: * (0 - категории нет)
+func (h *CategoryHandlers) currentVersion(id int64) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		item, err := h.categoryService.GetCategory(ctx, id)
+		if err != nil || item == nil {
+			return 0, err
+		}
+		return item.Version, nil
+	}
+}
+
+// UpdateCategory переименовывает категорию и переносит ее к другому родителю (без parent_id - в корень)
+func (h *CategoryHandlers) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	version, ok := requireIfMatch(w, r, "Category not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	var req models.CategoryRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	category := models.Category{ID: id, Name: *req.Name, ParentID: req.ParentID, Version: version}
+	if err := h.categoryService.UpdateCategory(r.Context(), &category); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(category.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+// DeleteCategory удаляет категорию без подкатегорий; товары категории остаются
+func (h *CategoryHandlers) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	version, ok := requireIfMatch(w, r, "Category not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	if err := h.categoryService.DeleteCategory(r.Context(), id, version); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCategoryProducts возвращает товары категории и всех ее подкатегорий
+func (h *CategoryHandlers) GetCategoryProducts(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	products, err := h.categoryService.GetCategoryProducts(r.Context(), id)
+	if errors.Is(err, models.ErrCategoryNotFound) {
+		RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if notModified(w, r, collectionETag(products, func(p *models.Product) (int64, int64) { return p.ID, p.Version })) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(products)
+}
+
+// GetProductCategories возвращает категории товара
+func (h *CategoryHandlers) GetProductCategories(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	categories, err := h.categoryService.GetProductCategories(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if categories == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	if notModified(w, r, collectionETag(categories, func(c *models.Category) (int64, int64) { return c.ID, c.Version })) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// SetProductCategories заменяет категории товара и возвращает новый набор
+func (h *CategoryHandlers) SetProductCategories(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var req models.ProductCategoriesRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categories, err := h.categoryService.SetProductCategories(r.Context(), id, req.CategoryIDs)
+	if err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	if categories == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// GetTags возвращает используемые теги с числом товаров
+func (h *CategoryHandlers) GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.categoryService.GetTags(r.Context())
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondComputed(w, r, tags)
+}
+
+// GetProductTags возвращает теги товара
+func (h *CategoryHandlers) GetProductTags(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	tags, err := h.categoryService.GetProductTags(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if tags == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	respondComputed(w, r, tags)
+}
+
+// SetProductTags заменяет теги товара и возвращает новый набор
+func (h *CategoryHandlers) SetProductTags(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var req models.ProductTagsRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tags, err := h.categoryService.SetProductTags(r.Context(), id, req.Tags)
+	if err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	if tags == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
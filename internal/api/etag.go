@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag формирует сильный ETag ресурса из его версии
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// collectionETag формирует слабый ETag списка из идентификаторов и версий его элементов:
+// он меняется при изменении, добавлении или удалении любого элемента
+func collectionETag[T any](items []*T, key func(*T) (id, version int64)) string {
+	hash := fnv.New64a()
+	for _, item := range items {
+		id, version := key(item)
+		fmt.Fprintf(hash, "%d:%d;", id, version)
+	}
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// contentETag формирует слабый ETag по телу ответа - для ответов, которые не сводятся к версиям записей
+// (результаты поиска зависят еще и от фасетов и общего числа найденных)
+func contentETag(body []byte) string {
+	hash := fnv.New64a()
+	hash.Write(body)
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// notModified выставляет ETag ответа и, если он совпадает с If-None-Match, отвечает 304.
+// Возвращает true, если ответ уже отправлен.
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		// If-None-Match сравнивает ETag в слабом режиме
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// respondComputed отвечает JSON-телом, ETag которого вычисляется по содержимому (contentETag)
+func respondComputed(w http.ResponseWriter, r *http.Request, value interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(value); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if notModified(w, r, contentETag(body.Bytes())) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
+
+// requireIfMatch извлекает ожидаемую версию из обязательного заголовка If-Match.
+// Без заголовка отвечает 428, с нераспознанным ETag - 412. "*" означает "ресурс существует": вместо него
+// берется текущая версия из current, поэтому изменение остается условным и параллельная правка дает 412.
+// current возвращает 0, если ресурса нет, - тогда ответ 404 с текстом notFound.
+// Возвращает false, если ответ уже отправлен.
+func requireIfMatch(w http.ResponseWriter, r *http.Request, notFound string, current func(ctx context.Context) (int64, error)) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		RespondWithError(w, http.StatusPreconditionRequired, "Требуется заголовок If-Match с ETag ресурса")
+		return 0, false
+	}
+	if header == "*" {
+		version, err := current(r.Context())
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return 0, false
+		}
+		if version == 0 {
+			RespondWithError(w, http.StatusNotFound, notFound)
+			return 0, false
+		}
+		return version, true
+	}
+
+	// If-Match сравнивает ETag в строгом режиме, поэтому слабые ETag не подходят
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		RespondWithError(w, http.StatusPreconditionFailed, "If-Match не соответствует текущей версии ресурса")
+		return 0, false
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		RespondWithError(w, http.StatusPreconditionFailed, "If-Match не соответствует текущей версии ресурса")
+		return 0, false
+	}
+	return version, true
+}
+
+// respondWriteError отвечает на ошибку изменения ресурса: конфликт версий - 412, ссылка на несуществующую
+// категорию - 400, нарушение дерева категорий, занятый артикул и промокод, исчерпанный лимит промокода
+// при снятии отмены покупки - 409, остальное - 500
+func respondWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrVersionConflict):
+		RespondWithError(w, http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, models.ErrCategoryNotFound):
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, models.ErrCategoryNotEmpty), errors.Is(err, models.ErrCategoryCycle),
+		errors.Is(err, models.ErrDuplicateSKU), errors.Is(err, models.ErrDuplicateCoupon),
+		errors.Is(err, models.ErrCouponExhausted), errors.Is(err, models.ErrCouponUserLimit):
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/exchange"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// exportFlushEvery - через сколько записей выгрузка отправляется клиенту, не дожидаясь конца таблицы
+const exportFlushEvery = 1000
+
+// serveExport выгружает таблицу потоком в формате из параметра format (csv или jsonl).
+// Записи кодируются по мере чтения из курсора, поэтому память не зависит от размера таблицы.
+func serveExport[T any](w http.ResponseWriter, r *http.Request, name string,
+	export func(ctx context.Context, fn func(item *T) error) error,
+) {
+	format, err := exchange.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Заголовки ответа уходят вместе с первыми данными: до этого ошибку еще можно вернуть статусом
+	out := &exportWriter{w: w}
+	encoder, err := exchange.NewEncoder[T](out, format)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", exchange.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+
+	count := 0
+	err = export(r.Context(), func(item *T) error {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			if err := encoder.Flush(); err != nil {
+				return err
+			}
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = encoder.Flush()
+	}
+	if err != nil {
+		if !out.started {
+			w.Header().Del("Content-Disposition")
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		// Часть файла уже отправлена: остается оборвать ответ и записать ошибку в лог
+		slog.Error("Export failed", "export", name, "records", count, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportWriter запоминает, начата ли отправка тела ответа
+type exportWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.started = true
+	return e.w.Write(p)
+}
+
+func (h *UserHandlers) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	serveExport(w, r, "users", h.userService.ExportUsers)
+}
+
+func (h *ProductHandlers) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	serveExport(w, r, "products", h.productService.ExportProducts)
+}
+
+func (h *PurchaseHandlers) ExportPurchases(w http.ResponseWriter, r *http.Request) {
+	serveExport(w, r, "purchases", h.purchaseService.ExportPurchases)
+}
+
+// ImportProducts загружает товары из тела запроса в формате CSV или JSONL.
+// Параметры: format, key (name или id), mapping (поле=столбец, можно повторять), dry_run.
+func (h *ProductHandlers) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format, err := exchange.ParseFormat(query.Get("format"))
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "dry_run: ожидается true или false")
+			return
+		}
+	}
+
+	report, err := exchange.ImportProducts(r.Context(), h.productService, r.Body, exchange.ImportOptions{
+		Format:  format,
+		Key:     query.Get("key"),
+		Mapping: query["mapping"],
+		DryRun:  dryRun,
+	})
+	if err != nil {
+		// Без отчета файл не удалось даже начать обрабатывать - это ошибка запроса
+		status := http.StatusInternalServerError
+		if report == nil {
+			status = http.StatusBadRequest
+		}
+		RespondWithError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
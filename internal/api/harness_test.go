@@ -0,0 +1,329 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/SaveljevRoman/go-layout-project/internal/api"
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqlite"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	sqlitepkg "github.com/SaveljevRoman/go-layout-project/pkg/sqlite"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test ./internal/api -update перезаписывает эталонные ответы в testdata/golden
+var update = flag.Bool("update", false, "перезаписать golden-файлы")
+
+// backends - хранилища, на которых прогоняется каждый сценарий; ответы API должны совпадать
+var backends = []string{"memory", "sqlite"}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	// Журнал запросов из LoggingMiddleware только засоряет вывод тестов
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// harness - роутер из api.NewRouter поверх хранилища в процессе и кешей в памяти
+type harness struct {
+	t          *testing.T
+	handler    http.Handler
+	users      service.UserRepository
+	products   service.ProductRepository
+	purchases  service.PurchaseRepository
+	categories service.CategoryRepository
+	tags       service.TagRepository
+	variants   service.VariantRepository
+	rollups    service.RollupRepository
+	prices     service.PriceRepository
+	promotions service.PromotionRepository
+
+	// header - заголовки последнего ответа
+	header http.Header
+
+	userCache     *memory.UserCache
+	productCache  *memory.ProductCache
+	purchaseCache *memory.PurchaseCache
+	categoryCache *memory.CategoryCache
+
+	productService        *service.ProductService
+	purchaseService       *service.PurchaseService
+	recommendationService *service.RecommendationService
+	rollupService         *service.RollupService
+}
+
+func newHarness(t *testing.T, backend string) *harness {
+	t.Helper()
+
+	h := &harness{
+		t:             t,
+		userCache:     memory.NewUserCache(),
+		productCache:  memory.NewProductCache(),
+		purchaseCache: memory.NewPurchaseCache(),
+		categoryCache: memory.NewCategoryCache(),
+	}
+
+	var txManager service.TxManager
+	switch backend {
+	case "memory":
+		db := memory.NewDB()
+		h.users = memory.NewUserRepository(db)
+		h.products = memory.NewProductRepository(db)
+		h.purchases = memory.NewPurchaseRepository(db)
+		h.categories = memory.NewCategoryRepository(db)
+		h.tags = memory.NewTagRepository(db)
+		h.variants = memory.NewVariantRepository(db)
+		h.rollups = memory.NewRollupRepository(db)
+		h.prices = memory.NewPriceRepository(db)
+		h.promotions = memory.NewPromotionRepository(db)
+		txManager = memory.NewTxManager(db)
+	case "sqlite":
+		conn, err := sqlitepkg.NewConnection(config.SQLiteConfig{Path: ":memory:", MaxOpenConns: 1, BusyTimeout: 1000})
+		if err != nil {
+			t.Fatalf("sqlite: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		if err := sqlite.Migrate(context.Background(), conn); err != nil {
+			t.Fatalf("sqlite migrate: %v", err)
+		}
+		db := sqlite.NewDB(conn)
+		h.users = sqlite.NewUserRepository(db)
+		h.products = sqlite.NewProductRepository(db)
+		h.purchases = sqlite.NewPurchaseRepository(db)
+		h.categories = sqlite.NewCategoryRepository(db)
+		h.tags = sqlite.NewTagRepository(db)
+		h.variants = sqlite.NewVariantRepository(db)
+		h.rollups = sqlite.NewRollupRepository(db)
+		h.prices = sqlite.NewPriceRepository(db)
+		h.promotions = sqlite.NewPromotionRepository(db)
+		txManager = sqlite.NewTxManager(db, 3)
+	default:
+		t.Fatalf("неизвестное хранилище %s", backend)
+	}
+
+	userService := service.NewUserService(h.users, h.userCache, txManager)
+	productService := service.NewProductService(h.products, h.productCache, txManager)
+	variantService := service.NewVariantService(h.variants, productService, txManager)
+	rollupService := service.NewRollupService(h.purchases, h.rollups, txManager)
+	categoryService := service.NewCategoryService(h.categories, h.tags, h.categoryCache, productService, txManager)
+	promotionService := service.NewPromotionService(h.promotions, categoryService, txManager)
+	purchaseService := service.NewPurchaseService(h.purchases, h.purchaseCache, userService, productService, variantService, promotionService, rollupService, txManager)
+	recommendationService := service.NewRecommendationService(h.purchases, h.purchaseCache, userService, productService, variantService)
+	reportService := service.NewReportService(h.rollups, h.purchaseCache, userService, productService)
+	priceService := service.NewPriceService(h.prices, productService, txManager)
+	h.handler = api.NewRouter(userService, productService, purchaseService, categoryService, variantService, recommendationService, reportService, priceService, promotionService)
+	h.productService = productService
+	h.purchaseService = purchaseService
+	h.recommendationService = recommendationService
+	h.rollupService = rollupService
+
+	return h
+}
+
+// seed заполняет хранилище напрямую через репозитории: два пользователя, два товара, три покупки
+// (первая ожидает оплаты, две завершены), дерево из двух категорий ("Периферия" внутри "Электроники")
+// с товарами и тегами, два варианта мыши, запланированная на 2099 год цена ноутбука, промокод WELCOME10
+// и правило "от 3 единиц периферии - 5%"
+func (h *harness) seed() {
+	h.t.Helper()
+	ctx := context.Background()
+
+	for _, user := range []*models.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+	} {
+		if _, err := h.users.Create(ctx, user); err != nil {
+			h.t.Fatalf("seed user: %v", err)
+		}
+	}
+	for _, product := range []*models.Product{
+		{Name: "Ноутбук ProBook 15", Description: "15.6\" ноутбук", Price: 89999.5, Quantity: 8},
+		{Name: "Игровая мышь ProGamer", Description: "RGB подсветка", Price: 2999.5, Quantity: 30},
+	} {
+		if _, err := h.products.Create(ctx, product); err != nil {
+			h.t.Fatalf("seed product: %v", err)
+		}
+	}
+	for _, purchase := range []*models.Purchase{
+		{UserID: 1, ProductID: 2, Quantity: 1, TotalPrice: 2999.5, Status: "pending"},
+		{UserID: 2, ProductID: 2, Quantity: 3, TotalPrice: 8998.5, Status: "completed"},
+		{UserID: 2, ProductID: 1, Quantity: 1, TotalPrice: 89999.5, Status: "completed"},
+	} {
+		if _, err := h.purchases.Create(ctx, purchase); err != nil {
+			h.t.Fatalf("seed purchase: %v", err)
+		}
+	}
+	electronics, err := h.categories.Create(ctx, &models.Category{Name: "Электроника"})
+	if err != nil {
+		h.t.Fatalf("seed category: %v", err)
+	}
+	peripherals, err := h.categories.Create(ctx, &models.Category{Name: "Периферия", ParentID: &electronics})
+	if err != nil {
+		h.t.Fatalf("seed category: %v", err)
+	}
+	for productID, categoryID := range map[int64]int64{1: electronics, 2: peripherals} {
+		if err := h.categories.SetProductCategories(ctx, productID, []int64{categoryID}); err != nil {
+			h.t.Fatalf("seed product categories: %v", err)
+		}
+	}
+	for productID, tags := range map[int64][]string{1: {"office"}, 2: {"gaming", "rgb"}} {
+		if err := h.tags.SetProductTags(ctx, productID, tags); err != nil {
+			h.t.Fatalf("seed product tags: %v", err)
+		}
+	}
+	for _, variant := range []*models.ProductVariant{
+		{ProductID: 2, SKU: "MOUSE-BLK", Attributes: models.VariantAttributes{"color": "черный"}, Price: 2999.5, Quantity: 10},
+		{ProductID: 2, SKU: "MOUSE-WHT", Attributes: models.VariantAttributes{"color": "белый"}, Price: 3199, Quantity: 5},
+	} {
+		if _, err := h.variants.Create(ctx, variant); err != nil {
+			h.t.Fatalf("seed variant: %v", err)
+		}
+	}
+	if _, err := h.prices.CreateScheduled(ctx, &models.ScheduledPrice{ProductID: 1, Price: 59999, EffectiveAt: time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		h.t.Fatalf("seed scheduled price: %v", err)
+	}
+	maxUses, perUserLimit := 100, 1
+	if _, err := h.promotions.CreateCoupon(ctx, &models.Coupon{Code: "WELCOME10", Type: models.DiscountPercent, Value: 10, MaxUses: &maxUses, PerUserLimit: &perUserLimit, MinOrderValue: 1000}); err != nil {
+		h.t.Fatalf("seed coupon: %v", err)
+	}
+	if _, err := h.promotions.CreateRule(ctx, &models.PromotionRule{Name: "Периферия от 3 штук", CategoryID: &peripherals, MinQuantity: 3, Type: models.DiscountPercent, Value: 5}); err != nil {
+		h.t.Fatalf("seed promotion rule: %v", err)
+	}
+	// Индекс подсказок строится так же, как при старте приложения (StartSuggestRebuilder)
+	if err := h.productService.RebuildSuggestions(ctx); err != nil {
+		h.t.Fatalf("seed suggestions: %v", err)
+	}
+	// Рейтинг популярных товаров - так же, как при старте приложения
+	if err := h.purchaseService.RebuildSales(ctx); err != nil {
+		h.t.Fatalf("seed sales: %v", err)
+	}
+	// Покупки созданы в обход PurchaseService, поэтому дневные агрегаты считаются как командой rollup-backfill
+	if _, err := h.rollupService.Backfill(ctx, time.Time{}, time.Now()); err != nil {
+		h.t.Fatalf("seed rollups: %v", err)
+	}
+	// Совместные покупки: bob купил оба товара
+	if err := h.recommendationService.RebuildRecommendations(ctx); err != nil {
+		h.t.Fatalf("seed recommendations: %v", err)
+	}
+}
+
+// do выполняет запрос к роутеру и возвращает код ответа и тело
+func (h *harness) do(method, path, body string) (int, []byte) {
+	h.t.Helper()
+	return h.doWithHeaders(method, path, body, nil)
+}
+
+// doWithHeaders выполняет запрос с дополнительными заголовками
+func (h *harness) doWithHeaders(method, path, body string, headers map[string]string) (int, []byte) {
+	h.t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, req)
+	h.header = rec.Header()
+
+	return rec.Code, rec.Body.Bytes()
+}
+
+// doJSON выполняет запрос, проверяет код ответа и декодирует тело в out
+func (h *harness) doJSON(method, path, body string, wantStatus int, out interface{}) {
+	h.t.Helper()
+	h.doJSONWithHeaders(method, path, body, nil, wantStatus, out)
+}
+
+// doJSONWithHeaders - doJSON с дополнительными заголовками запроса
+func (h *harness) doJSONWithHeaders(method, path, body string, headers map[string]string, wantStatus int, out interface{}) {
+	h.t.Helper()
+
+	status, respBody := h.doWithHeaders(method, path, body, headers)
+	if status != wantStatus {
+		h.t.Fatalf("%s %s: код %d, ожидался %d, тело: %s", method, path, status, wantStatus, respBody)
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			h.t.Fatalf("%s %s: некорректный JSON %q: %v", method, path, respBody, err)
+		}
+	}
+}
+
+// assertGolden сравнивает тело ответа с testdata/golden/<name>.golden.
+// JSON нормализуется: отступы и метки времени, зависящие от момента запуска.
+func assertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+
+	got := normalize(body)
+	path := filepath.Join("testdata", "golden", name+".golden")
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("запись %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("чтение %s: %v (запустите с -update, чтобы создать)", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ответ отличается от %s\nполучено:\n%s\nожидалось:\n%s", path, got, want)
+	}
+}
+
+func normalize(body []byte) []byte {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return body
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(maskTimestamps(value)); err != nil {
+		return body
+	}
+	return out.Bytes()
+}
+
+func maskTimestamps(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if key == "created_at" || key == "updated_at" || key == "changed_at" {
+				v[key] = "<timestamp>"
+				continue
+			}
+			// Диапазон отчета по умолчанию заканчивается сегодняшним днем
+			if _, ok := field.(string); ok && (key == "from" || key == "to") {
+				v[key] = "<date>"
+				continue
+			}
+			v[key] = maskTimestamps(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = maskTimestamps(v[i])
+		}
+	}
+	return value
+}
@@ -2,7 +2,8 @@ package api
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
+	"mime"
 	"net/http"
 	"time"
 )
@@ -15,16 +16,33 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 
 		// Логирование запроса
-		log.Printf(
-			"%s %s %s %s",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			time.Since(start),
+		slog.Info(
+			"Request",
+			"method", r.Method,
+			"uri", r.RequestURI,
+			"remote_addr", r.RemoteAddr,
+			"duration", time.Since(start),
 		)
 	})
 }
 
+// mergePatchContentType - тип содержимого JSON Merge Patch (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+// isMergePatch проверяет Content-Type PATCH-запроса. Кроме merge-patch+json принимается
+// application/json, а также запрос без Content-Type - большинство клиентов шлют именно их.
+func isMergePatch(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == mergePatchContentType || mediaType == "application/json"
+}
+
 // ValidationError представляет ошибку валидации
 type ValidationError struct {
 	Status  int    `json:"status"`
@@ -0,0 +1,519 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// operation описывает один маршрут API для спецификации OpenAPI.
+// Path задается в нотации OpenAPI ({id}), без регулярных выражений mux.
+type operation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Request  interface{} // модель тела запроса, nil - тела нет
+	Response interface{} // модель тела успешного ответа, nil - тела нет
+	Status   int
+	Errors   []int
+	// JSONErrors - ошибки отдаются как ValidationError, а не текстом
+	JSONErrors bool
+	// Conflict - модель тела ответа 409, если это не ValidationError (откат атомарного пакета)
+	Conflict interface{}
+	// Query - параметры строки запроса
+	Query []queryParam
+	// Files - типы содержимого файла, который передается в теле запроса (POST) или ответа (GET) вместо JSON;
+	// GET с Response отвечает JSON или, по параметру format, файлом
+	Files []string
+	// Unversioned - изменение не требует If-Match: у ресурса нет собственной версии (связи товара, запланированные цены)
+	Unversioned bool
+}
+
+// queryParam описывает параметр строки запроса
+type queryParam struct {
+	Name        string
+	Description string
+	Type        string // тип схемы OpenAPI: string, boolean, number, integer
+	Enum        []string
+	Repeated    bool
+}
+
+// exchangeFiles - форматы выгрузки и загрузки, см. internal/exchange
+var exchangeFiles = []string{"text/csv", "application/x-ndjson"}
+
+// reportFiles - отчеты о продажах с format=csv
+var reportFiles = []string{"text/csv"}
+
+// categoryParam и tagParam ограничивают список товаров и поиск категорией и тегом
+var (
+	categoryParam = queryParam{Name: "category", Description: "Только товары категории и ее подкатегорий", Type: "integer"}
+	tagParam      = queryParam{Name: "tag", Description: "Только товары с тегом", Type: "string"}
+)
+
+var formatParam = queryParam{Name: "format", Description: "Формат файла", Type: "string", Enum: []string{"csv", "jsonl"}}
+
+var recommendationLimitParam = queryParam{Name: "limit", Description: "Сколько товаров вернуть, по умолчанию 10, не больше 50", Type: "integer"}
+
+// reportParams - диапазон дат и формат отчетов о продажах
+var reportParams = []queryParam{
+	{Name: "from", Description: "Первый день диапазона, ГГГГ-ММ-ДД (UTC); по умолчанию 29 дней до to", Type: "string"},
+	{Name: "to", Description: "Последний день диапазона включительно, по умолчанию сегодня; диапазон не больше 366 дней", Type: "string"},
+	{Name: "format", Description: "json (по умолчанию) или csv - строки отчета и строка итогов total", Type: "string", Enum: []string{"json", "csv"}},
+}
+
+// operations - все маршруты из NewRouter; TestOpenAPIMatchesRouter проверяет, что списки совпадают
+var operations = []operation{
+	{Method: "GET", Path: "/api/users", Tag: "users", Summary: "Список пользователей", Response: []models.User{}, Status: http.StatusOK, Errors: []int{500}},
+	{Method: "POST", Path: "/api/users", Tag: "users", Summary: "Создать пользователя", Request: models.UserCreateRequest{}, Response: models.User{}, Status: http.StatusCreated, Errors: []int{400, 500}, JSONErrors: true},
+	{Method: "POST", Path: "/api/users/bulk", Tag: "users", Summary: "Создать пользователей пакетом", Request: models.UserBulkCreateRequest{}, Response: models.BulkResponse{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Conflict: models.BulkResponse{}},
+	{Method: "PUT", Path: "/api/users/bulk", Tag: "users", Summary: "Заменить пользователей пакетом", Request: models.UserBulkUpdateRequest{}, Response: models.BulkResponse{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Conflict: models.BulkResponse{}},
+	{Method: "DELETE", Path: "/api/users/bulk", Tag: "users", Summary: "Удалить пользователей пакетом", Request: models.BulkDeleteRequest{}, Response: models.BulkResponse{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Conflict: models.BulkResponse{}},
+	{Method: "GET", Path: "/api/users/export", Tag: "users", Summary: "Выгрузить пользователей (CSV или JSONL, потоком)", Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Query: []queryParam{formatParam}, Files: exchangeFiles},
+	{Method: "GET", Path: "/api/users/{id}", Tag: "users", Summary: "Получить пользователя", Response: models.User{}, Status: http.StatusOK, Errors: []int{400, 404, 500}},
+	{Method: "PUT", Path: "/api/users/{id}", Tag: "users", Summary: "Заменить пользователя (все поля обязательны)", Request: models.UserUpdateRequest{}, Response: models.User{}, Status: http.StatusOK, Errors: []int{400, 500}},
+	{Method: "PATCH", Path: "/api/users/{id}", Tag: "users", Summary: "Частично обновить пользователя (JSON Merge Patch)", Request: models.UserPatch{}, Response: models.User{}, Status: http.StatusOK, Errors: []int{400, 404, 415, 500}, JSONErrors: true},
+	{Method: "DELETE", Path: "/api/users/{id}", Tag: "users", Summary: "Удалить пользователя", Status: http.StatusNoContent, Errors: []int{400, 500}},
+	{Method: "GET", Path: "/api/users/{user_id}/purchases", Tag: "purchases", Summary: "Покупки пользователя", Response: []models.Purchase{}, Status: http.StatusOK, Errors: []int{400, 500}},
+
+	{Method: "GET", Path: "/api/products", Tag: "products", Summary: "Список товаров", Response: []models.Product{}, Status: http.StatusOK, Errors: []int{400, 500}, Query: []queryParam{categoryParam, tagParam}},
+	{Method: "POST", Path: "/api/products", Tag: "products", Summary: "Создать товар", Request: models.Product{}, Response: models.Product{}, Status: http.StatusCreated, Errors: []int{400, 500}},
+	{Method: "POST", Path: "/api/products/bulk", Tag: "products", Summary: "Создать товары пакетом", Request: models.ProductBulkCreateRequest{}, Response: models.BulkResponse{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Conflict: models.BulkResponse{}},
+	{Method: "PUT", Path: "/api/products/bulk", Tag: "products", Summary: "Заменить товары пакетом", Request: models.ProductBulkUpdateRequest{}, Response: models.BulkResponse{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Conflict: models.BulkResponse{}},
+	{Method: "DELETE", Path: "/api/products/bulk", Tag: "products", Summary: "Удалить товары пакетом", Request: models.BulkDeleteRequest{}, Response: models.BulkResponse{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Conflict: models.BulkResponse{}},
+	{Method: "GET", Path: "/api/products/export", Tag: "products", Summary: "Выгрузить товары (CSV или JSONL, потоком)", Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Query: []queryParam{formatParam}, Files: exchangeFiles},
+	{Method: "POST", Path: "/api/products/import", Tag: "products", Summary: "Загрузить товары из CSV или JSONL с обновлением по ключу", Response: models.ImportReport{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Files: exchangeFiles, Query: []queryParam{
+		formatParam,
+		{Name: "key", Description: "Естественный ключ для поиска существующего товара", Type: "string", Enum: []string{"name", "id"}},
+		{Name: "mapping", Description: "Сопоставление поле=столбец, можно повторять или перечислять через запятую", Type: "string", Repeated: true},
+		{Name: "dry_run", Description: "Только проверить файл и посчитать изменения", Type: "boolean"},
+	}},
+	{Method: "GET", Path: "/api/products/search", Tag: "products", Summary: "Полнотекстовый поиск товаров с фильтрами и фасетами", Response: models.ProductSearchResult{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Query: []queryParam{
+		{Name: "q", Description: "Текст запроса: каждое слово ищется как начало слова в названии или описании", Type: "string"},
+		{Name: "min_price", Description: "Цена не ниже", Type: "number"},
+		{Name: "max_price", Description: "Цена не выше", Type: "number"},
+		{Name: "in_stock", Description: "Только товары в наличии", Type: "boolean"},
+		categoryParam,
+		tagParam,
+		{Name: "sort", Description: "Сортировка; relevance без q - по id", Type: "string", Enum: []string{models.SortRelevance, models.SortPriceAsc, models.SortPriceDesc, models.SortNewest}},
+		{Name: "limit", Description: "Размер страницы, по умолчанию 20, не больше 100", Type: "integer"},
+		{Name: "offset", Description: "Сколько результатов пропустить", Type: "integer"},
+	}},
+	{Method: "GET", Path: "/api/products/suggest", Tag: "products", Summary: "Подсказки для строки поиска по началу названия, по убыванию продаж", Response: []models.ProductSuggestion{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Query: []queryParam{
+		{Name: "q", Description: "Введенный текст: начало названия или одного из его слов", Type: "string"},
+		{Name: "limit", Description: "Сколько подсказок вернуть, по умолчанию 10, не больше 20", Type: "integer"},
+	}},
+	{Method: "GET", Path: "/api/products/popular", Tag: "products", Summary: "Рейтинг товаров по завершенным покупкам за 7 или 30 дней", Response: []models.PopularProduct{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Query: []queryParam{
+		{Name: "period", Description: "Окно рейтинга: 7d (по умолчанию) или 30d, включая сегодняшний день (UTC)", Type: "string", Enum: []string{"7d", "30d"}},
+		{Name: "by", Description: "Критерий: units - проданные единицы (по умолчанию) или revenue - выручка", Type: "string", Enum: []string{models.PopularByUnits, models.PopularByRevenue}},
+		{Name: "limit", Description: "Сколько товаров вернуть, по умолчанию 10, не больше 50", Type: "integer"},
+	}},
+	{Method: "GET", Path: "/api/products/{id}", Tag: "products", Summary: "Получить товар", Response: models.Product{}, Status: http.StatusOK, Errors: []int{400, 404, 500}},
+	{Method: "PUT", Path: "/api/products/{id}", Tag: "products", Summary: "Заменить товар (все поля обязательны)", Request: models.ProductUpdateRequest{}, Response: models.Product{}, Status: http.StatusOK, Errors: []int{400, 500}},
+	{Method: "PATCH", Path: "/api/products/{id}", Tag: "products", Summary: "Частично обновить товар (JSON Merge Patch)", Request: models.ProductPatch{}, Response: models.Product{}, Status: http.StatusOK, Errors: []int{400, 404, 415, 500}, JSONErrors: true},
+	{Method: "DELETE", Path: "/api/products/{id}", Tag: "products", Summary: "Удалить товар", Status: http.StatusNoContent, Errors: []int{400, 500}},
+	{Method: "GET", Path: "/api/products/{id}/categories", Tag: "categories", Summary: "Категории товара", Response: []models.Category{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "PUT", Path: "/api/products/{id}/categories", Tag: "categories", Summary: "Заменить категории товара", Request: models.ProductCategoriesRequest{}, Response: []models.Category{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true, Unversioned: true},
+	{Method: "GET", Path: "/api/products/{id}/tags", Tag: "categories", Summary: "Теги товара", Response: []string{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "PUT", Path: "/api/products/{id}/tags", Tag: "categories", Summary: "Заменить теги товара (новые теги создаются)", Request: models.ProductTagsRequest{}, Response: []string{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true, Unversioned: true},
+
+	{Method: "GET", Path: "/api/products/{id}/variants", Tag: "variants", Summary: "Варианты товара", Response: []models.ProductVariant{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "POST", Path: "/api/products/{id}/variants", Tag: "variants", Summary: "Добавить товару вариант", Request: models.VariantRequest{}, Response: models.ProductVariant{}, Status: http.StatusCreated, Errors: []int{400, 404, 409, 500}, JSONErrors: true},
+	{Method: "GET", Path: "/api/variants/{id}", Tag: "variants", Summary: "Получить вариант товара", Response: models.ProductVariant{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "PUT", Path: "/api/variants/{id}", Tag: "variants", Summary: "Заменить вариант товара", Request: models.VariantRequest{}, Response: models.ProductVariant{}, Status: http.StatusOK, Errors: []int{400, 404, 409, 500}, JSONErrors: true},
+	{Method: "DELETE", Path: "/api/variants/{id}", Tag: "variants", Summary: "Удалить вариант товара", Status: http.StatusNoContent, Errors: []int{400, 500}, JSONErrors: true},
+
+	{Method: "GET", Path: "/api/products/{id}/recommendations", Tag: "recommendations", Summary: "С этим товаром покупают: товары в наличии по числу покупателей, купивших оба", Response: []models.Recommendation{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true, Query: []queryParam{recommendationLimitParam}},
+	{Method: "GET", Path: "/api/users/{id}/recommendations", Tag: "recommendations", Summary: "Рекомендации пользователю по совместным покупкам, без уже купленных товаров", Response: []models.Recommendation{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true, Query: []queryParam{recommendationLimitParam}},
+
+	{Method: "GET", Path: "/api/products/{id}/prices", Tag: "prices", Summary: "История цены товара, от новых изменений к старым", Response: []models.PriceChange{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true, Query: []queryParam{
+		{Name: "at", Description: "Только изменения до этого момента (RFC 3339): первая запись - цена, действовавшая в at", Type: "string"},
+	}},
+	{Method: "GET", Path: "/api/products/{id}/scheduled-prices", Tag: "prices", Summary: "Запланированные цены товара, еще не вступившие в силу", Response: []models.ScheduledPrice{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "POST", Path: "/api/products/{id}/scheduled-prices", Tag: "prices", Summary: "Запланировать смену цены товара", Request: models.ScheduledPriceRequest{}, Response: models.ScheduledPrice{}, Status: http.StatusCreated, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "DELETE", Path: "/api/products/{id}/scheduled-prices/{schedule_id}", Tag: "prices", Summary: "Отменить запланированную цену", Status: http.StatusNoContent, Errors: []int{400, 404, 500}, JSONErrors: true, Unversioned: true},
+
+	{Method: "GET", Path: "/api/categories", Tag: "categories", Summary: "Дерево категорий", Response: []models.CategoryNode{}, Status: http.StatusOK, Errors: []int{500}, JSONErrors: true},
+	{Method: "POST", Path: "/api/categories", Tag: "categories", Summary: "Создать категорию", Request: models.CategoryRequest{}, Response: models.Category{}, Status: http.StatusCreated, Errors: []int{400, 500}, JSONErrors: true},
+	{Method: "GET", Path: "/api/categories/{id}", Tag: "categories", Summary: "Получить категорию", Response: models.Category{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "PUT", Path: "/api/categories/{id}", Tag: "categories", Summary: "Переименовать категорию или перенести ее к другому родителю", Request: models.CategoryRequest{}, Response: models.Category{}, Status: http.StatusOK, Errors: []int{400, 404, 409, 500}, JSONErrors: true},
+	{Method: "DELETE", Path: "/api/categories/{id}", Tag: "categories", Summary: "Удалить категорию без подкатегорий", Status: http.StatusNoContent, Errors: []int{400, 409, 500}, JSONErrors: true},
+	{Method: "GET", Path: "/api/categories/{id}/products", Tag: "categories", Summary: "Товары категории и ее подкатегорий", Response: []models.Product{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "GET", Path: "/api/tags", Tag: "categories", Summary: "Используемые теги с числом товаров", Response: []models.TagCount{}, Status: http.StatusOK, Errors: []int{500}, JSONErrors: true},
+
+	{Method: "GET", Path: "/api/purchases", Tag: "purchases", Summary: "Список покупок", Response: []models.Purchase{}, Status: http.StatusOK, Errors: []int{500}},
+	{Method: "POST", Path: "/api/purchases", Tag: "purchases", Summary: "Оформить покупку", Request: models.PurchaseRequest{}, Response: models.Purchase{}, Status: http.StatusCreated, Errors: []int{400, 404, 409, 422, 500}, JSONErrors: true},
+	{Method: "GET", Path: "/api/purchases/export", Tag: "purchases", Summary: "Выгрузить покупки (CSV или JSONL, потоком)", Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Query: []queryParam{formatParam}, Files: exchangeFiles},
+	{Method: "GET", Path: "/api/purchases/{id}", Tag: "purchases", Summary: "Получить покупку", Response: models.Purchase{}, Status: http.StatusOK, Errors: []int{400, 404, 500}},
+	{Method: "PUT", Path: "/api/purchases/{id}/status", Tag: "purchases", Summary: "Изменить статус покупки", Request: models.PurchaseStatusRequest{}, Response: models.Purchase{}, Status: http.StatusOK, Errors: []int{400, 404, 409, 500}, JSONErrors: true},
+
+	{Method: "GET", Path: "/api/coupons", Tag: "promotions", Summary: "Промокоды со счетчиками применений", Response: []models.Coupon{}, Status: http.StatusOK, Errors: []int{500}, JSONErrors: true},
+	{Method: "POST", Path: "/api/coupons", Tag: "promotions", Summary: "Создать промокод", Request: models.CouponRequest{}, Response: models.Coupon{}, Status: http.StatusCreated, Errors: []int{400, 409, 500}, JSONErrors: true},
+	{Method: "GET", Path: "/api/coupons/{id}", Tag: "promotions", Summary: "Получить промокод", Response: models.Coupon{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "DELETE", Path: "/api/coupons/{id}", Tag: "promotions", Summary: "Удалить промокод (скидки в покупках сохраняются)", Status: http.StatusNoContent, Errors: []int{400, 404, 500}, JSONErrors: true, Unversioned: true},
+	{Method: "GET", Path: "/api/promotions", Tag: "promotions", Summary: "Правила автоматических скидок", Response: []models.PromotionRule{}, Status: http.StatusOK, Errors: []int{500}, JSONErrors: true},
+	{Method: "POST", Path: "/api/promotions", Tag: "promotions", Summary: "Создать правило скидки", Request: models.PromotionRuleRequest{}, Response: models.PromotionRule{}, Status: http.StatusCreated, Errors: []int{400, 500}, JSONErrors: true},
+	{Method: "GET", Path: "/api/promotions/{id}", Tag: "promotions", Summary: "Получить правило скидки", Response: models.PromotionRule{}, Status: http.StatusOK, Errors: []int{400, 404, 500}, JSONErrors: true},
+	{Method: "DELETE", Path: "/api/promotions/{id}", Tag: "promotions", Summary: "Удалить правило скидки", Status: http.StatusNoContent, Errors: []int{400, 404, 500}, JSONErrors: true, Unversioned: true},
+
+	{Method: "GET", Path: "/api/reports/sales", Tag: "reports", Summary: "Выручка, единицы, средний чек и доля отмен по периодам, товарам или пользователям", Response: models.SalesReport{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Files: reportFiles, Query: append([]queryParam{
+		{Name: "by", Description: "Группировка: day (по умолчанию), week (с понедельника), month, product или user", Type: "string", Enum: models.ReportGroups},
+		{Name: "limit", Description: "Сколько строк товаров или пользователей вернуть (по убыванию выручки), не больше 1000", Type: "integer"},
+	}, reportParams...)},
+	{Method: "GET", Path: "/api/reports/top-customers", Tag: "reports", Summary: "Покупатели с наибольшей выручкой за диапазон", Response: models.SalesReport{}, Status: http.StatusOK, Errors: []int{400, 500}, JSONErrors: true, Files: reportFiles, Query: append([]queryParam{
+		{Name: "limit", Description: "Сколько покупателей вернуть, по умолчанию 10, не больше 1000", Type: "integer"},
+	}, reportParams...)},
+}
+
+// schemaHints дополняет схемы, выведенные из моделей, тем, чего нет в Go-типах
+var schemaHints = map[string]map[string]interface{}{
+	"Purchase.status":               {"enum": []string{"pending", "completed", "cancelled"}},
+	"PurchaseStatusRequest.status":  {"enum": []string{"pending", "completed", "cancelled"}},
+	"UserBulkCreateRequest.mode":    bulkModeHint,
+	"UserBulkUpdateRequest.mode":    bulkModeHint,
+	"ProductBulkCreateRequest.mode": bulkModeHint,
+	"ProductBulkUpdateRequest.mode": bulkModeHint,
+	"BulkDeleteRequest.mode":        bulkModeHint,
+	"CouponRequest.type":            discountTypeHint,
+	"PromotionRuleRequest.type":     discountTypeHint,
+	"PurchaseDiscount.kind":         {"enum": []string{models.DiscountKindRule, models.DiscountKindCoupon}},
+}
+
+var bulkModeHint = map[string]interface{}{
+	"enum":    []string{models.BulkAtomic, models.BulkBestEffort},
+	"default": models.BulkAtomic,
+}
+
+var discountTypeHint = map[string]interface{}{
+	"enum": []string{models.DiscountPercent, models.DiscountFixed},
+}
+
+// schemaRequired - обязательные поля моделей запросов
+var schemaRequired = map[string][]string{
+	"UserCreateRequest":    {"username"},
+	"UserUpdateRequest":    {"username", "email"},
+	"ProductUpdateRequest": {"name", "description", "price", "quantity"},
+	"ProductCreateRequest": {"name", "price"},
+	"CategoryRequest":      {"name"},
+	"VariantRequest":       {"sku", "price", "quantity"},
+
+	"ProductCategoriesRequest": {"category_ids"},
+	"ProductTagsRequest":       {"tags"},
+	"ScheduledPriceRequest":    {"price", "effective_at"},
+	"CouponRequest":            {"code", "type", "value"},
+	"PromotionRuleRequest":     {"name", "type", "value"},
+
+	"UserBulkCreateRequest":    {"items"},
+	"UserBulkUpdateRequest":    {"items"},
+	"UserBulkUpdateItem":       {"id", "version", "username", "email"},
+	"ProductBulkCreateRequest": {"items"},
+	"ProductBulkUpdateRequest": {"items"},
+	"ProductBulkUpdateItem":    {"id", "version", "name", "description", "price", "quantity"},
+	"BulkDeleteRequest":        {"items"},
+	"BulkDeleteItem":           {"id", "version"},
+}
+
+//go:embed docs.html
+var docsPage []byte
+
+var openAPIDocument = mustMarshal(NewOpenAPISpec())
+
+// OpenAPIHandler отдает спецификацию API в формате OpenAPI 3
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// DocsHandler отдает встроенную страницу документации, которая рендерит /openapi.json
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// NewOpenAPISpec строит документ OpenAPI по списку operations; схемы выводятся из моделей
+func NewOpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]map[string]interface{}{}
+
+	for _, op := range operations {
+		item, ok := paths[op.Path]
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = op.build(schemas)
+	}
+
+	schemaRef(reflect.TypeOf(ValidationError{}), schemas)
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Go Layout Project API",
+			"version": "1.0.0",
+		},
+		"tags": []map[string]string{
+			{"name": "users", "description": "Пользователи"},
+			{"name": "products", "description": "Товары"},
+			{"name": "purchases", "description": "Покупки"},
+			{"name": "categories", "description": "Категории и теги"},
+			{"name": "variants", "description": "Варианты товаров"},
+			{"name": "recommendations", "description": "Рекомендации по совместным покупкам"},
+			{"name": "prices", "description": "История и запланированные цены товаров"},
+			{"name": "promotions", "description": "Промокоды и автоматические скидки"},
+			{"name": "reports", "description": "Отчеты о продажах"},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func (op operation) build(schemas map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"operationId": operationID(op),
+	}
+
+	var parameters []map[string]interface{}
+	for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]string{"type": "integer", "format": "int64"},
+		})
+	}
+
+	for _, param := range op.Query {
+		schema := map[string]interface{}{"type": param.Type}
+		if param.Enum != nil {
+			schema["enum"] = param.Enum
+		}
+		if param.Repeated {
+			schema = map[string]interface{}{"type": "array", "items": schema}
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":        param.Name,
+			"in":          "query",
+			"description": param.Description,
+			"schema":      schema,
+		})
+	}
+
+	// Условные запросы: чтение поддерживает If-None-Match, изменение требует If-Match
+	responses := map[string]interface{}{}
+	switch op.Method {
+	case "GET":
+		// Выгрузки файлов не версионируются
+		if op.Files != nil && op.Response == nil {
+			break
+		}
+		parameters = append(parameters, headerParam("If-None-Match", false, "ETag из предыдущего ответа; при совпадении - 304"))
+		responses["304"] = map[string]interface{}{"description": http.StatusText(http.StatusNotModified)}
+	case "PUT", "PATCH", "DELETE":
+		// Пакетные операции передают версии в теле, а не в If-Match
+		if !pathParam.MatchString(op.Path) || op.Unversioned {
+			break
+		}
+		parameters = append(parameters, headerParam("If-Match", true, "ETag текущей версии ресурса или *"))
+		responses["412"] = errorResponse(http.StatusPreconditionFailed, true)
+		responses["428"] = errorResponse(http.StatusPreconditionRequired, true)
+		// If-Match: * требует, чтобы ресурс существовал
+		responses["404"] = errorResponse(http.StatusNotFound, true)
+	}
+	if parameters != nil {
+		result["parameters"] = parameters
+	}
+
+	if op.Files != nil && op.Method != "GET" {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  fileContent(op.Files),
+		}
+	}
+	if op.Request != nil {
+		content := jsonContent(schemaRef(reflect.TypeOf(op.Request), schemas))
+		if op.Method == "PATCH" {
+			content[mergePatchContentType] = content["application/json"]
+		}
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  content,
+		}
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(op.Status)}
+	if op.Response != nil {
+		success["content"] = jsonContent(schemaRef(reflect.TypeOf(op.Response), schemas))
+		success["headers"] = map[string]interface{}{
+			"ETag": map[string]interface{}{
+				"description": "Версия ресурса (для списков - слабый ETag содержимого)",
+				"schema":      map[string]string{"type": "string"},
+			},
+		}
+	}
+	if op.Files != nil && op.Method == "GET" {
+		content := fileContent(op.Files)
+		if op.Response != nil {
+			content["application/json"] = success["content"].(map[string]interface{})["application/json"]
+		}
+		success["content"] = content
+	}
+	responses[strconv.Itoa(op.Status)] = success
+
+	for _, status := range op.Errors {
+		responses[strconv.Itoa(status)] = errorResponse(status, op.JSONErrors)
+	}
+	if op.Conflict != nil {
+		responses["409"] = map[string]interface{}{
+			"description": http.StatusText(http.StatusConflict),
+			"content":     jsonContent(schemaRef(reflect.TypeOf(op.Conflict), schemas)),
+		}
+	}
+	// Ограничитель частоты запросов отвечает на любой маршрут
+	responses["429"] = errorResponse(http.StatusTooManyRequests, true)
+	result["responses"] = responses
+
+	return result
+}
+
+func operationID(op operation) string {
+	id := strings.ToLower(op.Method)
+	for _, part := range strings.Split(op.Path, "/") {
+		if part == "" || part == "api" {
+			continue
+		}
+		part = strings.Trim(part, "{}")
+		for _, word := range strings.Split(part, "_") {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+func headerParam(name string, required bool, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "header",
+		"required":    required,
+		"description": description,
+		"schema":      map[string]string{"type": "string"},
+	}
+}
+
+func errorResponse(status int, asJSON bool) map[string]interface{} {
+	response := map[string]interface{}{"description": http.StatusText(status)}
+	if asJSON {
+		response["content"] = jsonContent(map[string]interface{}{"$ref": "#/components/schemas/ValidationError"})
+	} else {
+		response["content"] = map[string]interface{}{
+			"text/plain": map[string]interface{}{"schema": map[string]string{"type": "string"}},
+		}
+	}
+	return response
+}
+
+func fileContent(types []string) map[string]interface{} {
+	content := map[string]interface{}{}
+	for _, contentType := range types {
+		content[contentType] = map[string]interface{}{"schema": map[string]string{"type": "string", "format": "binary"}}
+	}
+	return content
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef возвращает схему типа; структуры регистрируются в schemas и подставляются ссылкой
+func schemaRef(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			// Регистрируем заранее, чтобы рекурсивные типы не зацикливались
+			schemas[t.Name()] = nil
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas)}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			return map[string]interface{}{"type": "integer", "format": "int64"}
+		}
+		return map[string]interface{}{"type": "integer"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaRef(field.Type, schemas)
+		if hint, ok := schemaHints[t.Name()+"."+name]; ok {
+			for key, value := range hint {
+				property[key] = value
+			}
+		}
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if required, ok := schemaRequired[t.Name()]; ok {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mustMarshal(value interface{}) []byte {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,156 @@
+package api_test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type openAPISpec struct {
+	Paths map[string]map[string]struct {
+		Responses map[string]struct {
+			Content map[string]struct {
+				Schema map[string]interface{} `json:"schema"`
+			} `json:"content"`
+		} `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T, h *harness) openAPISpec {
+	t.Helper()
+
+	var spec openAPISpec
+	h.doJSON("GET", "/openapi.json", "", http.StatusOK, &spec)
+	return spec
+}
+
+// muxVariable приводит {id:[0-9]+} к нотации OpenAPI {id}
+var muxVariable = regexp.MustCompile(`\{([^}:]+):[^}]+\}`)
+
+// TestOpenAPIMatchesRouter падает, если маршрут добавлен в роутер, но не описан в спецификации, или наоборот
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	h := newHarness(t, "memory")
+	spec := loadSpec(t, h)
+
+	var routes []string
+	err := h.handler.(*mux.Router).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Префикс подроутера без собственного обработчика
+			return nil
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+muxVariable.ReplaceAllString(path, "{$1}"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var documented []string
+	for path, item := range spec.Paths {
+		for method := range item {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+	if strings.Join(routes, "\n") != strings.Join(documented, "\n") {
+		t.Errorf("спецификация разошлась с роутером\nв роутере:\n%s\nв спецификации:\n%s",
+			strings.Join(routes, "\n"), strings.Join(documented, "\n"))
+	}
+}
+
+// exampleQueries - параметры запроса для GET, которые без них возвращают пустой список
+var exampleQueries = map[string]string{
+	"/api/products/suggest": "?q=pro",
+}
+
+// exampleIDs - id для путей, где у записи 1 после seed пустой список
+var exampleIDs = map[string]string{
+	"/api/products/{id}/variants": "2",
+}
+
+// TestOpenAPISchemasMatchResponses сверяет поля схем с реальными ответами на GET-запросы
+func TestOpenAPISchemasMatchResponses(t *testing.T) {
+	h := newHarness(t, "memory")
+	h.seed()
+	spec := loadSpec(t, h)
+
+	for path, item := range spec.Paths {
+		op, ok := item["get"]
+		if !ok {
+			continue
+		}
+		content, ok := op.Responses["200"].Content["application/json"]
+		if !ok {
+			continue
+		}
+
+		schema := content.Schema
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			schema = items
+		}
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			continue // Список строк (теги) - сверять нечего
+		}
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		properties := spec.Components.Schemas[name].Properties
+		if properties == nil {
+			t.Errorf("%s: схема %q не найдена", path, ref)
+			continue
+		}
+
+		id := exampleIDs[path]
+		if id == "" {
+			id = "1"
+		}
+		url := strings.NewReplacer("{id}", id, "{user_id}", id).Replace(path) + exampleQueries[path]
+		_, body := h.do("GET", url, "")
+
+		var object map[string]json.RawMessage
+		var list []map[string]json.RawMessage
+		if err := json.Unmarshal(body, &list); err == nil && len(list) > 0 {
+			object = list[0]
+		} else if err := json.Unmarshal(body, &object); err != nil {
+			t.Errorf("GET %s: некорректный JSON %s", url, body)
+			continue
+		}
+
+		for field := range object {
+			if _, ok := properties[field]; !ok {
+				t.Errorf("GET %s: поле %q отсутствует в схеме %s", url, field, name)
+			}
+		}
+		for field := range properties {
+			if _, ok := object[field]; !ok {
+				t.Errorf("GET %s: поле %q из схемы %s отсутствует в ответе", url, field, name)
+			}
+		}
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	h := newHarness(t, "memory")
+
+	status, body := h.do("GET", "/docs", "")
+	if status != http.StatusOK || !strings.Contains(string(body), "/openapi.json") {
+		t.Errorf("страница документации: код %d", status)
+	}
+}
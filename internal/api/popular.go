@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PopularProducts возвращает рейтинг товаров по продажам: period (7d или 30d), by (units или revenue), limit
+func (h *PurchaseHandlers) PopularProducts(w http.ResponseWriter, r *http.Request) {
+	query, err := parsePopularQuery(r.URL.Query())
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	popular, err := h.purchaseService.PopularProducts(r.Context(), query)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondComputed(w, r, popular)
+}
+
+func parsePopularQuery(values url.Values) (*models.PopularQuery, error) {
+	query := &models.PopularQuery{Period: values.Get("period"), By: values.Get("by")}
+	if value := values.Get("limit"); value != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit == 0 {
+			return nil, fmt.Errorf("limit: ожидается число от 1 до %d", models.MaxPopularLimit)
+		}
+	}
+	return query, nil
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type PriceHandlers struct {
+	priceService *service.PriceService
+}
+
+func NewPriceHandlers(priceService *service.PriceService) *PriceHandlers {
+	return &PriceHandlers{
+		priceService: priceService,
+	}
+}
+
+// GetPriceHistory возвращает изменения цены товара от новых к старым. С параметром at
+// (RFC 3339) остаются изменения до этого момента, и первая запись - цена, действовавшая в at.
+func (h *PriceHandlers) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var at time.Time
+	if value := r.URL.Query().Get("at"); value != "" {
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "at: ожидается время в формате RFC 3339")
+			return
+		}
+	}
+
+	history, err := h.priceService.PriceHistory(r.Context(), id, at)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if history == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	respondComputed(w, r, history)
+}
+
+// GetScheduledPrices возвращает еще не примененные запланированные цены товара
+func (h *PriceHandlers) GetScheduledPrices(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	scheduled, err := h.priceService.ScheduledPrices(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if scheduled == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	respondComputed(w, r, scheduled)
+}
+
+// SchedulePrice планирует смену цены товара в effective_at
+func (h *PriceHandlers) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var req models.ScheduledPriceRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scheduled := req.ScheduledPrice(id)
+	if err := h.priceService.SchedulePrice(r.Context(), scheduled); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if scheduled.ID == 0 {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+// CancelScheduledPrice отменяет еще не примененную запланированную цену
+func (h *PriceHandlers) CancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	scheduleID, err := strconv.ParseInt(vars["schedule_id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid scheduled price ID")
+		return
+	}
+
+	cancelled, err := h.priceService.CancelScheduled(r.Context(), id, scheduleID)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !cancelled {
+		RespondWithError(w, http.StatusNotFound, "Scheduled price not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
 	"github.com/SaveljevRoman/go-layout-project/internal/service"
 	"github.com/gorilla/mux"
@@ -10,12 +12,14 @@ import (
 )
 
 type ProductHandlers struct {
-	productService *service.ProductService
+	productService  *service.ProductService
+	categoryService *service.CategoryService
 }
 
-func NewProductHandlers(productService *service.ProductService) *ProductHandlers {
+func NewProductHandlers(productService *service.ProductService, categoryService *service.CategoryService) *ProductHandlers {
 	return &ProductHandlers{
-		productService: productService,
+		productService:  productService,
+		categoryService: categoryService,
 	}
 }
 
@@ -39,18 +43,41 @@ func (h *ProductHandlers) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, etag(product.Version)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(product)
 }
 
+// GetAllProducts возвращает товары; category и tag ограничивают список категорией (с подкатегориями) и тегом
 func (h *ProductHandlers) GetAllProducts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	products, err := h.productService.GetAllProducts(ctx)
+	var filter models.ProductFilter
+	if value := r.URL.Query().Get("category"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "category: некорректный идентификатор категории")
+			return
+		}
+		if filter.CategoryIDs, err = h.categoryService.SubtreeIDs(ctx, id); err != nil {
+			respondWriteError(w, err)
+			return
+		}
+	}
+	filter.Tag = models.NormalizeTag(r.URL.Query().Get("tag"))
+
+	products, err := h.productService.GetProductsByFilter(ctx, filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if notModified(w, r, collectionETag(products, func(p *models.Product) (int64, int64) { return p.ID, p.Version })) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(products)
 }
@@ -70,11 +97,23 @@ func (h *ProductHandlers) CreateProduct(w http.ResponseWriter, r *http.Request)
 	}
 
 	product.ID = id
+	w.Header().Set("ETag", etag(product.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(product)
 }
 
+// currentVersion возвращает текущую версию товара для If-Match: * (0 - товара нет)
+func (h *ProductHandlers) currentVersion(id int64) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		item, err := h.productService.GetProduct(ctx, id)
+		if err != nil || item == nil {
+			return 0, err
+		}
+		return item.Version, nil
+	}
+}
+
 func (h *ProductHandlers) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
@@ -83,19 +122,82 @@ func (h *ProductHandlers) UpdateProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+	version, ok := requireIfMatch(w, r, "Product not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	// PUT заменяет ресурс целиком, поэтому все поля обязательны
+	var req models.ProductUpdateRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	product.ID = id
+	product := models.Product{
+		ID:          id,
+		Name:        *req.Name,
+		Description: *req.Description,
+		Price:       *req.Price,
+		Quantity:    *req.Quantity,
+		Version:     version,
+	}
+
 	ctx := r.Context()
 	if err := h.productService.UpdateProduct(ctx, &product); err != nil {
+		// Конфликт версий отвечает JSON, как остальные ответы на условные запросы; прочие ошибки PUT - текстом, как раньше
+		if errors.Is(err, models.ErrVersionConflict) {
+			respondWriteError(w, err)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("ETag", etag(product.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
+
+// PatchProduct частично обновляет товар по JSON Merge Patch: меняются только переданные поля
+func (h *ProductHandlers) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	version, ok := requireIfMatch(w, r, "Product not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	if !isMergePatch(r) {
+		RespondWithError(w, http.StatusUnsupportedMediaType, "Ожидается Content-Type "+mergePatchContentType)
+		return
+	}
+
+	var patch models.ProductPatch
+	if err := models.ParseMergePatch(r, &patch); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	patch.Version = version
+
+	ctx := r.Context()
+	product, err := h.productService.PatchProduct(ctx, id, &patch)
+	if err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	if product == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	w.Header().Set("ETag", etag(product.Version))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(product)
 }
@@ -108,11 +210,87 @@ func (h *ProductHandlers) DeleteProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	version, ok := requireIfMatch(w, r, "Product not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
 	ctx := r.Context()
-	if err := h.productService.DeleteProduct(ctx, id); err != nil {
+	if err := h.productService.DeleteProduct(ctx, id, version); err != nil {
+		// Конфликт версий отвечает JSON, как остальные ответы на условные запросы; прочие ошибки - текстом, как раньше
+		if errors.Is(err, models.ErrVersionConflict) {
+			respondWriteError(w, err)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// BulkCreateProducts создает товары пакетом (POST /api/products/bulk)
+func (h *ProductHandlers) BulkCreateProducts(w http.ResponseWriter, r *http.Request) {
+	var req models.ProductBulkCreateRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	serveBulk(w, r, req.Mode, len(req.Items), http.StatusCreated,
+		func(i int) (*models.Product, error) {
+			item := req.Items[i]
+			if err := item.Validate(); err != nil {
+				return nil, err
+			}
+			return &models.Product{Name: item.Name, Description: item.Description, Price: item.Price, Quantity: item.Quantity}, nil
+		},
+		h.productService.BulkCreateProducts,
+		func(p *models.Product) (int64, int64) { return p.ID, p.Version },
+	)
+}
+
+// BulkUpdateProducts заменяет товары пакетом (PUT /api/products/bulk); версия каждого товара обязательна
+func (h *ProductHandlers) BulkUpdateProducts(w http.ResponseWriter, r *http.Request) {
+	var req models.ProductBulkUpdateRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	serveBulk(w, r, req.Mode, len(req.Items), http.StatusOK,
+		func(i int) (*models.Product, error) {
+			item := req.Items[i]
+			if err := item.Validate(); err != nil {
+				return nil, err
+			}
+			return &models.Product{
+				ID:          item.ID,
+				Name:        *item.Name,
+				Description: *item.Description,
+				Price:       *item.Price,
+				Quantity:    *item.Quantity,
+				Version:     item.Version,
+			}, nil
+		},
+		h.productService.BulkUpdateProducts,
+		func(p *models.Product) (int64, int64) { return p.ID, p.Version },
+	)
+}
+
+// BulkDeleteProducts удаляет товары пакетом (DELETE /api/products/bulk)
+func (h *ProductHandlers) BulkDeleteProducts(w http.ResponseWriter, r *http.Request) {
+	var req models.BulkDeleteRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	serveBulk(w, r, req.Mode, len(req.Items), http.StatusNoContent,
+		func(i int) (models.BulkDeleteItem, error) {
+			return req.Items[i], req.Items[i].Validate()
+		},
+		h.productService.BulkDeleteProducts,
+		func(item models.BulkDeleteItem) (int64, int64) { return item.ID, 0 },
+	)
+}
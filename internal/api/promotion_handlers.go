@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type PromotionHandlers struct {
+	promotionService *service.PromotionService
+}
+
+func NewPromotionHandlers(promotionService *service.PromotionService) *PromotionHandlers {
+	return &PromotionHandlers{
+		promotionService: promotionService,
+	}
+}
+
+// GetCoupons возвращает все промокоды со счетчиками применений
+func (h *PromotionHandlers) GetCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.promotionService.GetCoupons(r.Context())
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondComputed(w, r, coupons)
+}
+
+func (h *PromotionHandlers) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid coupon ID")
+		return
+	}
+
+	coupon, err := h.promotionService.GetCoupon(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if coupon == nil {
+		RespondWithError(w, http.StatusNotFound, "Coupon not found")
+		return
+	}
+
+	respondComputed(w, r, coupon)
+}
+
+// CreateCoupon создает промокод; занятый код - 409
+func (h *PromotionHandlers) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	var req models.CouponRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	coupon := req.Coupon()
+	if err := h.promotionService.CreateCoupon(r.Context(), coupon); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(coupon)
+}
+
+// DeleteCoupon удаляет промокод; скидки в уже созданных покупках остаются
+func (h *PromotionHandlers) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid coupon ID")
+		return
+	}
+
+	deleted, err := h.promotionService.DeleteCoupon(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !deleted {
+		RespondWithError(w, http.StatusNotFound, "Coupon not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRules возвращает правила автоматических скидок
+func (h *PromotionHandlers) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.promotionService.GetRules(r.Context())
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondComputed(w, r, rules)
+}
+
+func (h *PromotionHandlers) GetRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid promotion ID")
+		return
+	}
+
+	rule, err := h.promotionService.GetRule(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if rule == nil {
+		RespondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	}
+
+	respondComputed(w, r, rule)
+}
+
+// CreateRule создает правило скидки; несуществующая категория - 400
+func (h *PromotionHandlers) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req models.PromotionRuleRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule := req.Rule()
+	if err := h.promotionService.CreateRule(r.Context(), rule); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *PromotionHandlers) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid promotion ID")
+		return
+	}
+
+	deleted, err := h.promotionService.DeleteRule(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !deleted {
+		RespondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
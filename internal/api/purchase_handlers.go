@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
 	"github.com/SaveljevRoman/go-layout-project/internal/service"
 	"github.com/gorilla/mux"
@@ -22,17 +24,18 @@ func NewPurchaseHandlers(purchaseService *service.PurchaseService) *PurchaseHand
 func (h *PurchaseHandlers) CreatePurchase(w http.ResponseWriter, r *http.Request) {
 	var request models.PurchaseRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	ctx := r.Context()
 	purchase, err := h.purchaseService.CreatePurchase(ctx, &request)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondPurchaseError(w, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag(purchase.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(purchase)
@@ -58,6 +61,10 @@ func (h *PurchaseHandlers) GetPurchase(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, etag(purchase.Version)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(purchase)
 }
@@ -77,38 +84,62 @@ func (h *PurchaseHandlers) GetUserPurchases(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if notModified(w, r, collectionETag(purchases, purchaseKey)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(purchases)
 }
 
+// currentVersion возвращает текущую версию покупки для If-Match: * (0 - покупки нет)
+func (h *PurchaseHandlers) currentVersion(id int64) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		item, err := h.purchaseService.GetPurchase(ctx, id)
+		if err != nil || item == nil {
+			return 0, err
+		}
+		return item.Version, nil
+	}
+}
+
 func (h *PurchaseHandlers) UpdatePurchaseStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Неверный ID покупки", http.StatusBadRequest)
+		RespondWithError(w, http.StatusBadRequest, "Неверный ID покупки")
 		return
 	}
 
-	var statusRequest struct {
-		Status string `json:"status"`
+	version, ok := requireIfMatch(w, r, "Покупка не найдена", h.currentVersion(id))
+	if !ok {
+		return
 	}
+
+	var statusRequest models.PurchaseStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&statusRequest); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	ctx := r.Context()
-	if err := h.purchaseService.UpdatePurchaseStatus(ctx, id, statusRequest.Status); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.purchaseService.UpdatePurchaseStatus(ctx, id, statusRequest.Status, version); err != nil {
+		respondWriteError(w, err)
 		return
 	}
 
 	purchase, err := h.purchaseService.GetPurchase(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if purchase == nil {
+		RespondWithError(w, http.StatusNotFound, "Покупка не найдена")
+		return
+	}
+
+	w.Header().Set("ETag", etag(purchase.Version))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(purchase)
 }
@@ -121,6 +152,33 @@ func (h *PurchaseHandlers) GetAllPurchases(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if notModified(w, r, collectionETag(purchases, purchaseKey)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(purchases)
 }
+
+func purchaseKey(p *models.Purchase) (int64, int64) {
+	return p.ID, p.Version
+}
+
+// respondPurchaseError отвечает на ошибку оформления покупки: вариант не указан или относится к другому
+// товару - 400, промокод не найден - 404, истек или сумма меньше минимальной - 422, остатка не хватает
+// или лимит промокода исчерпан - 409, остальное - 500
+func respondPurchaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrVariantRequired), errors.Is(err, models.ErrVariantProductMismatch):
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, models.ErrCouponNotFound):
+		RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, models.ErrCouponExpired), errors.Is(err, models.ErrCouponMinOrder):
+		RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, models.ErrInsufficientStock), errors.Is(err, models.ErrCouponExhausted),
+		errors.Is(err, models.ErrCouponUserLimit):
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter ограничивает частоту запросов с одного IP по алгоритму token bucket.
+// Лимиты можно менять на лету через SetLimit.
+type RateLimiter struct {
+	mu      sync.Mutex
+	enabled bool
+	rate    float64 // токенов в секунду
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// bucketIdleTimeout - через сколько неактивный клиент удаляется из памяти
+const bucketIdleTimeout = 10 * time.Minute
+
+func NewRateLimiter(enabled bool, requestsPerSecond float64, burst int) *RateLimiter {
+	l := &RateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	l.SetLimit(enabled, requestsPerSecond, burst)
+	return l
+}
+
+func (l *RateLimiter) SetLimit(enabled bool, requestsPerSecond float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabled = enabled
+	l.rate = requestsPerSecond
+	l.burst = float64(burst)
+}
+
+// Allow списывает токен для клиента и сообщает, разрешен ли запрос.
+// Если запрос отклонен, возвращается время до появления следующего токена.
+func (l *RateLimiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.enabled {
+		return true, 0
+	}
+
+	now := l.now()
+	b, ok := l.buckets[client]
+	if !ok {
+		l.cleanup(now)
+		b = &bucket{tokens: l.burst, lastSeen: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+func (l *RateLimiter) cleanup(now time.Time) {
+	for client, b := range l.buckets {
+		if now.Sub(b.lastSeen) > bucketIdleTimeout {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := l.Allow(clientIP(r))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			RespondWithError(w, http.StatusTooManyRequests, "Слишком много запросов")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
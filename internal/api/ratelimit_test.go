@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// step - запрос клиента через after после предыдущего и ожидаемое решение
+type step struct {
+	client  string
+	after   time.Duration
+	allowed bool
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		rate    float64
+		burst   int
+		steps   []step
+	}{
+		{"выключен", false, 1, 1, []step{{"a", 0, true}, {"a", 0, true}, {"a", 0, true}}},
+		{"запас исчерпан", true, 1, 2, []step{{"a", 0, true}, {"a", 0, true}, {"a", 0, false}}},
+		{"токены восстанавливаются", true, 2, 1, []step{
+			{"a", 0, true}, {"a", 0, false}, {"a", 400 * time.Millisecond, false}, {"a", 100 * time.Millisecond, true},
+		}},
+		{"запас не растет выше burst", true, 10, 2, []step{
+			{"a", 0, true}, {"a", time.Minute, true}, {"a", 0, true}, {"a", 0, false},
+		}},
+		{"клиенты независимы", true, 1, 1, []step{{"a", 0, true}, {"a", 0, false}, {"b", 0, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			l := NewRateLimiter(tt.enabled, tt.rate, tt.burst)
+			l.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				allowed, wait := l.Allow(s.client)
+				if allowed != s.allowed {
+					t.Fatalf("шаг %d: allowed = %v, ожидалось %v", i, allowed, s.allowed)
+				}
+				if !allowed && wait <= 0 {
+					t.Errorf("шаг %d: для отклоненного запроса не указано время ожидания", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterSetLimit(t *testing.T) {
+	l := NewRateLimiter(true, 1, 1)
+	l.now = func() time.Time { return time.Unix(0, 0) }
+
+	if allowed, _ := l.Allow("a"); !allowed {
+		t.Fatal("первый запрос отклонен")
+	}
+	if allowed, _ := l.Allow("a"); allowed {
+		t.Fatal("запрос сверх лимита разрешен")
+	}
+
+	// Выключение лимита на лету сразу пропускает запросы
+	l.SetLimit(false, 1, 1)
+	if allowed, _ := l.Allow("a"); !allowed {
+		t.Error("после выключения лимита запрос отклонен")
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(true, 1, 1)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	now = now.Add(bucketIdleTimeout + time.Second)
+	l.Allow("b")
+
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("неактивный клиент не удален")
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	l := NewRateLimiter(true, 0.5, 1)
+	l.now = func() time.Time { return time.Unix(0, 0) }
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(); rec.Code != http.StatusNoContent {
+		t.Fatalf("первый запрос: статус %d", rec.Code)
+	}
+	rec := serve()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("запрос сверх лимита: статус %d, ожидался 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, ожидалось 2", got)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type RecommendationHandlers struct {
+	recommendationService *service.RecommendationService
+}
+
+func NewRecommendationHandlers(recommendationService *service.RecommendationService) *RecommendationHandlers {
+	return &RecommendationHandlers{
+		recommendationService: recommendationService,
+	}
+}
+
+// GetProductRecommendations возвращает товары, которые покупают вместе с товаром
+func (h *RecommendationHandlers) GetProductRecommendations(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	limit, err := parseRecommendationLimit(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	recommendations, err := h.recommendationService.ProductRecommendations(r.Context(), id, limit)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if recommendations == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	respondComputed(w, r, recommendations)
+}
+
+// GetUserRecommendations возвращает товары, которые покупают вместе с купленными пользователем
+func (h *RecommendationHandlers) GetUserRecommendations(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	limit, err := parseRecommendationLimit(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	recommendations, err := h.recommendationService.UserRecommendations(r.Context(), id, limit)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if recommendations == nil {
+		RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	respondComputed(w, r, recommendations)
+}
+
+func parseRecommendationLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return models.DefaultRecommendationLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > models.MaxRecommendationLimit {
+		return 0, fmt.Errorf("limit: ожидается число от 1 до %d", models.MaxRecommendationLimit)
+	}
+	return limit, nil
+}
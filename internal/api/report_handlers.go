@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/exchange"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Форматы отчета: JSON с итогами или CSV со строкой итогов в конце
+const (
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+)
+
+type ReportHandlers struct {
+	reportService *service.ReportService
+}
+
+func NewReportHandlers(reportService *service.ReportService) *ReportHandlers {
+	return &ReportHandlers{
+		reportService: reportService,
+	}
+}
+
+// SalesReport возвращает отчет о продажах: from, to (YYYY-MM-DD), by, limit и format
+func (h *ReportHandlers) SalesReport(w http.ResponseWriter, r *http.Request) {
+	query, err := parseReportQuery(r.URL.Query())
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.serveReport(w, r, query, "sales-"+query.By)
+}
+
+// TopCustomers возвращает покупателей с наибольшей выручкой за диапазон; limit по умолчанию DefaultTopCustomers
+func (h *ReportHandlers) TopCustomers(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	if values.Get("by") != "" {
+		RespondWithError(w, http.StatusBadRequest, "by: не поддерживается, покупатели группируются по пользователю")
+		return
+	}
+	values.Set("by", models.ReportByUser)
+	if values.Get("limit") == "" {
+		values.Set("limit", strconv.Itoa(models.DefaultTopCustomers))
+	}
+
+	query, err := parseReportQuery(values)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.serveReport(w, r, query, "top-customers")
+}
+
+func (h *ReportHandlers) serveReport(w http.ResponseWriter, r *http.Request, query *models.ReportQuery, name string) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = reportFormatJSON
+	}
+	if format != reportFormatJSON && format != reportFormatCSV {
+		RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("format: ожидается %s или %s", reportFormatJSON, reportFormatCSV))
+		return
+	}
+
+	report, err := h.reportService.SalesReport(r.Context(), query)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if format == reportFormatJSON {
+		respondComputed(w, r, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", exchange.ContentType(exchange.FormatCSV))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s-%s.csv"`, name, report.From, report.To))
+	encoder, err := exchange.NewEncoder[models.ReportRow](w, exchange.FormatCSV)
+	if err == nil {
+		rows := append(report.Rows, models.ReportRow{Key: "total", ReportTotals: report.Totals})
+		for i := range rows {
+			if err = encoder.Encode(&rows[i]); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = encoder.Flush()
+	}
+	if err != nil {
+		// Отчет уже в памяти, поэтому ошибка здесь - только обрыв соединения
+		slog.Error("Failed to write sales report", "error", err)
+	}
+}
+
+func parseReportQuery(values url.Values) (*models.ReportQuery, error) {
+	query := &models.ReportQuery{By: values.Get("by")}
+
+	var err error
+	if query.From, err = parseReportDate(values, "from"); err != nil {
+		return nil, err
+	}
+	if query.To, err = parseReportDate(values, "to"); err != nil {
+		return nil, err
+	}
+	if value := values.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit == 0 {
+			return nil, fmt.Errorf("limit: ожидается число от 1 до %d", models.MaxReportLimit)
+		}
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// parseReportDate разбирает дату YYYY-MM-DD (UTC); пустое значение - нулевое время
+func parseReportDate(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(models.ReportDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: ожидается дата в формате ГГГГ-ММ-ДД", name)
+	}
+	return date, nil
+}
@@ -6,13 +6,21 @@ import (
 	"net/http"
 )
 
-func NewRouter(userService *service.UserService, productService *service.ProductService, purchaseService *service.PurchaseService) http.Handler {
+func NewRouter(userService *service.UserService, productService *service.ProductService, purchaseService *service.PurchaseService, categoryService *service.CategoryService,
+	variantService *service.VariantService, recommendationService *service.RecommendationService, reportService *service.ReportService, priceService *service.PriceService,
+	promotionService *service.PromotionService) http.Handler {
 	router := mux.NewRouter()
 
 	// Инициализация хендлеров
 	userHandlers := NewUserHandlers(userService)
-	productHandlers := NewProductHandlers(productService)
+	productHandlers := NewProductHandlers(productService, categoryService)
 	purchaseHandlers := NewPurchaseHandlers(purchaseService)
+	categoryHandlers := NewCategoryHandlers(categoryService)
+	variantHandlers := NewVariantHandlers(variantService)
+	recommendationHandlers := NewRecommendationHandlers(recommendationService)
+	reportHandlers := NewReportHandlers(reportService)
+	priceHandlers := NewPriceHandlers(priceService)
+	promotionHandlers := NewPromotionHandlers(promotionService)
 
 	// Определение маршрутов
 
@@ -20,26 +28,90 @@ func NewRouter(userService *service.UserService, productService *service.Product
 	userRouter := router.PathPrefix("/api/users").Subrouter()
 	userRouter.HandleFunc("", userHandlers.GetAllUsers).Methods("GET")
 	userRouter.HandleFunc("", userHandlers.CreateUser).Methods("POST")
+	userRouter.HandleFunc("/bulk", userHandlers.BulkCreateUsers).Methods("POST")
+	userRouter.HandleFunc("/bulk", userHandlers.BulkUpdateUsers).Methods("PUT")
+	userRouter.HandleFunc("/bulk", userHandlers.BulkDeleteUsers).Methods("DELETE")
+	userRouter.HandleFunc("/export", userHandlers.ExportUsers).Methods("GET")
 	userRouter.HandleFunc("/{id:[0-9]+}", userHandlers.GetUser).Methods("GET")
 	userRouter.HandleFunc("/{id:[0-9]+}", userHandlers.UpdateUser).Methods("PUT")
+	userRouter.HandleFunc("/{id:[0-9]+}", userHandlers.PatchUser).Methods("PATCH")
 	userRouter.HandleFunc("/{id:[0-9]+}", userHandlers.DeleteUser).Methods("DELETE")
 	userRouter.HandleFunc("/{user_id:[0-9]+}/purchases", purchaseHandlers.GetUserPurchases).Methods("GET")
+	userRouter.HandleFunc("/{id:[0-9]+}/recommendations", recommendationHandlers.GetUserRecommendations).Methods("GET")
 
 	// Группа маршрутов для продуктов
 	productRouter := router.PathPrefix("/api/products").Subrouter()
 	productRouter.HandleFunc("", productHandlers.GetAllProducts).Methods("GET")
 	productRouter.HandleFunc("", productHandlers.CreateProduct).Methods("POST")
+	productRouter.HandleFunc("/bulk", productHandlers.BulkCreateProducts).Methods("POST")
+	productRouter.HandleFunc("/bulk", productHandlers.BulkUpdateProducts).Methods("PUT")
+	productRouter.HandleFunc("/bulk", productHandlers.BulkDeleteProducts).Methods("DELETE")
+	productRouter.HandleFunc("/export", productHandlers.ExportProducts).Methods("GET")
+	productRouter.HandleFunc("/import", productHandlers.ImportProducts).Methods("POST")
+	productRouter.HandleFunc("/search", productHandlers.SearchProducts).Methods("GET")
+	productRouter.HandleFunc("/suggest", productHandlers.SuggestProducts).Methods("GET")
+	productRouter.HandleFunc("/popular", purchaseHandlers.PopularProducts).Methods("GET")
 	productRouter.HandleFunc("/{id:[0-9]+}", productHandlers.GetProduct).Methods("GET")
 	productRouter.HandleFunc("/{id:[0-9]+}", productHandlers.UpdateProduct).Methods("PUT")
+	productRouter.HandleFunc("/{id:[0-9]+}", productHandlers.PatchProduct).Methods("PATCH")
 	productRouter.HandleFunc("/{id:[0-9]+}", productHandlers.DeleteProduct).Methods("DELETE")
+	productRouter.HandleFunc("/{id:[0-9]+}/categories", categoryHandlers.GetProductCategories).Methods("GET")
+	productRouter.HandleFunc("/{id:[0-9]+}/categories", categoryHandlers.SetProductCategories).Methods("PUT")
+	productRouter.HandleFunc("/{id:[0-9]+}/tags", categoryHandlers.GetProductTags).Methods("GET")
+	productRouter.HandleFunc("/{id:[0-9]+}/tags", categoryHandlers.SetProductTags).Methods("PUT")
+	productRouter.HandleFunc("/{id:[0-9]+}/variants", variantHandlers.GetProductVariants).Methods("GET")
+	productRouter.HandleFunc("/{id:[0-9]+}/variants", variantHandlers.CreateVariant).Methods("POST")
+	productRouter.HandleFunc("/{id:[0-9]+}/recommendations", recommendationHandlers.GetProductRecommendations).Methods("GET")
+	productRouter.HandleFunc("/{id:[0-9]+}/prices", priceHandlers.GetPriceHistory).Methods("GET")
+	productRouter.HandleFunc("/{id:[0-9]+}/scheduled-prices", priceHandlers.GetScheduledPrices).Methods("GET")
+	productRouter.HandleFunc("/{id:[0-9]+}/scheduled-prices", priceHandlers.SchedulePrice).Methods("POST")
+	productRouter.HandleFunc("/{id:[0-9]+}/scheduled-prices/{schedule_id:[0-9]+}", priceHandlers.CancelScheduledPrice).Methods("DELETE")
+
+	// Группа маршрутов для вариантов товаров
+	variantRouter := router.PathPrefix("/api/variants").Subrouter()
+	variantRouter.HandleFunc("/{id:[0-9]+}", variantHandlers.GetVariant).Methods("GET")
+	variantRouter.HandleFunc("/{id:[0-9]+}", variantHandlers.UpdateVariant).Methods("PUT")
+	variantRouter.HandleFunc("/{id:[0-9]+}", variantHandlers.DeleteVariant).Methods("DELETE")
+
+	// Группа маршрутов для категорий и тегов
+	categoryRouter := router.PathPrefix("/api/categories").Subrouter()
+	categoryRouter.HandleFunc("", categoryHandlers.GetCategoryTree).Methods("GET")
+	categoryRouter.HandleFunc("", categoryHandlers.CreateCategory).Methods("POST")
+	categoryRouter.HandleFunc("/{id:[0-9]+}", categoryHandlers.GetCategory).Methods("GET")
+	categoryRouter.HandleFunc("/{id:[0-9]+}", categoryHandlers.UpdateCategory).Methods("PUT")
+	categoryRouter.HandleFunc("/{id:[0-9]+}", categoryHandlers.DeleteCategory).Methods("DELETE")
+	categoryRouter.HandleFunc("/{id:[0-9]+}/products", categoryHandlers.GetCategoryProducts).Methods("GET")
+	router.HandleFunc("/api/tags", categoryHandlers.GetTags).Methods("GET")
 
 	// Группа маршрутов для покупок
 	purchaseRouter := router.PathPrefix("/api/purchases").Subrouter()
 	purchaseRouter.HandleFunc("", purchaseHandlers.GetAllPurchases).Methods("GET")
 	purchaseRouter.HandleFunc("", purchaseHandlers.CreatePurchase).Methods("POST")
+	purchaseRouter.HandleFunc("/export", purchaseHandlers.ExportPurchases).Methods("GET")
 	purchaseRouter.HandleFunc("/{id:[0-9]+}", purchaseHandlers.GetPurchase).Methods("GET")
 	purchaseRouter.HandleFunc("/{id:[0-9]+}/status", purchaseHandlers.UpdatePurchaseStatus).Methods("PUT")
 
+	// Промокоды и правила автоматических скидок
+	couponRouter := router.PathPrefix("/api/coupons").Subrouter()
+	couponRouter.HandleFunc("", promotionHandlers.GetCoupons).Methods("GET")
+	couponRouter.HandleFunc("", promotionHandlers.CreateCoupon).Methods("POST")
+	couponRouter.HandleFunc("/{id:[0-9]+}", promotionHandlers.GetCoupon).Methods("GET")
+	couponRouter.HandleFunc("/{id:[0-9]+}", promotionHandlers.DeleteCoupon).Methods("DELETE")
+	promotionRouter := router.PathPrefix("/api/promotions").Subrouter()
+	promotionRouter.HandleFunc("", promotionHandlers.GetRules).Methods("GET")
+	promotionRouter.HandleFunc("", promotionHandlers.CreateRule).Methods("POST")
+	promotionRouter.HandleFunc("/{id:[0-9]+}", promotionHandlers.GetRule).Methods("GET")
+	promotionRouter.HandleFunc("/{id:[0-9]+}", promotionHandlers.DeleteRule).Methods("DELETE")
+
+	// Отчеты о продажах
+	reportRouter := router.PathPrefix("/api/reports").Subrouter()
+	reportRouter.HandleFunc("/sales", reportHandlers.SalesReport).Methods("GET")
+	reportRouter.HandleFunc("/top-customers", reportHandlers.TopCustomers).Methods("GET")
+
+	// Спецификация API и документация
+	router.HandleFunc("/openapi.json", OpenAPIHandler).Methods("GET")
+	router.HandleFunc("/docs", DocsHandler).Methods("GET")
+
 	// Промежуточное ПО
 	router.Use(LoggingMiddleware)
 
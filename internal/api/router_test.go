@@ -0,0 +1,455 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Заголовки для изменения ресурса версии 1 (после seed у всех записей версия 1)
+var (
+	ifMatch    = map[string]string{"If-Match": `"1"`}
+	mergePatch = map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"1"`}
+)
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		// headers - дополнительные заголовки запроса
+		headers map[string]string
+	}{
+		// Пользователи
+		{"users_list", "GET", "/api/users", "", http.StatusOK, nil},
+		{"users_get", "GET", "/api/users/1", "", http.StatusOK, nil},
+		{"users_get_not_found", "GET", "/api/users/99", "", http.StatusNotFound, nil},
+		{"users_create", "POST", "/api/users", `{"username":"carol","email":"carol@example.com"}`, http.StatusCreated, nil},
+		{"users_create_invalid_json", "POST", "/api/users", `{"username":`, http.StatusBadRequest, nil},
+		{"users_update", "PUT", "/api/users/2", `{"username":"bobby","email":"bobby@example.com"}`, http.StatusOK, ifMatch},
+		{"users_update_invalid_id", "PUT", "/api/users/99999999999999999999", `{"username":"bobby","email":"bobby@example.com"}`, http.StatusBadRequest, ifMatch},
+		{"users_update_partial", "PUT", "/api/users/2", `{"username":"bobby"}`, http.StatusBadRequest, ifMatch},
+		{"users_patch", "PATCH", "/api/users/2", `{"email":"bobby@example.com"}`, http.StatusOK, mergePatch},
+		{"users_patch_null", "PATCH", "/api/users/2", `{"email":null}`, http.StatusBadRequest, mergePatch},
+		{"users_patch_unknown_field", "PATCH", "/api/users/2", `{"nickname":"bob"}`, http.StatusBadRequest, mergePatch},
+		{"users_patch_not_found", "PATCH", "/api/users/99", `{"email":"x@example.com"}`, http.StatusNotFound, map[string]string{"If-Match": "*"}},
+		{"users_update_any_version", "PUT", "/api/users/2", `{"username":"bobby","email":"bobby@example.com"}`, http.StatusOK, map[string]string{"If-Match": "*"}},
+		{"users_patch_missing_with_version", "PATCH", "/api/users/99", `{"email":"x@example.com"}`, http.StatusPreconditionFailed, mergePatch},
+		{"users_patch_unsupported_media_type", "PATCH", "/api/users/2", `email=x`, http.StatusUnsupportedMediaType, map[string]string{"Content-Type": "application/x-www-form-urlencoded", "If-Match": `"1"`}},
+		{"users_update_without_if_match", "PUT", "/api/users/2", `{"username":"bobby","email":"bobby@example.com"}`, http.StatusPreconditionRequired, nil},
+		{"users_update_stale_version", "PUT", "/api/users/2", `{"username":"bobby","email":"bobby@example.com"}`, http.StatusPreconditionFailed, map[string]string{"If-Match": `"7"`}},
+		{"users_patch_weak_etag", "PATCH", "/api/users/2", `{"email":"bobby@example.com"}`, http.StatusPreconditionFailed, map[string]string{"If-Match": `W/"1"`}},
+		{"users_get_not_modified", "GET", "/api/users/1", "", http.StatusNotModified, map[string]string{"If-None-Match": `"1"`}},
+		{"users_get_modified", "GET", "/api/users/1", "", http.StatusOK, map[string]string{"If-None-Match": `"0"`}},
+		{"users_delete", "DELETE", "/api/users/2", "", http.StatusNoContent, ifMatch},
+		{"users_purchases", "GET", "/api/users/1/purchases", "", http.StatusOK, nil},
+		{"users_bulk_create", "POST", "/api/users/bulk", `{"items":[{"username":"carol","email":"carol@example.com"},{"username":"dave","email":"dave@example.com"}]}`, http.StatusOK, nil},
+		{"users_bulk_update", "PUT", "/api/users/bulk", `{"mode":"best_effort","items":[{"id":1,"version":1,"username":"alice","email":"alice@example.org"},{"id":2,"version":1,"username":"bob"}]}`, http.StatusOK, nil},
+		{"users_bulk_delete", "DELETE", "/api/users/bulk", `{"mode":"best_effort","items":[{"id":2,"version":1},{"id":1}]}`, http.StatusOK, nil},
+
+		// Пакетные операции с товарами
+		{"products_bulk_create", "POST", "/api/products/bulk", `{"mode":"best_effort","items":[{"name":"Клавиатура","price":4500,"quantity":12},{"name":" ","price":1},{"name":"Коврик","price":-1},{"name":"Монитор","description":"27 дюймов","price":25000,"quantity":5}]}`, http.StatusOK, nil},
+		{"products_bulk_create_atomic_invalid", "POST", "/api/products/bulk", `{"mode":"atomic","items":[{"name":"Клавиатура","price":4500},{"name":"","price":1}]}`, http.StatusConflict, nil},
+		{"products_bulk_create_empty", "POST", "/api/products/bulk", `{"items":[]}`, http.StatusBadRequest, nil},
+		{"products_bulk_create_unknown_mode", "POST", "/api/products/bulk", `{"mode":"partial","items":[{"name":"Клавиатура","price":4500}]}`, http.StatusBadRequest, nil},
+		{"products_bulk_update", "PUT", "/api/products/bulk", `{"mode":"best_effort","items":[{"id":1,"version":1,"name":"Ноутбук","description":"","price":79999,"quantity":8},{"id":2,"version":5,"name":"Мышь","description":"","price":990,"quantity":30}]}`, http.StatusOK, nil},
+		{"products_bulk_update_atomic_conflict", "PUT", "/api/products/bulk", `{"items":[{"id":1,"version":1,"name":"Ноутбук","description":"","price":79999,"quantity":8},{"id":2,"version":5,"name":"Мышь","description":"","price":990,"quantity":30}]}`, http.StatusConflict, nil},
+		{"products_bulk_delete", "DELETE", "/api/products/bulk", `{"mode":"best_effort","items":[{"id":2,"version":1},{"id":99,"version":1}]}`, http.StatusOK, nil},
+
+		// Выгрузка и загрузка
+		{"products_import_dry_run", "POST", "/api/products/import?dry_run=true", "name,price,quantity\nКлавиатура,4500,12\nИгровая мышь ProGamer,\"2 499,5\",30\nНоутбук ProBook 15,89999.5,8\n,1,1\nКоврик,-5,1\n", http.StatusOK, nil},
+		{"products_import_mapping", "POST", "/api/products/import?mapping=name=Название,price=Цена", "\ufeffНазвание;Цена\nКлавиатура;4500\nКлавиатура;4600\n", http.StatusOK, nil},
+		{"products_import_by_id", "POST", "/api/products/import?format=jsonl&key=id", "{\"id\":1,\"quantity\":7}\n{\"id\":99,\"quantity\":1}\n{\"name\":\"Монитор\",\"price\":25000}\n", http.StatusOK, nil},
+		{"products_import_unknown_format", "POST", "/api/products/import?format=xlsx", "name\n", http.StatusBadRequest, nil},
+		{"products_import_invalid_dry_run", "POST", "/api/products/import?dry_run=maybe", "name\n", http.StatusBadRequest, nil},
+		{"products_import_missing_column", "POST", "/api/products/import?mapping=price=Цена", "name,price\nКлавиатура,4500\n", http.StatusBadRequest, nil},
+		{"products_export_unknown_format", "GET", "/api/products/export?format=xml", "", http.StatusBadRequest, nil},
+
+		// Поиск
+		{"products_search", "GET", "/api/products/search?q=ноутбуки", "", http.StatusOK, nil},
+		{"products_search_filters", "GET", "/api/products/search?q=pro&max_price=5000&in_stock=true&sort=price_asc", "", http.StatusOK, nil},
+		{"products_search_all_price_desc", "GET", "/api/products/search?sort=price_desc&limit=1&offset=1", "", http.StatusOK, nil},
+		{"products_search_no_match", "GET", "/api/products/search?q=планшет", "", http.StatusOK, nil},
+		{"products_search_unknown_sort", "GET", "/api/products/search?q=ноутбук&sort=rating", "", http.StatusBadRequest, nil},
+		{"products_suggest", "GET", "/api/products/suggest?q=Pro", "", http.StatusOK, nil},
+		{"products_suggest_phrase", "GET", "/api/products/suggest?q=игровая%20мы", "", http.StatusOK, nil},
+		{"products_suggest_limit", "GET", "/api/products/suggest?q=pro&limit=1", "", http.StatusOK, nil},
+		{"products_suggest_empty", "GET", "/api/products/suggest?q=%20-", "", http.StatusOK, nil},
+		{"products_suggest_invalid_limit", "GET", "/api/products/suggest?q=pro&limit=50", "", http.StatusBadRequest, nil},
+		{"products_popular", "GET", "/api/products/popular", "", http.StatusOK, nil},
+		{"products_popular_revenue", "GET", "/api/products/popular?period=30d&by=revenue&limit=1", "", http.StatusOK, nil},
+		{"products_popular_invalid_period", "GET", "/api/products/popular?period=1y", "", http.StatusBadRequest, nil},
+		{"products_search_invalid_price_range", "GET", "/api/products/search?min_price=5000&max_price=1000", "", http.StatusBadRequest, nil},
+
+		// Товары
+		{"products_list", "GET", "/api/products", "", http.StatusOK, nil},
+		{"products_get", "GET", "/api/products/1", "", http.StatusOK, nil},
+		{"products_get_not_found", "GET", "/api/products/99", "", http.StatusNotFound, nil},
+		{"products_create", "POST", "/api/products", `{"name":"Клавиатура","description":"Механическая","price":4500,"quantity":12}`, http.StatusCreated, nil},
+		{"products_create_invalid_json", "POST", "/api/products", `[]`, http.StatusBadRequest, nil},
+		{"products_update", "PUT", "/api/products/1", `{"name":"Ноутбук ProBook 16","description":"16\" ноутбук","price":99999,"quantity":4}`, http.StatusOK, ifMatch},
+		{"products_update_partial", "PUT", "/api/products/1", `{"price":1}`, http.StatusBadRequest, ifMatch},
+		{"products_patch_price", "PATCH", "/api/products/1", `{"price":79999}`, http.StatusOK, mergePatch},
+		{"products_patch_empty", "PATCH", "/api/products/1", `{}`, http.StatusOK, ifMatch},
+		{"products_patch_negative_quantity", "PATCH", "/api/products/1", `{"quantity":-1}`, http.StatusBadRequest, mergePatch},
+		{"products_patch_not_object", "PATCH", "/api/products/1", `[{"price":1}]`, http.StatusBadRequest, mergePatch},
+		{"products_delete", "DELETE", "/api/products/1", "", http.StatusNoContent, ifMatch},
+		{"products_delete_stale_version", "DELETE", "/api/products/1", "", http.StatusPreconditionFailed, map[string]string{"If-Match": `"2"`}},
+		{"products_delete_any_not_found", "DELETE", "/api/products/99", "", http.StatusNotFound, map[string]string{"If-Match": "*"}},
+		{"products_delete_without_if_match", "DELETE", "/api/products/1", "", http.StatusPreconditionRequired, nil},
+
+		// Категории и теги (после seed: 1 "Электроника" с товаром 1, внутри нее 2 "Периферия" с товаром 2)
+		{"categories_tree", "GET", "/api/categories", "", http.StatusOK, nil},
+		{"categories_get", "GET", "/api/categories/2", "", http.StatusOK, nil},
+		{"categories_get_not_found", "GET", "/api/categories/99", "", http.StatusNotFound, nil},
+		{"categories_create", "POST", "/api/categories", `{"name":"Мыши","parent_id":2}`, http.StatusCreated, nil},
+		{"categories_create_unknown_parent", "POST", "/api/categories", `{"name":"Мыши","parent_id":99}`, http.StatusBadRequest, nil},
+		{"categories_create_without_name", "POST", "/api/categories", `{"name":" "}`, http.StatusBadRequest, nil},
+		{"categories_update_move_to_root", "PUT", "/api/categories/2", `{"name":"Аксессуары"}`, http.StatusOK, ifMatch},
+		{"categories_update_cycle", "PUT", "/api/categories/1", `{"name":"Электроника","parent_id":2}`, http.StatusConflict, ifMatch},
+		{"categories_update_not_found", "PUT", "/api/categories/99", `{"name":"Мыши"}`, http.StatusNotFound, map[string]string{"If-Match": "*"}},
+		{"categories_delete_not_empty", "DELETE", "/api/categories/1", "", http.StatusConflict, ifMatch},
+		{"categories_delete", "DELETE", "/api/categories/2", "", http.StatusNoContent, ifMatch},
+		{"categories_products", "GET", "/api/categories/1/products", "", http.StatusOK, nil},
+		{"categories_products_not_found", "GET", "/api/categories/99/products", "", http.StatusNotFound, nil},
+		{"products_list_by_category", "GET", "/api/products?category=2", "", http.StatusOK, nil},
+		{"products_list_by_tag", "GET", "/api/products?tag=RGB", "", http.StatusOK, nil},
+		{"products_list_unknown_category", "GET", "/api/products?category=99", "", http.StatusBadRequest, nil},
+		{"products_search_by_category", "GET", "/api/products/search?q=pro&category=2", "", http.StatusOK, nil},
+		{"products_search_by_tag", "GET", "/api/products/search?tag=office", "", http.StatusOK, nil},
+		{"products_categories", "GET", "/api/products/2/categories", "", http.StatusOK, nil},
+		{"products_categories_set", "PUT", "/api/products/2/categories", `{"category_ids":[1,2,2]}`, http.StatusOK, nil},
+		{"products_categories_set_unknown", "PUT", "/api/products/2/categories", `{"category_ids":[99]}`, http.StatusBadRequest, nil},
+		{"products_categories_not_found", "GET", "/api/products/99/categories", "", http.StatusNotFound, nil},
+		{"products_tags", "GET", "/api/products/2/tags", "", http.StatusOK, nil},
+		{"products_tags_set", "PUT", "/api/products/2/tags", `{"tags":["Gaming"," wireless ","gaming"]}`, http.StatusOK, nil},
+		{"products_tags_set_empty_tag", "PUT", "/api/products/2/tags", `{"tags":[""]}`, http.StatusBadRequest, nil},
+		{"products_tags_set_not_found", "PUT", "/api/products/99/tags", `{"tags":["rgb"]}`, http.StatusNotFound, nil},
+		{"tags_list", "GET", "/api/tags", "", http.StatusOK, nil},
+
+		// Варианты (после seed: у товара 2 варианты 1 "MOUSE-BLK" и 2 "MOUSE-WHT")
+		{"variants_list", "GET", "/api/products/2/variants", "", http.StatusOK, nil},
+		{"variants_list_empty", "GET", "/api/products/1/variants", "", http.StatusOK, nil},
+		{"variants_list_not_found", "GET", "/api/products/99/variants", "", http.StatusNotFound, nil},
+		{"variants_get", "GET", "/api/variants/2", "", http.StatusOK, nil},
+		{"variants_get_not_found", "GET", "/api/variants/99", "", http.StatusNotFound, nil},
+		{"variants_create", "POST", "/api/products/2/variants", `{"sku":" MOUSE-RED ","attributes":{"color":"красный","dpi":"16000"},"price":3299,"quantity":3}`, http.StatusCreated, nil},
+		{"variants_create_duplicate_sku", "POST", "/api/products/1/variants", `{"sku":"MOUSE-BLK","price":1,"quantity":1}`, http.StatusConflict, nil},
+		{"variants_create_without_price", "POST", "/api/products/2/variants", `{"sku":"MOUSE-RED","quantity":3}`, http.StatusBadRequest, nil},
+		{"variants_create_product_not_found", "POST", "/api/products/99/variants", `{"sku":"X-1","price":1,"quantity":1}`, http.StatusNotFound, nil},
+		{"variants_update", "PUT", "/api/variants/1", `{"sku":"MOUSE-BLK","attributes":{"color":"черный"},"price":2799,"quantity":12}`, http.StatusOK, ifMatch},
+		{"variants_update_duplicate_sku", "PUT", "/api/variants/1", `{"sku":"MOUSE-WHT","price":2799,"quantity":12}`, http.StatusConflict, ifMatch},
+		{"variants_update_not_found", "PUT", "/api/variants/99", `{"sku":"X-1","price":1,"quantity":1}`, http.StatusNotFound, map[string]string{"If-Match": "*"}},
+		{"variants_delete", "DELETE", "/api/variants/1", "", http.StatusNoContent, ifMatch},
+		{"variants_delete_without_if_match", "DELETE", "/api/variants/1", "", http.StatusPreconditionRequired, nil},
+
+		// Рекомендации
+		{"recommendations_product", "GET", "/api/products/1/recommendations", "", http.StatusOK, nil},
+		{"recommendations_product_not_found", "GET", "/api/products/99/recommendations", "", http.StatusNotFound, nil},
+		{"recommendations_product_invalid_limit", "GET", "/api/products/1/recommendations?limit=0", "", http.StatusBadRequest, nil},
+		{"recommendations_user", "GET", "/api/users/1/recommendations", "", http.StatusOK, nil},
+		{"recommendations_user_bought_everything", "GET", "/api/users/2/recommendations", "", http.StatusOK, nil},
+		{"recommendations_user_not_found", "GET", "/api/users/99/recommendations", "", http.StatusNotFound, nil},
+
+		// Цены (после seed: у каждого товара одна запись истории, у товара 1 запланированная цена 1 на 2099 год)
+		{"prices_history", "GET", "/api/products/1/prices", "", http.StatusOK, nil},
+		{"prices_history_before_creation", "GET", "/api/products/1/prices?at=2000-01-01T00:00:00Z", "", http.StatusOK, nil},
+		{"prices_history_invalid_at", "GET", "/api/products/1/prices?at=2026-01-01", "", http.StatusBadRequest, nil},
+		{"prices_history_not_found", "GET", "/api/products/99/prices", "", http.StatusNotFound, nil},
+		{"prices_scheduled", "GET", "/api/products/1/scheduled-prices", "", http.StatusOK, nil},
+		{"prices_scheduled_empty", "GET", "/api/products/2/scheduled-prices", "", http.StatusOK, nil},
+		{"prices_schedule", "POST", "/api/products/2/scheduled-prices", `{"price":1999,"effective_at":"2099-06-01T00:00:00+03:00"}`, http.StatusCreated, nil},
+		{"prices_schedule_in_past", "POST", "/api/products/2/scheduled-prices", `{"price":1999,"effective_at":"2020-01-01T00:00:00Z"}`, http.StatusBadRequest, nil},
+		{"prices_schedule_without_price", "POST", "/api/products/2/scheduled-prices", `{"effective_at":"2099-06-01T00:00:00Z"}`, http.StatusBadRequest, nil},
+		{"prices_schedule_not_found", "POST", "/api/products/99/scheduled-prices", `{"price":1999,"effective_at":"2099-06-01T00:00:00Z"}`, http.StatusNotFound, nil},
+		{"prices_schedule_cancel", "DELETE", "/api/products/1/scheduled-prices/1", "", http.StatusNoContent, nil},
+		{"prices_schedule_cancel_other_product", "DELETE", "/api/products/2/scheduled-prices/1", "", http.StatusNotFound, nil},
+
+		// Скидки (после seed: промокод 1 WELCOME10 - 10% от 1000, один раз на пользователя;
+		// правило 1 - 5% на периферию от 3 штук)
+		{"promotions_coupons_list", "GET", "/api/coupons", "", http.StatusOK, nil},
+		{"promotions_coupon_get", "GET", "/api/coupons/1", "", http.StatusOK, nil},
+		{"promotions_coupon_get_not_found", "GET", "/api/coupons/99", "", http.StatusNotFound, nil},
+		{"promotions_coupon_create", "POST", "/api/coupons", `{"code":" summer-500 ","type":"fixed","value":500,"min_order_value":3000,"expires_at":"2099-09-01T00:00:00+03:00"}`, http.StatusCreated, nil},
+		{"promotions_coupon_create_duplicate", "POST", "/api/coupons", `{"code":"welcome10","type":"percent","value":5}`, http.StatusConflict, nil},
+		{"promotions_coupon_create_invalid_percent", "POST", "/api/coupons", `{"code":"HALF","type":"percent","value":150}`, http.StatusBadRequest, nil},
+		{"promotions_coupon_create_expired", "POST", "/api/coupons", `{"code":"OLD","type":"fixed","value":100,"expires_at":"2020-01-01T00:00:00Z"}`, http.StatusBadRequest, nil},
+		{"promotions_coupon_delete", "DELETE", "/api/coupons/1", "", http.StatusNoContent, nil},
+		{"promotions_coupon_delete_not_found", "DELETE", "/api/coupons/99", "", http.StatusNotFound, nil},
+		{"promotions_rules_list", "GET", "/api/promotions", "", http.StatusOK, nil},
+		{"promotions_rule_get", "GET", "/api/promotions/1", "", http.StatusOK, nil},
+		{"promotions_rule_get_not_found", "GET", "/api/promotions/99", "", http.StatusNotFound, nil},
+		{"promotions_rule_create", "POST", "/api/promotions", `{"name":"Электроника - 1000","category_id":1,"type":"fixed","value":1000}`, http.StatusCreated, nil},
+		{"promotions_rule_create_unknown_category", "POST", "/api/promotions", `{"name":"Скидка","category_id":99,"type":"percent","value":5}`, http.StatusBadRequest, nil},
+		{"promotions_rule_create_unknown_type", "POST", "/api/promotions", `{"name":"Скидка","type":"gift","value":5}`, http.StatusBadRequest, nil},
+		{"promotions_rule_delete", "DELETE", "/api/promotions/1", "", http.StatusNoContent, nil},
+
+		// Покупки
+		{"purchases_list", "GET", "/api/purchases", "", http.StatusOK, nil},
+		{"purchases_get", "GET", "/api/purchases/1", "", http.StatusOK, nil},
+		{"purchases_get_not_found", "GET", "/api/purchases/99", "", http.StatusNotFound, nil},
+		{"purchases_create", "POST", "/api/purchases", `{"user_id":2,"product_id":1,"quantity":3}`, http.StatusCreated, nil},
+		{"purchases_create_insufficient_stock", "POST", "/api/purchases", `{"user_id":2,"product_id":1,"quantity":100}`, http.StatusConflict, nil},
+		{"purchases_create_unknown_user", "POST", "/api/purchases", `{"user_id":99,"product_id":1,"quantity":1}`, http.StatusInternalServerError, nil},
+		{"purchases_create_variant", "POST", "/api/purchases", `{"user_id":2,"variant_id":2,"quantity":2}`, http.StatusCreated, nil},
+		{"purchases_create_variant_insufficient_stock", "POST", "/api/purchases", `{"user_id":2,"variant_id":2,"quantity":6}`, http.StatusConflict, nil},
+		{"purchases_create_variant_required", "POST", "/api/purchases", `{"user_id":2,"product_id":2,"quantity":1}`, http.StatusBadRequest, nil},
+		{"purchases_create_variant_wrong_product", "POST", "/api/purchases", `{"user_id":2,"product_id":1,"variant_id":2,"quantity":1}`, http.StatusBadRequest, nil},
+		{"purchases_create_with_coupon", "POST", "/api/purchases", `{"user_id":2,"product_id":1,"quantity":1,"coupon_code":"welcome10"}`, http.StatusCreated, nil},
+		{"purchases_create_with_rule_and_coupon", "POST", "/api/purchases", `{"user_id":1,"variant_id":1,"quantity":3,"coupon_code":"WELCOME10"}`, http.StatusCreated, nil},
+		{"purchases_create_unknown_coupon", "POST", "/api/purchases", `{"user_id":2,"product_id":1,"quantity":1,"coupon_code":"NOPE"}`, http.StatusNotFound, nil},
+		{"purchases_update_status", "PUT", "/api/purchases/1/status", `{"status":"completed"}`, http.StatusOK, ifMatch},
+		{"purchases_update_status_any_not_found", "PUT", "/api/purchases/99/status", `{"status":"completed"}`, http.StatusNotFound, map[string]string{"If-Match": "*"}},
+		{"purchases_update_status_without_if_match", "PUT", "/api/purchases/1/status", `{"status":"completed"}`, http.StatusPreconditionRequired, nil},
+
+		// Отчеты о продажах
+		{"reports_sales_by_product", "GET", "/api/reports/sales?by=product", "", http.StatusOK, nil},
+		{"reports_sales_by_user_csv", "GET", "/api/reports/sales?by=user&format=csv", "", http.StatusOK, nil},
+		{"reports_sales_invalid_by", "GET", "/api/reports/sales?by=year", "", http.StatusBadRequest, nil},
+		{"reports_sales_invalid_date", "GET", "/api/reports/sales?from=19.10.2026", "", http.StatusBadRequest, nil},
+		{"reports_sales_reversed_range", "GET", "/api/reports/sales?from=2026-02-01&to=2026-01-01", "", http.StatusBadRequest, nil},
+		{"reports_sales_too_long", "GET", "/api/reports/sales?from=2025-01-01&to=2026-06-01", "", http.StatusBadRequest, nil},
+		{"reports_top_customers", "GET", "/api/reports/top-customers?limit=1", "", http.StatusOK, nil},
+
+		// Маршрутизация
+		{"unknown_route", "GET", "/api/unknown", "", http.StatusNotFound, nil},
+		{"non_numeric_id", "GET", "/api/users/abc", "", http.StatusNotFound, nil},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					h := newHarness(t, backend)
+					h.seed()
+
+					status, body := h.doWithHeaders(tt.method, tt.path, tt.body, tt.headers)
+					if status != tt.status {
+						t.Fatalf("код %d, ожидался %d, тело: %s", status, tt.status, body)
+					}
+					assertGolden(t, tt.name, body)
+				})
+			}
+		})
+	}
+}
+
+// TestBulkScenario проверяет то, чего не видно в ответе пакетной операции: откат атомарного пакета,
+// раздельную фиксацию best_effort и прогрев кеша созданными товарами
+func TestBulkScenario(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			h := newHarness(t, backend)
+			h.seed()
+			ctx := context.Background()
+
+			// Атомарный пакет с дубликатом пользователя не создает никого
+			var response models.BulkResponse
+			h.doJSON("POST", "/api/users/bulk", `{"items":[{"username":"carol","email":"carol@example.com"},{"username":"alice","email":"alice2@example.com"}]}`, http.StatusConflict, &response)
+			if response.Succeeded != 0 || response.Failed != 2 || response.Results[0].Status != http.StatusFailedDependency {
+				t.Fatalf("атомарный пакет с дубликатом: %+v", response)
+			}
+			var users []models.User
+			h.doJSON("GET", "/api/users", "", http.StatusOK, &users)
+			if len(users) != 2 {
+				t.Fatalf("после отката ожидалось 2 пользователя, получено %d", len(users))
+			}
+
+			// best_effort записывает все, кроме дубликата
+			h.doJSON("POST", "/api/users/bulk", `{"mode":"best_effort","items":[{"username":"carol","email":"carol@example.com"},{"username":"alice","email":"alice2@example.com"}]}`, http.StatusOK, &response)
+			if response.Succeeded != 1 || response.Results[0].ID != 3 || response.Results[1].Status != http.StatusInternalServerError {
+				t.Fatalf("best_effort с дубликатом: %+v", response)
+			}
+
+			// Откаченное атомарное обновление не меняет первый товар
+			h.doJSON("PUT", "/api/products/bulk", `{"items":[{"id":1,"version":1,"name":"Ноутбук","description":"","price":1,"quantity":8},{"id":2,"version":5,"name":"Мышь","description":"","price":990,"quantity":30}]}`, http.StatusConflict, &response)
+			var product models.Product
+			h.doJSON("GET", "/api/products/1", "", http.StatusOK, &product)
+			if product.Price != 89999.5 || product.Version != 1 {
+				t.Fatalf("товар после отката: %+v", product)
+			}
+
+			// Созданные товары сразу лежат в кеше
+			h.doJSON("POST", "/api/products/bulk", `{"items":[{"name":"Клавиатура","price":4500,"quantity":12},{"name":"Монитор","price":25000,"quantity":5}]}`, http.StatusOK, &response)
+			for _, result := range response.Results {
+				cached, _ := h.productCache.GetByID(ctx, result.ID)
+				if cached == nil || cached.Version != 1 {
+					t.Errorf("товар %d не прогрет в кеше: %+v", result.ID, cached)
+				}
+			}
+		})
+	}
+}
+
+// TestPurchaseScenario проходит путь клиента через API: пользователь, товар, покупка и ее отмена,
+// проверяя остаток на складе и содержимое кешей после каждого шага
+func TestPurchaseScenario(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			h := newHarness(t, backend)
+			ctx := context.Background()
+
+			var user models.User
+			h.doJSON("POST", "/api/users", `{"username":"dave","email":"dave@example.com"}`, http.StatusCreated, &user)
+
+			var product models.Product
+			h.doJSON("POST", "/api/products", `{"name":"Монитор","description":"27 дюймов","price":25000,"quantity":5}`, http.StatusCreated, &product)
+
+			// Прогреваем кеш товара, чтобы проверить его сброс при покупке
+			h.doJSON("GET", "/api/products/1", "", http.StatusOK, nil)
+			if cached, _ := h.productCache.GetByID(ctx, product.ID); cached == nil {
+				t.Fatal("товар не попал в кеш после чтения")
+			}
+
+			var purchase models.Purchase
+			h.doJSON("POST", "/api/purchases", `{"user_id":1,"product_id":1,"quantity":2}`, http.StatusCreated, &purchase)
+			if purchase.TotalPrice != 50000 || purchase.Status != "pending" {
+				t.Fatalf("покупка: %+v", purchase)
+			}
+
+			if cached, _ := h.productCache.GetByID(ctx, product.ID); cached != nil {
+				t.Error("кеш товара не сброшен после списания остатка")
+			}
+			h.doJSON("GET", "/api/products/1", "", http.StatusOK, &product)
+			if product.Quantity != 3 || product.Version != 2 || h.header.Get("ETag") != `"2"` {
+				t.Errorf("после покупки: остаток %d, версия %d, ETag %s; ожидалось 3, 2, \"2\"", product.Quantity, product.Version, h.header.Get("ETag"))
+			}
+			// Клиент с закешированной версией 1 получает новое представление, с версией 2 - 304
+			if status, _ := h.doWithHeaders("GET", "/api/products/1", "", map[string]string{"If-None-Match": `"1"`}); status != http.StatusOK {
+				t.Errorf("If-None-Match со старой версией: код %d", status)
+			}
+			if status, _ := h.doWithHeaders("GET", "/api/products/1", "", map[string]string{"If-None-Match": `"2"`}); status != http.StatusNotModified {
+				t.Errorf("If-None-Match с текущей версией: код %d", status)
+			}
+
+			// Остатка не хватает - покупка отклоняется, склад не меняется
+			status, _ := h.do("POST", "/api/purchases", `{"user_id":1,"product_id":1,"quantity":4}`)
+			if status != http.StatusConflict {
+				t.Errorf("покупка сверх остатка: код %d", status)
+			}
+			stored, err := h.products.GetByID(ctx, product.ID)
+			if err != nil || stored.Quantity != 3 {
+				t.Errorf("остаток после отклоненной покупки: %+v, %v", stored, err)
+			}
+
+			var cancelled models.Purchase
+			h.doJSONWithHeaders("PUT", "/api/purchases/1/status", `{"status":"cancelled"}`, ifMatch, http.StatusOK, &cancelled)
+			if cancelled.Status != "cancelled" || h.header.Get("ETag") != `"2"` {
+				t.Errorf("после отмены: статус %q, ETag %s", cancelled.Status, h.header.Get("ETag"))
+			}
+			if cached, _ := h.purchaseCache.GetByID(ctx, purchase.ID); cached != nil && (cached.Status != "cancelled" || cached.Version != 2) {
+				t.Errorf("в кеше устаревшая покупка: %+v", cached)
+			}
+
+			// Отмена меняет только статус и не возвращает товар на склад
+			stored, err = h.products.GetByID(ctx, product.ID)
+			if err != nil || stored.Quantity != 3 {
+				t.Errorf("остаток после отмены: %+v, %v", stored, err)
+			}
+
+			var purchases []models.Purchase
+			h.doJSON("GET", "/api/users/1/purchases", "", http.StatusOK, &purchases)
+			if len(purchases) != 1 || purchases[0].Status != "cancelled" {
+				t.Errorf("покупки пользователя: %+v", purchases)
+			}
+		})
+	}
+}
+
+// TestConcurrentEdits - два администратора правят один товар, прочитав одну и ту же версию:
+// второе изменение отклоняется, а не перезаписывает первое молча
+func TestConcurrentEdits(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			h := newHarness(t, backend)
+			h.seed()
+			ctx := context.Background()
+
+			h.doJSON("GET", "/api/products/1", "", http.StatusOK, nil)
+			tag := h.header.Get("ETag")
+			headers := map[string]string{"If-Match": tag}
+
+			var first models.Product
+			h.doJSONWithHeaders("PATCH", "/api/products/1", `{"price":85000}`, headers, http.StatusOK, &first)
+			if first.Version != 2 || h.header.Get("ETag") != `"2"` {
+				t.Fatalf("первое изменение: версия %d, ETag %s", first.Version, h.header.Get("ETag"))
+			}
+
+			status, body := h.doWithHeaders("PATCH", "/api/products/1", `{"quantity":0}`, headers)
+			if status != http.StatusPreconditionFailed {
+				t.Fatalf("второе изменение со старым ETag: код %d, тело %s", status, body)
+			}
+
+			stored, err := h.products.GetByID(ctx, 1)
+			if err != nil || stored.Price != 85000 || stored.Quantity != 8 || stored.Version != 2 {
+				t.Errorf("в хранилище: %+v, %v", stored, err)
+			}
+			if cached, _ := h.productCache.GetByID(ctx, 1); cached == nil || cached.Version != 2 || cached.Price != 85000 {
+				t.Errorf("в кеше: %+v", cached)
+			}
+		})
+	}
+}
+
+// TestExportScenario сверяет выгрузки с данными API; метки времени в выгрузке не нормализуются,
+// поэтому вместо golden-файлов проверяются заголовки и значения полей
+func TestExportScenario(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			h := newHarness(t, backend)
+			h.seed()
+
+			status, body := h.do("GET", "/api/products/export", "")
+			if status != http.StatusOK {
+				t.Fatalf("выгрузка товаров: код %d, тело: %s", status, body)
+			}
+			if h.header.Get("Content-Type") != "text/csv; charset=utf-8" ||
+				h.header.Get("Content-Disposition") != `attachment; filename="products.csv"` {
+				t.Errorf("заголовки CSV: %v", h.header)
+			}
+			rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+			if err != nil {
+				t.Fatalf("CSV: %v", err)
+			}
+			if len(rows) != 3 || strings.Join(rows[0][:6], ",") != "id,name,description,price,quantity,version" {
+				t.Fatalf("CSV: %q", rows)
+			}
+			if strings.Join(rows[1][:6], "|") != `1|Ноутбук ProBook 15|15.6" ноутбук|89999.5|8|1` {
+				t.Errorf("строка товара: %q", rows[1])
+			}
+
+			// Выгрузка, измененная импортом, читается обратно как JSONL
+			h.do("POST", "/api/products/import?format=jsonl&key=id", `{"id":2,"price":"2 499,5"}`)
+			status, body = h.do("GET", "/api/products/export?format=ndjson", "")
+			if status != http.StatusOK || h.header.Get("Content-Type") != "application/x-ndjson" {
+				t.Fatalf("выгрузка JSONL: код %d, заголовки %v", status, h.header)
+			}
+			lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("JSONL: ожидалось 2 строки, получено %q", body)
+			}
+			var product models.Product
+			if err := json.Unmarshal([]byte(lines[1]), &product); err != nil {
+				t.Fatal(err)
+			}
+			if product.ID != 2 || product.Price != 2499.5 || product.Version != 2 {
+				t.Errorf("товар после импорта: %+v", product)
+			}
+
+			for path, want := range map[string]int{"/api/users/export": 3, "/api/purchases/export": 4} {
+				status, body := h.do("GET", path, "")
+				rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+				if status != http.StatusOK || err != nil || len(rows) != want {
+					t.Errorf("GET %s: код %d, строк %d (%v), ожидалось %d", path, status, len(rows), err, want)
+				}
+			}
+		})
+	}
+}
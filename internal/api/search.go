@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// SearchProducts ищет товары: q - текст запроса, фильтры min_price, max_price, in_stock, category, tag,
+// сортировка sort, страница limit и offset
+func (h *ProductHandlers) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r.URL.Query())
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Category != 0 {
+		if query.CategoryIDs, err = h.categoryService.SubtreeIDs(r.Context(), query.Category); err != nil {
+			respondWriteError(w, err)
+			return
+		}
+	}
+
+	result, err := h.productService.SearchProducts(r.Context(), query)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondComputed(w, r, result)
+}
+
+// SuggestProducts возвращает подсказки для строки поиска: q - введенный текст, limit - сколько подсказок
+func (h *ProductHandlers) SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	limit := models.DefaultSuggestLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > models.MaxSuggestLimit {
+			RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit: ожидается число от 1 до %d", models.MaxSuggestLimit))
+			return
+		}
+	}
+
+	suggestions, err := h.productService.SuggestProducts(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondComputed(w, r, suggestions)
+}
+
+func parseSearchQuery(values url.Values) (*models.ProductSearchQuery, error) {
+	query := &models.ProductSearchQuery{Query: values.Get("q"), Sort: values.Get("sort"), Tag: values.Get("tag")}
+
+	var err error
+	if query.MinPrice, err = parsePrice(values, "min_price"); err != nil {
+		return nil, err
+	}
+	if query.MaxPrice, err = parsePrice(values, "max_price"); err != nil {
+		return nil, err
+	}
+	if value := values.Get("in_stock"); value != "" {
+		if query.InStock, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("in_stock: ожидается true или false")
+		}
+	}
+	if value := values.Get("category"); value != "" {
+		if query.Category, err = strconv.ParseInt(value, 10, 64); err != nil || query.Category == 0 {
+			return nil, fmt.Errorf("category: некорректный идентификатор категории")
+		}
+	}
+	if value := values.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit == 0 {
+			return nil, fmt.Errorf("limit: ожидается число от 1 до %d", models.MaxSearchLimit)
+		}
+	}
+	if value := values.Get("offset"); value != "" {
+		if query.Offset, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("offset: некорректное число %q", value)
+		}
+	}
+	return query, nil
+}
+
+func parsePrice(values url.Values, name string) (*float64, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 {
+		return nil, fmt.Errorf("%s: некорректная цена %q", name, value)
+	}
+	return &price, nil
+}
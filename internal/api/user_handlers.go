@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
 	"github.com/SaveljevRoman/go-layout-project/internal/service"
 	"github.com/gorilla/mux"
@@ -39,6 +41,10 @@ func (h *UserHandlers) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, etag(user.Version)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
@@ -51,6 +57,10 @@ func (h *UserHandlers) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, collectionETag(users, func(u *models.User) (int64, int64) { return u.ID, u.Version })) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
 }
@@ -78,11 +88,23 @@ func (h *UserHandlers) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user.ID = id
+	w.Header().Set("ETag", etag(user.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
 }
 
+// currentVersion возвращает текущую версию пользователя для If-Match: * (0 - пользователя нет)
+func (h *UserHandlers) currentVersion(id int64) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		item, err := h.userService.GetUser(ctx, id)
+		if err != nil || item == nil {
+			return 0, err
+		}
+		return item.Version, nil
+	}
+}
+
 func (h *UserHandlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
@@ -91,19 +113,80 @@ func (h *UserHandlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	version, ok := requireIfMatch(w, r, "User not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	// PUT заменяет ресурс целиком, поэтому все поля обязательны
+	var req models.UserUpdateRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	user.ID = id
+	user := models.User{
+		ID:       id,
+		Username: *req.Username,
+		Email:    *req.Email,
+		Version:  version,
+	}
+
 	ctx := r.Context()
 	if err := h.userService.UpdateUser(ctx, &user); err != nil {
+		// Конфликт версий отвечает JSON, как остальные ответы на условные запросы; прочие ошибки PUT - текстом, как раньше
+		if errors.Is(err, models.ErrVersionConflict) {
+			respondWriteError(w, err)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("ETag", etag(user.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// PatchUser частично обновляет пользователя по JSON Merge Patch: меняются только переданные поля
+func (h *UserHandlers) PatchUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	version, ok := requireIfMatch(w, r, "User not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	if !isMergePatch(r) {
+		RespondWithError(w, http.StatusUnsupportedMediaType, "Ожидается Content-Type "+mergePatchContentType)
+		return
+	}
+
+	var patch models.UserPatch
+	if err := models.ParseMergePatch(r, &patch); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	patch.Version = version
+
+	ctx := r.Context()
+	user, err := h.userService.PatchUser(ctx, id, &patch)
+	if err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	if user == nil {
+		RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("ETag", etag(user.Version))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
@@ -116,11 +199,80 @@ func (h *UserHandlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := requireIfMatch(w, r, "User not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
 	ctx := r.Context()
-	if err := h.userService.DeleteUser(ctx, id); err != nil {
+	if err := h.userService.DeleteUser(ctx, id, version); err != nil {
+		// Конфликт версий отвечает JSON, как остальные ответы на условные запросы; прочие ошибки - текстом, как раньше
+		if errors.Is(err, models.ErrVersionConflict) {
+			respondWriteError(w, err)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// BulkCreateUsers создает пользователей пакетом (POST /api/users/bulk)
+func (h *UserHandlers) BulkCreateUsers(w http.ResponseWriter, r *http.Request) {
+	var req models.UserBulkCreateRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	serveBulk(w, r, req.Mode, len(req.Items), http.StatusCreated,
+		func(i int) (*models.User, error) {
+			item := req.Items[i]
+			if err := item.Validate(); err != nil {
+				return nil, err
+			}
+			return &models.User{Username: item.Username, Email: item.Email}, nil
+		},
+		h.userService.BulkCreateUsers,
+		func(u *models.User) (int64, int64) { return u.ID, u.Version },
+	)
+}
+
+// BulkUpdateUsers заменяет пользователей пакетом (PUT /api/users/bulk); версия каждого пользователя обязательна
+func (h *UserHandlers) BulkUpdateUsers(w http.ResponseWriter, r *http.Request) {
+	var req models.UserBulkUpdateRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	serveBulk(w, r, req.Mode, len(req.Items), http.StatusOK,
+		func(i int) (*models.User, error) {
+			item := req.Items[i]
+			if err := item.Validate(); err != nil {
+				return nil, err
+			}
+			return &models.User{ID: item.ID, Username: *item.Username, Email: *item.Email, Version: item.Version}, nil
+		},
+		h.userService.BulkUpdateUsers,
+		func(u *models.User) (int64, int64) { return u.ID, u.Version },
+	)
+}
+
+// BulkDeleteUsers удаляет пользователей пакетом (DELETE /api/users/bulk)
+func (h *UserHandlers) BulkDeleteUsers(w http.ResponseWriter, r *http.Request) {
+	var req models.BulkDeleteRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	serveBulk(w, r, req.Mode, len(req.Items), http.StatusNoContent,
+		func(i int) (models.BulkDeleteItem, error) {
+			return req.Items[i], req.Items[i].Validate()
+		},
+		h.userService.BulkDeleteUsers,
+		func(item models.BulkDeleteItem) (int64, int64) { return item.ID, 0 },
+	)
+}
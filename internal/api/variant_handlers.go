@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type VariantHandlers struct {
+	variantService *service.VariantService
+}
+
+func NewVariantHandlers(variantService *service.VariantService) *VariantHandlers {
+	return &VariantHandlers{
+		variantService: variantService,
+	}
+}
+
+// GetProductVariants возвращает варианты товара
+func (h *VariantHandlers) GetProductVariants(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	variants, err := h.variantService.GetProductVariants(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if variants == nil {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	if notModified(w, r, collectionETag(variants, func(v *models.ProductVariant) (int64, int64) { return v.ID, v.Version })) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variants)
+}
+
+// CreateVariant добавляет товару вариант
+func (h *VariantHandlers) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var req models.VariantRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	variant := req.Variant(id)
+	if err := h.variantService.CreateVariant(r.Context(), variant); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	if variant.ID == 0 {
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	w.Header().Set("ETag", etag(variant.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *VariantHandlers) GetVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid variant ID")
+		return
+	}
+
+	variant, err := h.variantService.GetVariant(r.Context(), id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if variant == nil {
+		RespondWithError(w, http.StatusNotFound, "Variant not found")
+		return
+	}
+
+	if notModified(w, r, etag(variant.Version)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variant)
+}
+
+// currentVersion возвращает текущую версию варианта для If-Match: * (0 - варианта нет)
+func (h *VariantHandlers) currentVersion(id int64) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		item, err := h.variantService.GetVariant(ctx, id)
+		if err != nil || item == nil {
+			return 0, err
+		}
+		return item.Version, nil
+	}
+}
+
+// UpdateVariant заменяет артикул, характеристики, цену и остаток варианта
+func (h *VariantHandlers) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid variant ID")
+		return
+	}
+
+	version, ok := requireIfMatch(w, r, "Variant not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	var req models.VariantRequest
+	if err := models.ParseAndValidate(r, &req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	variant := req.Variant(0)
+	variant.ID = id
+	variant.Version = version
+	if err := h.variantService.UpdateVariant(r.Context(), variant); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(variant.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variant)
+}
+
+// DeleteVariant удаляет вариант; покупки варианта сохраняются без ссылки на него
+func (h *VariantHandlers) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid variant ID")
+		return
+	}
+
+	version, ok := requireIfMatch(w, r, "Variant not found", h.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	if err := h.variantService.DeleteVariant(r.Context(), id, version); err != nil {
+		respondWriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
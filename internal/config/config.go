@@ -2,43 +2,274 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultPath - путь к файлу конфигурации, если он не передан флагом -config
+const DefaultPath = "config.json"
+
+// Config - конфигурация приложения.
+// Поля с тегом reload:"true" применяются на лету при перезагрузке (SIGHUP или изменение файла),
+// изменение остальных полей требует перезапуска.
 type Config struct {
-	ServerAddress       string      `json:"server_address"`
-	CacheUpdateInterval int         `json:"cache_update_interval"` // в секундах
-	MySQL               MySQLConfig `json:"mysql"`
-	Redis               RedisConfig `json:"redis"`
+	ServerAddress       string          `json:"server_address" yaml:"server_address" env:"SERVER_ADDRESS"`
+	LogLevel            string          `json:"log_level" yaml:"log_level" env:"LOG_LEVEL" reload:"true"`                                     // debug, info, warn, error
+	CacheUpdateInterval int             `json:"cache_update_interval" yaml:"cache_update_interval" env:"CACHE_UPDATE_INTERVAL" reload:"true"` // в секундах
+	CacheTTL            int             `json:"cache_ttl" yaml:"cache_ttl" env:"CACHE_TTL" reload:"true"`                                     // в секундах
+	CacheRefreshTTL     int             `json:"cache_refresh_ttl" yaml:"cache_refresh_ttl" env:"CACHE_REFRESH_TTL" reload:"true"`             // в секундах
+	ConfigWatchInterval int             `json:"config_watch_interval" yaml:"config_watch_interval" env:"CONFIG_WATCH_INTERVAL"`               // в секундах, 0 - только по SIGHUP
+	RateLimit           RateLimitConfig `json:"rate_limit" yaml:"rate_limit" env:"RATE_LIMIT"`
+	Features            map[string]bool `json:"features" yaml:"features" env:"FEATURES" reload:"true"`
+	Search              SearchConfig    `json:"search" yaml:"search" env:"SEARCH"`
+	// Driver - хранилище данных: mysql (по умолчанию), postgres или sqlite
+	Driver   string         `json:"driver" yaml:"driver" env:"DRIVER"`
+	MySQL    MySQLConfig    `json:"mysql" yaml:"mysql" env:"MYSQL"`
+	Postgres PostgresConfig `json:"postgres" yaml:"postgres" env:"POSTGRES"`
+	SQLite   SQLiteConfig   `json:"sqlite" yaml:"sqlite" env:"SQLITE"`
+	// CacheDriver - кеш: redis (по умолчанию) или memory (в памяти процесса)
+	CacheDriver string      `json:"cache_driver" yaml:"cache_driver" env:"CACHE_DRIVER"`
+	Redis       RedisConfig `json:"redis" yaml:"redis" env:"REDIS"`
+
+	// RecommendRebuildInterval - период пересчета совместных покупок для рекомендаций
+	// (GET /api/products/{id}/recommendations, /api/users/{id}/recommendations), в секундах
+	RecommendRebuildInterval int `json:"recommend_rebuild_interval" yaml:"recommend_rebuild_interval" env:"RECOMMEND_REBUILD_INTERVAL" reload:"true"`
+
+	// PriceScheduleInterval - наибольший период проверки запланированных цен, в секундах. Цена, запланированная
+	// на этом экземпляре, применяется точно в срок; запланированная на другом - не позже чем через период.
+	PriceScheduleInterval int `json:"price_schedule_interval" yaml:"price_schedule_interval" env:"PRICE_SCHEDULE_INTERVAL" reload:"true"`
+}
+
+// RateLimitConfig - ограничение частоты запросов с одного IP
+type RateLimitConfig struct {
+	Enabled           bool    `json:"enabled" yaml:"enabled" env:"ENABLED" reload:"true"`
+	RequestsPerSecond float64 `json:"requests_per_second" yaml:"requests_per_second" env:"REQUESTS_PER_SECOND" reload:"true"`
+	Burst             int     `json:"burst" yaml:"burst" env:"BURST" reload:"true"`
+}
+
+// SearchConfig - поиск товаров (GET /api/products/search)
+type SearchConfig struct {
+	// Stemming - отбрасывать окончания русских слов запроса, чтобы "ноутбуки" находило "ноутбука"
+	Stemming bool `json:"stemming" yaml:"stemming" env:"STEMMING" reload:"true"`
+	// CacheTTL - время жизни кешированной страницы результатов, в секундах
+	CacheTTL int `json:"cache_ttl" yaml:"cache_ttl" env:"CACHE_TTL" reload:"true"`
+	// SuggestRebuildInterval - период перестроения индекса подсказок (GET /api/products/suggest)
+	// с пересчетом продаж, в секундах
+	SuggestRebuildInterval int `json:"suggest_rebuild_interval" yaml:"suggest_rebuild_interval" env:"SUGGEST_REBUILD_INTERVAL" reload:"true"`
 }
 
 type MySQLConfig struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Database string `json:"database"`
+	Host     string `json:"host" yaml:"host" env:"HOST"`
+	Port     int    `json:"port" yaml:"port" env:"PORT"`
+	User     string `json:"user" yaml:"user" env:"USER"`
+	Password string `json:"password" yaml:"password" env:"PASSWORD"`
+	// PasswordFile - путь к файлу с паролем (например, docker/k8s secret), имеет приоритет над Password
+	PasswordFile string `json:"password_file" yaml:"password_file" env:"PASSWORD_FILE"`
+	Database     string `json:"database" yaml:"database" env:"DATABASE"`
+
+	// Пул соединений
+	MaxOpenConns    int `json:"max_open_conns" yaml:"max_open_conns" env:"MAX_OPEN_CONNS"`
+	MaxIdleConns    int `json:"max_idle_conns" yaml:"max_idle_conns" env:"MAX_IDLE_CONNS"`
+	ConnMaxLifetime int `json:"conn_max_lifetime" yaml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME"`    // в секундах, 0 - без ограничения
+	ConnMaxIdleTime int `json:"conn_max_idle_time" yaml:"conn_max_idle_time" env:"CONN_MAX_IDLE_TIME"` // в секундах, 0 - без ограничения
+
+	// Таймауты, в секундах (0 - по умолчанию драйвера)
+	DialTimeout  int `json:"dial_timeout" yaml:"dial_timeout" env:"DIAL_TIMEOUT"`
+	ReadTimeout  int `json:"read_timeout" yaml:"read_timeout" env:"READ_TIMEOUT"`
+	WriteTimeout int `json:"write_timeout" yaml:"write_timeout" env:"WRITE_TIMEOUT"`
+
+	Charset   string `json:"charset" yaml:"charset" env:"CHARSET"`
+	Collation string `json:"collation" yaml:"collation" env:"COLLATION"`
+
+	TLS MySQLTLSConfig `json:"tls" yaml:"tls" env:"TLS"`
+
+	// Params - произвольные параметры DSN (например, "interpolateParams": "true")
+	Params map[string]string `json:"params" yaml:"params" env:"PARAMS"`
+
+	// Повторные попытки подключения при старте, пока БД поднимается
+	ConnectRetries       int `json:"connect_retries" yaml:"connect_retries" env:"CONNECT_RETRIES"`
+	ConnectRetryDelay    int `json:"connect_retry_delay" yaml:"connect_retry_delay" env:"CONNECT_RETRY_DELAY"`             // начальная задержка в секундах, удваивается с каждой попыткой
+	ConnectRetryMaxDelay int `json:"connect_retry_max_delay" yaml:"connect_retry_max_delay" env:"CONNECT_RETRY_MAX_DELAY"` // в секундах
+
+	// TxMaxRetries - сколько раз повторять транзакцию при deadlock или lock wait timeout
+	TxMaxRetries int `json:"tx_max_retries" yaml:"tx_max_retries" env:"TX_MAX_RETRIES"`
+
+	// Replicas - адреса реплик для чтения (host:port), учетные данные и параметры берутся от primary
+	Replicas                   []string `json:"replicas" yaml:"replicas" env:"REPLICAS"`
+	ReplicaMaxLag              int      `json:"replica_max_lag" yaml:"replica_max_lag" env:"REPLICA_MAX_LAG"`                                           // в секундах, 0 - не проверять отставание
+	ReplicaHealthCheckInterval int      `json:"replica_health_check_interval" yaml:"replica_health_check_interval" env:"REPLICA_HEALTH_CHECK_INTERVAL"` // в секундах
+}
+
+// MySQLTLSConfig - настройки TLS-соединения с MySQL
+type MySQLTLSConfig struct {
+	// Mode: disabled (по умолчанию), preferred, skip-verify, verify
+	Mode       string `json:"mode" yaml:"mode" env:"MODE"`
+	CAFile     string `json:"ca_file" yaml:"ca_file" env:"CA_FILE"`
+	CertFile   string `json:"cert_file" yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile    string `json:"key_file" yaml:"key_file" env:"KEY_FILE"`
+	ServerName string `json:"server_name" yaml:"server_name" env:"SERVER_NAME"`
+}
+
+type PostgresConfig struct {
+	Host     string `json:"host" yaml:"host" env:"HOST"`
+	Port     int    `json:"port" yaml:"port" env:"PORT"`
+	User     string `json:"user" yaml:"user" env:"USER"`
+	Password string `json:"password" yaml:"password" env:"PASSWORD"`
+	// PasswordFile - путь к файлу с паролем, имеет приоритет над Password
+	PasswordFile string `json:"password_file" yaml:"password_file" env:"PASSWORD_FILE"`
+	Database     string `json:"database" yaml:"database" env:"DATABASE"`
+	// SSLMode - disable, require, verify-ca, verify-full
+	SSLMode     string `json:"sslmode" yaml:"sslmode" env:"SSLMODE"`
+	SSLRootCert string `json:"sslrootcert" yaml:"sslrootcert" env:"SSLROOTCERT"`
+
+	MaxOpenConns    int `json:"max_open_conns" yaml:"max_open_conns" env:"MAX_OPEN_CONNS"`
+	MaxIdleConns    int `json:"max_idle_conns" yaml:"max_idle_conns" env:"MAX_IDLE_CONNS"`
+	ConnMaxLifetime int `json:"conn_max_lifetime" yaml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME"` // в секундах
+	ConnectTimeout  int `json:"connect_timeout" yaml:"connect_timeout" env:"CONNECT_TIMEOUT"`       // в секундах
+
+	ConnectRetries    int `json:"connect_retries" yaml:"connect_retries" env:"CONNECT_RETRIES"`
+	ConnectRetryDelay int `json:"connect_retry_delay" yaml:"connect_retry_delay" env:"CONNECT_RETRY_DELAY"` // начальная задержка в секундах
+	TxMaxRetries      int `json:"tx_max_retries" yaml:"tx_max_retries" env:"TX_MAX_RETRIES"`
+}
+
+// SQLiteConfig - встроенная БД для запуска без внешних сервисов (демо, CI, edge)
+type SQLiteConfig struct {
+	// Path - путь к файлу БД или ":memory:"
+	Path         string `json:"path" yaml:"path" env:"PATH"`
+	MaxOpenConns int    `json:"max_open_conns" yaml:"max_open_conns" env:"MAX_OPEN_CONNS"`
+	BusyTimeout  int    `json:"busy_timeout" yaml:"busy_timeout" env:"BUSY_TIMEOUT"` // в миллисекундах
+	TxMaxRetries int    `json:"tx_max_retries" yaml:"tx_max_retries" env:"TX_MAX_RETRIES"`
 }
 
 type RedisConfig struct {
-	Address  string `json:"address"`
-	Password string `json:"password"`
-	DB       int    `json:"db"`
+	// Mode - топология Redis: single (по умолчанию), sentinel, cluster
+	Mode string `json:"mode" yaml:"mode" env:"MODE"`
+	// Address - адрес сервера в режиме single
+	Address string `json:"address" yaml:"address" env:"ADDRESS"`
+	// Addresses - адреса sentinel-узлов или начальный список узлов кластера
+	Addresses []string `json:"addresses" yaml:"addresses" env:"ADDRESSES"`
+	// MasterName - имя мастера, отслеживаемого sentinel
+	MasterName string `json:"master_name" yaml:"master_name" env:"MASTER_NAME"`
+
+	Username string `json:"username" yaml:"username" env:"USERNAME"`
+	Password string `json:"password" yaml:"password" env:"PASSWORD"`
+	// PasswordFile - путь к файлу с паролем, имеет приоритет над Password
+	PasswordFile     string `json:"password_file" yaml:"password_file" env:"PASSWORD_FILE"`
+	SentinelPassword string `json:"sentinel_password" yaml:"sentinel_password" env:"SENTINEL_PASSWORD"`
+	// SentinelPasswordFile - путь к файлу с паролем sentinel, имеет приоритет над SentinelPassword
+	SentinelPasswordFile string `json:"sentinel_password_file" yaml:"sentinel_password_file" env:"SENTINEL_PASSWORD_FILE"`
+	// DB - номер базы, не используется в режиме cluster
+	DB int `json:"db" yaml:"db" env:"DB"`
+
+	// Пул соединений
+	PoolSize     int `json:"pool_size" yaml:"pool_size" env:"POOL_SIZE"` // 0 - по умолчанию клиента (10 на CPU)
+	MinIdleConns int `json:"min_idle_conns" yaml:"min_idle_conns" env:"MIN_IDLE_CONNS"`
+	MaxRetries   int `json:"max_retries" yaml:"max_retries" env:"MAX_RETRIES"`
+
+	// Таймауты, в секундах (0 - по умолчанию клиента)
+	DialTimeout  int `json:"dial_timeout" yaml:"dial_timeout" env:"DIAL_TIMEOUT"`
+	ReadTimeout  int `json:"read_timeout" yaml:"read_timeout" env:"READ_TIMEOUT"`
+	WriteTimeout int `json:"write_timeout" yaml:"write_timeout" env:"WRITE_TIMEOUT"`
+	PoolTimeout  int `json:"pool_timeout" yaml:"pool_timeout" env:"POOL_TIMEOUT"`
+
+	TLS RedisTLSConfig `json:"tls" yaml:"tls" env:"TLS"`
 }
 
-func Load() (*Config, error) {
-	configFile, err := os.Open("config.json")
-	if err != nil {
+// RedisTLSConfig - настройки TLS-соединения с Redis
+type RedisTLSConfig struct {
+	Enabled            bool   `json:"enabled" yaml:"enabled" env:"ENABLED"`
+	CAFile             string `json:"ca_file" yaml:"ca_file" env:"CA_FILE"`
+	CertFile           string `json:"cert_file" yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile            string `json:"key_file" yaml:"key_file" env:"KEY_FILE"`
+	ServerName         string `json:"server_name" yaml:"server_name" env:"SERVER_NAME"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify" env:"INSECURE_SKIP_VERIFY"`
+}
+
+// Load читает конфигурацию из файла (JSON или YAML по расширению),
+// применяет значения по умолчанию и переопределения из переменных окружения,
+// подгружает секреты из файлов и валидирует результат.
+// Пустой path означает DefaultPath; отсутствие файла не является ошибкой,
+// если вся конфигурация задана через окружение.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+
+	config := Default()
+
+	if err := decodeFile(path, config); err != nil {
+		if !os.IsNotExist(err) || path != DefaultPath {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(config, os.LookupEnv); err != nil {
 		return nil, err
 	}
-	defer configFile.Close()
 
-	var config Config
-	decoder := json.NewDecoder(configFile)
-	err = decoder.Decode(&config)
-	if err != nil {
+	if err := config.loadSecrets(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	return config, nil
+}
+
+func decodeFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, config)
+	case ".json", "":
+		err = json.Unmarshal(data, config)
+	default:
+		return fmt.Errorf("неподдерживаемый формат файла конфигурации: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка разбора %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadSecrets подставляет значения секретов из файлов, указанных в *_file полях
+func (c *Config) loadSecrets() error {
+	var err error
+	if c.MySQL.Password, err = readSecret(c.MySQL.PasswordFile, c.MySQL.Password); err != nil {
+		return err
+	}
+	if c.Postgres.Password, err = readSecret(c.Postgres.PasswordFile, c.Postgres.Password); err != nil {
+		return err
+	}
+	if c.Redis.Password, err = readSecret(c.Redis.PasswordFile, c.Redis.Password); err != nil {
+		return err
+	}
+	if c.Redis.SentinelPassword, err = readSecret(c.Redis.SentinelPasswordFile, c.Redis.SentinelPassword); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readSecret(path, fallback string) (string, error) {
+	if path == "" {
+		return fallback, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("не удалось прочитать секрет из %s: %w", path, err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
 }
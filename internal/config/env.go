@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix - префикс переменных окружения, переопределяющих конфигурацию.
+// Имя переменной строится из тегов env вложенных полей: APP_MYSQL_HOST, APP_REDIS_DB и т.д.
+const EnvPrefix = "APP"
+
+type lookupFunc func(key string) (string, bool)
+
+// applyEnv рекурсивно обходит структуру и подставляет значения из переменных окружения
+func applyEnv(config *Config, lookup lookupFunc) error {
+	return applyEnvValue(reflect.ValueOf(config).Elem(), EnvPrefix, lookup)
+}
+
+func applyEnvValue(v reflect.Value, prefix string, lookup lookupFunc) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("env")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := prefix + "_" + tag
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Duration(0)) {
+			if err := applyEnvValue(fv, key, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := lookup(key)
+		if !ok {
+			continue
+		}
+
+		if err := setFromString(fv, raw); err != nil {
+			return fmt.Errorf("некорректное значение переменной %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setFromString(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// Списки задаются через запятую: "a,b,c"
+		parts := splitList(raw)
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setFromString(slice.Index(i), part); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		// Словари задаются парами через запятую: "key1=value1,key2=value2"
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("неподдерживаемый тип ключа %s", v.Type().Key())
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range splitList(raw) {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("ожидалась пара key=value, получено %q", pair)
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setFromString(elem, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+	}
+	return nil
+}
+
+func splitList(raw string) []string {
+	var parts []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
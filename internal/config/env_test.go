@@ -0,0 +1,111 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envStub подставляет переменные окружения из словаря вместо os.LookupEnv
+func envStub(vars map[string]string) lookupFunc {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+func TestApplyEnvTypes(t *testing.T) {
+	config := Default()
+	err := applyEnv(config, envStub(map[string]string{
+		"APP_SERVER_ADDRESS":                 ":9090",
+		"APP_CACHE_TTL":                      "42",
+		"APP_RATE_LIMIT_ENABLED":             "true",
+		"APP_RATE_LIMIT_REQUESTS_PER_SECOND": "12.5",
+		"APP_FEATURES":                       "search=true, export=false",
+		"APP_MYSQL_REPLICAS":                 "r1:3306, ,r2:3306",
+		"APP_MYSQL_PARAMS":                   "interpolateParams=true,parseTime=true",
+		"APP_REDIS_TLS_INSECURE_SKIP_VERIFY": "1",
+		"APP_UNKNOWN":                        "ignored",
+	}))
+	if err != nil {
+		t.Fatalf("applyEnv: %v", err)
+	}
+
+	if config.ServerAddress != ":9090" {
+		t.Errorf("ServerAddress = %q", config.ServerAddress)
+	}
+	if config.CacheTTL != 42 {
+		t.Errorf("CacheTTL = %d", config.CacheTTL)
+	}
+	if !config.RateLimit.Enabled || config.RateLimit.RequestsPerSecond != 12.5 {
+		t.Errorf("RateLimit = %+v", config.RateLimit)
+	}
+	if want := map[string]bool{"search": true, "export": false}; !reflect.DeepEqual(config.Features, want) {
+		t.Errorf("Features = %v, ожидалось %v", config.Features, want)
+	}
+	if want := []string{"r1:3306", "r2:3306"}; !reflect.DeepEqual(config.MySQL.Replicas, want) {
+		t.Errorf("MySQL.Replicas = %q, ожидалось %q", config.MySQL.Replicas, want)
+	}
+	if want := map[string]string{"interpolateParams": "true", "parseTime": "true"}; !reflect.DeepEqual(config.MySQL.Params, want) {
+		t.Errorf("MySQL.Params = %v, ожидалось %v", config.MySQL.Params, want)
+	}
+	if !config.Redis.TLS.InsecureSkipVerify {
+		t.Error("Redis.TLS.InsecureSkipVerify не установлен")
+	}
+	// Незаданные переменные не трогают значения по умолчанию
+	if config.MySQL.Port != 3306 || config.LogLevel != "info" {
+		t.Errorf("значения по умолчанию изменены: port %d, log_level %q", config.MySQL.Port, config.LogLevel)
+	}
+}
+
+func TestSetFromStringMap(t *testing.T) {
+	var flags map[string]bool
+	v := reflect.ValueOf(&flags).Elem()
+	if err := setFromString(v, "search=true, export=0"); err != nil {
+		t.Fatalf("setFromString(map): %v", err)
+	}
+	if want := map[string]bool{"search": true, "export": false}; !reflect.DeepEqual(flags, want) {
+		t.Errorf("setFromString(map) = %v, ожидалось %v", flags, want)
+	}
+	if err := setFromString(v, "search=maybe"); err == nil {
+		t.Error("некорректное значение словаря принято")
+	}
+}
+
+func TestSetFromStringDuration(t *testing.T) {
+	var d time.Duration
+	if err := setFromString(reflect.ValueOf(&d).Elem(), "1m30s"); err != nil || d != 90*time.Second {
+		t.Errorf("setFromString(duration) = (%v, %v), ожидалось 1m30s", d, err)
+	}
+	if err := setFromString(reflect.ValueOf(&d).Elem(), "90"); err == nil {
+		t.Error("длительность без единиц принята")
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"APP_CACHE_TTL", "abc"},
+		{"APP_MYSQL_PORT", "99999999999999999999"},
+		{"APP_RATE_LIMIT_ENABLED", "yes"},
+		{"APP_RATE_LIMIT_REQUESTS_PER_SECOND", "fast"},
+		{"APP_FEATURES", "search"},
+		{"APP_FEATURES", "search=maybe"},
+		{"APP_MYSQL_PARAMS", "parseTime"},
+		{"APP_SQLITE_BUSY_TIMEOUT", "1.5"},
+	}
+	for _, tt := range tests {
+		err := applyEnv(Default(), envStub(map[string]string{tt.key: tt.value}))
+		if err == nil {
+			t.Errorf("%s=%q: ошибка не возвращена", tt.key, tt.value)
+			continue
+		}
+		// В ошибке указана переменная, чтобы ее было легко найти в окружении
+		if !strings.Contains(err.Error(), tt.key) {
+			t.Errorf("%s=%q: в ошибке %q нет имени переменной", tt.key, tt.value, err)
+		}
+	}
+}
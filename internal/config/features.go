@@ -0,0 +1,31 @@
+package config
+
+import "sync/atomic"
+
+// Features - потокобезопасный набор флагов функциональности, обновляемый при перезагрузке конфигурации
+type Features struct {
+	flags atomic.Pointer[map[string]bool]
+}
+
+func NewFeatures(flags map[string]bool) *Features {
+	f := &Features{}
+	f.Set(flags)
+	return f
+}
+
+// Enabled сообщает, включен ли флаг. Неизвестные флаги считаются выключенными.
+func (f *Features) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+	flags := f.flags.Load()
+	return flags != nil && (*flags)[name]
+}
+
+func (f *Features) Set(flags map[string]bool) {
+	copied := make(map[string]bool, len(flags))
+	for name, enabled := range flags {
+		copied[name] = enabled
+	}
+	f.flags.Store(&copied)
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Changes - результат сравнения двух конфигураций
+type Changes struct {
+	Reloadable      []string // поля, применяемые на лету
+	RestartRequired []string // поля, вступающие в силу только после перезапуска
+}
+
+func (c Changes) Empty() bool {
+	return len(c.Reloadable) == 0 && len(c.RestartRequired) == 0
+}
+
+// Diff сравнивает конфигурации по полям и разделяет изменения по тегу reload.
+// Значения не возвращаются, чтобы секреты не попадали в логи.
+func Diff(old, new *Config) Changes {
+	var changes Changes
+	diffValue(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", false, &changes)
+	return changes
+}
+
+func diffValue(old, new reflect.Value, path string, reloadable bool, changes *Changes) {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+		fieldReloadable := reloadable || field.Tag.Get("reload") == "true"
+
+		ov, nv := old.Field(i), new.Field(i)
+		if ov.Kind() == reflect.Struct {
+			diffValue(ov, nv, name, fieldReloadable, changes)
+			continue
+		}
+		if reflect.DeepEqual(ov.Interface(), nv.Interface()) {
+			continue
+		}
+		if fieldReloadable {
+			changes.Reloadable = append(changes.Reloadable, name)
+		} else {
+			changes.RestartRequired = append(changes.RestartRequired, name)
+		}
+	}
+}
+
+// Watcher перечитывает конфигурацию по сигналу или при изменении файла
+// и передает новую версию в apply, если она прошла валидацию
+type Watcher struct {
+	path    string
+	apply   func(*Config)
+	mu      sync.Mutex
+	current *Config
+	modTime time.Time
+}
+
+func NewWatcher(path string, current *Config, apply func(*Config)) *Watcher {
+	if path == "" {
+		path = DefaultPath
+	}
+	w := &Watcher{
+		path:    path,
+		apply:   apply,
+		current: current,
+	}
+	w.modTime = w.fileModTime()
+	return w
+}
+
+// Current возвращает последнюю примененную конфигурацию
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload загружает конфигурацию заново. При ошибке валидации текущая конфигурация сохраняется.
+// Поля, требующие перезапуска, в примененной конфигурации остаются прежними.
+func (w *Watcher) Reload() (Changes, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.modTime = w.fileModTime()
+
+	next, err := Load(w.path)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	changes := Diff(w.current, next)
+	if len(changes.Reloadable) == 0 {
+		return changes, nil
+	}
+
+	applied := *w.current
+	copyReloadable(reflect.ValueOf(&applied).Elem(), reflect.ValueOf(next).Elem(), false)
+	w.current = &applied
+	w.apply(&applied)
+
+	return changes, nil
+}
+
+// Run обрабатывает сигналы перезагрузки и, если pollInterval > 0, следит за временем изменения файла
+func (w *Watcher) Run(ctx context.Context, signals <-chan os.Signal, pollInterval time.Duration) {
+	var poll <-chan time.Time
+	if pollInterval > 0 {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Debug("Config watcher stopped")
+			return
+		case <-signals:
+			w.reloadAndLog("signal")
+		case <-poll:
+			if w.changedOnDisk() {
+				w.reloadAndLog("file change")
+			}
+		}
+	}
+}
+
+func (w *Watcher) reloadAndLog(reason string) {
+	changes, err := w.Reload()
+	if err != nil {
+		slog.Error("Config reload rejected", "reason", reason, "error", err)
+		return
+	}
+	if changes.Empty() {
+		slog.Info("Config reload: no changes", "reason", reason)
+		return
+	}
+	if len(changes.Reloadable) > 0 {
+		slog.Info("Config reload: applied", "reason", reason, "fields", strings.Join(changes.Reloadable, ", "))
+	}
+	if len(changes.RestartRequired) > 0 {
+		slog.Warn("Config reload: restart required", "reason", reason, "fields", strings.Join(changes.RestartRequired, ", "))
+	}
+}
+
+func (w *Watcher) changedOnDisk() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	modTime := w.fileModTime()
+	return !modTime.IsZero() && !modTime.Equal(w.modTime)
+}
+
+func (w *Watcher) fileModTime() time.Time {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func copyReloadable(dst, src reflect.Value, reloadable bool) {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fieldReloadable := reloadable || t.Field(i).Tag.Get("reload") == "true"
+		if dst.Field(i).Kind() == reflect.Struct {
+			copyReloadable(dst.Field(i), src.Field(i), fieldReloadable)
+			continue
+		}
+		if fieldReloadable {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
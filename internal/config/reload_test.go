@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name            string
+		modify          func(c *Config)
+		reloadable      []string
+		restartRequired []string
+	}{
+		{"без изменений", func(c *Config) {}, nil, nil},
+		{"уровень логирования", func(c *Config) { c.LogLevel = "debug" }, []string{"log_level"}, nil},
+		{"вложенное поле", func(c *Config) { c.RateLimit.Burst = 1 }, []string{"rate_limit.burst"}, nil},
+		{"флаги", func(c *Config) { c.Features = map[string]bool{"search": true} }, []string{"features"}, nil},
+		{"адрес сервера", func(c *Config) { c.ServerAddress = ":9090" }, nil, []string{"server_address"}},
+		{"секция без тега", func(c *Config) { c.MySQL.Host = "db" }, nil, []string{"mysql.host"}},
+		{"оба вида", func(c *Config) {
+			c.CacheTTL = 1
+			c.Redis.Address = "cache:6379"
+		}, []string{"cache_ttl"}, []string{"redis.address"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := validConfig()
+			tt.modify(next)
+
+			changes := Diff(validConfig(), next)
+			if !reflect.DeepEqual(changes.Reloadable, tt.reloadable) {
+				t.Errorf("Reloadable = %q, ожидалось %q", changes.Reloadable, tt.reloadable)
+			}
+			if !reflect.DeepEqual(changes.RestartRequired, tt.restartRequired) {
+				t.Errorf("RestartRequired = %q, ожидалось %q", changes.RestartRequired, tt.restartRequired)
+			}
+			if changes.Empty() != (tt.reloadable == nil && tt.restartRequired == nil) {
+				t.Errorf("Empty() = %v", changes.Empty())
+			}
+		})
+	}
+}
+
+func TestCopyReloadable(t *testing.T) {
+	src := validConfig()
+	src.LogLevel = "debug"
+	src.RateLimit.RequestsPerSecond = 7
+	src.Features = map[string]bool{"search": true}
+	src.Search.CacheTTL = 5
+	src.ServerAddress = ":9090"
+	src.MySQL.Host = "db"
+
+	dst := validConfig()
+	copyReloadable(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), false)
+
+	if dst.LogLevel != "debug" || dst.RateLimit.RequestsPerSecond != 7 || !dst.Features["search"] || dst.Search.CacheTTL != 5 {
+		t.Errorf("поля reload не скопированы: %+v", dst)
+	}
+	if dst.ServerAddress != validConfig().ServerAddress || dst.MySQL.Host != validConfig().MySQL.Host {
+		t.Errorf("скопированы поля, требующие перезапуска: server_address %q, mysql.host %q", dst.ServerAddress, dst.MySQL.Host)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("mysql:\n  user: app\n  database: shop\n"+data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("log_level: info\n")
+	current, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var applied []*Config
+	w := NewWatcher(path, current, func(c *Config) { applied = append(applied, c) })
+
+	// Поля reload применяются, поля, требующие перезапуска, остаются прежними
+	write("log_level: debug\nserver_address: \":9090\"\nfeatures:\n  search: true\n")
+	changes, err := w.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !reflect.DeepEqual(changes.Reloadable, []string{"log_level", "features"}) ||
+		!reflect.DeepEqual(changes.RestartRequired, []string{"server_address"}) {
+		t.Errorf("Reload: изменения %+v", changes)
+	}
+	if len(applied) != 1 || applied[0] != w.Current() {
+		t.Fatalf("apply вызван %d раз, ожидался один вызов с текущей конфигурацией", len(applied))
+	}
+	got := w.Current()
+	if got.LogLevel != "debug" || !got.Features["search"] || got.ServerAddress != current.ServerAddress {
+		t.Errorf("Current: log_level %q, features %v, server_address %q", got.LogLevel, got.Features, got.ServerAddress)
+	}
+
+	// Некорректная конфигурация отклоняется целиком
+	write("log_level: loud\n")
+	if _, err := w.Reload(); err == nil {
+		t.Error("Reload с некорректным уровнем логирования завершился без ошибки")
+	}
+	if len(applied) != 1 || w.Current() != got {
+		t.Error("отклоненная конфигурация была применена")
+	}
+
+	// Изменения только в полях, требующих перезапуска, не вызывают apply
+	write("log_level: debug\nserver_address: \":9091\"\nfeatures:\n  search: true\n")
+	changes, err = w.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(changes.Reloadable) != 0 || len(applied) != 1 {
+		t.Errorf("Reload без полей reload: изменения %+v, вызовов apply %d", changes, len(applied))
+	}
+}
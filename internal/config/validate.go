@@ -0,0 +1,333 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Default возвращает конфигурацию со значениями по умолчанию,
+// которые затем перекрываются файлом и переменными окружения
+func Default() *Config {
+	return &Config{
+		ServerAddress:       ":8080",
+		LogLevel:            "info",
+		CacheUpdateInterval: 60,
+		CacheTTL:            300,
+		CacheRefreshTTL:     600,
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: 50,
+			Burst:             100,
+		},
+		Search: SearchConfig{
+			Stemming:               true,
+			CacheTTL:               60,
+			SuggestRebuildInterval: 300,
+		},
+		Driver:      "mysql",
+		CacheDriver: "redis",
+		MySQL: MySQLConfig{
+			Host:                       "localhost",
+			Port:                       3306,
+			MaxOpenConns:               25,
+			MaxIdleConns:               5,
+			ConnMaxLifetime:            300,
+			DialTimeout:                5,
+			Charset:                    "utf8mb4",
+			Collation:                  "utf8mb4_unicode_ci",
+			ConnectRetries:             5,
+			ConnectRetryDelay:          1,
+			ConnectRetryMaxDelay:       30,
+			TxMaxRetries:               3,
+			ReplicaMaxLag:              10,
+			ReplicaHealthCheckInterval: 5,
+		},
+		SQLite: SQLiteConfig{
+			Path:         "app.db",
+			MaxOpenConns: 4,
+			BusyTimeout:  5000,
+			TxMaxRetries: 3,
+		},
+		Postgres: PostgresConfig{
+			Host:              "localhost",
+			Port:              5432,
+			SSLMode:           "disable",
+			MaxOpenConns:      25,
+			MaxIdleConns:      5,
+			ConnMaxLifetime:   300,
+			ConnectTimeout:    5,
+			ConnectRetries:    5,
+			ConnectRetryDelay: 1,
+			TxMaxRetries:      3,
+		},
+		Redis: RedisConfig{
+			Mode:    "single",
+			Address: "localhost:6379",
+		},
+		RecommendRebuildInterval: 600,
+		PriceScheduleInterval:    60,
+	}
+}
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные проблемы разом
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.ServerAddress); err != nil {
+		add("server_address: некорректный адрес %q: %v", c.ServerAddress, err)
+	}
+	if !isValidLogLevel(c.LogLevel) {
+		add("log_level: неизвестный уровень %q, допустимы debug, info, warn, error", c.LogLevel)
+	}
+	if c.CacheUpdateInterval <= 0 {
+		add("cache_update_interval: должен быть больше нуля, получено %d", c.CacheUpdateInterval)
+	}
+	if c.CacheTTL <= 0 {
+		add("cache_ttl: должен быть больше нуля, получено %d", c.CacheTTL)
+	}
+	if c.CacheRefreshTTL <= 0 {
+		add("cache_refresh_ttl: должен быть больше нуля, получено %d", c.CacheRefreshTTL)
+	}
+	if c.ConfigWatchInterval < 0 {
+		add("config_watch_interval: не может быть отрицательным, получено %d", c.ConfigWatchInterval)
+	}
+	if c.RateLimit.Enabled {
+		if c.RateLimit.RequestsPerSecond <= 0 {
+			add("rate_limit.requests_per_second: должен быть больше нуля, получено %v", c.RateLimit.RequestsPerSecond)
+		}
+		if c.RateLimit.Burst <= 0 {
+			add("rate_limit.burst: должен быть больше нуля, получено %d", c.RateLimit.Burst)
+		}
+	}
+
+	if c.Search.CacheTTL <= 0 {
+		add("search.cache_ttl: должен быть больше нуля, получено %d", c.Search.CacheTTL)
+	}
+	if c.Search.SuggestRebuildInterval <= 0 {
+		add("search.suggest_rebuild_interval: должен быть больше нуля, получено %d", c.Search.SuggestRebuildInterval)
+	}
+	if c.RecommendRebuildInterval <= 0 {
+		add("recommend_rebuild_interval: должен быть больше нуля, получено %d", c.RecommendRebuildInterval)
+	}
+	if c.PriceScheduleInterval <= 0 {
+		add("price_schedule_interval: должен быть больше нуля, получено %d", c.PriceScheduleInterval)
+	}
+
+	switch c.Driver {
+	case "mysql":
+		errs = append(errs, c.MySQL.validate()...)
+	case "postgres":
+		errs = append(errs, c.Postgres.validate()...)
+	case "sqlite":
+		errs = append(errs, c.SQLite.validate()...)
+	default:
+		add("driver: неизвестное хранилище %q, допустимы mysql, postgres, sqlite", c.Driver)
+	}
+
+	switch c.CacheDriver {
+	case "redis":
+		errs = append(errs, c.Redis.validate()...)
+	case "memory":
+	default:
+		add("cache_driver: неизвестный кеш %q, допустимы redis, memory", c.CacheDriver)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+func (c *MySQLConfig) validate() []error {
+	var errs []error
+	if strings.TrimSpace(c.Host) == "" {
+		errs = append(errs, errors.New("mysql.host: обязательное поле"))
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("mysql.port: недопустимый порт %d", c.Port))
+	}
+	if strings.TrimSpace(c.User) == "" {
+		errs = append(errs, errors.New("mysql.user: обязательное поле"))
+	}
+	if strings.TrimSpace(c.Database) == "" {
+		errs = append(errs, errors.New("mysql.database: обязательное поле"))
+	}
+	if c.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("mysql.max_open_conns: не может быть отрицательным, получено %d", c.MaxOpenConns))
+	}
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("mysql.max_idle_conns: не может быть отрицательным, получено %d", c.MaxIdleConns))
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("mysql.max_idle_conns: %d превышает max_open_conns %d", c.MaxIdleConns, c.MaxOpenConns))
+	}
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"conn_max_lifetime", c.ConnMaxLifetime},
+		{"conn_max_idle_time", c.ConnMaxIdleTime},
+		{"dial_timeout", c.DialTimeout},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"connect_retries", c.ConnectRetries},
+		{"connect_retry_delay", c.ConnectRetryDelay},
+		{"connect_retry_max_delay", c.ConnectRetryMaxDelay},
+		{"tx_max_retries", c.TxMaxRetries},
+		{"replica_max_lag", c.ReplicaMaxLag},
+	} {
+		if field.value < 0 {
+			errs = append(errs, fmt.Errorf("mysql.%s: не может быть отрицательным, получено %d", field.name, field.value))
+		}
+	}
+	for _, address := range c.Replicas {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			errs = append(errs, fmt.Errorf("mysql.replicas: некорректный адрес %q: %v", address, err))
+		}
+	}
+	if len(c.Replicas) > 0 && c.ReplicaHealthCheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("mysql.replica_health_check_interval: должен быть больше нуля, получено %d", c.ReplicaHealthCheckInterval))
+	}
+	switch c.TLS.Mode {
+	case "", "disabled", "preferred", "skip-verify", "verify":
+	default:
+		errs = append(errs, fmt.Errorf("mysql.tls.mode: неизвестный режим %q, допустимы disabled, preferred, skip-verify, verify", c.TLS.Mode))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("mysql.tls: cert_file и key_file задаются вместе"))
+	}
+	return errs
+}
+
+func (c *PostgresConfig) validate() []error {
+	var errs []error
+	if strings.TrimSpace(c.Host) == "" {
+		errs = append(errs, errors.New("postgres.host: обязательное поле"))
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("postgres.port: недопустимый порт %d", c.Port))
+	}
+	if strings.TrimSpace(c.User) == "" {
+		errs = append(errs, errors.New("postgres.user: обязательное поле"))
+	}
+	if strings.TrimSpace(c.Database) == "" {
+		errs = append(errs, errors.New("postgres.database: обязательное поле"))
+	}
+	switch c.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("postgres.sslmode: неизвестный режим %q", c.SSLMode))
+	}
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"max_open_conns", c.MaxOpenConns},
+		{"max_idle_conns", c.MaxIdleConns},
+		{"conn_max_lifetime", c.ConnMaxLifetime},
+		{"connect_timeout", c.ConnectTimeout},
+		{"connect_retries", c.ConnectRetries},
+		{"connect_retry_delay", c.ConnectRetryDelay},
+		{"tx_max_retries", c.TxMaxRetries},
+	} {
+		if field.value < 0 {
+			errs = append(errs, fmt.Errorf("postgres.%s: не может быть отрицательным, получено %d", field.name, field.value))
+		}
+	}
+	return errs
+}
+
+func (c *SQLiteConfig) validate() []error {
+	var errs []error
+	if strings.TrimSpace(c.Path) == "" {
+		errs = append(errs, errors.New("sqlite.path: обязательное поле"))
+	}
+	if c.MaxOpenConns <= 0 {
+		errs = append(errs, fmt.Errorf("sqlite.max_open_conns: должен быть больше нуля, получено %d", c.MaxOpenConns))
+	}
+	if c.BusyTimeout < 0 {
+		errs = append(errs, fmt.Errorf("sqlite.busy_timeout: не может быть отрицательным, получено %d", c.BusyTimeout))
+	}
+	if c.TxMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("sqlite.tx_max_retries: не может быть отрицательным, получено %d", c.TxMaxRetries))
+	}
+	return errs
+}
+
+func (c *RedisConfig) validate() []error {
+	var errs []error
+	switch c.Mode {
+	case "single", "":
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			errs = append(errs, fmt.Errorf("redis.address: некорректный адрес %q: %v", c.Address, err))
+		}
+	case "sentinel", "cluster":
+		if len(c.Addresses) == 0 {
+			errs = append(errs, fmt.Errorf("redis.addresses: в режиме %s нужен хотя бы один адрес", c.Mode))
+		}
+		for _, address := range c.Addresses {
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				errs = append(errs, fmt.Errorf("redis.addresses: некорректный адрес %q: %v", address, err))
+			}
+		}
+		if c.Mode == "sentinel" && strings.TrimSpace(c.MasterName) == "" {
+			errs = append(errs, errors.New("redis.master_name: обязательное поле в режиме sentinel"))
+		}
+		if c.Mode == "cluster" && c.DB != 0 {
+			errs = append(errs, errors.New("redis.db: в режиме cluster доступна только база 0"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("redis.mode: неизвестный режим %q, допустимы single, sentinel, cluster", c.Mode))
+	}
+	if c.DB < 0 {
+		errs = append(errs, fmt.Errorf("redis.db: номер базы не может быть отрицательным, получено %d", c.DB))
+	}
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"pool_size", c.PoolSize},
+		{"min_idle_conns", c.MinIdleConns},
+		{"dial_timeout", c.DialTimeout},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"pool_timeout", c.PoolTimeout},
+	} {
+		if field.value < 0 {
+			errs = append(errs, fmt.Errorf("redis.%s: не может быть отрицательным, получено %d", field.name, field.value))
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("redis.tls: cert_file и key_file задаются вместе"))
+	}
+	return errs
+}
+
+func isValidLogLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+// ValidationError содержит все ошибки валидации конфигурации
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = "  - " + err.Error()
+	}
+	return "некорректная конфигурация:\n" + strings.Join(lines, "\n")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
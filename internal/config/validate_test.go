@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig возвращает минимальную корректную конфигурацию для драйвера mysql
+func validConfig() *Config {
+	config := Default()
+	config.MySQL.User = "app"
+	config.MySQL.Database = "shop"
+	return config
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// want - фрагменты, которые должны быть в ошибке; пусто - конфигурация корректна
+		want []string
+	}{
+		{"по умолчанию", func(c *Config) {}, nil},
+		{"sqlite без mysql", func(c *Config) { c.Driver = "sqlite"; c.MySQL = MySQLConfig{} }, nil},
+		{"уровень логирования", func(c *Config) { c.LogLevel = "verbose" }, []string{"log_level"}},
+		{"адрес сервера", func(c *Config) { c.ServerAddress = "8080" }, []string{"server_address"}},
+		{"интервалы", func(c *Config) { c.CacheTTL = 0; c.Search.CacheTTL = -1 }, []string{"cache_ttl:", "search.cache_ttl"}},
+		{"rate limit выключен", func(c *Config) { c.RateLimit.Burst = 0 }, nil},
+		{"rate limit", func(c *Config) { c.RateLimit.Enabled = true; c.RateLimit.Burst = 0 }, []string{"rate_limit.burst"}},
+		{"драйвер", func(c *Config) { c.Driver = "oracle" }, []string{"driver"}},
+		{"mysql обязательные поля", func(c *Config) { c.MySQL.User = " "; c.MySQL.Port = 0 }, []string{"mysql.user", "mysql.port"}},
+		{"mysql пул", func(c *Config) { c.MySQL.MaxIdleConns = 50 }, []string{"mysql.max_idle_conns"}},
+		{"mysql реплики", func(c *Config) { c.MySQL.Replicas = []string{"replica"} }, []string{"mysql.replicas"}},
+		{"mysql tls", func(c *Config) { c.MySQL.TLS.Mode = "strict"; c.MySQL.TLS.CertFile = "cert.pem" }, []string{"mysql.tls.mode", "mysql.tls:"}},
+		{"postgres", func(c *Config) { c.Driver = "postgres"; c.Postgres.SSLMode = "on" }, []string{"postgres.user", "postgres.database", "postgres.sslmode"}},
+		{"sqlite", func(c *Config) { c.Driver = "sqlite"; c.SQLite.MaxOpenConns = 0 }, []string{"sqlite.max_open_conns"}},
+		{"кеш", func(c *Config) { c.CacheDriver = "memcached" }, []string{"cache_driver"}},
+		{"redis sentinel", func(c *Config) { c.Redis.Mode = "sentinel" }, []string{"redis.addresses", "redis.master_name"}},
+		{"redis cluster", func(c *Config) { c.Redis = RedisConfig{Mode: "cluster", Addresses: []string{"n1:6379"}, DB: 1} }, []string{"redis.db"}},
+		{"redis в памяти не проверяется", func(c *Config) { c.CacheDriver = "memory"; c.Redis.Mode = "unknown" }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.modify(config)
+			err := config.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate = %v, ожидалась ValidationError", err)
+			}
+			// Все проблемы возвращаются разом, по одной на строку
+			if len(validationErr.Errors) != len(tt.want) {
+				t.Errorf("ошибок %d, ожидалось %d:\n%v", len(validationErr.Errors), len(tt.want), err)
+			}
+			for _, fragment := range tt.want {
+				if !strings.Contains(err.Error(), fragment) {
+					t.Errorf("в ошибке нет %q:\n%v", fragment, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "password")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	data := "log_level: debug\nmysql:\n  user: app\n  database: shop\n  password: plain\n  password_file: " + secret + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Окружение перекрывает файл
+	t.Setenv("APP_LOG_LEVEL", "warn")
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if config.LogLevel != "warn" || config.MySQL.User != "app" || config.MySQL.Password != "s3cret" {
+		t.Errorf("Load: log_level %q, user %q, password %q", config.LogLevel, config.MySQL.User, config.MySQL.Password)
+	}
+
+	t.Setenv("APP_LOG_LEVEL", "loud")
+	if _, err := Load(path); err == nil {
+		t.Error("Load с некорректным уровнем логирования завершился без ошибки")
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Load с отсутствующим явно указанным файлом завершился без ошибки")
+	}
+}
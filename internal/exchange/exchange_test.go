@@ -0,0 +1,262 @@
+package exchange_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/exchange"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newProductService() *service.ProductService {
+	db := memory.NewDB()
+	return service.NewProductService(memory.NewProductRepository(db), memory.NewProductCache(), memory.NewTxManager(db))
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	products := []*models.Product{
+		{ID: 1, Name: "Чайник", Description: "с запятой, и \"кавычками\"", Price: 1299.9, Quantity: 3, Version: 1, CreatedAt: created},
+		{ID: 2, Name: "Кружка", Price: 250, Quantity: 10, Version: 2, CreatedAt: created},
+	}
+
+	for _, format := range []string{exchange.FormatCSV, exchange.FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder, err := exchange.NewEncoder[models.Product](&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, product := range products {
+				if err := encoder.Encode(product); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := encoder.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if format == exchange.FormatCSV && !strings.HasPrefix(buf.String(), "id,name,description,price,quantity,version,") {
+				t.Errorf("заголовок CSV: %q", strings.SplitN(buf.String(), "\n", 2)[0])
+			}
+
+			records, err := exchange.NewRecordReader(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, product := range products {
+				record, _, err := records.Next()
+				if err != nil {
+					t.Fatalf("запись %d: %v", i, err)
+				}
+				if record["name"] != product.Name || record["description"] != product.Description ||
+					record["price"] != strconv.FormatFloat(product.Price, 'f', -1, 64) {
+					t.Errorf("запись %d: %v", i, record)
+				}
+				if record["created_at"] != created.Format(time.RFC3339) {
+					t.Errorf("запись %d: created_at = %q", i, record["created_at"])
+				}
+			}
+			if _, _, err := records.Next(); err != io.EOF {
+				t.Errorf("после последней записи: %v, ожидался io.EOF", err)
+			}
+		})
+	}
+}
+
+// Необязательное поле-указатель пишется значением, а отсутствующее - пустой ячейкой
+func TestEncoderOptionalField(t *testing.T) {
+	variantID := int64(7)
+	var buf bytes.Buffer
+	encoder, err := exchange.NewEncoder[models.Purchase](&buf, exchange.FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, purchase := range []*models.Purchase{{ID: 1, VariantID: &variantID}, {ID: 2}} {
+		if err := encoder.Encode(purchase); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := exchange.NewRecordReader(&buf, exchange.FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"7", ""} {
+		record, _, err := records.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record["variant_id"] != want {
+			t.Errorf("variant_id = %q, ожидалось %q", record["variant_id"], want)
+		}
+	}
+}
+
+func TestEncoderEmbeddedFields(t *testing.T) {
+	var buf bytes.Buffer
+	encoder, err := exchange.NewEncoder[models.ReportRow](&buf, exchange.FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := &models.ReportRow{Key: "2026-10-01", ReportTotals: models.ReportTotals{Orders: 3, Revenue: 99.5}}
+	if err := encoder.Encode(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "key,label,orders,completed,cancelled,units,revenue,average_order_value,cancellation_rate\n" +
+		"2026-10-01,,3,0,0,0,99.5,0,0\n"
+	if buf.String() != want {
+		t.Errorf("CSV:\n%s\nожидалось:\n%s", buf.String(), want)
+	}
+}
+
+func TestRecordReaderExcelCSV(t *testing.T) {
+	// Выгрузка из Excel в русской локали: BOM, точка с запятой, десятичная запятая
+	src := "\ufeffНазвание;Цена;Остаток\r\nЧайник;1 299,90;3\r\n;;\r\n"
+	records, err := exchange.NewRecordReader(strings.NewReader(src), exchange.FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, line, err := records.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != 2 || record["Название"] != "Чайник" || record["Цена"] != "1 299,90" || record["Остаток"] != "3" {
+		t.Errorf("строка %d: %v", line, record)
+	}
+	record, _, err = records.Next()
+	if err != nil || len(record) != 0 {
+		t.Errorf("пустая строка: (%v, %v)", record, err)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	fields := []string{"name", "price"}
+	mapping, err := exchange.ParseMapping([]string{"name=Название, price=Цена"}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping["name"] != "Название" || mapping["price"] != "Цена" {
+		t.Errorf("сопоставление: %v", mapping)
+	}
+
+	for _, pairs := range [][]string{{"name"}, {"color=Цвет"}, {"=Цена"}} {
+		if _, err := exchange.ParseMapping(pairs, fields); err == nil {
+			t.Errorf("ParseMapping(%v): ожидалась ошибка", pairs)
+		}
+	}
+}
+
+func TestImportProducts(t *testing.T) {
+	ctx := context.Background()
+	products := newProductService()
+	if _, err := products.CreateProduct(ctx, &models.Product{Name: "Чайник", Price: 1000, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := products.CreateProduct(ctx, &models.Product{Name: "Кружка", Price: 250, Quantity: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	src := "Название;Цена;Остаток\n" +
+		"Чайник;1 299,90;3\n" + // обновление
+		"Кружка;250;10\n" + // без изменений
+		"Блюдце;99,5;7\n" + // новый товар
+		"Ложка;-1;1\n" + // ошибка проверки
+		"Блюдце;100;1\n" // повтор ключа
+	opts := exchange.ImportOptions{Format: exchange.FormatCSV, Mapping: []string{"name=Название,price=Цена,quantity=Остаток"}, DryRun: true}
+
+	report, err := exchange.ImportProducts(ctx, products, strings.NewReader(src), opts)
+	if err != nil {
+		t.Fatalf("dry-run: %v", err)
+	}
+	if report.Total != 5 || report.Created != 1 || report.Updated != 1 || report.Unchanged != 1 || report.Failed != 2 {
+		t.Errorf("dry-run: %+v", report)
+	}
+	if len(report.Errors) != 2 || report.Errors[0].Line != 5 || report.Errors[1].Line != 6 || report.Errors[1].Key != "Блюдце" {
+		t.Errorf("ошибки dry-run: %+v", report.Errors)
+	}
+	if found, _ := products.GetProductsByName(ctx, "Блюдце"); len(found) != 0 {
+		t.Fatal("dry-run не должен ничего записывать")
+	}
+
+	opts.DryRun = false
+	report, err = exchange.ImportProducts(ctx, products, strings.NewReader(src), opts)
+	if err != nil {
+		t.Fatalf("импорт: %v", err)
+	}
+	if report.Created != 1 || report.Updated != 1 || report.Unchanged != 1 || report.Failed != 2 {
+		t.Errorf("импорт: %+v", report)
+	}
+	kettle, _ := products.GetProductsByName(ctx, "Чайник")
+	if len(kettle) != 1 || kettle[0].Price != 1299.9 || kettle[0].Quantity != 3 || kettle[0].Version != 2 {
+		t.Errorf("обновленный товар: %+v", kettle)
+	}
+	saucer, _ := products.GetProductsByName(ctx, "Блюдце")
+	if len(saucer) != 1 || saucer[0].Price != 99.5 {
+		t.Errorf("новый товар: %+v", saucer)
+	}
+
+	// Повторный импорт того же файла ничего не меняет
+	report, err = exchange.ImportProducts(ctx, products, strings.NewReader(src), opts)
+	if err != nil || report.Created != 0 || report.Updated != 0 || report.Unchanged != 3 {
+		t.Errorf("повторный импорт: (%+v, %v)", report, err)
+	}
+}
+
+func TestImportProductsByID(t *testing.T) {
+	ctx := context.Background()
+	products := newProductService()
+	id, err := products.CreateProduct(ctx, &models.Product{Name: "Чайник", Price: 1000, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `{"id": ` + strconv.FormatInt(id, 10) + `, "name": "Чайник электрический"}
+{"id": 999999, "quantity": 1}
+{"name": "Кружка", "price": 250}
+not json
+`
+	report, err := exchange.ImportProducts(ctx, products, strings.NewReader(src), exchange.ImportOptions{Format: exchange.FormatJSONL, Key: exchange.KeyID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 4 || report.Created != 1 || report.Updated != 1 || report.Failed != 2 {
+		t.Errorf("отчет: %+v", report)
+	}
+	stored, _ := products.GetProduct(ctx, id)
+	if stored.Name != "Чайник электрический" || stored.Price != 1000 {
+		t.Errorf("частичное обновление по id: %+v", stored)
+	}
+}
+
+func TestImportProductsRejectsFile(t *testing.T) {
+	ctx := context.Background()
+	products := newProductService()
+
+	cases := map[string]exchange.ImportOptions{
+		"unknown key":    {Key: "sku"},
+		"missing column": {Mapping: []string{"price=Цена"}},
+	}
+	for name, opts := range cases {
+		report, err := exchange.ImportProducts(ctx, products, strings.NewReader("name,price\nЧайник,1\n"), opts)
+		if err == nil || report != nil {
+			t.Errorf("%s: ожидалась ошибка без отчета, получено (%+v, %v)", name, report, err)
+		}
+	}
+	if _, err := exchange.ImportProducts(ctx, products, strings.NewReader(""), exchange.ImportOptions{}); err == nil {
+		t.Error("пустой CSV: ожидалась ошибка")
+	}
+}
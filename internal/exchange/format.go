@@ -0,0 +1,148 @@
+// Package exchange - выгрузка и загрузка данных в CSV и JSONL (по одному JSON-объекту на строку).
+// Записи кодируются и читаются потоком, по одной, чтобы размер файла не ограничивался памятью.
+package exchange
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы файлов
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// ParseFormat проверяет название формата; пустое означает CSV
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatJSONL, "ndjson":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("неизвестный формат: %s (ожидается %s или %s)", format, FormatCSV, FormatJSONL)
+	}
+}
+
+// ContentType - MIME-тип файла формата
+func ContentType(format string) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Encoder пишет модели T в CSV или JSONL. Столбцы CSV - поля модели с тегом json в порядке объявления,
+// поэтому выгрузка совпадает по названиям полей с API.
+type Encoder[T any] struct {
+	csv     *csv.Writer
+	json    *json.Encoder
+	columns []column
+}
+
+type column struct {
+	name  string
+	index []int
+}
+
+// NewEncoder создает кодировщик; для CSV сразу пишется строка заголовка
+func NewEncoder[T any](w io.Writer, format string) (*Encoder[T], error) {
+	if format == FormatJSONL {
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		return &Encoder[T]{json: encoder}, nil
+	}
+
+	e := &Encoder[T]{csv: csv.NewWriter(w), columns: columnsOf(reflect.TypeOf((*T)(nil)).Elem())}
+	header := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		header[i] = c.name
+	}
+	if err := e.csv.Write(header); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Encode записывает одну модель
+func (e *Encoder[T]) Encode(item *T) error {
+	if e.json != nil {
+		return e.json.Encode(item)
+	}
+
+	value := reflect.ValueOf(item).Elem()
+	record := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		record[i] = formatValue(value.FieldByIndex(c.index))
+	}
+	return e.csv.Write(record)
+}
+
+// Flush дописывает буферизованные данные в io.Writer
+func (e *Encoder[T]) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func columnsOf(t reflect.Type) []column {
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		// Встроенная структура без имени в json раскрывается своими полями, как в encoding/json
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for _, c := range columnsOf(field.Type) {
+				columns = append(columns, column{name: c.name, index: append([]int{i}, c.index...)})
+			}
+			continue
+		}
+		if !field.IsExported() || name == "-" || name == "" {
+			continue
+		}
+		columns = append(columns, column{name: name, index: []int{i}})
+	}
+	return columns
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// formatValue записывает значение так, чтобы его можно было прочитать обратно при импорте
+func formatValue(v reflect.Value) string {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "" // Необязательное поле, например variant_id покупки без варианта
+		}
+		return formatValue(v.Elem())
+	case reflect.String:
+		return v.String()
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Slice, reflect.Map:
+		// Вложенные значения, например скидки покупки, пишутся в ячейку JSON-текстом
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,380 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Естественные ключи, по которым импорт находит существующий товар
+const (
+	KeyName = "name"
+	KeyID   = "id"
+)
+
+// productFields - поля товара, которые можно загрузить
+var productFields = []string{"id", "name", "description", "price", "quantity"}
+
+const (
+	// importBatchSize - сколько строк накапливается перед записью одной пакетной операцией
+	importBatchSize = 500
+	// maxImportErrors - сколько ошибок попадает в отчет; остальные только считаются
+	maxImportErrors = 1000
+)
+
+// ImportOptions - параметры импорта товаров
+type ImportOptions struct {
+	Format string
+	// Key - естественный ключ: строки с найденным по нему товаром обновляют его, остальные создают новый
+	Key string
+	// Mapping - пары "поле=столбец" (можно через запятую): из какого столбца файла брать поле товара.
+	// Без сопоставления столбец называется так же, как поле.
+	Mapping []string
+	// DryRun - только проверить файл и посчитать изменения, ничего не записывая
+	DryRun bool
+}
+
+// ProductStore - то, что импорт использует из сервиса товаров
+type ProductStore interface {
+	GetProduct(ctx context.Context, id int64) (*models.Product, error)
+	GetProductsByName(ctx context.Context, name string) ([]*models.Product, error)
+	BulkCreateProducts(ctx context.Context, products []*models.Product, atomic bool) ([]error, error)
+	BulkUpdateProducts(ctx context.Context, products []*models.Product, atomic bool) ([]error, error)
+}
+
+// ParseMapping строит сопоставление поле -> столбец из пар "поле=столбец"
+func ParseMapping(pairs []string, fields []string) (map[string]string, error) {
+	mapping := make(map[string]string, len(fields))
+	for _, field := range fields {
+		mapping[field] = field
+	}
+
+	for _, list := range pairs {
+		for _, pair := range strings.Split(list, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			field, column, ok := strings.Cut(pair, "=")
+			field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+			if !ok || field == "" || column == "" {
+				return nil, fmt.Errorf("сопоставление %q: ожидается поле=столбец", pair)
+			}
+			if _, known := mapping[field]; !known {
+				return nil, fmt.Errorf("неизвестное поле %s (доступны: %s)", field, strings.Join(fields, ", "))
+			}
+			mapping[field] = column
+		}
+	}
+	return mapping, nil
+}
+
+// ImportProducts загружает товары из CSV или JSONL: строки читаются потоком, проверяются,
+// сопоставляются с существующими товарами по ключу и записываются пачками в режиме best_effort.
+// Ошибки строк попадают в отчет; ошибка возвращается, только если файл нельзя обработать вовсе.
+func ImportProducts(ctx context.Context, store ProductStore, src io.Reader, opts ImportOptions) (*models.ImportReport, error) {
+	if opts.Key == "" {
+		opts.Key = KeyName
+	}
+	if opts.Key != KeyName && opts.Key != KeyID {
+		return nil, fmt.Errorf("неизвестный ключ: %s (ожидается %s или %s)", opts.Key, KeyName, KeyID)
+	}
+	mapping, err := ParseMapping(opts.Mapping, productFields)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := NewRecordReader(src, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkColumns(records.header, mapping, opts); err != nil {
+		return nil, err
+	}
+
+	im := &productImport{
+		store:  store,
+		report: &models.ImportReport{DryRun: opts.DryRun, Key: opts.Key, Errors: []models.ImportError{}},
+	}
+	// Ключи, уже встреченные в файле: вторая строка с тем же ключом - ошибка, а не второе обновление
+	seen := make(map[string]int)
+
+	for {
+		record, line, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		im.report.Total++
+		if err != nil {
+			im.fail(line, "", err)
+			continue
+		}
+
+		row, err := parseProductRow(record, mapping)
+		if err != nil {
+			im.fail(line, "", err)
+			continue
+		}
+		key, err := row.key(opts.Key)
+		if err != nil {
+			im.fail(line, "", err)
+			continue
+		}
+		if key != "" {
+			if previous, ok := seen[key]; ok {
+				im.fail(line, key, fmt.Errorf("ключ уже встречался в строке %d", previous))
+				continue
+			}
+			seen[key] = line
+		}
+
+		existing, err := im.lookup(ctx, opts.Key, row)
+		if err != nil {
+			im.fail(line, key, err)
+			continue
+		}
+		product, err := row.apply(existing)
+		if err != nil {
+			im.fail(line, key, err)
+			continue
+		}
+
+		pending := pendingProduct{line: line, key: key, product: product}
+		switch {
+		case existing == nil:
+			im.creates = append(im.creates, pending)
+		case sameProduct(existing, product):
+			im.report.Unchanged++
+		default:
+			im.updates = append(im.updates, pending)
+		}
+
+		if len(im.creates)+len(im.updates) >= importBatchSize {
+			if err := im.flush(ctx); err != nil {
+				return im.report, err
+			}
+		}
+	}
+
+	if err := im.flush(ctx); err != nil {
+		return im.report, err
+	}
+	return im.report, nil
+}
+
+// checkColumns проверяет, что в заголовке CSV есть явно сопоставленные столбцы и столбец ключа
+func checkColumns(header []string, mapping map[string]string, opts ImportOptions) error {
+	if header == nil {
+		return nil // JSONL: набор полей у каждой строки свой
+	}
+
+	present := make(map[string]bool, len(header))
+	for _, name := range header {
+		present[name] = true
+	}
+	required := map[string]bool{opts.Key: true}
+	for _, list := range opts.Mapping {
+		for _, pair := range strings.Split(list, ",") {
+			if field, _, ok := strings.Cut(pair, "="); ok {
+				required[strings.TrimSpace(field)] = true
+			}
+		}
+	}
+	for _, field := range productFields {
+		if required[field] && !present[mapping[field]] {
+			return fmt.Errorf("в файле нет столбца %s для поля %s", mapping[field], field)
+		}
+	}
+	return nil
+}
+
+// productRow - значения одной строки файла; nil - значения в строке нет
+type productRow struct {
+	id          *int64
+	name        *string
+	description *string
+	price       *float64
+	quantity    *int
+}
+
+func parseProductRow(record map[string]string, mapping map[string]string) (*productRow, error) {
+	row := &productRow{}
+	if value, ok := record[mapping["id"]]; ok {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("id: некорректное значение %q", value)
+		}
+		row.id = &id
+	}
+	if value, ok := record[mapping["name"]]; ok {
+		row.name = &value
+	}
+	if value, ok := record[mapping["description"]]; ok {
+		row.description = &value
+	}
+	if value, ok := record[mapping["price"]]; ok {
+		price, err := parseDecimal(value)
+		if err != nil {
+			return nil, fmt.Errorf("price: некорректное число %q", value)
+		}
+		row.price = &price
+	}
+	if value, ok := record[mapping["quantity"]]; ok {
+		quantity, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("quantity: некорректное целое число %q", value)
+		}
+		row.quantity = &quantity
+	}
+	return row, nil
+}
+
+// parseDecimal разбирает число, в том числе в русской записи: "1 299,90"
+func parseDecimal(value string) (float64, error) {
+	value = strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(value)
+	return strconv.ParseFloat(value, 64)
+}
+
+// key - значение естественного ключа строки; пустое, если строка по ключу id описывает новый товар
+func (r *productRow) key(key string) (string, error) {
+	if key == KeyID {
+		if r.id == nil {
+			return "", nil
+		}
+		return strconv.FormatInt(*r.id, 10), nil
+	}
+	if r.name == nil {
+		return "", errors.New("не задан ключ name")
+	}
+	return *r.name, nil
+}
+
+// apply накладывает значения строки на существующий товар или, если его нет, создает новый
+func (r *productRow) apply(existing *models.Product) (*models.Product, error) {
+	product := &models.Product{}
+	if existing != nil {
+		*product = *existing
+	} else if r.name == nil || r.price == nil {
+		return nil, errors.New("для нового товара обязательны name и price")
+	}
+
+	if r.name != nil {
+		product.Name = *r.name
+	}
+	if r.description != nil {
+		product.Description = *r.description
+	}
+	if r.price != nil {
+		product.Price = *r.price
+	}
+	if r.quantity != nil {
+		product.Quantity = *r.quantity
+	}
+
+	request := models.ProductCreateRequest{Name: product.Name, Price: product.Price, Quantity: product.Quantity}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func sameProduct(a, b *models.Product) bool {
+	return a.Name == b.Name && a.Description == b.Description && a.Price == b.Price && a.Quantity == b.Quantity
+}
+
+type pendingProduct struct {
+	line    int
+	key     string
+	product *models.Product
+}
+
+type productImport struct {
+	store   ProductStore
+	report  *models.ImportReport
+	creates []pendingProduct
+	updates []pendingProduct
+}
+
+// lookup находит товар, который обновляет строка; nil - строка создает новый товар
+func (im *productImport) lookup(ctx context.Context, key string, row *productRow) (*models.Product, error) {
+	if key == KeyID {
+		if row.id == nil {
+			return nil, nil
+		}
+		product, err := im.store.GetProduct(ctx, *row.id)
+		if err != nil {
+			return nil, err
+		}
+		if product == nil {
+			return nil, fmt.Errorf("товар с id %d не найден", *row.id)
+		}
+		return product, nil
+	}
+
+	products, err := im.store.GetProductsByName(ctx, *row.name)
+	if err != nil {
+		return nil, err
+	}
+	switch len(products) {
+	case 0:
+		return nil, nil
+	case 1:
+		return products[0], nil
+	default:
+		return nil, fmt.Errorf("название неоднозначно: найдено товаров - %d, используйте ключ id", len(products))
+	}
+}
+
+// flush записывает накопленные строки; при DryRun только считает их
+func (im *productImport) flush(ctx context.Context) error {
+	creates, updates := im.creates, im.updates
+	im.creates, im.updates = nil, nil
+
+	if im.report.DryRun {
+		im.report.Created += len(creates)
+		im.report.Updated += len(updates)
+		return nil
+	}
+
+	if err := im.write(ctx, creates, im.store.BulkCreateProducts, &im.report.Created); err != nil {
+		return err
+	}
+	return im.write(ctx, updates, im.store.BulkUpdateProducts, &im.report.Updated)
+}
+
+func (im *productImport) write(ctx context.Context, pending []pendingProduct,
+	run func(ctx context.Context, products []*models.Product, atomic bool) ([]error, error), written *int,
+) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	products := make([]*models.Product, len(pending))
+	for i, p := range pending {
+		products[i] = p.product
+	}
+	errs, err := run(ctx, products, false)
+	if err != nil {
+		return err
+	}
+	for i, err := range errs {
+		if err != nil {
+			im.fail(pending[i].line, pending[i].key, err)
+			continue
+		}
+		*written++
+	}
+	return nil
+}
+
+func (im *productImport) fail(line int, key string, err error) {
+	im.report.Failed++
+	if len(im.report.Errors) >= maxImportErrors {
+		im.report.ErrorsTruncated = true
+		return
+	}
+	im.report.Errors = append(im.report.Errors, models.ImportError{Line: line, Key: key, Error: err.Error()})
+}
@@ -0,0 +1,147 @@
+package exchange
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxLineSize - наибольшая длина строки JSONL
+const maxLineSize = 1 << 20
+
+// utf8BOM добавляют в начало CSV табличные редакторы
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// RecordReader читает файл импорта по одной записи. Запись - значения столбцов по их названиям;
+// пустое значение равносильно отсутствию столбца.
+type RecordReader struct {
+	csv    *csv.Reader
+	header []string
+
+	lines *bufio.Scanner
+	line  int
+}
+
+// NewRecordReader создает читателя. Для CSV сразу читается заголовок; разделитель (запятая или
+// точка с запятой, как в русской локали Excel) определяется по первой строке, BOM пропускается.
+func NewRecordReader(r io.Reader, format string) (*RecordReader, error) {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+
+	if format == FormatJSONL {
+		lines := bufio.NewScanner(br)
+		lines.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &RecordReader{lines: lines}, nil
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = detectComma(br)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("файл пуст: нет строки заголовка")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("заголовок CSV: %w", err)
+	}
+	names := make([]string, len(header))
+	for i, name := range header {
+		names[i] = strings.TrimSpace(name)
+	}
+	return &RecordReader{csv: reader, header: names}, nil
+}
+
+// detectComma выбирает разделитель CSV по первой строке, не извлекая ее из буфера
+func detectComma(br *bufio.Reader) rune {
+	first, _ := br.Peek(br.Size())
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// Next возвращает следующую запись и номер ее строки в файле; в конце файла - io.EOF.
+// Ошибка разбора одной записи не мешает читать следующие.
+func (r *RecordReader) Next() (map[string]string, int, error) {
+	if r.csv != nil {
+		return r.nextCSV()
+	}
+	return r.nextJSON()
+}
+
+func (r *RecordReader) nextCSV() (map[string]string, int, error) {
+	values, err := r.csv.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	line, _ := r.csv.FieldPos(0)
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+		}
+		return nil, line, err
+	}
+
+	record := make(map[string]string, len(r.header))
+	for i, name := range r.header {
+		if i < len(values) {
+			if value := strings.TrimSpace(values[i]); value != "" {
+				record[name] = value
+			}
+		}
+	}
+	return record, line, nil
+}
+
+func (r *RecordReader) nextJSON() (map[string]string, int, error) {
+	for r.lines.Scan() {
+		r.line++
+		text := bytes.TrimSpace(r.lines.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var fields map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err != nil || fields == nil {
+			return nil, r.line, errors.New("строка должна быть JSON-объектом")
+		}
+
+		record := make(map[string]string, len(fields))
+		for name, value := range fields {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				if v = strings.TrimSpace(v); v != "" {
+					record[name] = v
+				}
+			case json.Number:
+				record[name] = v.String()
+			case bool:
+				record[name] = strconv.FormatBool(v)
+			default:
+				return nil, r.line, fmt.Errorf("поле %s: ожидается строка или число", name)
+			}
+		}
+		return record, r.line, nil
+	}
+	if err := r.lines.Err(); err != nil {
+		return nil, r.line + 1, err
+	}
+	return nil, 0, io.EOF
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Режимы пакетной обработки
+const (
+	// BulkAtomic - все или ничего: ошибка любого элемента откатывает весь пакет
+	BulkAtomic = "atomic"
+	// BulkBestEffort - элементы сохраняются независимо, ошибочные пропускаются
+	BulkBestEffort = "best_effort"
+)
+
+// MaxBulkItems - сколько элементов можно передать в одном пакетном запросе
+const MaxBulkItems = 10000
+
+// validateBulk проверяет общие параметры пакетного запроса; пустой режим означает BulkAtomic
+func validateBulk(mode *string, items int) error {
+	if *mode == "" {
+		*mode = BulkAtomic
+	}
+	if *mode != BulkAtomic && *mode != BulkBestEffort {
+		return fmt.Errorf("неизвестный режим пакета: %s (ожидается %s или %s)", *mode, BulkAtomic, BulkBestEffort)
+	}
+	if items == 0 {
+		return errors.New("пакет не содержит элементов")
+	}
+	if items > MaxBulkItems {
+		return fmt.Errorf("в пакете не может быть больше %d элементов", MaxBulkItems)
+	}
+	return nil
+}
+
+// validateBulkTarget проверяет идентификатор и ожидаемую версию изменяемой записи.
+// Версия в пакете обязательна по той же причине, что и If-Match у одиночных запросов.
+func validateBulkTarget(id, version int64) error {
+	if id <= 0 {
+		return errors.New("id обязателен")
+	}
+	if version <= 0 {
+		return errors.New("version обязательна")
+	}
+	return nil
+}
+
+// UserBulkCreateRequest - пакетное создание пользователей.
+// Элементы проверяются по отдельности: ошибка одного не делает невалидным весь запрос.
+type UserBulkCreateRequest struct {
+	Mode  string              `json:"mode"`
+	Items []UserCreateRequest `json:"items"`
+}
+
+// Validate реализует интерфейс Request
+func (r *UserBulkCreateRequest) Validate() error {
+	return validateBulk(&r.Mode, len(r.Items))
+}
+
+// UserBulkUpdateItem - замена одного пользователя в пакете: полное представление и ожидаемая версия
+type UserBulkUpdateItem struct {
+	ID       int64   `json:"id"`
+	Version  int64   `json:"version"`
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+}
+
+// Validate реализует интерфейс Request
+func (i *UserBulkUpdateItem) Validate() error {
+	if err := validateBulkTarget(i.ID, i.Version); err != nil {
+		return err
+	}
+	return (&UserUpdateRequest{Username: i.Username, Email: i.Email}).Validate()
+}
+
+// UserBulkUpdateRequest - пакетная замена пользователей
+type UserBulkUpdateRequest struct {
+	Mode  string               `json:"mode"`
+	Items []UserBulkUpdateItem `json:"items"`
+}
+
+// Validate реализует интерфейс Request
+func (r *UserBulkUpdateRequest) Validate() error {
+	return validateBulk(&r.Mode, len(r.Items))
+}
+
+// ProductCreateRequest - товар в пакетном создании
+type ProductCreateRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Quantity    int     `json:"quantity"`
+}
+
+// Validate реализует интерфейс Request
+func (r *ProductCreateRequest) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("название товара обязательно")
+	}
+	return (&ProductPatch{Price: &r.Price, Quantity: &r.Quantity}).Validate()
+}
+
+// ProductBulkCreateRequest - пакетное создание товаров
+type ProductBulkCreateRequest struct {
+	Mode  string                 `json:"mode"`
+	Items []ProductCreateRequest `json:"items"`
+}
+
+// Validate реализует интерфейс Request
+func (r *ProductBulkCreateRequest) Validate() error {
+	return validateBulk(&r.Mode, len(r.Items))
+}
+
+// ProductBulkUpdateItem - замена одного товара в пакете: полное представление и ожидаемая версия
+type ProductBulkUpdateItem struct {
+	ID          int64    `json:"id"`
+	Version     int64    `json:"version"`
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	Quantity    *int     `json:"quantity"`
+}
+
+// Validate реализует интерфейс Request
+func (i *ProductBulkUpdateItem) Validate() error {
+	if err := validateBulkTarget(i.ID, i.Version); err != nil {
+		return err
+	}
+	return (&ProductUpdateRequest{Name: i.Name, Description: i.Description, Price: i.Price, Quantity: i.Quantity}).Validate()
+}
+
+// ProductBulkUpdateRequest - пакетная замена товаров
+type ProductBulkUpdateRequest struct {
+	Mode  string                  `json:"mode"`
+	Items []ProductBulkUpdateItem `json:"items"`
+}
+
+// Validate реализует интерфейс Request
+func (r *ProductBulkUpdateRequest) Validate() error {
+	return validateBulk(&r.Mode, len(r.Items))
+}
+
+// BulkDeleteItem - удаляемая запись и ее ожидаемая версия
+type BulkDeleteItem struct {
+	ID      int64 `json:"id"`
+	Version int64 `json:"version"`
+}
+
+// Validate реализует интерфейс Request
+func (i *BulkDeleteItem) Validate() error {
+	return validateBulkTarget(i.ID, i.Version)
+}
+
+// BulkDeleteRequest - пакетное удаление пользователей или товаров
+type BulkDeleteRequest struct {
+	Mode  string           `json:"mode"`
+	Items []BulkDeleteItem `json:"items"`
+}
+
+// Validate реализует интерфейс Request
+func (r *BulkDeleteRequest) Validate() error {
+	return validateBulk(&r.Mode, len(r.Items))
+}
+
+// BulkResult - результат одного элемента пакета; Status - HTTP-статус, который получил бы одиночный запрос
+type BulkResult struct {
+	Index   int    `json:"index"`
+	Status  int    `json:"status"`
+	ID      int64  `json:"id,omitempty"`
+	Version int64  `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkResponse - ответ пакетной операции; Results идут в порядке элементов запроса
+type BulkResponse struct {
+	Mode      string       `json:"mode"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Category - категория товаров; ParentID = nil у корневой категории
+type Category struct {
+	ID        int64     `json:"id" db:"id"`
+	ParentID  *int64    `json:"parent_id" db:"parent_id"`
+	Name      string    `json:"name" db:"name"`
+	Version   int64     `json:"version" db:"version"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CategoryNode - категория в дереве категорий вместе с подкатегориями
+type CategoryNode struct {
+	ID       int64          `json:"id"`
+	ParentID *int64         `json:"parent_id"`
+	Name     string         `json:"name"`
+	Version  int64          `json:"version"`
+	Children []CategoryNode `json:"children"`
+}
+
+// NewCategoryTree строит дерево из списка категорий: корни и подкатегории упорядочены по id.
+// Категория, родителя которой нет в списке, считается корневой.
+func NewCategoryTree(categories []*Category) []CategoryNode {
+	children := make(map[int64][]*Category)
+	ids := make(map[int64]bool, len(categories))
+	for _, category := range categories {
+		ids[category.ID] = true
+	}
+	var roots []*Category
+	for _, category := range categories {
+		if category.ParentID == nil || !ids[*category.ParentID] {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	var build func(level []*Category) []CategoryNode
+	build = func(level []*Category) []CategoryNode {
+		sort.Slice(level, func(i, j int) bool { return level[i].ID < level[j].ID })
+		nodes := make([]CategoryNode, len(level))
+		for i, category := range level {
+			nodes[i] = CategoryNode{
+				ID:       category.ID,
+				ParentID: category.ParentID,
+				Name:     category.Name,
+				Version:  category.Version,
+				Children: build(children[category.ID]),
+			}
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+// FindCategory ищет категорию в дереве; nil - не найдена
+func FindCategory(tree []CategoryNode, id int64) *CategoryNode {
+	for i := range tree {
+		if tree[i].ID == id {
+			return &tree[i]
+		}
+		if node := FindCategory(tree[i].Children, id); node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+// SubtreeIDs возвращает идентификаторы категории и всех ее подкатегорий
+func (n *CategoryNode) SubtreeIDs() []int64 {
+	ids := []int64{n.ID}
+	for i := range n.Children {
+		ids = append(ids, n.Children[i].SubtreeIDs()...)
+	}
+	return ids
+}
+
+// CategoryRequest - создание и замена (PUT) категории; без parent_id категория корневая
+type CategoryRequest struct {
+	Name     *string `json:"name"`
+	ParentID *int64  `json:"parent_id"`
+}
+
+// Validate реализует интерфейс Request
+func (r *CategoryRequest) Validate() error {
+	if r.Name == nil || strings.TrimSpace(*r.Name) == "" {
+		return errors.New("название категории обязательно")
+	}
+	if r.ParentID != nil && *r.ParentID <= 0 {
+		return errors.New("parent_id должен быть положительным")
+	}
+	return nil
+}
+
+// ProductCategoriesRequest - замена набора категорий товара
+type ProductCategoriesRequest struct {
+	CategoryIDs []int64 `json:"category_ids"`
+}
+
+// Validate реализует интерфейс Request
+func (r *ProductCategoriesRequest) Validate() error {
+	if r.CategoryIDs == nil {
+		return errors.New("category_ids обязателен; пустой список снимает все категории")
+	}
+	for _, id := range r.CategoryIDs {
+		if id <= 0 {
+			return fmt.Errorf("category_ids: некорректный идентификатор %d", id)
+		}
+	}
+	return nil
+}
+
+// Ограничения тегов товара
+const (
+	MaxProductTags = 20
+	MaxTagLength   = 50
+)
+
+// ProductTagsRequest - замена набора тегов товара
+type ProductTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// Validate реализует интерфейс Request и приводит теги к виду, в котором они хранятся (NormalizeTag)
+func (r *ProductTagsRequest) Validate() error {
+	if r.Tags == nil {
+		return errors.New("tags обязателен; пустой список снимает все теги")
+	}
+	tags, err := NormalizeTags(r.Tags)
+	if err != nil {
+		return err
+	}
+	r.Tags = tags
+	return nil
+}
+
+// NormalizeTag приводит тег к нижнему регистру без пробелов по краям: "RGB " и "rgb" - один тег
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags нормализует теги, убирает повторы и проверяет ограничения
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" {
+			return nil, errors.New("tags: тег не может быть пустым")
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, fmt.Errorf("tags: тег %q длиннее %d символов", tag, MaxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > MaxProductTags {
+		return nil, fmt.Errorf("tags: у товара не может быть больше %d тегов", MaxProductTags)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// TagCount - тег и число товаров с ним
+type TagCount struct {
+	Name     string `json:"name" db:"name"`
+	Products int    `json:"products" db:"products"`
+}
+
+// ProductFilter ограничивает набор товаров категориями и тегом
+type ProductFilter struct {
+	// CategoryIDs - товары хотя бы одной из категорий (категория вместе с подкатегориями); пусто - любые
+	CategoryIDs []int64
+	// Tag - нормализованный тег (NormalizeTag); пусто - любые
+	Tag string
+}
+
+// IsEmpty сообщает, что фильтр не ограничивает товары
+func (f ProductFilter) IsEmpty() bool {
+	return len(f.CategoryIDs) == 0 && f.Tag == ""
+}
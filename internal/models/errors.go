@@ -0,0 +1,48 @@
+package models
+
+import "errors"
+
+// ErrInsufficientStock возвращается, когда товара на складе меньше, чем запрошено
+var ErrInsufficientStock = errors.New("недостаточное количество товара")
+
+// ErrVersionConflict возвращается, когда запись изменилась (или удалена) с момента чтения версии клиентом
+var ErrVersionConflict = errors.New("запись была изменена другим запросом")
+
+// ErrBulkAborted возвращается для элементов атомарного пакета, откаченного из-за ошибки в другом элементе
+var ErrBulkAborted = errors.New("пакет отменен из-за ошибки в другом элементе")
+
+// ErrCategoryNotFound возвращается, когда запрос ссылается на несуществующую категорию (родитель, фильтр, категории товара)
+var ErrCategoryNotFound = errors.New("категория не найдена")
+
+// ErrCategoryNotEmpty возвращается при удалении категории, у которой есть подкатегории
+var ErrCategoryNotEmpty = errors.New("у категории есть подкатегории")
+
+// ErrCategoryCycle возвращается, когда категорию переносят в нее саму или в ее подкатегорию
+var ErrCategoryCycle = errors.New("категорию нельзя вложить в нее саму или в ее подкатегорию")
+
+// ErrDuplicateSKU возвращается, когда артикул уже занят другим вариантом
+var ErrDuplicateSKU = errors.New("вариант с таким артикулом уже существует")
+
+// ErrVariantRequired возвращается при покупке товара с вариантами без указания варианта
+var ErrVariantRequired = errors.New("у товара есть варианты: укажите variant_id")
+
+// ErrVariantProductMismatch возвращается при покупке, в которой вариант относится к другому товару
+var ErrVariantProductMismatch = errors.New("вариант не относится к указанному товару")
+
+// ErrDuplicateCoupon возвращается, когда промокод уже существует
+var ErrDuplicateCoupon = errors.New("промокод уже существует")
+
+// ErrCouponNotFound возвращается при покупке с несуществующим промокодом
+var ErrCouponNotFound = errors.New("промокод не найден")
+
+// ErrCouponExpired возвращается при покупке с промокодом, срок действия которого истек
+var ErrCouponExpired = errors.New("срок действия промокода истек")
+
+// ErrCouponMinOrder возвращается, когда сумма покупки меньше минимальной для промокода
+var ErrCouponMinOrder = errors.New("сумма покупки меньше минимальной для промокода")
+
+// ErrCouponExhausted возвращается, когда промокод применен максимальное число раз
+var ErrCouponExhausted = errors.New("промокод больше недоступен")
+
+// ErrCouponUserLimit возвращается, когда пользователь исчерпал свой лимит применений промокода
+var ErrCouponUserLimit = errors.New("промокод уже использован максимальное число раз")
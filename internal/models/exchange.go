@@ -0,0 +1,24 @@
+package models
+
+// ImportReport - итог импорта. При DryRun ничего не записывается, а Created и Updated
+// показывают, сколько записей было бы создано и обновлено.
+type ImportReport struct {
+	DryRun  bool   `json:"dry_run"`
+	Key     string `json:"key"`
+	Total   int    `json:"total"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	// Unchanged - строки, совпадающие с сохраненным товаром: они не записываются и не меняют версию
+	Unchanged int           `json:"unchanged"`
+	Failed    int           `json:"failed"`
+	Errors    []ImportError `json:"errors"`
+	// ErrorsTruncated - ошибок больше, чем попало в Errors
+	ErrorsTruncated bool `json:"errors_truncated,omitempty"`
+}
+
+// ImportError - ошибка одной строки файла импорта
+type ImportError struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Рейтинг популярных товаров (GET /api/products/popular) строится по завершенным покупкам
+// за скользящее окно в днях; день покупки - дата ее завершения по UTC
+const (
+	DefaultPopularLimit = 10
+	MaxPopularLimit     = 50
+	// MaxPopularDays - самое длинное окно рейтинга; дневные продажи хранятся столько дней
+	MaxPopularDays = 30
+)
+
+// Критерии рейтинга
+const (
+	PopularByUnits   = "units"
+	PopularByRevenue = "revenue"
+)
+
+// PopularPeriods - допустимые значения параметра period и их длина в днях
+var PopularPeriods = map[string]int{
+	"7d":  7,
+	"30d": MaxPopularDays,
+}
+
+// SalesDay возвращает день продаж для момента завершения покупки: начало суток по UTC
+func SalesDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// ProductSales - продажи товара: проданные единицы и выручка
+type ProductSales struct {
+	ProductID int64   `json:"product_id"`
+	Units     int64   `json:"units"`
+	Revenue   float64 `json:"revenue"`
+}
+
+// DailySales - продажи товара за день Day (SalesDay)
+type DailySales struct {
+	Day time.Time
+	ProductSales
+}
+
+// PopularProduct - строка рейтинга: место, товар и его продажи за период
+type PopularProduct struct {
+	Rank    int      `json:"rank"`
+	Product *Product `json:"product"`
+	Units   int64    `json:"units"`
+	Revenue float64  `json:"revenue"`
+}
+
+// PopularQuery - параметры рейтинга: period (7d или 30d), by (units или revenue) и limit.
+// Days заполняется из Period в Validate.
+type PopularQuery struct {
+	Period string
+	By     string
+	Limit  int
+	Days   int
+}
+
+// Validate подставляет значения по умолчанию (7d, units, DefaultPopularLimit) и проверяет параметры
+func (q *PopularQuery) Validate() error {
+	if q.Period == "" {
+		q.Period = "7d"
+	}
+	days, ok := PopularPeriods[q.Period]
+	if !ok {
+		return errors.New("period: ожидается 7d или 30d")
+	}
+	q.Days = days
+
+	if q.By == "" {
+		q.By = PopularByUnits
+	}
+	if q.By != PopularByUnits && q.By != PopularByRevenue {
+		return fmt.Errorf("by: ожидается %s или %s", PopularByUnits, PopularByRevenue)
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultPopularLimit
+	}
+	if q.Limit < 1 || q.Limit > MaxPopularLimit {
+		return fmt.Errorf("limit: ожидается число от 1 до %d", MaxPopularLimit)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// PriceChange - запись истории цены товара (product_prices): цена, действующая с ChangedAt.
+// Пишется хранилищем при создании товара и при каждом изменении цены, в том числе пакетном и запланированном.
+type PriceChange struct {
+	ID        int64     `json:"id" db:"id"`
+	ProductID int64     `json:"product_id" db:"product_id"`
+	Price     float64   `json:"price" db:"price"`
+	ChangedAt time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// ScheduledPrice - цена, которую фоновая задача установит товару в EffectiveAt (scheduled_prices).
+// AppliedAt задается при применении; в API показываются только непримененные цены.
+type ScheduledPrice struct {
+	ID          int64      `json:"id" db:"id"`
+	ProductID   int64      `json:"product_id" db:"product_id"`
+	Price       float64    `json:"price" db:"price"`
+	EffectiveAt time.Time  `json:"effective_at" db:"effective_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	AppliedAt   *time.Time `json:"-" db:"applied_at"`
+}
+
+// ScheduledPriceRequest - запрос на смену цены в будущем (POST /api/products/{id}/scheduled-prices)
+type ScheduledPriceRequest struct {
+	Price       *float64  `json:"price"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// Validate реализует интерфейс Request
+func (r *ScheduledPriceRequest) Validate() error {
+	if r.Price == nil || r.EffectiveAt.IsZero() {
+		return errors.New("запланированная цена требует price и effective_at")
+	}
+	if *r.Price < 0 {
+		return errors.New("цена не может быть отрицательной")
+	}
+	if !r.EffectiveAt.After(time.Now()) {
+		return errors.New("effective_at: время вступления цены в силу должно быть в будущем")
+	}
+	return nil
+}
+
+// ScheduledPrice возвращает запланированную цену товара productID
+func (r *ScheduledPriceRequest) ScheduledPrice(productID int64) *ScheduledPrice {
+	return &ScheduledPrice{
+		ProductID:   productID,
+		Price:       *r.Price,
+		EffectiveAt: r.EffectiveAt.UTC().Truncate(time.Second),
+	}
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Типы скидки купона и правила
+const (
+	DiscountPercent = "percent" // Value - процент от суммы
+	DiscountFixed   = "fixed"   // Value - сумма, не больше стоимости заказа
+)
+
+// Источники строки скидки покупки (PurchaseDiscount.Kind)
+const (
+	DiscountKindRule   = "rule"
+	DiscountKindCoupon = "coupon"
+)
+
+// Ограничения промокодов и правил
+const (
+	MaxCouponCodeLength = 32
+	MaxRuleNameLength   = 100
+)
+
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]+$`)
+
+// Coupon - промокод (coupons). Uses - сколько раз он применен; лимиты считаются в хранилище
+// атомарно, поэтому параллельные покупки не превышают MaxUses и PerUserLimit.
+type Coupon struct {
+	ID            int64      `json:"id" db:"id"`
+	Code          string     `json:"code" db:"code"`
+	Type          string     `json:"type" db:"type"`
+	Value         float64    `json:"value" db:"value"`
+	MaxUses       *int       `json:"max_uses" db:"max_uses"`             // nil - без ограничения
+	PerUserLimit  *int       `json:"per_user_limit" db:"per_user_limit"` // nil - без ограничения
+	MinOrderValue float64    `json:"min_order_value" db:"min_order_value"`
+	ExpiresAt     *time.Time `json:"expires_at" db:"expires_at"` // nil - бессрочный
+	Uses          int        `json:"uses" db:"uses"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PromotionRule - автоматическая скидка (promotion_rules): применяется к покупке от MinQuantity единиц
+// товара из категории CategoryID или ее подкатегорий (nil - любого товара)
+type PromotionRule struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	CategoryID  *int64    `json:"category_id" db:"category_id"`
+	MinQuantity int       `json:"min_quantity" db:"min_quantity"`
+	Type        string    `json:"type" db:"type"`
+	Value       float64   `json:"value" db:"value"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// NormalizeCouponCode приводит промокод к виду, в котором он хранится: без пробелов по краям, в верхнем регистре
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// DiscountAmount возвращает скидку типа kind размера value с суммы base, округленную до копеек
+func DiscountAmount(kind string, value, base float64) float64 {
+	amount := value
+	if kind == DiscountPercent {
+		amount = base * value / 100
+	}
+	return math.Round(min(amount, base)*100) / 100
+}
+
+// validateDiscount проверяет тип и размер скидки
+func validateDiscount(kind string, value *float64) error {
+	if kind != DiscountPercent && kind != DiscountFixed {
+		return fmt.Errorf("type: ожидается %s или %s", DiscountPercent, DiscountFixed)
+	}
+	if value == nil || *value <= 0 {
+		return errors.New("value: размер скидки должен быть больше нуля")
+	}
+	if kind == DiscountPercent && *value > 100 {
+		return errors.New("value: процент скидки не может быть больше 100")
+	}
+	return nil
+}
+
+// CouponRequest - создание промокода (POST /api/coupons)
+type CouponRequest struct {
+	Code          string     `json:"code"`
+	Type          string     `json:"type"`
+	Value         *float64   `json:"value"`
+	MaxUses       *int       `json:"max_uses"`
+	PerUserLimit  *int       `json:"per_user_limit"`
+	MinOrderValue float64    `json:"min_order_value"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// Validate реализует интерфейс Request и приводит промокод к верхнему регистру
+func (r *CouponRequest) Validate() error {
+	r.Code = NormalizeCouponCode(r.Code)
+	if utf8.RuneCountInString(r.Code) > MaxCouponCodeLength || !couponCodePattern.MatchString(r.Code) {
+		return fmt.Errorf("code: ожидается от 1 до %d латинских букв, цифр, _ или -", MaxCouponCodeLength)
+	}
+	if err := validateDiscount(r.Type, r.Value); err != nil {
+		return err
+	}
+	if r.MaxUses != nil && *r.MaxUses <= 0 {
+		return errors.New("max_uses: должен быть больше нуля")
+	}
+	if r.PerUserLimit != nil && *r.PerUserLimit <= 0 {
+		return errors.New("per_user_limit: должен быть больше нуля")
+	}
+	if r.MinOrderValue < 0 {
+		return errors.New("min_order_value: не может быть отрицательной")
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at: срок действия должен заканчиваться в будущем")
+	}
+	return nil
+}
+
+// Coupon строит промокод из проверенного запроса
+func (r *CouponRequest) Coupon() *Coupon {
+	coupon := &Coupon{
+		Code:          r.Code,
+		Type:          r.Type,
+		Value:         *r.Value,
+		MaxUses:       r.MaxUses,
+		PerUserLimit:  r.PerUserLimit,
+		MinOrderValue: r.MinOrderValue,
+	}
+	if r.ExpiresAt != nil {
+		expiresAt := r.ExpiresAt.UTC().Truncate(time.Second)
+		coupon.ExpiresAt = &expiresAt
+	}
+	return coupon
+}
+
+// PromotionRuleRequest - создание правила скидки (POST /api/promotions); min_quantity по умолчанию 1
+type PromotionRuleRequest struct {
+	Name        string   `json:"name"`
+	CategoryID  *int64   `json:"category_id"`
+	MinQuantity int      `json:"min_quantity"`
+	Type        string   `json:"type"`
+	Value       *float64 `json:"value"`
+}
+
+// Validate реализует интерфейс Request
+func (r *PromotionRuleRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || utf8.RuneCountInString(r.Name) > MaxRuleNameLength {
+		return fmt.Errorf("name: ожидается от 1 до %d символов", MaxRuleNameLength)
+	}
+	if r.MinQuantity == 0 {
+		r.MinQuantity = 1
+	}
+	if r.MinQuantity < 0 {
+		return errors.New("min_quantity: должен быть больше нуля")
+	}
+	return validateDiscount(r.Type, r.Value)
+}
+
+// Rule строит правило из проверенного запроса
+func (r *PromotionRuleRequest) Rule() *PromotionRule {
+	return &PromotionRule{
+		Name:        r.Name,
+		CategoryID:  r.CategoryID,
+		MinQuantity: r.MinQuantity,
+		Type:        r.Type,
+		Value:       *r.Value,
+	}
+}
+
+// PurchaseDiscount - строка скидки покупки: правило или промокод и вычтенная сумма
+type PurchaseDiscount struct {
+	Kind   string  `json:"kind"` // DiscountKindRule или DiscountKindCoupon
+	ID     int64   `json:"id"`   // id правила или промокода
+	Name   string  `json:"name"` // название правила или промокод
+	Amount float64 `json:"amount"`
+}
+
+// PurchaseDiscounts - скидки покупки; в БД хранятся JSON-массивом в purchases.discounts
+type PurchaseDiscounts []PurchaseDiscount
+
+// Value реализует driver.Valuer: nil сохраняется как пустой массив
+func (d PurchaseDiscounts) Value() (driver.Value, error) {
+	if d == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]PurchaseDiscount(d))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan реализует sql.Scanner для столбцов JSON, JSONB и TEXT
+func (d *PurchaseDiscounts) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*d = PurchaseDiscounts{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("discounts: неподдерживаемый тип %T", src)
+	}
+	discounts := PurchaseDiscounts{}
+	if err := json.Unmarshal(data, &discounts); err != nil {
+		return err
+	}
+	*d = discounts
+	return nil
+}
+
+// Total возвращает сумму всех скидок
+func (d PurchaseDiscounts) Total() float64 {
+	total := 0.0
+	for _, discount := range d {
+		total += discount.Amount
+	}
+	return math.Round(total*100) / 100
+}
@@ -6,16 +6,29 @@ type Purchase struct {
 	ID         int64     `json:"id" db:"id"`
 	UserID     int64     `json:"user_id" db:"user_id"`
 	ProductID  int64     `json:"product_id" db:"product_id"`
+	VariantID  *int64    `json:"variant_id" db:"variant_id"` // nil - товар без вариантов
 	Quantity   int       `json:"quantity" db:"quantity"`
 	TotalPrice float64   `json:"total_price" db:"total_price"`
 	Status     string    `json:"status" db:"status"` // "pending", "completed", "cancelled"
+	Version    int64     `json:"version" db:"version"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	// Discounts - примененные скидки, TotalPrice уже уменьшен на их сумму
+	Discounts PurchaseDiscounts `json:"discounts" db:"discounts"`
 }
 
-// PurchaseRequest представляет данные, отправляемые при создании новой покупки
+// PurchaseRequest представляет данные, отправляемые при создании новой покупки.
+// У товара с вариантами покупается вариант: variant_id обязателен, product_id можно не указывать.
+// coupon_code необязателен; автоматические правила скидок применяются и без него.
 type PurchaseRequest struct {
-	UserID    int64 `json:"user_id"`
-	ProductID int64 `json:"product_id"`
-	Quantity  int   `json:"quantity"`
+	UserID     int64  `json:"user_id"`
+	ProductID  int64  `json:"product_id"`
+	VariantID  int64  `json:"variant_id"`
+	Quantity   int    `json:"quantity"`
+	CouponCode string `json:"coupon_code"`
+}
+
+// PurchaseStatusRequest представляет данные для смены статуса покупки
+type PurchaseStatusRequest struct {
+	Status string `json:"status"`
 }
@@ -0,0 +1,26 @@
+package models
+
+// Рекомендации "с этим товаром покупают" строятся по совместным покупкам: два товара куплены
+// вместе, если их купил один и тот же пользователь (отмененные покупки не считаются)
+const (
+	DefaultRecommendationLimit = 10
+	MaxRecommendationLimit     = 50
+	// MaxCoPurchases - сколько товаров, чаще всего покупаемых вместе, хранится на каждый товар
+	MaxCoPurchases = 100
+	// MaxBasketProducts - сколько последних купленных пользователем товаров учитывается при подсчете совместных
+	// покупок: число пар растет квадратично от размера корзины, а память - от числа пользователей
+	MaxBasketProducts = 50
+)
+
+// CoPurchase - товар, купленный вместе с другим, и число покупателей, купивших оба
+type CoPurchase struct {
+	ProductID int64 `json:"product_id"`
+	Count     int64 `json:"count"`
+}
+
+// Recommendation - рекомендованный товар и его вес: для товара - сколько покупателей купили оба,
+// для пользователя - сумма таких чисел по товарам, которые он уже купил
+type Recommendation struct {
+	Product *Product `json:"product"`
+	Score   int64    `json:"score"`
+}
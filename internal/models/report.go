@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Отчеты о продажах (GET /api/reports/sales) считаются по покупкам, созданным в диапазоне дат
+// включительно (UTC), - по их дневным агрегатам (SalesRollup). Выручка и единицы - только
+// по завершенным покупкам, отмененные учитываются в доле отмен.
+const (
+	ReportDateLayout = "2006-01-02"
+	// DefaultReportDays - длина диапазона, если from не задан
+	DefaultReportDays = 30
+	MaxReportDays     = 366
+	// DefaultTopCustomers - сколько покупателей в GET /api/reports/top-customers без limit
+	DefaultTopCustomers = 10
+	MaxReportLimit      = 1000
+)
+
+// Группировки отчета
+const (
+	ReportByDay     = "day"
+	ReportByWeek    = "week"
+	ReportByMonth   = "month"
+	ReportByProduct = "product"
+	ReportByUser    = "user"
+)
+
+// ReportGroups - допустимые значения параметра by
+var ReportGroups = []string{ReportByDay, ReportByWeek, ReportByMonth, ReportByProduct, ReportByUser}
+
+// ReportTotals - показатели группы покупок
+type ReportTotals struct {
+	Orders    int64   `json:"orders"`
+	Completed int64   `json:"completed"`
+	Cancelled int64   `json:"cancelled"`
+	Units     int64   `json:"units"`
+	Revenue   float64 `json:"revenue"`
+	// AverageOrderValue - выручка на завершенную покупку
+	AverageOrderValue float64 `json:"average_order_value"`
+	// CancellationRate - доля отмененных среди всех покупок, от 0 до 1
+	CancellationRate float64 `json:"cancellation_rate"`
+}
+
+// ReportRow - строка отчета: Key - начало периода (YYYY-MM-DD, для месяца YYYY-MM) или id товара
+// либо пользователя, Label - название товара или имя пользователя
+type ReportRow struct {
+	Key   string `json:"key"`
+	Label string `json:"label,omitempty"`
+	ReportTotals
+}
+
+// SalesReport - отчет за диапазон from..to: строки по группировке By и итог по всему диапазону
+type SalesReport struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	By     string       `json:"by"`
+	Totals ReportTotals `json:"totals"`
+	Rows   []ReportRow  `json:"rows"`
+}
+
+// ReportQuery - параметры отчета. From и To - даты (начало суток UTC), To включительно;
+// Limit ограничивает строки товаров и пользователей (0 - все).
+type ReportQuery struct {
+	From  time.Time
+	To    time.Time
+	By    string
+	Limit int
+}
+
+// Validate подставляет значения по умолчанию (to - сегодня, from - DefaultReportDays дней до to, by - day)
+// и проверяет параметры
+func (q *ReportQuery) Validate() error {
+	if q.To.IsZero() {
+		q.To = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if q.From.IsZero() {
+		q.From = q.To.AddDate(0, 0, -(DefaultReportDays - 1))
+	}
+	if q.From.After(q.To) {
+		return errors.New("from: дата начала позже даты окончания")
+	}
+	if q.To.Sub(q.From) >= MaxReportDays*24*time.Hour {
+		return fmt.Errorf("диапазон не больше %d дней", MaxReportDays)
+	}
+
+	if q.By == "" {
+		q.By = ReportByDay
+	}
+	valid := false
+	for _, by := range ReportGroups {
+		valid = valid || q.By == by
+	}
+	if !valid {
+		return fmt.Errorf("by: ожидается одно из %s", strings.Join(ReportGroups, ", "))
+	}
+	if q.Limit < 0 || q.Limit > MaxReportLimit {
+		return fmt.Errorf("limit: ожидается число от 1 до %d", MaxReportLimit)
+	}
+	return nil
+}
+
+// CacheKey - ключ отчета в кеше; вызывается после Validate
+func (q *ReportQuery) CacheKey() string {
+	return fmt.Sprintf("%s:%s:%s:%d", q.By, q.From.Format(ReportDateLayout), q.To.Format(ReportDateLayout), q.Limit)
+}
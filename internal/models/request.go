@@ -1,10 +1,13 @@
 package models
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -33,6 +36,47 @@ func ParseAndValidate(r *http.Request, req Request) error {
 	return req.Validate()
 }
 
+// ParseMergePatch разбирает тело PATCH-запроса по правилам JSON Merge Patch (RFC 7396):
+// поля, которых нет в документе, не меняются. null в Merge Patch означает удаление поля,
+// а у моделей нет необязательных полей, поэтому null и неизвестные поля отклоняются.
+func ParseMergePatch(r *http.Request, req Request) error {
+	if r.Body == nil {
+		return errors.New("тело запроса отсутствует")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return errors.New("тело запроса пустое")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return errors.New("документ JSON Merge Patch должен быть объектом")
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if string(bytes.TrimSpace(fields[name])) == "null" {
+			return fmt.Errorf("поле %s нельзя удалить", name)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		return err
+	}
+
+	return req.Validate()
+}
+
 // UserCreateRequest - модель запроса для создания пользователя
 type UserCreateRequest struct {
 	Username string `json:"username"`
@@ -48,17 +92,87 @@ func (r *UserCreateRequest) Validate() error {
 	return nil
 }
 
-// UserUpdateRequest - модель запроса для обновления пользователя
+// UserUpdateRequest - модель запроса для замены пользователя (PUT): все поля обязательны
 type UserUpdateRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
 }
 
 // Validate реализует интерфейс Request
 func (r *UserUpdateRequest) Validate() error {
-	// Просто проверяем наличие username
-	if strings.TrimSpace(r.Username) == "" {
+	if r.Username == nil || r.Email == nil {
+		return errors.New("PUT требует полное представление: username и email")
+	}
+	if strings.TrimSpace(*r.Username) == "" {
 		return errors.New("имя пользователя обязательно")
 	}
 	return nil
 }
+
+// UserPatch - частичное обновление пользователя (PATCH): nil-поля не меняются
+type UserPatch struct {
+	// Version - ожидаемая версия записи (0 - без проверки); задается из If-Match, а не из тела
+	Version int64 `json:"-"`
+
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+}
+
+// Validate реализует интерфейс Request
+func (p *UserPatch) Validate() error {
+	if p.Username != nil && strings.TrimSpace(*p.Username) == "" {
+		return errors.New("имя пользователя не может быть пустым")
+	}
+	return nil
+}
+
+// IsEmpty сообщает, что патч не меняет ни одного поля
+func (p *UserPatch) IsEmpty() bool {
+	return p.Username == nil && p.Email == nil
+}
+
+// ProductUpdateRequest - модель запроса для замены товара (PUT): все поля обязательны
+type ProductUpdateRequest struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	Quantity    *int     `json:"quantity"`
+}
+
+// Validate реализует интерфейс Request
+func (r *ProductUpdateRequest) Validate() error {
+	if r.Name == nil || r.Description == nil || r.Price == nil || r.Quantity == nil {
+		return errors.New("PUT требует полное представление: name, description, price и quantity")
+	}
+	return (&ProductPatch{Name: r.Name, Price: r.Price, Quantity: r.Quantity}).Validate()
+}
+
+// ProductPatch - частичное обновление товара (PATCH): nil-поля не меняются
+type ProductPatch struct {
+	// Version - ожидаемая версия записи (0 - без проверки); задается из If-Match, а не из тела
+	Version int64 `json:"-"`
+
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	Quantity    *int     `json:"quantity"`
+}
+
+// Validate реализует интерфейс Request
+func (p *ProductPatch) Validate() error {
+	if p.Name != nil && strings.TrimSpace(*p.Name) == "" {
+		return errors.New("название товара не может быть пустым")
+	}
+	if p.Price != nil && *p.Price < 0 {
+		return errors.New("цена не может быть отрицательной")
+	}
+	if p.Quantity != nil && *p.Quantity < 0 {
+		return errors.New("количество не может быть отрицательным")
+	}
+	return nil
+}
+
+// IsEmpty сообщает, что патч не меняет ни одного поля
+func (p *ProductPatch) IsEmpty() bool {
+	return p.Name == nil && p.Description == nil && p.Price == nil && p.Quantity == nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// SalesRollup - дневной агрегат покупок (таблицы sales_daily_products и sales_daily_users).
+// Day - дата создания покупок, начало суток UTC (SalesDay). В строках товаров задан ProductID,
+// в строках пользователей - UserID; в дельтах (RollupRepository.Apply) заданы оба, а счетчики
+// могут быть отрицательными.
+type SalesRollup struct {
+	Day       time.Time `db:"day"`
+	ProductID int64     `db:"product_id"`
+	UserID    int64     `db:"user_id"`
+	Orders    int64     `db:"orders"`
+	Completed int64     `db:"completed"`
+	Cancelled int64     `db:"cancelled"`
+	Units     int64     `db:"units"`
+	Revenue   float64   `db:"revenue"`
+}
+
+// RollupOf возвращает вклад покупки в дневной агрегат: покупка считается в Orders, завершенная -
+// в Completed, Units и Revenue, отмененная - в Cancelled
+func RollupOf(purchase *Purchase) SalesRollup {
+	rollup := SalesRollup{
+		Day:       SalesDay(purchase.CreatedAt),
+		ProductID: purchase.ProductID,
+		UserID:    purchase.UserID,
+		Orders:    1,
+	}
+	switch purchase.Status {
+	case "completed":
+		rollup.Completed = 1
+		rollup.Units = int64(purchase.Quantity)
+		rollup.Revenue = purchase.TotalPrice
+	case "cancelled":
+		rollup.Cancelled = 1
+	}
+	return rollup
+}
+
+// Add прибавляет к агрегату счетчики other
+func (r *SalesRollup) Add(other SalesRollup) {
+	r.Orders += other.Orders
+	r.Completed += other.Completed
+	r.Cancelled += other.Cancelled
+	r.Units += other.Units
+	r.Revenue += other.Revenue
+}
+
+// Sub вычитает из агрегата счетчики other
+func (r *SalesRollup) Sub(other SalesRollup) {
+	r.Orders -= other.Orders
+	r.Completed -= other.Completed
+	r.Cancelled -= other.Cancelled
+	r.Units -= other.Units
+	r.Revenue -= other.Revenue
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Сортировка результатов поиска
+const (
+	SortRelevance = "relevance"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNewest    = "newest"
+)
+
+// Размер страницы результатов поиска
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// PriceBuckets - нижние границы диапазонов фасета цены; последний диапазон не ограничен сверху
+var PriceBuckets = []float64{0, 1000, 5000, 20000, 50000}
+
+// ProductSearchQuery - параметры поиска товаров
+type ProductSearchQuery struct {
+	// Query - текст запроса; пустой - все товары, подходящие под фильтры
+	Query    string
+	MinPrice *float64
+	MaxPrice *float64
+	// InStock - только товары с ненулевым остатком
+	InStock bool
+	// Category - только товары категории и ее подкатегорий (0 - любые); Tag - только товары с тегом.
+	// В отличие от фильтров по цене и наличию они ограничивают и фасеты.
+	Category int64
+	Tag      string
+	Sort     string
+	Limit    int
+	Offset   int
+
+	// Terms - термы запроса после разбора (search.Terms); хранилища ищут по ним, а не по Query
+	Terms []string
+	// CategoryIDs - Category вместе с подкатегориями; заполняется по дереву категорий (CategoryService.SubtreeIDs)
+	CategoryIDs []int64
+}
+
+// Validate проверяет параметры и подставляет значения по умолчанию
+func (q *ProductSearchQuery) Validate() error {
+	switch q.Sort {
+	case "":
+		q.Sort = SortRelevance
+	case SortRelevance, SortPriceAsc, SortPriceDesc, SortNewest:
+	default:
+		return fmt.Errorf("sort: неизвестная сортировка %s (ожидается %s)", q.Sort,
+			strings.Join([]string{SortRelevance, SortPriceAsc, SortPriceDesc, SortNewest}, ", "))
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxSearchLimit {
+		return fmt.Errorf("limit: ожидается число от 1 до %d", MaxSearchLimit)
+	}
+	if q.Offset < 0 {
+		return errors.New("offset не может быть отрицательным")
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return errors.New("min_price больше max_price")
+	}
+	if q.Category < 0 {
+		return errors.New("category: некорректный идентификатор категории")
+	}
+	q.Tag = NormalizeTag(q.Tag)
+	return nil
+}
+
+// Filter - ограничение товаров категориями и тегом
+func (q *ProductSearchQuery) Filter() ProductFilter {
+	return ProductFilter{CategoryIDs: q.CategoryIDs, Tag: q.Tag}
+}
+
+// CacheKey - ключ страницы результатов в кеше; запросы с одинаковыми термами дают один ключ
+func (q *ProductSearchQuery) CacheKey() string {
+	price := func(p *float64) string {
+		if p == nil {
+			return ""
+		}
+		return fmt.Sprint(*p)
+	}
+	// Подкатегории, а не только Category: после переноса категории в дереве ключ меняется
+	return fmt.Sprintf("%s|%s|%s|%t|%v|%s|%s|%d|%d",
+		strings.Join(q.Terms, " "), price(q.MinPrice), price(q.MaxPrice), q.InStock, q.CategoryIDs, q.Tag, q.Sort, q.Limit, q.Offset)
+}
+
+// ProductSearchResult - страница результатов поиска
+type ProductSearchResult struct {
+	Items []ProductSearchHit `json:"items"`
+	// Total - сколько всего товаров подходит под запрос и фильтры
+	Total  int                 `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+	Facets ProductSearchFacets `json:"facets"`
+}
+
+// ProductSearchHit - найденный товар с подсвеченными совпадениями
+type ProductSearchHit struct {
+	Product   *Product         `json:"product"`
+	Highlight ProductHighlight `json:"highlight"`
+}
+
+// ProductHighlight - название и фрагмент описания, экранированные для HTML,
+// с совпавшими словами в тегах <mark>
+type ProductHighlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ProductSearchFacets - распределение товаров, найденных по тексту запроса, без учета фильтров
+// по цене и наличию: клиент показывает, сколько товаров останется после выбора фильтра
+type ProductSearchFacets struct {
+	InStock int           `json:"in_stock"`
+	Price   []PriceBucket `json:"price"`
+}
+
+// PriceBucket - диапазон цен [From, To); To = nil - без верхней границы
+type PriceBucket struct {
+	From  float64  `json:"from"`
+	To    *float64 `json:"to,omitempty"`
+	Count int      `json:"count"`
+}
+
+// NewPriceFacet создает диапазоны фасета цены по PriceBuckets с количествами counts
+func NewPriceFacet(counts []int) []PriceBucket {
+	buckets := make([]PriceBucket, len(PriceBuckets))
+	for i, from := range PriceBuckets {
+		buckets[i] = PriceBucket{From: from, Count: counts[i]}
+		if i+1 < len(PriceBuckets) {
+			to := PriceBuckets[i+1]
+			buckets[i].To = &to
+		}
+	}
+	return buckets
+}
+
+// NewProductSearchResult собирает страницу результатов из товаров страницы и счетчиков хранилища;
+// подсветку заполняет сервис
+func NewProductSearchResult(query *ProductSearchQuery, products []*Product, total, inStock int, priceCounts []int) *ProductSearchResult {
+	items := make([]ProductSearchHit, len(products))
+	for i, product := range products {
+		items[i] = ProductSearchHit{Product: product}
+	}
+	return &ProductSearchResult{
+		Items:  items,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Facets: ProductSearchFacets{InStock: inStock, Price: NewPriceFacet(priceCounts)},
+	}
+}
+
+// PriceBucketIndex - номер диапазона PriceBuckets, в который попадает цена
+func PriceBucketIndex(price float64) int {
+	index := 0
+	for i, from := range PriceBuckets {
+		if price >= from {
+			index = i
+		}
+	}
+	return index
+}
@@ -0,0 +1,15 @@
+package models
+
+// Количество подсказок в ответе
+const (
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = 20
+)
+
+// ProductSuggestion - подсказка для строки поиска: товар, название которого начинается с введенного текста
+// (или одно из слов названия). Подсказки упорядочены по Sales - сколько единиц товара продано.
+type ProductSuggestion struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Sales int64  `json:"sales"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ProductVariant - вариант товара (цвет, объем памяти и т.п.) со своим артикулом, ценой и остатком
+type ProductVariant struct {
+	ID         int64             `json:"id" db:"id"`
+	ProductID  int64             `json:"product_id" db:"product_id"`
+	SKU        string            `json:"sku" db:"sku"`
+	Attributes VariantAttributes `json:"attributes" db:"attributes"`
+	Price      float64           `json:"price" db:"price"`
+	Quantity   int               `json:"quantity" db:"quantity"`
+	Version    int64             `json:"version" db:"version"`
+	CreatedAt  time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// VariantAttributes - характеристики варианта ("color": "черный"); в БД хранятся JSON-объектом
+type VariantAttributes map[string]string
+
+// Value реализует driver.Valuer: nil сохраняется как пустой объект
+func (a VariantAttributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan реализует sql.Scanner для столбцов JSON, JSONB и TEXT
+func (a *VariantAttributes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*a = VariantAttributes{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("attributes: неподдерживаемый тип %T", src)
+	}
+	attributes := VariantAttributes{}
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return err
+	}
+	*a = attributes
+	return nil
+}
+
+// Ограничения варианта товара
+const (
+	MaxSKULength        = 64
+	MaxVariantAttrs     = 20
+	MaxVariantAttrValue = 100
+)
+
+// VariantRequest - создание и замена (PUT) варианта: sku, price и quantity обязательны
+type VariantRequest struct {
+	SKU        *string           `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      *float64          `json:"price"`
+	Quantity   *int              `json:"quantity"`
+}
+
+// Validate реализует интерфейс Request и убирает пробелы по краям артикула и характеристик
+func (r *VariantRequest) Validate() error {
+	if r.SKU == nil || r.Price == nil || r.Quantity == nil {
+		return errors.New("вариант требует sku, price и quantity")
+	}
+	sku := strings.TrimSpace(*r.SKU)
+	if sku == "" || utf8.RuneCountInString(sku) > MaxSKULength {
+		return fmt.Errorf("sku: ожидается от 1 до %d символов", MaxSKULength)
+	}
+	r.SKU = &sku
+	if *r.Price < 0 {
+		return errors.New("цена не может быть отрицательной")
+	}
+	if *r.Quantity < 0 {
+		return errors.New("количество не может быть отрицательным")
+	}
+
+	if len(r.Attributes) > MaxVariantAttrs {
+		return fmt.Errorf("attributes: не больше %d характеристик", MaxVariantAttrs)
+	}
+	attributes := make(map[string]string, len(r.Attributes))
+	for name, value := range r.Attributes {
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "" {
+			return errors.New("attributes: название характеристики не может быть пустым")
+		}
+		if utf8.RuneCountInString(name) > MaxVariantAttrValue || utf8.RuneCountInString(value) > MaxVariantAttrValue {
+			return fmt.Errorf("attributes: название и значение характеристики - не длиннее %d символов", MaxVariantAttrValue)
+		}
+		attributes[name] = value
+	}
+	r.Attributes = attributes
+	return nil
+}
+
+// Variant строит вариант товара productID из проверенного запроса
+func (r *VariantRequest) Variant(productID int64) *ProductVariant {
+	return &ProductVariant{
+		ProductID:  productID,
+		SKU:        *r.SKU,
+		Attributes: r.Attributes,
+		Price:      *r.Price,
+		Quantity:   *r.Quantity,
+	}
+}
@@ -0,0 +1,472 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"testing"
+	"time"
+)
+
+// Caches - набор кешей одной реализации
+type Caches struct {
+	Users      service.UserCache
+	Products   service.ProductCache
+	Purchases  service.PurchaseCache
+	Categories service.CategoryCache
+	// CoPurchases и Reports - совместные покупки и отчеты; обычно тот же кеш, что и Purchases
+	CoPurchases service.CoPurchaseCache
+	Reports     service.ReportCache
+}
+
+// cacheTTL достаточно мал, чтобы проверить истечение, и достаточно велик для Redis (PX в миллисекундах)
+const cacheTTL = 200 * time.Millisecond
+
+// RunCaches прогоняет контракт кешей: промах возвращает (nil, nil), Set/Delete и истечение TTL
+func RunCaches(t *testing.T, newCaches func(t *testing.T) Caches) {
+	t.Run("Users", func(t *testing.T) {
+		cache := newCaches(t).Users
+		testCache(t,
+			func(ctx context.Context, id int64) (any, error) { return orNil(cache.GetByID(ctx, id)) },
+			func(ctx context.Context, id int64, ttl time.Duration) error {
+				return cache.Set(ctx, &models.User{ID: id, Username: "cached"}, ttl)
+			},
+			cache.Delete,
+		)
+	})
+	t.Run("Products", func(t *testing.T) {
+		cache := newCaches(t).Products
+		testCache(t,
+			func(ctx context.Context, id int64) (any, error) { return orNil(cache.GetByID(ctx, id)) },
+			func(ctx context.Context, id int64, ttl time.Duration) error {
+				return cache.Set(ctx, &models.Product{ID: id, Name: "cached", Price: 10}, ttl)
+			},
+			cache.Delete,
+		)
+	})
+	t.Run("Purchases", func(t *testing.T) {
+		cache := newCaches(t).Purchases
+		testCache(t,
+			func(ctx context.Context, id int64) (any, error) { return orNil(cache.GetByID(ctx, id)) },
+			func(ctx context.Context, id int64, ttl time.Duration) error {
+				return cache.Set(ctx, &models.Purchase{ID: id, Status: "pending"}, ttl)
+			},
+			cache.Delete,
+		)
+	})
+	t.Run("UserPurchases", func(t *testing.T) {
+		testUserPurchases(t, newCaches(t).Purchases)
+	})
+	t.Run("Many", func(t *testing.T) {
+		testMany(t, newCaches(t))
+	})
+	t.Run("Search", func(t *testing.T) {
+		testSearchCache(t, newCaches(t).Products)
+	})
+	t.Run("Suggestions", func(t *testing.T) {
+		testSuggestions(t, newCaches(t).Products)
+	})
+	t.Run("CategoryTree", func(t *testing.T) {
+		testCategoryTree(t, newCaches(t).Categories)
+	})
+	t.Run("Sales", func(t *testing.T) {
+		testSales(t, newCaches(t).Purchases)
+	})
+	t.Run("CoPurchases", func(t *testing.T) {
+		testCoPurchases(t, newCaches(t).CoPurchases)
+	})
+	t.Run("Reports", func(t *testing.T) {
+		cache := newCaches(t).Reports
+		testCache(t,
+			func(ctx context.Context, id int64) (any, error) { return orNil(cache.GetReport(ctx, fmt.Sprint(id))) },
+			func(ctx context.Context, id int64, ttl time.Duration) error {
+				report := &models.SalesReport{By: models.ReportByDay, Rows: []models.ReportRow{{Key: "2026-10-01"}}}
+				return cache.SetReport(ctx, fmt.Sprint(id), report, ttl)
+			},
+			nil,
+		)
+	})
+}
+
+// orNil превращает типизированный nil-указатель в nil интерфейс
+func orNil[T any](v *T, err error) (any, error) {
+	if v == nil {
+		return nil, err
+	}
+	return v, err
+}
+
+func testCache(t *testing.T,
+	get func(ctx context.Context, id int64) (any, error),
+	set func(ctx context.Context, id int64, ttl time.Duration) error,
+	del func(ctx context.Context, id int64) error,
+) {
+	ctx := context.Background()
+	id := time.Now().UnixNano()
+
+	if got, err := get(ctx, id); err != nil || got != nil {
+		t.Fatalf("промах кеша: ожидалось (nil, nil), получено (%v, %v)", got, err)
+	}
+
+	if err := set(ctx, id, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, err := get(ctx, id); err != nil || got == nil {
+		t.Fatalf("после Set: ожидалось значение, получено (%v, %v)", got, err)
+	}
+
+	// del == nil - записи кеша только истекают, как готовые отчеты
+	if del != nil {
+		if err := del(ctx, id); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if got, err := get(ctx, id); err != nil || got != nil {
+			t.Errorf("после Delete: ожидалось (nil, nil), получено (%v, %v)", got, err)
+		}
+	}
+
+	if err := set(ctx, id, cacheTTL); err != nil {
+		t.Fatalf("Set с TTL: %v", err)
+	}
+	time.Sleep(2 * cacheTTL)
+	if got, err := get(ctx, id); err != nil || got != nil {
+		t.Errorf("после истечения TTL: ожидалось (nil, nil), получено (%v, %v)", got, err)
+	}
+}
+
+// testMany проверяет пакетную запись и удаление (в Redis - конвейером)
+func testMany(t *testing.T, caches Caches) {
+	ctx := context.Background()
+	base := time.Now().UnixNano()
+
+	products := []*models.Product{{ID: base, Name: "first", Price: 1}, {ID: base + 1, Name: "second", Price: 2}}
+	if err := caches.Products.SetMany(ctx, products, time.Minute); err != nil {
+		t.Fatalf("Products.SetMany: %v", err)
+	}
+	for _, product := range products {
+		got, err := caches.Products.GetByID(ctx, product.ID)
+		if err != nil || got == nil || got.Name != product.Name {
+			t.Errorf("после SetMany: ожидался %s, получено (%+v, %v)", product.Name, got, err)
+		}
+	}
+	if err := caches.Products.DeleteMany(ctx, []int64{base, base + 1}); err != nil {
+		t.Fatalf("Products.DeleteMany: %v", err)
+	}
+	for _, product := range products {
+		if got, err := caches.Products.GetByID(ctx, product.ID); err != nil || got != nil {
+			t.Errorf("после DeleteMany: ожидалось (nil, nil), получено (%v, %v)", got, err)
+		}
+	}
+
+	users := []*models.User{{ID: base, Username: "first"}, {ID: base + 1, Username: "second"}}
+	if err := caches.Users.SetMany(ctx, users, time.Minute); err != nil {
+		t.Fatalf("Users.SetMany: %v", err)
+	}
+	if got, err := caches.Users.GetByID(ctx, base+1); err != nil || got == nil || got.Username != "second" {
+		t.Errorf("Users после SetMany: (%+v, %v)", got, err)
+	}
+	if err := caches.Users.DeleteMany(ctx, []int64{base, base + 1}); err != nil {
+		t.Fatalf("Users.DeleteMany: %v", err)
+	}
+	if got, err := caches.Users.GetByID(ctx, base); err != nil || got != nil {
+		t.Errorf("Users после DeleteMany: (%v, %v)", got, err)
+	}
+
+	if err := caches.Products.SetMany(ctx, nil, time.Minute); err != nil {
+		t.Errorf("SetMany без элементов: %v", err)
+	}
+	if err := caches.Products.DeleteMany(ctx, nil); err != nil {
+		t.Errorf("DeleteMany без элементов: %v", err)
+	}
+}
+
+func testUserPurchases(t *testing.T, cache service.PurchaseCache) {
+	ctx := context.Background()
+	userID := time.Now().UnixNano()
+
+	if got, err := cache.GetUserPurchases(ctx, userID); err != nil || got != nil {
+		t.Fatalf("промах кеша: ожидалось (nil, nil), получено (%v, %v)", got, err)
+	}
+
+	purchases := []*models.Purchase{
+		{ID: 1, UserID: userID, Status: "pending"},
+		{ID: 2, UserID: userID, Status: "completed"},
+	}
+	if err := cache.SetUserPurchases(ctx, userID, purchases, time.Minute); err != nil {
+		t.Fatalf("SetUserPurchases: %v", err)
+	}
+
+	// Изменения исходного среза после записи не должны влиять на кеш
+	purchases[0].Status = "cancelled"
+
+	got, err := cache.GetUserPurchases(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserPurchases: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[0].Status != "pending" || got[1].Status != "completed" {
+		t.Errorf("GetUserPurchases: получено %+v", got)
+	}
+}
+
+func testSearchCache(t *testing.T, cache service.ProductCache) {
+	ctx := context.Background()
+	key := fmt.Sprintf("ноутбук|||false|relevance|20|%d", time.Now().UnixNano())
+
+	if result, err := cache.GetSearch(ctx, key); err != nil || result != nil {
+		t.Fatalf("GetSearch до записи: (%+v, %v), ожидалось (nil, nil)", result, err)
+	}
+
+	query := &models.ProductSearchQuery{Limit: 20}
+	product := &models.Product{ID: 7, Name: "Ноутбук", Price: 45000}
+	result := models.NewProductSearchResult(query, []*models.Product{product}, 1, 1, []int{0, 0, 0, 1, 0})
+	result.Items[0].Highlight.Name = "<mark>Ноутбук</mark>"
+	if err := cache.SetSearch(ctx, key, result, time.Minute); err != nil {
+		t.Fatalf("SetSearch: %v", err)
+	}
+
+	cached, err := cache.GetSearch(ctx, key)
+	if err != nil || cached == nil {
+		t.Fatalf("GetSearch: (%+v, %v)", cached, err)
+	}
+	if cached.Total != 1 || len(cached.Items) != 1 || cached.Items[0].Product.Name != product.Name ||
+		cached.Items[0].Highlight.Name != "<mark>Ноутбук</mark>" || cached.Facets.Price[3].Count != 1 {
+		t.Errorf("GetSearch: %+v", cached)
+	}
+
+	if err := cache.InvalidateSearch(ctx); err != nil {
+		t.Fatalf("InvalidateSearch: %v", err)
+	}
+	if cached, err := cache.GetSearch(ctx, key); err != nil || cached != nil {
+		t.Errorf("GetSearch после InvalidateSearch: (%+v, %v), ожидалось (nil, nil)", cached, err)
+	}
+}
+
+func testSuggestions(t *testing.T, cache service.ProductCache) {
+	ctx := context.Background()
+	// Уникальное слово: Redis может быть общим для нескольких прогонов. Оно короткое,
+	// чтобы фразы с ним уместились в search.MaxPrefixLength
+	n := time.Now().UnixNano()/1000 + uniqueCounter.Add(1)
+	tag := fmt.Sprintf("qx%06d", n%1000000)
+	base := n % 1000000 * 10
+
+	suggest := func(prefix string, limit int) []int64 {
+		t.Helper()
+		suggestions, err := cache.Suggest(ctx, prefix, limit)
+		if err != nil {
+			t.Fatalf("Suggest(%q): %v", prefix, err)
+		}
+		ids := make([]int64, len(suggestions))
+		for i, suggestion := range suggestions {
+			ids[i] = suggestion.ID
+		}
+		return ids
+	}
+	expect := func(prefix string, limit int, want ...int64) {
+		t.Helper()
+		if got := suggest(prefix, limit); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Suggest(%q, %d) = %v, ожидалось %v", prefix, limit, got, want)
+		}
+	}
+
+	err := cache.RebuildSuggestions(ctx, []models.ProductSuggestion{
+		{ID: base + 1, Name: tag + " Ноутбук", Sales: 3},
+		{ID: base + 2, Name: tag + " Ноутбук Pro", Sales: 10},
+		{ID: base + 3, Name: "Сумка " + tag, Sales: 1},
+	})
+	if err != nil {
+		t.Fatalf("RebuildSuggestions: %v", err)
+	}
+
+	// По убыванию продаж; префикс - начало любого слова, регистр не важен
+	expect(tag, 10, base+2, base+1, base+3)
+	expect(tag, 2, base+2, base+1)
+	expect(tag+" ноут", 10, base+2, base+1)
+	expect(tag+" ноутбук p", 10, base+2)
+	if suggestions, err := cache.Suggest(ctx, tag, 1); err != nil || len(suggestions) != 1 ||
+		suggestions[0].Name != tag+" Ноутбук Pro" || suggestions[0].Sales != 10 {
+		t.Errorf("Suggest: (%+v, %v)", suggestions, err)
+	}
+
+	// Переименование сохраняет вес и убирает старые префиксы; новый товар получает нулевой вес
+	err = cache.IndexSuggestions(ctx, []*models.Product{
+		{ID: base + 1, Name: tag + " Планшет"},
+		{ID: base + 4, Name: tag + " Планшет mini"},
+	})
+	if err != nil {
+		t.Fatalf("IndexSuggestions: %v", err)
+	}
+	expect(tag+" ноут", 10, base+2)
+	expect(tag+" план", 10, base+1, base+4)
+
+	if err := cache.RemoveSuggestions(ctx, []int64{base + 2, base + 99}); err != nil {
+		t.Fatalf("RemoveSuggestions: %v", err)
+	}
+	expect(tag, 10, base+1, base+3, base+4)
+
+	// Перестроение удаляет товары, которых нет в наборе, и пересчитывает веса
+	if err := cache.RebuildSuggestions(ctx, []models.ProductSuggestion{{ID: base + 4, Name: tag + " Планшет mini", Sales: 7}}); err != nil {
+		t.Fatalf("RebuildSuggestions: %v", err)
+	}
+	expect(tag, 10, base+4)
+	expect(tag+" план", 10, base+4)
+}
+
+func testSales(t *testing.T, cache service.PurchaseCache) {
+	ctx := context.Background()
+	// Рейтинг общий для всего Redis: проверяются только свои товары с уникальными id
+	base := (time.Now().UnixNano()/1000 + uniqueCounter.Add(1)) % 1000000 * 10
+	now := time.Now()
+	day := func(ago int) time.Time { return now.AddDate(0, 0, -ago) }
+
+	top := func(days int, by string, limit int) []models.ProductSales {
+		t.Helper()
+		sales, err := cache.TopSales(ctx, now, days, by, limit)
+		if err != nil {
+			t.Fatalf("TopSales(%d, %s): %v", days, by, err)
+		}
+		own := []models.ProductSales{}
+		for _, s := range sales {
+			if s.ProductID > base && s.ProductID < base+10 {
+				own = append(own, s)
+			}
+		}
+		return own
+	}
+	expect := func(days int, by string, want ...models.ProductSales) {
+		t.Helper()
+		if got := top(days, by, models.MaxPopularLimit); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("TopSales(%d, %s) = %v, ожидалось %v", days, by, got, want)
+		}
+	}
+
+	err := cache.RebuildSales(ctx, []models.DailySales{
+		{Day: day(0), ProductSales: models.ProductSales{ProductID: base + 1, Units: 2, Revenue: 200}},
+		{Day: day(1), ProductSales: models.ProductSales{ProductID: base + 2, Units: 5, Revenue: 50}},
+		{Day: day(10), ProductSales: models.ProductSales{ProductID: base + 3, Units: 1, Revenue: 1000}},
+		// За пределами самого длинного окна - не учитывается
+		{Day: day(models.MaxPopularDays + 5), ProductSales: models.ProductSales{ProductID: base + 1, Units: 100, Revenue: 100}},
+	})
+	if err != nil {
+		t.Fatalf("RebuildSales: %v", err)
+	}
+
+	p1 := models.ProductSales{ProductID: base + 1, Units: 2, Revenue: 200}
+	p2 := models.ProductSales{ProductID: base + 2, Units: 5, Revenue: 50}
+	p3 := models.ProductSales{ProductID: base + 3, Units: 1, Revenue: 1000}
+	expect(7, models.PopularByUnits, p2, p1)
+	expect(models.MaxPopularDays, models.PopularByRevenue, p3, p1, p2)
+	if sales, err := cache.TopSales(ctx, now, models.MaxPopularDays, models.PopularByRevenue, 1); err != nil || len(sales) != 1 {
+		t.Errorf("TopSales с limit 1: (%v, %v)", sales, err)
+	}
+
+	// Продажи дня складываются; отмена вычитает их, и товар без продаж пропадает из рейтинга
+	if err := cache.AddSales(ctx, now, models.ProductSales{ProductID: base + 1, Units: 1, Revenue: 100}); err != nil {
+		t.Fatalf("AddSales: %v", err)
+	}
+	if err := cache.AddSales(ctx, day(1), models.ProductSales{ProductID: base + 2, Units: -5, Revenue: -50}); err != nil {
+		t.Fatalf("AddSales: %v", err)
+	}
+	expect(7, models.PopularByUnits, models.ProductSales{ProductID: base + 1, Units: 3, Revenue: 300})
+
+	// Перестроение заменяет все дни окна
+	if err := cache.RebuildSales(ctx, []models.DailySales{{Day: day(3), ProductSales: p2}}); err != nil {
+		t.Fatalf("RebuildSales: %v", err)
+	}
+	expect(models.MaxPopularDays, models.PopularByUnits, p2)
+}
+
+func testCoPurchases(t *testing.T, cache service.CoPurchaseCache) {
+	ctx := context.Background()
+	base := (time.Now().UnixNano()/1000 + uniqueCounter.Add(1)) % 1000000 * 10
+
+	get := func(ids ...int64) map[int64][]models.CoPurchase {
+		t.Helper()
+		got, err := cache.GetCoPurchases(ctx, ids)
+		if err != nil {
+			t.Fatalf("GetCoPurchases: %v", err)
+		}
+		return got
+	}
+
+	err := cache.RebuildCoPurchases(ctx, map[int64][]models.CoPurchase{
+		base + 1: {{ProductID: base + 2, Count: 3}, {ProductID: base + 3, Count: 1}},
+		base + 2: {{ProductID: base + 1, Count: 3}},
+		base + 3: {{ProductID: base + 1, Count: 1}},
+	})
+	if err != nil {
+		t.Fatalf("RebuildCoPurchases: %v", err)
+	}
+
+	// Наборы по убыванию числа покупателей; товаров без набора в ответе нет
+	got := get(base+1, base+2, base+9)
+	want := map[int64][]models.CoPurchase{
+		base + 1: {{ProductID: base + 2, Count: 3}, {ProductID: base + 3, Count: 1}},
+		base + 2: {{ProductID: base + 1, Count: 3}},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetCoPurchases = %v, ожидалось %v", got, want)
+	}
+	if got := get(); len(got) != 0 {
+		t.Errorf("GetCoPurchases без товаров: %v", got)
+	}
+
+	// Перестроение заменяет наборы и удаляет наборы товаров, которых в нем нет
+	err = cache.RebuildCoPurchases(ctx, map[int64][]models.CoPurchase{
+		base + 1: {{ProductID: base + 3, Count: 2}},
+		base + 3: {{ProductID: base + 1, Count: 2}},
+	})
+	if err != nil {
+		t.Fatalf("RebuildCoPurchases: %v", err)
+	}
+	got = get(base+1, base+2, base+3)
+	want = map[int64][]models.CoPurchase{
+		base + 1: {{ProductID: base + 3, Count: 2}},
+		base + 3: {{ProductID: base + 1, Count: 2}},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("после перестроения GetCoPurchases = %v, ожидалось %v", got, want)
+	}
+}
+
+func testCategoryTree(t *testing.T, cache service.CategoryCache) {
+	ctx := context.Background()
+	// Ключ дерева один на кеш: перед проверкой промаха его нужно сбросить
+	if err := cache.InvalidateTree(ctx); err != nil {
+		t.Fatalf("InvalidateTree: %v", err)
+	}
+	if tree, err := cache.GetTree(ctx); err != nil || tree != nil {
+		t.Fatalf("GetTree до записи: (%v, %v), ожидалось (nil, nil)", tree, err)
+	}
+
+	parentID := int64(1)
+	tree := models.NewCategoryTree([]*models.Category{
+		{ID: 1, Name: "Электроника", Version: 1},
+		{ID: 2, ParentID: &parentID, Name: "Периферия", Version: 3},
+	})
+	if err := cache.SetTree(ctx, tree, time.Minute); err != nil {
+		t.Fatalf("SetTree: %v", err)
+	}
+	cached, err := cache.GetTree(ctx)
+	if err != nil || len(cached) != 1 || len(cached[0].Children) != 1 {
+		t.Fatalf("GetTree: (%+v, %v)", cached, err)
+	}
+	if child := cached[0].Children[0]; child.Name != "Периферия" || child.ParentID == nil || *child.ParentID != 1 || child.Version != 3 {
+		t.Errorf("подкатегория из кеша: %+v", child)
+	}
+
+	// Пустое дерево - не промах
+	if err := cache.SetTree(ctx, nil, time.Minute); err != nil {
+		t.Fatalf("SetTree: %v", err)
+	}
+	if cached, err := cache.GetTree(ctx); err != nil || cached == nil || len(cached) != 0 {
+		t.Errorf("GetTree пустого дерева: (%#v, %v), ожидался пустой срез", cached, err)
+	}
+
+	if err := cache.InvalidateTree(ctx); err != nil {
+		t.Fatalf("InvalidateTree: %v", err)
+	}
+	if cached, err := cache.GetTree(ctx); err != nil || cached != nil {
+		t.Errorf("GetTree после InvalidateTree: (%v, %v), ожидалось (nil, nil)", cached, err)
+	}
+}
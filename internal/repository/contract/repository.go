@@ -0,0 +1,1565 @@
+// Package contract содержит общие тесты, которые должна проходить любая реализация
+// интерфейсов репозиториев и кешей из пакета service (MySQL, PostgreSQL, SQLite, memory, Redis).
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Repositories - набор репозиториев одного хранилища
+type Repositories struct {
+	Users      service.UserRepository
+	Products   service.ProductRepository
+	Purchases  service.PurchaseRepository
+	Categories service.CategoryRepository
+	Tags       service.TagRepository
+	Variants   service.VariantRepository
+	Rollups    service.RollupRepository
+	Prices     service.PriceRepository
+	Promotions service.PromotionRepository
+	TxManager  service.TxManager
+}
+
+// RunRepositories прогоняет контракт всех репозиториев. newRepos вызывается для каждого подтеста;
+// хранилище может быть общим, тесты не рассчитывают на пустые таблицы.
+func RunRepositories(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newRepos(t)) })
+	t.Run("Products", func(t *testing.T) { testProducts(t, newRepos(t)) })
+	t.Run("Purchases", func(t *testing.T) { testPurchases(t, newRepos(t)) })
+	t.Run("TxManager", func(t *testing.T) { testTxManager(t, newRepos(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, newRepos(t)) })
+	t.Run("CreateBatch", func(t *testing.T) { testCreateBatch(t, newRepos(t)) })
+	t.Run("CreateBatchConcurrent", func(t *testing.T) { testCreateBatchConcurrent(t, newRepos(t)) })
+	t.Run("ForEach", func(t *testing.T) { testForEach(t, newRepos(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newRepos(t)) })
+	t.Run("SalesCounts", func(t *testing.T) { testSalesCounts(t, newRepos(t)) })
+	t.Run("Categories", func(t *testing.T) { testCategories(t, newRepos(t)) })
+	t.Run("Tags", func(t *testing.T) { testTags(t, newRepos(t)) })
+	t.Run("Variants", func(t *testing.T) { testVariants(t, newRepos(t)) })
+	t.Run("VariantStock", func(t *testing.T) { testVariantStock(t, newRepos(t)) })
+	t.Run("Rollups", func(t *testing.T) { testRollups(t, newRepos(t)) })
+	t.Run("Prices", func(t *testing.T) { testPrices(t, newRepos(t)) })
+	t.Run("Promotions", func(t *testing.T) { testPromotions(t, newRepos(t)) })
+}
+
+var uniqueCounter atomic.Int64
+
+// unique возвращает строку, уникальную в пределах запуска, чтобы не упираться в UNIQUE-ограничения
+func unique(prefix string) string {
+	return fmt.Sprintf("%s_%d_%d", prefix, time.Now().UnixNano(), uniqueCounter.Add(1))
+}
+
+func newUser() *models.User {
+	name := unique("user")
+	return &models.User{Username: name, Email: name + "@example.com"}
+}
+
+func newProduct(quantity int) *models.Product {
+	return &models.Product{Name: unique("product"), Description: "описание", Price: 199.99, Quantity: quantity}
+}
+
+func testUsers(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Users
+
+	user := newUser()
+	id, err := repo.Create(ctx, user)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if id <= 0 {
+		t.Fatalf("Create: ожидался положительный id, получено %d", id)
+	}
+
+	got, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got == nil || got.ID != id || got.Username != user.Username || got.Email != user.Email {
+		t.Fatalf("GetByID: получено %+v, ожидался пользователь %d %q", got, id, user.Username)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Errorf("GetByID: created_at не заполнен")
+	}
+
+	if _, err := repo.Create(ctx, &models.User{Username: user.Username, Email: unique("dup") + "@example.com"}); err == nil {
+		t.Errorf("Create: ожидалась ошибка для повторяющегося username")
+	}
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if !containsID(all, id, func(u *models.User) int64 { return u.ID }) {
+		t.Errorf("GetAll: пользователь %d не найден", id)
+	}
+
+	updated := &models.User{ID: id, Username: unique("renamed"), Email: unique("renamed") + "@example.com"}
+	if err := repo.Update(ctx, updated); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err = repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID после Update: %v", err)
+	}
+	if got.Username != updated.Username || got.Email != updated.Email {
+		t.Errorf("Update: получено %+v, ожидалось %+v", got, updated)
+	}
+
+	email := unique("patched") + "@example.com"
+	if err := repo.Patch(ctx, id, &models.UserPatch{Email: &email}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if err := repo.Patch(ctx, id, &models.UserPatch{}); err != nil {
+		t.Fatalf("Patch без полей: %v", err)
+	}
+	got, err = repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID после Patch: %v", err)
+	}
+	if got.Username != updated.Username || got.Email != email {
+		t.Errorf("Patch: получено %+v, ожидалось имя %q и email %q", got, updated.Username, email)
+	}
+
+	if err := repo.Delete(ctx, id, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	got, err = repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID после Delete: %v", err)
+	}
+	if got != nil {
+		t.Errorf("GetByID после Delete: ожидался nil, получено %+v", got)
+	}
+}
+
+func testProducts(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Products
+
+	product := newProduct(10)
+	id, err := repo.Create(ctx, product)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got == nil || got.Name != product.Name || got.Description != product.Description ||
+		got.Price != product.Price || got.Quantity != product.Quantity {
+		t.Fatalf("GetByID: получено %+v, ожидалось %+v", got, product)
+	}
+
+	missing, err := repo.GetByID(ctx, id+1_000_000)
+	if err != nil || missing != nil {
+		t.Errorf("GetByID несуществующего: ожидалось (nil, nil), получено (%+v, %v)", missing, err)
+	}
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if !containsID(all, id, func(p *models.Product) int64 { return p.ID }) {
+		t.Errorf("GetAll: продукт %d не найден", id)
+	}
+
+	got.Price = 99.5
+	got.Quantity = 7
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if err := repo.DecreaseQuantity(ctx, id, 5); err != nil {
+		t.Fatalf("DecreaseQuantity: %v", err)
+	}
+	if err := repo.DecreaseQuantity(ctx, id, 3); !errors.Is(err, models.ErrInsufficientStock) {
+		t.Errorf("DecreaseQuantity сверх остатка: ожидалась ErrInsufficientStock, получено %v", err)
+	}
+
+	got, err = repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID после Update: %v", err)
+	}
+	if got.Price != 99.5 || got.Quantity != 2 {
+		t.Errorf("после Update и DecreaseQuantity: цена %v, остаток %d; ожидалось 99.5 и 2", got.Price, got.Quantity)
+	}
+
+	description := "новое описание"
+	if err := repo.Patch(ctx, id, &models.ProductPatch{Description: &description}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	got, err = repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID после Patch: %v", err)
+	}
+	if got.Name != product.Name || got.Description != description || got.Price != 99.5 || got.Quantity != 2 {
+		t.Errorf("Patch изменил лишние поля: %+v", got)
+	}
+
+	if err := repo.Delete(ctx, id, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, id); got != nil {
+		t.Errorf("GetByID после Delete: ожидался nil, получено %+v", got)
+	}
+}
+
+func testPurchases(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+
+	userID, err := repos.Users.Create(ctx, newUser())
+	if err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	productID, err := repos.Products.Create(ctx, newProduct(10))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+
+	purchase := &models.Purchase{UserID: userID, ProductID: productID, Quantity: 2, TotalPrice: 399.98, Status: "pending"}
+	id, err := repos.Purchases.Create(ctx, purchase)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := repos.Purchases.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got == nil || got.UserID != userID || got.ProductID != productID || got.Quantity != 2 ||
+		got.TotalPrice != 399.98 || got.Status != "pending" {
+		t.Fatalf("GetByID: получено %+v, ожидалось %+v", got, purchase)
+	}
+
+	byUser, err := repos.Purchases.GetByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if len(byUser) != 1 || byUser[0].ID != id {
+		t.Errorf("GetByUserID: ожидалась одна покупка %d, получено %+v", id, byUser)
+	}
+
+	if err := repos.Purchases.UpdateStatus(ctx, id, "completed", 0); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	got, err = repos.Purchases.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID после UpdateStatus: %v", err)
+	}
+	if got.Status != "completed" {
+		t.Errorf("UpdateStatus: статус %q, ожидался completed", got.Status)
+	}
+
+	err = repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		locked, err := repos.Purchases.GetByIDForUpdate(ctx, id)
+		if err != nil || locked == nil || locked.Status != "completed" {
+			t.Errorf("GetByIDForUpdate: (%+v, %v)", locked, err)
+		}
+		if missing, err := repos.Purchases.GetByIDForUpdate(ctx, -1); err != nil || missing != nil {
+			t.Errorf("GetByIDForUpdate несуществующей: (%+v, %v), ожидалось (nil, nil)", missing, err)
+		}
+		if err := repos.Purchases.LockCreated(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour)); err != nil {
+			t.Errorf("LockCreated: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TxManager.Do: %v", err)
+	}
+
+	all, err := repos.Purchases.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if !containsID(all, id, func(p *models.Purchase) int64 { return p.ID }) {
+		t.Errorf("GetAll: покупка %d не найдена", id)
+	}
+
+	if _, err := repos.Purchases.Create(ctx, &models.Purchase{UserID: userID, ProductID: productID + 1_000_000, Quantity: 1, Status: "pending"}); err == nil {
+		t.Errorf("Create с несуществующим товаром: ожидалась ошибка")
+	}
+}
+
+func testTxManager(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+
+	productID, err := repos.Products.Create(ctx, newProduct(10))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+
+	quantity := func() int {
+		t.Helper()
+		product, err := repos.Products.GetByID(ctx, productID)
+		if err != nil || product == nil {
+			t.Fatalf("GetByID: (%+v, %v)", product, err)
+		}
+		return product.Quantity
+	}
+
+	// Ошибка внутри транзакции откатывает все изменения
+	errAbort := errors.New("abort")
+	err = repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		if err := repos.Products.DecreaseQuantity(ctx, productID, 4); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Do: ожидалась исходная ошибка, получено %v", err)
+	}
+	if q := quantity(); q != 10 {
+		t.Errorf("после отката: остаток %d, ожидалось 10", q)
+	}
+
+	// Успешная транзакция фиксирует изменения, а ошибка во вложенной откатывает только ее
+	err = repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		if err := repos.Products.DecreaseQuantity(ctx, productID, 3); err != nil {
+			return err
+		}
+		nestedErr := repos.TxManager.Do(ctx, func(ctx context.Context) error {
+			if err := repos.Products.DecreaseQuantity(ctx, productID, 2); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(nestedErr, errAbort) {
+			return fmt.Errorf("вложенная транзакция: %v", nestedErr)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if q := quantity(); q != 7 {
+		t.Errorf("после вложенного отката: остаток %d, ожидалось 7", q)
+	}
+
+	// AfterCommit вызывается только после фиксации внешней транзакции и только для не откаченных точек сохранения
+	var calls []string
+	record := func(name string) func() { return func() { calls = append(calls, name) } }
+	err = repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		repos.TxManager.AfterCommit(ctx, record("outer"))
+		repos.TxManager.Do(ctx, func(ctx context.Context) error {
+			repos.TxManager.AfterCommit(ctx, record("released"))
+			return nil
+		})
+		repos.TxManager.Do(ctx, func(ctx context.Context) error {
+			repos.TxManager.AfterCommit(ctx, record("rolled back"))
+			return errAbort
+		})
+		if len(calls) != 0 {
+			return fmt.Errorf("AfterCommit вызван до фиксации: %q", calls)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if want := "outer,released"; strings.Join(calls, ",") != want {
+		t.Errorf("AfterCommit после фиксации: %q, ожидалось %s", calls, want)
+	}
+
+	calls = nil
+	repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		repos.TxManager.AfterCommit(ctx, record("aborted"))
+		return errAbort
+	})
+	repos.TxManager.AfterCommit(ctx, record("no tx"))
+	if want := "no tx"; strings.Join(calls, ",") != want {
+		t.Errorf("AfterCommit при откате и вне транзакции: %q, ожидалось %s", calls, want)
+	}
+}
+
+// testVersions проверяет оптимистичную блокировку: каждая запись увеличивает версию,
+// а запись с устаревшей версией отклоняется с ErrVersionConflict и ничего не меняет
+func testVersions(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+
+	userID, err := repos.Users.Create(ctx, newUser())
+	if err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	user, err := repos.Users.GetByID(ctx, userID)
+	if err != nil || user == nil || user.Version != 1 {
+		t.Fatalf("новый пользователь: ожидалась версия 1, получено %+v, %v", user, err)
+	}
+
+	user.Username = unique("v2")
+	if err := repos.Users.Update(ctx, user); err != nil {
+		t.Fatalf("Users.Update с текущей версией: %v", err)
+	}
+	stale := *user
+	stale.Username = unique("stale")
+	if err := repos.Users.Update(ctx, &stale); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("Users.Update с устаревшей версией: ожидалась ErrVersionConflict, получено %v", err)
+	}
+	email := unique("v3") + "@example.com"
+	if err := repos.Users.Patch(ctx, userID, &models.UserPatch{Email: &email, Version: 1}); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("Users.Patch с устаревшей версией: ожидалась ErrVersionConflict, получено %v", err)
+	}
+	// Пустой патч ничего не записывает и версию не проверяет - это делает сервис
+	if err := repos.Users.Patch(ctx, userID, &models.UserPatch{Version: 99}); err != nil {
+		t.Errorf("Users.Patch без полей: %v", err)
+	}
+	if err := repos.Users.Patch(ctx, userID, &models.UserPatch{Email: &email, Version: 2}); err != nil {
+		t.Fatalf("Users.Patch с текущей версией: %v", err)
+	}
+	got, err := repos.Users.GetByID(ctx, userID)
+	if err != nil || got.Version != 3 || got.Username != user.Username || got.Email != email {
+		t.Errorf("после Update и Patch: ожидалась версия 3 и изменения без устаревшей записи, получено %+v, %v", got, err)
+	}
+	if err := repos.Users.Update(ctx, &models.User{ID: userID + 1_000_000, Username: unique("missing"), Version: 1}); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("Users.Update несуществующего с версией: ожидалась ErrVersionConflict, получено %v", err)
+	}
+
+	productID, err := repos.Products.Create(ctx, newProduct(5))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+	if err := repos.Products.DecreaseQuantity(ctx, productID, 1); err != nil {
+		t.Fatalf("DecreaseQuantity: %v", err)
+	}
+	product, err := repos.Products.GetByID(ctx, productID)
+	if err != nil || product.Version != 2 {
+		t.Fatalf("DecreaseQuantity должен увеличить версию до 2, получено %+v, %v", product, err)
+	}
+	product.Version = 1
+	if err := repos.Products.Update(ctx, product); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("Products.Update после списания со старой версией: ожидалась ErrVersionConflict, получено %v", err)
+	}
+
+	purchaseID, err := repos.Purchases.Create(ctx, &models.Purchase{UserID: userID, ProductID: productID, Quantity: 1, TotalPrice: 1, Status: "pending"})
+	if err != nil {
+		t.Fatalf("Purchases.Create: %v", err)
+	}
+	if err := repos.Purchases.UpdateStatus(ctx, purchaseID, "completed", 2); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("UpdateStatus с неверной версией: ожидалась ErrVersionConflict, получено %v", err)
+	}
+	if err := repos.Purchases.UpdateStatus(ctx, purchaseID, "completed", 1); err != nil {
+		t.Fatalf("UpdateStatus с текущей версией: %v", err)
+	}
+	purchase, err := repos.Purchases.GetByID(ctx, purchaseID)
+	if err != nil || purchase.Version != 2 || purchase.Status != "completed" {
+		t.Errorf("после UpdateStatus: ожидалась версия 2, получено %+v, %v", purchase, err)
+	}
+
+	if err := repos.Products.Delete(ctx, productID, 1); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("Products.Delete с устаревшей версией: ожидалась ErrVersionConflict, получено %v", err)
+	}
+	if err := repos.Products.Delete(ctx, productID, 2); err != nil {
+		t.Fatalf("Products.Delete с текущей версией: %v", err)
+	}
+	if err := repos.Users.Delete(ctx, userID, 3); err != nil {
+		t.Fatalf("Users.Delete с текущей версией: %v", err)
+	}
+}
+
+// testCreateBatch проверяет пакетную вставку: идентификаторы возвращаются в порядке элементов,
+// а нарушение уникальности в одном элементе не оставляет в таблице остальных
+func testCreateBatch(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+
+	products := []*models.Product{newProduct(1), newProduct(2), newProduct(3)}
+	ids, err := repos.Products.CreateBatch(ctx, products)
+	if err != nil {
+		t.Fatalf("Products.CreateBatch: %v", err)
+	}
+	if len(ids) != len(products) {
+		t.Fatalf("Products.CreateBatch: ожидалось %d id, получено %v", len(products), ids)
+	}
+	for i, id := range ids {
+		stored, err := repos.Products.GetByID(ctx, id)
+		if err != nil || stored == nil {
+			t.Fatalf("GetByID(%d): (%+v, %v)", id, stored, err)
+		}
+		if stored.Name != products[i].Name || stored.Quantity != products[i].Quantity || stored.Version != 1 {
+			t.Errorf("товар %d: ожидался %+v, получено %+v", i, products[i], stored)
+		}
+	}
+
+	if ids, err := repos.Products.CreateBatch(ctx, nil); err != nil || len(ids) != 0 {
+		t.Errorf("CreateBatch без элементов: (%v, %v)", ids, err)
+	}
+
+	users := []*models.User{newUser(), newUser()}
+	ids, err = repos.Users.CreateBatch(ctx, users)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("Users.CreateBatch: (%v, %v)", ids, err)
+	}
+	for i, id := range ids {
+		stored, err := repos.Users.GetByID(ctx, id)
+		if err != nil || stored == nil || stored.Username != users[i].Username {
+			t.Errorf("пользователь %d: ожидался %s, получено (%+v, %v)", i, users[i].Username, stored, err)
+		}
+	}
+
+	fresh := newUser()
+	duplicate := &models.User{Username: users[0].Username, Email: unique("dup") + "@example.com"}
+	if _, err := repos.Users.CreateBatch(ctx, []*models.User{fresh, duplicate}); err == nil {
+		t.Fatal("Users.CreateBatch с дубликатом: ожидалась ошибка")
+	}
+	all, err := repos.Users.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range all {
+		if user.Username == fresh.Username {
+			t.Error("Users.CreateBatch с дубликатом: остальные строки не должны быть вставлены")
+		}
+	}
+}
+
+// testCreateBatchConcurrent проверяет, что параллельные пакеты получают свои идентификаторы:
+// автоинкременты пакетов могут перемежаться, и каждый id должен указывать на свой товар
+func testCreateBatchConcurrent(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	const workers, size = 4, 20
+
+	batches := make([][]*models.Product, workers)
+	results := make([][]int64, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := range batches {
+		batches[w] = make([]*models.Product, size)
+		for i := range batches[w] {
+			batches[w][i] = newProduct(w*size + i)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[w], errs[w] = repos.Products.CreateBatch(ctx, batches[w])
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int64]bool)
+	for w, ids := range results {
+		if errs[w] != nil {
+			t.Fatalf("пакет %d: %v", w, errs[w])
+		}
+		if len(ids) != size {
+			t.Fatalf("пакет %d: ожидалось %d id, получено %d", w, size, len(ids))
+		}
+		for i, id := range ids {
+			if seen[id] {
+				t.Fatalf("id %d выдан дважды", id)
+			}
+			seen[id] = true
+			stored, err := repos.Products.GetByID(ctx, id)
+			if err != nil || stored == nil || stored.Name != batches[w][i].Name {
+				t.Errorf("пакет %d, товар %d: ожидался %s, получено (%+v, %v)", w, i, batches[w][i].Name, stored, err)
+			}
+		}
+	}
+}
+
+func testForEach(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+
+	products := []*models.Product{newProduct(1), newProduct(2)}
+	products[1].Name = products[0].Name
+	ids, err := repos.Products.CreateBatch(ctx, products)
+	if err != nil {
+		t.Fatalf("Products.CreateBatch: %v", err)
+	}
+
+	var seen []*models.Product
+	var lastID int64
+	err = repos.Products.ForEach(ctx, func(product *models.Product) error {
+		if product.ID <= lastID {
+			t.Errorf("ForEach: id %d после %d, ожидался порядок по id", product.ID, lastID)
+		}
+		lastID = product.ID
+		seen = append(seen, product)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Products.ForEach: %v", err)
+	}
+	for _, id := range ids {
+		if !containsID(seen, id, func(p *models.Product) int64 { return p.ID }) {
+			t.Errorf("Products.ForEach: нет товара %d", id)
+		}
+	}
+
+	// Ошибка функции прерывает обход и возвращается как есть
+	stop := errors.New("stop")
+	calls := 0
+	err = repos.Products.ForEach(ctx, func(*models.Product) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ForEach с ошибкой: (%v, вызовов %d), ожидалось (stop, 1)", err, calls)
+	}
+
+	byName, err := repos.Products.GetByName(ctx, products[0].Name)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if len(byName) != 2 || !containsID(byName, ids[0], func(p *models.Product) int64 { return p.ID }) ||
+		!containsID(byName, ids[1], func(p *models.Product) int64 { return p.ID }) {
+		t.Errorf("GetByName: ожидались товары %v, получено %d", ids, len(byName))
+	}
+	if byName, err := repos.Products.GetByName(ctx, unique("missing")); err != nil || len(byName) != 0 {
+		t.Errorf("GetByName несуществующего: (%v, %v)", byName, err)
+	}
+
+	user := newUser()
+	userID, err := repos.Users.Create(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := newProduct(5)
+	productID, err := repos.Products.Create(ctx, product)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purchaseID, err := repos.Purchases.Create(ctx, &models.Purchase{UserID: userID, ProductID: productID, Quantity: 1, TotalPrice: product.Price, Status: "pending"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foundUser := false
+	if err := repos.Users.ForEach(ctx, func(u *models.User) error {
+		foundUser = foundUser || u.ID == userID && u.Username == user.Username
+		return nil
+	}); err != nil || !foundUser {
+		t.Errorf("Users.ForEach: (%v, найден %v)", err, foundUser)
+	}
+	foundPurchase := false
+	if err := repos.Purchases.ForEach(ctx, func(p *models.Purchase) error {
+		foundPurchase = foundPurchase || p.ID == purchaseID && p.UserID == userID
+		return nil
+	}); err != nil || !foundPurchase {
+		t.Errorf("Purchases.ForEach: (%v, найдена %v)", err, foundPurchase)
+	}
+}
+
+func testSearch(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Products
+
+	// Уникальное слово из букв и цифр: хранилище может быть общим, а "_" не везде разделяет слова
+	tag := fmt.Sprintf("qx%d", time.Now().UnixNano()+uniqueCounter.Add(1))
+	products := []*models.Product{
+		{Name: "Ноутбук " + tag, Description: "легкий", Price: 45000, Quantity: 3},
+		{Name: "Сумка", Description: "для ноутбука " + tag, Price: 2500, Quantity: 0},
+		{Name: "Мышь " + tag, Description: "беспроводная", Price: 900, Quantity: 10},
+		{Name: "Коврик", Description: "без метки", Price: 500, Quantity: 1},
+	}
+	ids, err := repo.CreateBatch(ctx, products)
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+
+	search := func(query models.ProductSearchQuery) *models.ProductSearchResult {
+		t.Helper()
+		if err := query.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		result, err := repo.Search(ctx, &query)
+		if err != nil {
+			t.Fatalf("Search(%+v): %v", query, err)
+		}
+		return result
+	}
+	resultIDs := func(result *models.ProductSearchResult) []int64 {
+		ids := make([]int64, len(result.Items))
+		for i, item := range result.Items {
+			ids[i] = item.Product.ID
+		}
+		return ids
+	}
+
+	// Все термы обязательны, терм - начало слова, регистр не важен
+	result := search(models.ProductSearchQuery{Terms: []string{tag, "ноутбук"}, Sort: models.SortPriceAsc})
+	if got := resultIDs(result); result.Total != 2 || fmt.Sprint(got) != fmt.Sprint([]int64{ids[1], ids[0]}) {
+		t.Errorf("поиск по двум термам: total %d, товары %v, ожидались %v", result.Total, got, []int64{ids[1], ids[0]})
+	}
+
+	// Фасеты считаются по совпадениям с текстом без фильтров
+	result = search(models.ProductSearchQuery{Terms: []string{tag}, InStock: true, MinPrice: floatPtr(1000)})
+	if got := resultIDs(result); result.Total != 1 || len(got) != 1 || got[0] != ids[0] {
+		t.Errorf("фильтры: total %d, товары %v, ожидался %d", result.Total, got, ids[0])
+	}
+	if result.Facets.InStock != 2 {
+		t.Errorf("фасет in_stock: %d, ожидалось 2", result.Facets.InStock)
+	}
+	priceCounts := make([]int, len(result.Facets.Price))
+	for i, bucket := range result.Facets.Price {
+		priceCounts[i] = bucket.Count
+	}
+	if fmt.Sprint(priceCounts) != fmt.Sprint([]int{1, 1, 0, 1, 0}) {
+		t.Errorf("фасет цены: %v", priceCounts)
+	}
+
+	// Без термов релевантности нет: находятся все товары под фильтрами, по умолчанию в порядке id
+	result = search(models.ProductSearchQuery{MinPrice: floatPtr(45000), MaxPrice: floatPtr(45000)})
+	if got := resultIDs(result); result.Total < 1 || len(got) == 0 {
+		t.Errorf("поиск без термов: total %d, товары %v, ожидался хотя бы %d", result.Total, got, ids[0])
+	} else {
+		for i := 1; i < len(got); i++ {
+			if got[i-1] >= got[i] {
+				t.Errorf("поиск без термов: товары %v не по возрастанию id", got)
+				break
+			}
+		}
+	}
+
+	// Страница
+	result = search(models.ProductSearchQuery{Terms: []string{tag}, Sort: models.SortPriceDesc, Limit: 1, Offset: 1})
+	if got := resultIDs(result); result.Total != 3 || len(got) != 1 || got[0] != ids[1] {
+		t.Errorf("вторая страница по убыванию цены: total %d, товары %v, ожидался %d", result.Total, got, ids[1])
+	}
+
+	// Индекс следует за изменением и удалением товаров
+	products[3].ID, products[3].Name = ids[3], "Коврик "+tag
+	if err := repo.Update(ctx, products[3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, ids[2], 0); err != nil {
+		t.Fatal(err)
+	}
+	result = search(models.ProductSearchQuery{Terms: []string{tag}, Sort: models.SortPriceAsc})
+	if got := resultIDs(result); fmt.Sprint(got) != fmt.Sprint([]int64{ids[3], ids[1], ids[0]}) {
+		t.Errorf("после изменения и удаления: товары %v, ожидались %v", got, []int64{ids[3], ids[1], ids[0]})
+	}
+
+	if result := search(models.ProductSearchQuery{Terms: []string{tag + "zz"}}); result.Total != 0 || len(result.Items) != 0 {
+		t.Errorf("несуществующее слово: %+v", result)
+	}
+}
+
+func testSalesCounts(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+
+	userID, err := repos.Users.Create(ctx, newUser())
+	if err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	sold, err := repos.Products.Create(ctx, newProduct(10))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+	unsold, err := repos.Products.Create(ctx, newProduct(10))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+
+	for _, purchase := range []*models.Purchase{
+		{UserID: userID, ProductID: sold, Quantity: 2, TotalPrice: 20, Status: "pending"},
+		{UserID: userID, ProductID: sold, Quantity: 3, TotalPrice: 30, Status: "completed"},
+		{UserID: userID, ProductID: sold, Quantity: 5, TotalPrice: 50, Status: "cancelled"},
+	} {
+		if _, err := repos.Purchases.Create(ctx, purchase); err != nil {
+			t.Fatalf("Purchases.Create: %v", err)
+		}
+	}
+
+	sales, err := repos.Products.SalesCounts(ctx)
+	if err != nil {
+		t.Fatalf("SalesCounts: %v", err)
+	}
+	if sales[sold] != 5 {
+		t.Errorf("продажи товара %d: %d, ожидалось 5 (отмененные покупки не считаются)", sold, sales[sold])
+	}
+	if count, ok := sales[unsold]; ok {
+		t.Errorf("товар без продаж попал в результат: %d", count)
+	}
+
+	// ForEachCompleted отдает только завершенные покупки, измененные не раньше since
+	completed := func(since time.Time) []*models.Purchase {
+		t.Helper()
+		var found []*models.Purchase
+		err := repos.Purchases.ForEachCompleted(ctx, since, func(purchase *models.Purchase) error {
+			if purchase.ProductID == sold {
+				found = append(found, purchase)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ForEachCompleted: %v", err)
+		}
+		return found
+	}
+	if found := completed(time.Now().Add(-time.Hour)); len(found) != 1 || found[0].Quantity != 3 || found[0].Status != "completed" {
+		t.Errorf("ForEachCompleted: ожидалась одна завершенная покупка, получено %+v", found)
+	}
+	if found := completed(time.Now().Add(time.Hour)); len(found) != 0 {
+		t.Errorf("ForEachCompleted с since в будущем: получено %+v", found)
+	}
+
+	// ForEachCreated отдает покупки любого статуса, созданные в [from, to)
+	created := func(from, to time.Time) int {
+		t.Helper()
+		count := 0
+		err := repos.Purchases.ForEachCreated(ctx, from, to, func(purchase *models.Purchase) error {
+			if purchase.ProductID == sold {
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ForEachCreated: %v", err)
+		}
+		return count
+	}
+	now := time.Now()
+	if count := created(now.Add(-time.Hour), now.Add(time.Hour)); count != 3 {
+		t.Errorf("ForEachCreated: %d покупок, ожидалось 3", count)
+	}
+	if count := created(now.Add(time.Hour), now.Add(2*time.Hour)); count != 0 {
+		t.Errorf("ForEachCreated за будущий час: %d покупок", count)
+	}
+}
+
+func testCategories(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Categories
+
+	rootID, err := repo.Create(ctx, &models.Category{Name: unique("root")})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	childID, err := repo.Create(ctx, &models.Category{Name: unique("child"), ParentID: &rootID})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	child, err := repo.GetByID(ctx, childID)
+	if err != nil || child == nil {
+		t.Fatalf("GetByID: (%+v, %v)", child, err)
+	}
+	if child.ParentID == nil || *child.ParentID != rootID || child.Version != 1 {
+		t.Errorf("GetByID: %+v, ожидался родитель %d и версия 1", child, rootID)
+	}
+	if missing, err := repo.GetByID(ctx, -1); err != nil || missing != nil {
+		t.Errorf("GetByID несуществующей: (%+v, %v), ожидалось (nil, nil)", missing, err)
+	}
+
+	if has, err := repo.HasChildren(ctx, rootID); err != nil || !has {
+		t.Errorf("HasChildren корня: (%v, %v), ожидалось true", has, err)
+	}
+	if has, err := repo.HasChildren(ctx, childID); err != nil || has {
+		t.Errorf("HasChildren листа: (%v, %v), ожидалось false", has, err)
+	}
+	err = repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		locked, err := repo.GetByIDForUpdate(ctx, childID)
+		if err != nil || locked == nil || locked.ID != childID {
+			t.Errorf("GetByIDForUpdate: (%+v, %v)", locked, err)
+		}
+		if missing, err := repo.GetByIDForUpdate(ctx, -1); err != nil || missing != nil {
+			t.Errorf("GetByIDForUpdate несуществующей: (%+v, %v), ожидалось (nil, nil)", missing, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TxManager.Do: %v", err)
+	}
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	getID := func(c *models.Category) int64 { return c.ID }
+	if !containsID(all, rootID, getID) || !containsID(all, childID, getID) {
+		t.Errorf("GetAll: нет созданных категорий %d и %d", rootID, childID)
+	}
+
+	// Перенос в корень с проверкой версии
+	child.ParentID = nil
+	if err := repo.Update(ctx, child); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.Update(ctx, child); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("Update с устаревшей версией: %v, ожидался ErrVersionConflict", err)
+	}
+	if moved, _ := repo.GetByID(ctx, childID); moved == nil || moved.ParentID != nil || moved.Version != 2 {
+		t.Errorf("после переноса: %+v", moved)
+	}
+
+	// Связи с товарами: повторы схлопываются, удаление категории снимает ее с товара
+	productID, err := repos.Products.Create(ctx, newProduct(1))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+	if err := repo.SetProductCategories(ctx, productID, []int64{childID, rootID, childID}); err != nil {
+		t.Fatalf("SetProductCategories: %v", err)
+	}
+	categories, err := repo.GetProductCategories(ctx, productID)
+	if err != nil || len(categories) != 2 || categories[0].ID != rootID || categories[1].ID != childID {
+		t.Fatalf("GetProductCategories: (%v, %v), ожидались %d и %d", categories, err, rootID, childID)
+	}
+	// Как и в сервисе, замена идет в транзакции: при ошибке прежние связи остаются
+	err = repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		return repo.SetProductCategories(ctx, productID, []int64{-1})
+	})
+	if err == nil {
+		t.Error("SetProductCategories с несуществующей категорией: ожидалась ошибка")
+	}
+
+	products, err := repos.Products.GetByFilter(ctx, models.ProductFilter{CategoryIDs: []int64{childID}})
+	if err != nil || len(products) != 1 || products[0].ID != productID {
+		t.Errorf("GetByFilter по категории: (%v, %v), ожидался товар %d", products, err, productID)
+	}
+
+	if err := repo.Delete(ctx, childID, 2); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	categories, err = repo.GetProductCategories(ctx, productID)
+	if err != nil || len(categories) != 1 || categories[0].ID != rootID {
+		t.Errorf("категории товара после удаления категории: (%v, %v), ожидалась %d", categories, err, rootID)
+	}
+
+	// Категорию с подкатегориями удалить нельзя
+	nestedID, err := repo.Create(ctx, &models.Category{Name: unique("nested"), ParentID: &rootID})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repo.Delete(ctx, rootID, 0); err == nil {
+		t.Error("Delete категории с подкатегорией: ожидалась ошибка")
+	}
+	if err := repo.Delete(ctx, nestedID, 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// Удаление товара удаляет его связи
+	if err := repos.Products.Delete(ctx, productID, 0); err != nil {
+		t.Fatalf("Products.Delete: %v", err)
+	}
+	if categories, err := repo.GetProductCategories(ctx, productID); err != nil || len(categories) != 0 {
+		t.Errorf("категории удаленного товара: (%v, %v)", categories, err)
+	}
+	if err := repo.Delete(ctx, rootID, 0); err != nil {
+		t.Errorf("Delete пустой категории: %v", err)
+	}
+}
+
+func testTags(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Tags
+
+	// Уникальное слово из букв и цифр: оно же - слово для полнотекстового поиска
+	word := fmt.Sprintf("tg%d", time.Now().UnixNano()+uniqueCounter.Add(1))
+	tagA, tagB := word+"a", word+"b"
+	ids, err := repos.Products.CreateBatch(ctx, []*models.Product{
+		{Name: "Мышь " + word, Price: 900, Quantity: 5},
+		{Name: "Клавиатура " + word, Price: 4500, Quantity: 0},
+		{Name: "Монитор " + word, Price: 25000, Quantity: 2},
+	})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+
+	if err := repo.SetProductTags(ctx, ids[0], []string{tagA, tagB}); err != nil {
+		t.Fatalf("SetProductTags: %v", err)
+	}
+	if err := repo.SetProductTags(ctx, ids[1], []string{tagB}); err != nil {
+		t.Fatalf("SetProductTags: %v", err)
+	}
+	tags, err := repo.GetProductTags(ctx, ids[0])
+	if err != nil || fmt.Sprint(tags) != fmt.Sprint([]string{tagA, tagB}) {
+		t.Errorf("GetProductTags: (%v, %v), ожидались %v", tags, err, []string{tagA, tagB})
+	}
+	if tags, err := repo.GetProductTags(ctx, ids[2]); err != nil || tags == nil || len(tags) != 0 {
+		t.Errorf("GetProductTags товара без тегов: (%#v, %v), ожидался пустой список", tags, err)
+	}
+
+	// Замена набора: старые теги снимаются
+	if err := repo.SetProductTags(ctx, ids[0], []string{tagB}); err != nil {
+		t.Fatalf("SetProductTags: %v", err)
+	}
+	counts, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	found := map[string]int{}
+	for _, count := range counts {
+		found[count.Name] = count.Products
+	}
+	if _, ok := found[tagA]; ok || found[tagB] != 2 {
+		t.Errorf("GetAll: %s=%d, %s=%d; ожидалось, что %s без товаров не показан, а у %s два товара",
+			tagA, found[tagA], tagB, found[tagB], tagA, tagB)
+	}
+
+	products, err := repos.Products.GetByFilter(ctx, models.ProductFilter{Tag: tagB})
+	if err != nil || len(products) != 2 || products[0].ID != ids[0] || products[1].ID != ids[1] {
+		t.Errorf("GetByFilter по тегу: (%v, %v), ожидались %d и %d", products, err, ids[0], ids[1])
+	}
+
+	// Тег ограничивает и результаты поиска, и фасеты
+	query := models.ProductSearchQuery{Terms: []string{word}, Tag: tagB, InStock: true}
+	if err := query.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := repos.Products.Search(ctx, &query)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.Total != 1 || len(result.Items) != 1 || result.Items[0].Product.ID != ids[0] || result.Facets.InStock != 1 {
+		t.Errorf("поиск с тегом: total %d, in_stock %d, %+v", result.Total, result.Facets.InStock, result.Items)
+	}
+	if err := repo.SetProductTags(ctx, ids[0], nil); err != nil {
+		t.Fatalf("SetProductTags без тегов: %v", err)
+	}
+	if tags, err := repo.GetProductTags(ctx, ids[0]); err != nil || len(tags) != 0 {
+		t.Errorf("теги после снятия: (%v, %v)", tags, err)
+	}
+}
+
+func testVariants(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Variants
+
+	userID, err := repos.Users.Create(ctx, newUser())
+	if err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	productID, err := repos.Products.Create(ctx, newProduct(0))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+
+	sku := unique("sku")
+	variant := &models.ProductVariant{ProductID: productID, SKU: sku, Attributes: models.VariantAttributes{"color": "черный", "size": "M"}, Price: 249.5, Quantity: 3}
+	id, err := repo.Create(ctx, variant)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second, err := repo.Create(ctx, &models.ProductVariant{ProductID: productID, SKU: unique("sku"), Price: 199, Quantity: 1})
+	if err != nil {
+		t.Fatalf("Create без характеристик: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got == nil || got.ProductID != productID || got.SKU != sku || got.Price != 249.5 || got.Quantity != 3 ||
+		got.Version != 1 || got.Attributes["color"] != "черный" || got.Attributes["size"] != "M" {
+		t.Fatalf("GetByID: получено %+v", got)
+	}
+	if bySKU, err := repo.GetBySKU(ctx, sku); err != nil || bySKU == nil || bySKU.ID != id {
+		t.Errorf("GetBySKU: (%+v, %v), ожидался вариант %d", bySKU, err, id)
+	}
+	if missing, err := repo.GetBySKU(ctx, unique("sku")); err != nil || missing != nil {
+		t.Errorf("GetBySKU свободного артикула: (%+v, %v), ожидалось (nil, nil)", missing, err)
+	}
+	variants, err := repo.GetByProductID(ctx, productID)
+	if err != nil || len(variants) != 2 || variants[0].ID != id || variants[1].ID != second {
+		t.Fatalf("GetByProductID: (%+v, %v), ожидались %d и %d", variants, err, id, second)
+	}
+	if variants[1].Attributes == nil || len(variants[1].Attributes) != 0 {
+		t.Errorf("характеристики варианта без них: %#v, ожидался пустой объект", variants[1].Attributes)
+	}
+
+	if _, err := repo.Create(ctx, &models.ProductVariant{ProductID: productID, SKU: sku, Price: 1}); err == nil {
+		t.Errorf("Create с занятым артикулом: ожидалась ошибка")
+	}
+	if _, err := repo.Create(ctx, &models.ProductVariant{ProductID: productID + 1_000_000, SKU: unique("sku"), Price: 1}); err == nil {
+		t.Errorf("Create с несуществующим товаром: ожидалась ошибка")
+	}
+
+	// Остаток варианта списывается атомарно и не уходит в минус
+	if err := repo.DecreaseQuantity(ctx, id, 2); err != nil {
+		t.Fatalf("DecreaseQuantity: %v", err)
+	}
+	if err := repo.DecreaseQuantity(ctx, id, 2); !errors.Is(err, models.ErrInsufficientStock) {
+		t.Errorf("DecreaseQuantity сверх остатка: %v, ожидался ErrInsufficientStock", err)
+	}
+
+	got.SKU = unique("sku")
+	got.Attributes = models.VariantAttributes{"color": "белый"}
+	got.Quantity = 7
+	if err := repo.Update(ctx, got); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("Update устаревшей версии: %v, ожидался ErrVersionConflict", err)
+	}
+	got.Version = 2
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	updated, err := repo.GetByID(ctx, id)
+	if err != nil || updated.Version != 3 || updated.Quantity != 7 || len(updated.Attributes) != 1 || updated.Attributes["color"] != "белый" {
+		t.Errorf("GetByID после Update: (%+v, %v)", updated, err)
+	}
+
+	// Покупка ссылается на вариант; после удаления варианта ссылка обнуляется
+	purchaseID, err := repos.Purchases.Create(ctx, &models.Purchase{UserID: userID, ProductID: productID, VariantID: &id, Quantity: 1, TotalPrice: 249.5, Status: "pending"})
+	if err != nil {
+		t.Fatalf("Purchases.Create с вариантом: %v", err)
+	}
+	if purchase, err := repos.Purchases.GetByID(ctx, purchaseID); err != nil || purchase.VariantID == nil || *purchase.VariantID != id {
+		t.Errorf("покупка варианта: (%+v, %v)", purchase, err)
+	}
+	if err := repo.Delete(ctx, id, 3); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if deleted, err := repo.GetByID(ctx, id); err != nil || deleted != nil {
+		t.Errorf("GetByID после Delete: (%+v, %v)", deleted, err)
+	}
+	if purchase, err := repos.Purchases.GetByID(ctx, purchaseID); err != nil || purchase == nil || purchase.VariantID != nil {
+		t.Errorf("покупка после удаления варианта: (%+v, %v), ожидалась покупка без variant_id", purchase, err)
+	}
+
+	// Варианты удаляются вместе с товаром
+	if err := repos.Products.Delete(ctx, productID, 0); err != nil {
+		t.Fatalf("Products.Delete: %v", err)
+	}
+	if remaining, err := repo.GetByID(ctx, second); err != nil || remaining != nil {
+		t.Errorf("вариант после удаления товара: (%+v, %v)", remaining, err)
+	}
+}
+
+// testVariantStock проверяет, что наличие товара с вариантами в поиске считается по вариантам
+func testVariantStock(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	word := fmt.Sprintf("vs%d", time.Now().UnixNano()+uniqueCounter.Add(1))
+
+	// Остаток самого товара с вариантами не учитывается
+	products := []*models.Product{
+		{Name: "Телефон " + word, Price: 100, Quantity: 0},
+		{Name: "Планшет " + word, Price: 100, Quantity: 5},
+		{Name: "Чехол " + word, Price: 100, Quantity: 3},
+	}
+	ids, err := repos.Products.CreateBatch(ctx, products)
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	for _, variant := range []*models.ProductVariant{
+		{ProductID: ids[0], SKU: unique("sku"), Price: 100, Quantity: 0},
+		{ProductID: ids[0], SKU: unique("sku"), Price: 100, Quantity: 2},
+		{ProductID: ids[1], SKU: unique("sku"), Price: 100, Quantity: 0},
+	} {
+		if _, err := repos.Variants.Create(ctx, variant); err != nil {
+			t.Fatalf("Variants.Create: %v", err)
+		}
+	}
+
+	query := models.ProductSearchQuery{Terms: []string{word}, InStock: true}
+	if err := query.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := repos.Products.Search(ctx, &query)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	got := make([]int64, len(result.Items))
+	for i, item := range result.Items {
+		got[i] = item.Product.ID
+	}
+	slices.Sort(got)
+	if want := []int64{ids[0], ids[2]}; fmt.Sprint(got) != fmt.Sprint(want) || result.Facets.InStock != 2 {
+		t.Errorf("в наличии: товары %v, фасет %d; ожидались %v и 2", got, result.Facets.InStock, want)
+	}
+}
+
+func testRollups(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Rollups
+
+	// Дни далеко в прошлом не пересекаются с покупками других тестов; строки прошлых запусков удаляются
+	day := time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)
+	if err := repo.Replace(ctx, day, day.AddDate(0, 0, 3), nil); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	rollups := func(get func(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error)) string {
+		t.Helper()
+		got, err := get(ctx, day, day.AddDate(0, 0, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := make([]string, len(got))
+		for i, r := range got {
+			rows[i] = fmt.Sprintf("%s/%d/%d:%d/%d/%d/%d/%v", r.Day.UTC().Format(models.ReportDateLayout),
+				r.ProductID, r.UserID, r.Orders, r.Completed, r.Cancelled, r.Units, r.Revenue)
+		}
+		return fmt.Sprint(rows)
+	}
+
+	// Время внутри дня отбрасывается; дельты одного дня складываются
+	err := repo.Apply(ctx, []models.SalesRollup{
+		{Day: day.Add(13 * time.Hour), ProductID: 7, UserID: 3, Orders: 1, Completed: 1, Units: 2, Revenue: 10.5},
+		{Day: day, ProductID: 7, UserID: 4, Orders: 1, Cancelled: 1},
+		{Day: day.AddDate(0, 0, 1), ProductID: 5, UserID: 3, Orders: 1},
+		{Day: day.AddDate(0, 0, 2), ProductID: 7, UserID: 3, Orders: 1},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got, want := rollups(repo.ProductRollups), "[2001-02-03/7/0:2/1/1/2/10.5 2001-02-04/5/0:1/0/0/0/0]"; got != want {
+		t.Errorf("ProductRollups: %s, ожидалось %s", got, want)
+	}
+	if got, want := rollups(repo.UserRollups), "[2001-02-03/0/3:1/1/0/2/10.5 2001-02-03/0/4:1/0/1/0/0 2001-02-04/0/3:1/0/0/0/0]"; got != want {
+		t.Errorf("UserRollups: %s, ожидалось %s", got, want)
+	}
+
+	// Отрицательная дельта переносит покупку из завершенных в отмененные
+	err = repo.Apply(ctx, []models.SalesRollup{{Day: day, ProductID: 7, UserID: 3, Completed: -1, Cancelled: 1, Units: -2, Revenue: -10.5}})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got, want := rollups(repo.ProductRollups), "[2001-02-03/7/0:2/0/2/0/0 2001-02-04/5/0:1/0/0/0/0]"; got != want {
+		t.Errorf("ProductRollups после отмены: %s, ожидалось %s", got, want)
+	}
+
+	// Replace заменяет только дни диапазона
+	err = repos.TxManager.Do(ctx, func(ctx context.Context) error {
+		return repo.Replace(ctx, day.AddDate(0, 0, 1), day.AddDate(0, 0, 2), []models.SalesRollup{
+			{Day: day.AddDate(0, 0, 1), ProductID: 6, UserID: 3, Orders: 2, Completed: 2, Units: 3, Revenue: 99.99},
+		})
+	})
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if got, want := rollups(repo.ProductRollups), "[2001-02-03/7/0:2/0/2/0/0 2001-02-04/6/0:2/2/0/3/99.99]"; got != want {
+		t.Errorf("ProductRollups после Replace: %s, ожидалось %s", got, want)
+	}
+	if got, want := rollups(repo.UserRollups), "[2001-02-03/0/3:1/0/1/0/0 2001-02-03/0/4:1/0/1/0/0 2001-02-04/0/3:2/2/0/3/99.99]"; got != want {
+		t.Errorf("UserRollups после Replace: %s, ожидалось %s", got, want)
+	}
+}
+
+func testPrices(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Prices
+
+	productID, err := repos.Products.Create(ctx, newProduct(5))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+	// prices сводит историю товара к ценам от новых к старым
+	prices := func(id int64, until time.Time) string {
+		t.Helper()
+		history, err := repo.GetHistory(ctx, id, until)
+		if err != nil {
+			t.Fatalf("GetHistory: %v", err)
+		}
+		got := make([]float64, len(history))
+		for i, change := range history {
+			if change.ProductID != id || change.ChangedAt.IsZero() {
+				t.Errorf("запись истории %+v", change)
+			}
+			got[i] = change.Price
+		}
+		return fmt.Sprint(got)
+	}
+
+	// История пишется хранилищем при создании товара и при каждом изменении цены
+	if got := prices(productID, time.Time{}); got != "[199.99]" {
+		t.Errorf("история нового товара: %s", got)
+	}
+	if err := repos.Products.Patch(ctx, productID, &models.ProductPatch{Quantity: intPtr(4)}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if err := repos.Products.Patch(ctx, productID, &models.ProductPatch{Price: floatPtr(149.5)}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if err := repos.Products.Update(ctx, &models.Product{ID: productID, Name: unique("product"), Price: 99}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := prices(productID, time.Time{}); got != "[99 149.5 199.99]" {
+		t.Errorf("история после изменений цены: %s", got)
+	}
+	if got := prices(productID, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); got != "[]" {
+		t.Errorf("история до создания товара: %s", got)
+	}
+	batch, err := repos.Products.CreateBatch(ctx, []*models.Product{newProduct(1)})
+	if err != nil {
+		t.Fatalf("Products.CreateBatch: %v", err)
+	}
+	if got := prices(batch[0], time.Time{}); got != "[199.99]" {
+		t.Errorf("история товара из пакета: %s", got)
+	}
+
+	// Запланированные цены: одна уже наступила (в прошлом), другая в будущем
+	past := time.Date(2001, 1, 1, 12, 0, 0, 0, time.UTC)
+	future := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	dueID, err := repo.CreateScheduled(ctx, &models.ScheduledPrice{ProductID: productID, Price: 89.5, EffectiveAt: past})
+	if err != nil {
+		t.Fatalf("CreateScheduled: %v", err)
+	}
+	futureID, err := repo.CreateScheduled(ctx, &models.ScheduledPrice{ProductID: productID, Price: 79, EffectiveAt: future})
+	if err != nil {
+		t.Fatalf("CreateScheduled: %v", err)
+	}
+	if _, err := repo.CreateScheduled(ctx, &models.ScheduledPrice{ProductID: productID + 1_000_000, Price: 1, EffectiveAt: future}); err == nil {
+		t.Errorf("CreateScheduled с несуществующим товаром: ожидалась ошибка")
+	}
+
+	got, err := repo.GetScheduledByID(ctx, dueID)
+	if err != nil || got == nil || got.ProductID != productID || got.Price != 89.5 || !got.EffectiveAt.Equal(past) ||
+		got.CreatedAt.IsZero() || got.AppliedAt != nil {
+		t.Fatalf("GetScheduledByID: (%+v, %v)", got, err)
+	}
+	scheduled, err := repo.GetScheduled(ctx, productID)
+	if err != nil || len(scheduled) != 2 || scheduled[0].ID != dueID || scheduled[1].ID != futureID {
+		t.Fatalf("GetScheduled: (%+v, %v), ожидались %d и %d", scheduled, err, dueID, futureID)
+	}
+	due, err := repo.DueScheduled(ctx, past.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("DueScheduled: %v", err)
+	}
+	if !containsID(due, dueID, func(s *models.ScheduledPrice) int64 { return s.ID }) || containsID(due, futureID, func(s *models.ScheduledPrice) int64 { return s.ID }) {
+		t.Errorf("DueScheduled: %+v, ожидалась только цена %d", due, dueID)
+	}
+	if next, err := repo.NextScheduled(ctx); err != nil || next == nil || next.After(past) {
+		t.Errorf("NextScheduled: (%v, %v), ожидалось не позже %v", next, err, past)
+	}
+
+	// Примененную цену забирает только первый MarkApplied, и она пропадает из списков
+	if claimed, err := repo.MarkApplied(ctx, dueID, time.Now()); err != nil || !claimed {
+		t.Fatalf("MarkApplied: (%v, %v), ожидалось true", claimed, err)
+	}
+	if claimed, err := repo.MarkApplied(ctx, dueID, time.Now()); err != nil || claimed {
+		t.Errorf("повторный MarkApplied: (%v, %v), ожидалось false", claimed, err)
+	}
+	if applied, err := repo.GetScheduledByID(ctx, dueID); err != nil || applied == nil || applied.AppliedAt == nil {
+		t.Errorf("GetScheduledByID примененной цены: (%+v, %v)", applied, err)
+	}
+	due, err = repo.DueScheduled(ctx, past.Add(time.Hour))
+	if err != nil || containsID(due, dueID, func(s *models.ScheduledPrice) int64 { return s.ID }) {
+		t.Errorf("DueScheduled после MarkApplied: (%+v, %v)", due, err)
+	}
+
+	// Удаляются только непримененные цены
+	if err := repo.DeleteScheduled(ctx, dueID); err != nil {
+		t.Fatalf("DeleteScheduled примененной цены: %v", err)
+	}
+	if applied, err := repo.GetScheduledByID(ctx, dueID); err != nil || applied == nil {
+		t.Errorf("примененная цена после DeleteScheduled: (%+v, %v), ожидалось, что она сохранится", applied, err)
+	}
+	if err := repo.DeleteScheduled(ctx, futureID); err != nil {
+		t.Fatalf("DeleteScheduled: %v", err)
+	}
+	if scheduled, err := repo.GetScheduled(ctx, productID); err != nil || len(scheduled) != 0 {
+		t.Errorf("GetScheduled после удаления: (%+v, %v)", scheduled, err)
+	}
+
+	// История и запланированные цены удаляются вместе с товаром
+	if err := repos.Products.Delete(ctx, productID, 0); err != nil {
+		t.Fatalf("Products.Delete: %v", err)
+	}
+	if got := prices(productID, time.Time{}); got != "[]" {
+		t.Errorf("история после удаления товара: %s", got)
+	}
+	if applied, err := repo.GetScheduledByID(ctx, dueID); err != nil || applied != nil {
+		t.Errorf("запланированная цена после удаления товара: (%+v, %v)", applied, err)
+	}
+}
+
+func testPromotions(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	repo := repos.Promotions
+
+	userID, err := repos.Users.Create(ctx, newUser())
+	if err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	expiresAt := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	coupon := &models.Coupon{Code: unique("C"), Type: models.DiscountPercent, Value: 12.5, MaxUses: intPtr(5),
+		PerUserLimit: intPtr(2), MinOrderValue: 1000, ExpiresAt: &expiresAt}
+	couponID, err := repo.CreateCoupon(ctx, coupon)
+	if err != nil {
+		t.Fatalf("CreateCoupon: %v", err)
+	}
+	if _, err := repo.CreateCoupon(ctx, &models.Coupon{Code: coupon.Code, Type: models.DiscountFixed, Value: 1}); err == nil {
+		t.Errorf("CreateCoupon: ожидалась ошибка для повторяющегося кода")
+	}
+
+	got, err := repo.GetCouponByID(ctx, couponID)
+	if err != nil || got == nil || got.Code != coupon.Code || got.Type != coupon.Type || got.Value != 12.5 ||
+		got.MaxUses == nil || *got.MaxUses != 5 || got.PerUserLimit == nil || *got.PerUserLimit != 2 ||
+		got.MinOrderValue != 1000 || got.ExpiresAt == nil || !got.ExpiresAt.Equal(expiresAt) || got.Uses != 0 || got.CreatedAt.IsZero() {
+		t.Fatalf("GetCouponByID: (%+v, %v)", got, err)
+	}
+	if byCode, err := repo.GetCouponByCode(ctx, coupon.Code); err != nil || byCode == nil || byCode.ID != couponID {
+		t.Errorf("GetCouponByCode: (%+v, %v)", byCode, err)
+	}
+	if missing, err := repo.GetCouponByCode(ctx, unique("missing")); err != nil || missing != nil {
+		t.Errorf("GetCouponByCode несуществующего: (%+v, %v), ожидалось nil", missing, err)
+	}
+	unlimitedID, err := repo.CreateCoupon(ctx, &models.Coupon{Code: unique("C"), Type: models.DiscountFixed, Value: 300})
+	if err != nil {
+		t.Fatalf("CreateCoupon без лимитов: %v", err)
+	}
+	if unlimited, err := repo.GetCouponByID(ctx, unlimitedID); err != nil || unlimited.MaxUses != nil || unlimited.PerUserLimit != nil || unlimited.ExpiresAt != nil {
+		t.Errorf("промокод без лимитов: (%+v, %v)", unlimited, err)
+	}
+	coupons, err := repo.GetCoupons(ctx)
+	if err != nil || !containsID(coupons, couponID, func(c *models.Coupon) int64 { return c.ID }) {
+		t.Errorf("GetCoupons: (%d промокодов, %v), ожидался %d", len(coupons), err, couponID)
+	}
+
+	// Параллельные применения не превышают max_uses
+	var claims atomic.Int64
+	done := make(chan error)
+	for i := 0; i < 12; i++ {
+		go func() {
+			claimed, err := repo.ClaimCoupon(ctx, couponID)
+			if claimed {
+				claims.Add(1)
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < 12; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("ClaimCoupon: %v", err)
+		}
+	}
+	if claims.Load() != 5 {
+		t.Errorf("ClaimCoupon: успешно %d раз, ожидалось 5 (max_uses)", claims.Load())
+	}
+	if got, err := repo.GetCouponByID(ctx, couponID); err != nil || got.Uses != 5 {
+		t.Errorf("uses после ClaimCoupon: (%+v, %v), ожидалось 5", got, err)
+	}
+	if claimed, err := repo.ClaimCoupon(ctx, unlimitedID); err != nil || !claimed {
+		t.Errorf("ClaimCoupon без лимита: (%v, %v), ожидалось true", claimed, err)
+	}
+
+	// Лимит пользователя считается отдельно для каждого пользователя
+	for i, want := range []bool{true, true, false} {
+		if claimed, err := repo.ClaimUserCoupon(ctx, couponID, userID, 2); err != nil || claimed != want {
+			t.Errorf("ClaimUserCoupon %d: (%v, %v), ожидалось %v", i+1, claimed, err, want)
+		}
+	}
+	otherID, err := repos.Users.Create(ctx, newUser())
+	if err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	if claimed, err := repo.ClaimUserCoupon(ctx, couponID, otherID, 2); err != nil || !claimed {
+		t.Errorf("ClaimUserCoupon другого пользователя: (%v, %v), ожидалось true", claimed, err)
+	}
+	if _, err := repo.ClaimUserCoupon(ctx, couponID, otherID+1_000_000, 2); err == nil {
+		t.Errorf("ClaimUserCoupon с несуществующим пользователем: ожидалась ошибка")
+	}
+
+	// Отмена покупки возвращает применение: освобождаются и max_uses, и лимит пользователя
+	if err := repo.AdjustCouponUses(ctx, couponID, userID, -1); err != nil {
+		t.Fatalf("AdjustCouponUses: %v", err)
+	}
+	if got, err := repo.GetCouponByID(ctx, couponID); err != nil || got.Uses != 4 {
+		t.Errorf("uses после AdjustCouponUses: (%+v, %v), ожидалось 4", got, err)
+	}
+	if claimed, err := repo.ClaimCoupon(ctx, couponID); err != nil || !claimed {
+		t.Errorf("ClaimCoupon после AdjustCouponUses: (%v, %v), ожидалось true", claimed, err)
+	}
+	if claimed, err := repo.ClaimUserCoupon(ctx, couponID, userID, 2); err != nil || !claimed {
+		t.Errorf("ClaimUserCoupon после AdjustCouponUses: (%v, %v), ожидалось true", claimed, err)
+	}
+	// Без счетчика пользователя меняется только uses, и ниже нуля он не опускается
+	for i := 0; i < 2; i++ {
+		if err := repo.AdjustCouponUses(ctx, unlimitedID, userID, -1); err != nil {
+			t.Fatalf("AdjustCouponUses без лимита: %v", err)
+		}
+	}
+	if got, err := repo.GetCouponByID(ctx, unlimitedID); err != nil || got.Uses != 0 {
+		t.Errorf("uses после AdjustCouponUses ниже нуля: (%+v, %v), ожидалось 0", got, err)
+	}
+
+	// Скидки покупки сохраняются JSON-массивом, без скидок - пустым массивом
+	productID, err := repos.Products.Create(ctx, newProduct(5))
+	if err != nil {
+		t.Fatalf("Products.Create: %v", err)
+	}
+	discounts := models.PurchaseDiscounts{{Kind: models.DiscountKindCoupon, ID: couponID, Name: coupon.Code, Amount: 24.99}}
+	purchaseID, err := repos.Purchases.Create(ctx, &models.Purchase{UserID: userID, ProductID: productID, Quantity: 1, TotalPrice: 175, Status: "pending", Discounts: discounts})
+	if err != nil {
+		t.Fatalf("Purchases.Create: %v", err)
+	}
+	if purchase, err := repos.Purchases.GetByID(ctx, purchaseID); err != nil || len(purchase.Discounts) != 1 || purchase.Discounts[0] != discounts[0] {
+		t.Errorf("скидки покупки: (%+v, %v), ожидалось %+v", purchase, err, discounts)
+	}
+	purchaseID, err = repos.Purchases.Create(ctx, &models.Purchase{UserID: userID, ProductID: productID, Quantity: 1, TotalPrice: 199.99, Status: "pending"})
+	if err != nil {
+		t.Fatalf("Purchases.Create: %v", err)
+	}
+	if purchase, err := repos.Purchases.GetByID(ctx, purchaseID); err != nil || purchase.Discounts == nil || len(purchase.Discounts) != 0 {
+		t.Errorf("покупка без скидок: (%+v, %v), ожидался пустой список", purchase, err)
+	}
+
+	// Правила; правило категории удаляется вместе с категорией
+	categoryID, err := repos.Categories.Create(ctx, &models.Category{Name: unique("category")})
+	if err != nil {
+		t.Fatalf("Categories.Create: %v", err)
+	}
+	ruleID, err := repo.CreateRule(ctx, &models.PromotionRule{Name: "Категория от 3 штук", CategoryID: &categoryID, MinQuantity: 3, Type: models.DiscountPercent, Value: 10})
+	if err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+	anyID, err := repo.CreateRule(ctx, &models.PromotionRule{Name: "Любой товар", MinQuantity: 1, Type: models.DiscountFixed, Value: 50})
+	if err != nil {
+		t.Fatalf("CreateRule без категории: %v", err)
+	}
+	missingCategory := categoryID + 1_000_000
+	if _, err := repo.CreateRule(ctx, &models.PromotionRule{Name: "Нет категории", CategoryID: &missingCategory, MinQuantity: 1, Type: models.DiscountFixed, Value: 1}); err == nil {
+		t.Errorf("CreateRule с несуществующей категорией: ожидалась ошибка")
+	}
+	rule, err := repo.GetRuleByID(ctx, ruleID)
+	if err != nil || rule == nil || rule.CategoryID == nil || *rule.CategoryID != categoryID || rule.MinQuantity != 3 ||
+		rule.Type != models.DiscountPercent || rule.Value != 10 || rule.CreatedAt.IsZero() {
+		t.Fatalf("GetRuleByID: (%+v, %v)", rule, err)
+	}
+	rules, err := repo.GetRules(ctx)
+	if err != nil || !containsID(rules, ruleID, func(r *models.PromotionRule) int64 { return r.ID }) ||
+		!containsID(rules, anyID, func(r *models.PromotionRule) int64 { return r.ID }) {
+		t.Errorf("GetRules: (%d правил, %v), ожидались %d и %d", len(rules), err, ruleID, anyID)
+	}
+	if err := repos.Categories.Delete(ctx, categoryID, 0); err != nil {
+		t.Fatalf("Categories.Delete: %v", err)
+	}
+	if rule, err := repo.GetRuleByID(ctx, ruleID); err != nil || rule != nil {
+		t.Errorf("правило после удаления категории: (%+v, %v)", rule, err)
+	}
+	if err := repo.DeleteRule(ctx, anyID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	if rule, err := repo.GetRuleByID(ctx, anyID); err != nil || rule != nil {
+		t.Errorf("правило после DeleteRule: (%+v, %v)", rule, err)
+	}
+
+	// Счетчики пользователей удаляются вместе с промокодом
+	if err := repo.DeleteCoupon(ctx, couponID); err != nil {
+		t.Fatalf("DeleteCoupon: %v", err)
+	}
+	if got, err := repo.GetCouponByID(ctx, couponID); err != nil || got != nil {
+		t.Errorf("промокод после DeleteCoupon: (%+v, %v)", got, err)
+	}
+	if claimed, err := repo.ClaimCoupon(ctx, couponID); err != nil || claimed {
+		t.Errorf("ClaimCoupon удаленного промокода: (%v, %v), ожидалось false", claimed, err)
+	}
+	if err := repo.AdjustCouponUses(ctx, couponID, userID, -1); err != nil {
+		t.Errorf("AdjustCouponUses удаленного промокода: %v", err)
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func containsID[T any](items []*T, id int64, getID func(*T) int64) bool {
+	for _, item := range items {
+		if getID(item) == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+// CategoryCache - кеш дерева категорий в памяти процесса, замена redis.CategoryCache без внешних сервисов
+type CategoryCache struct {
+	trees *store[string, []models.CategoryNode]
+}
+
+func NewCategoryCache() *CategoryCache {
+	return &CategoryCache{
+		trees: newStore[string, []models.CategoryNode](),
+	}
+}
+
+func (c *CategoryCache) GetTree(ctx context.Context) ([]models.CategoryNode, error) {
+	tree, ok := c.trees.get("categories:tree")
+	if !ok {
+		return nil, nil // Кеш пуст
+	}
+	return tree, nil
+}
+
+func (c *CategoryCache) SetTree(ctx context.Context, tree []models.CategoryNode, expiration time.Duration) error {
+	// Пустое дерево хранится как пустой срез, чтобы GetTree отличал его от промаха
+	if tree == nil {
+		tree = []models.CategoryNode{}
+	}
+	c.trees.set("categories:tree", tree, expiration)
+	return nil
+}
+
+func (c *CategoryCache) InvalidateTree(ctx context.Context) error {
+	c.trees.delete("categories:tree")
+	return nil
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"slices"
+	"sort"
+)
+
+type CategoryRepository struct {
+	db *DB
+}
+
+func NewCategoryRepository(db *DB) *CategoryRepository {
+	return &CategoryRepository{
+		db: db,
+	}
+}
+
+func (r *CategoryRepository) GetByID(ctx context.Context, id int64) (*models.Category, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	category, ok := r.db.categories[id]
+	if !ok {
+		return nil, nil // Категория не найдена
+	}
+	return &category, nil
+}
+
+func (r *CategoryRepository) GetAll(ctx context.Context) ([]*models.Category, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	categories := make([]*models.Category, 0, len(r.db.categories))
+	for _, category := range r.db.categories {
+		category := category
+		categories = append(categories, &category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].ID < categories[j].ID })
+	return categories, nil
+}
+
+// GetByIDForUpdate - то же, что GetByID: блокировок строк в памяти нет
+func (r *CategoryRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.Category, error) {
+	return r.GetByID(ctx, id)
+}
+
+// HasChildren сообщает, есть ли у категории подкатегории
+func (r *CategoryRepository) HasChildren(ctx context.Context, id int64) (bool, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, category := range r.db.categories {
+		if category.ParentID != nil && *category.ParentID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, category *models.Category) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if category.ParentID != nil {
+		if _, ok := r.db.categories[*category.ParentID]; !ok {
+			return 0, ErrForeignKey
+		}
+	}
+
+	stored := *category
+	stored.ID = r.db.nextID("categories")
+	stored.Version = 1
+	stored.CreatedAt = r.db.now()
+	stored.UpdatedAt = stored.CreatedAt
+	r.db.categories[stored.ID] = stored
+	return stored.ID, nil
+}
+
+// Update перезаписывает название и родителя и увеличивает версию; category.Version проверяется, если задана
+func (r *CategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.categories[category.ID]
+	if err := checkVersion(ok, stored.Version, category.Version); err != nil || !ok {
+		return err // Как и UPDATE без совпадений в SQL
+	}
+	if category.ParentID != nil {
+		if _, ok := r.db.categories[*category.ParentID]; !ok {
+			return ErrForeignKey
+		}
+	}
+	stored.Version++
+	stored.Name = category.Name
+	stored.ParentID = category.ParentID
+	stored.UpdatedAt = r.db.now()
+	r.db.categories[category.ID] = stored
+	return nil
+}
+
+// Delete удаляет категорию вместе со связями с товарами; категорию с подкатегориями удалить нельзя (ON DELETE RESTRICT)
+func (r *CategoryRepository) Delete(ctx context.Context, id int64, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.categories[id]
+	if err := checkVersion(ok, stored.Version, version); err != nil {
+		return err
+	}
+	for _, category := range r.db.categories {
+		if category.ParentID != nil && *category.ParentID == id {
+			return ErrForeignKey
+		}
+	}
+	delete(r.db.categories, id)
+	// ON DELETE CASCADE
+	for productID, categoryIDs := range r.db.productCategories {
+		if slices.Contains(categoryIDs, id) {
+			r.db.productCategories[productID] = slices.DeleteFunc(slices.Clone(categoryIDs), func(c int64) bool { return c == id })
+		}
+	}
+	for ruleID, rule := range r.db.promotionRules {
+		if rule.CategoryID != nil && *rule.CategoryID == id {
+			delete(r.db.promotionRules, ruleID)
+		}
+	}
+	return nil
+}
+
+// GetProductCategories возвращает категории товара в порядке id
+func (r *CategoryRepository) GetProductCategories(ctx context.Context, productID int64) ([]*models.Category, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	categories := []*models.Category{}
+	for _, id := range r.db.productCategories[productID] {
+		category := r.db.categories[id]
+		categories = append(categories, &category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].ID < categories[j].ID })
+	return categories, nil
+}
+
+// SetProductCategories заменяет категории товара; товар и категории должны существовать
+func (r *CategoryRepository) SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.products[productID]; !ok {
+		return ErrForeignKey
+	}
+	ids := make([]int64, 0, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if _, ok := r.db.categories[id]; !ok {
+			return ErrForeignKey
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	r.db.productCategories[productID] = ids
+	return nil
+}
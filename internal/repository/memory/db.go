@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDuplicate - нарушение уникальности (аналог UNIQUE в SQL)
+	ErrDuplicate = errors.New("запись с такими данными уже существует")
+	// ErrForeignKey - связанная запись не существует (аналог FOREIGN KEY в SQL)
+	ErrForeignKey = errors.New("связанная запись не найдена")
+)
+
+// DB - таблицы в памяти процесса, общие для репозиториев memory.
+// Используется в тестах и как эталонная реализация контрактов репозиториев.
+type DB struct {
+	mu         sync.RWMutex
+	users      map[int64]models.User
+	products   map[int64]models.Product
+	purchases  map[int64]models.Purchase
+	categories map[int64]models.Category
+	variants   map[int64]models.ProductVariant
+	// productCategories и productTags - связи товаров (product_categories, product_tags);
+	// срезы не изменяются на месте, а заменяются, поэтому снимку достаточно копии карты
+	productCategories map[int64][]int64
+	productTags       map[int64][]string
+	lastID            map[string]int64
+	now               func() time.Time
+	// productRollups и userRollups - дневные агрегаты покупок (sales_daily_products, sales_daily_users)
+	productRollups map[rollupKey]models.SalesRollup
+	userRollups    map[rollupKey]models.SalesRollup
+	// productPrices и scheduledPrices - история и запланированные цены (product_prices, scheduled_prices)
+	productPrices   map[int64]models.PriceChange
+	scheduledPrices map[int64]models.ScheduledPrice
+	// coupons, couponUses и promotionRules - промокоды, их применения пользователями и правила скидок
+	coupons        map[int64]models.Coupon
+	couponUses     map[couponUseKey]int
+	promotionRules map[int64]models.PromotionRule
+}
+
+func NewDB() *DB {
+	return &DB{
+		users:             make(map[int64]models.User),
+		products:          make(map[int64]models.Product),
+		purchases:         make(map[int64]models.Purchase),
+		categories:        make(map[int64]models.Category),
+		variants:          make(map[int64]models.ProductVariant),
+		productCategories: make(map[int64][]int64),
+		productTags:       make(map[int64][]string),
+		productRollups:    make(map[rollupKey]models.SalesRollup),
+		userRollups:       make(map[rollupKey]models.SalesRollup),
+		productPrices:     make(map[int64]models.PriceChange),
+		scheduledPrices:   make(map[int64]models.ScheduledPrice),
+		coupons:           make(map[int64]models.Coupon),
+		couponUses:        make(map[couponUseKey]int),
+		promotionRules:    make(map[int64]models.PromotionRule),
+		lastID:            make(map[string]int64),
+		now:               time.Now,
+	}
+}
+
+// nextID выдает автоинкрементный идентификатор для таблицы; вызывается под d.mu
+func (d *DB) nextID(table string) int64 {
+	d.lastID[table]++
+	return d.lastID[table]
+}
+
+// recordPrice пишет цену товара в историю, как триггеры products в SQL; вызывается под d.mu
+func (d *DB) recordPrice(product *models.Product) {
+	id := d.nextID("product_prices")
+	d.productPrices[id] = models.PriceChange{ID: id, ProductID: product.ID, Price: product.Price, ChangedAt: product.UpdatedAt}
+}
+
+type snapshot struct {
+	users             map[int64]models.User
+	products          map[int64]models.Product
+	purchases         map[int64]models.Purchase
+	categories        map[int64]models.Category
+	variants          map[int64]models.ProductVariant
+	productCategories map[int64][]int64
+	productTags       map[int64][]string
+	productRollups    map[rollupKey]models.SalesRollup
+	userRollups       map[rollupKey]models.SalesRollup
+	productPrices     map[int64]models.PriceChange
+	scheduledPrices   map[int64]models.ScheduledPrice
+	coupons           map[int64]models.Coupon
+	couponUses        map[couponUseKey]int
+	promotionRules    map[int64]models.PromotionRule
+	lastID            map[string]int64
+}
+
+func (d *DB) snapshot() *snapshot {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return &snapshot{
+		users:             cloneMap(d.users),
+		products:          cloneMap(d.products),
+		purchases:         cloneMap(d.purchases),
+		categories:        cloneMap(d.categories),
+		variants:          cloneMap(d.variants),
+		productCategories: cloneMap(d.productCategories),
+		productTags:       cloneMap(d.productTags),
+		productRollups:    cloneMap(d.productRollups),
+		userRollups:       cloneMap(d.userRollups),
+		productPrices:     cloneMap(d.productPrices),
+		scheduledPrices:   cloneMap(d.scheduledPrices),
+		coupons:           cloneMap(d.coupons),
+		couponUses:        cloneMap(d.couponUses),
+		promotionRules:    cloneMap(d.promotionRules),
+		lastID:            cloneMap(d.lastID),
+	}
+}
+
+func (d *DB) restore(s *snapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.users = s.users
+	d.products = s.products
+	d.purchases = s.purchases
+	d.categories = s.categories
+	d.variants = s.variants
+	d.productCategories = s.productCategories
+	d.productTags = s.productTags
+	d.productRollups = s.productRollups
+	d.userRollups = s.userRollups
+	d.productPrices = s.productPrices
+	d.scheduledPrices = s.scheduledPrices
+	d.coupons = s.coupons
+	d.couponUses = s.couponUses
+	d.promotionRules = s.promotionRules
+	d.lastID = s.lastID
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	copied := make(map[K]V, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+// TxManager откатывает изменения DB, если fn вернула ошибку. Вложенные вызовы
+// откатывают только свои изменения, как SAVEPOINT. Изоляции между параллельными
+// транзакциями нет: это реализация для тестов и демо, а не для конкурентной нагрузки.
+type TxManager struct {
+	db *DB
+}
+
+func NewTxManager(db *DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+type txKey struct{}
+
+// txState - функции, отложенные до завершения внешнего вызова Do
+type txState struct {
+	afterCommit []func()
+}
+
+func (m *TxManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, _ := ctx.Value(txKey{}).(*txState)
+	state := &txState{}
+
+	s := m.db.snapshot()
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		m.db.restore(s)
+		return err
+	}
+
+	if parent != nil {
+		parent.afterCommit = append(parent.afterCommit, state.afterCommit...)
+		return nil
+	}
+	for _, f := range state.afterCommit {
+		f()
+	}
+	return nil
+}
+
+// AfterCommit откладывает fn до успешного завершения внешнего вызова Do; вне Do вызывает fn сразу
+func (m *TxManager) AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// checkVersion повторяет поведение SQL-репозиториев: при заданной версии (не 0) отсутствующая запись
+// или запись с другой версией дают ErrVersionConflict, без версии отсутствующая запись не ошибка
+func checkVersion(found bool, stored, expected int64) error {
+	if expected != 0 && (!found || stored != expected) {
+		return models.ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/contract"
+	"testing"
+)
+
+func TestRepositoriesContract(t *testing.T) {
+	contract.RunRepositories(t, func(t *testing.T) contract.Repositories {
+		db := NewDB()
+		return contract.Repositories{
+			Users:      NewUserRepository(db),
+			Products:   NewProductRepository(db),
+			Purchases:  NewPurchaseRepository(db),
+			Categories: NewCategoryRepository(db),
+			Tags:       NewTagRepository(db),
+			Variants:   NewVariantRepository(db),
+			Rollups:    NewRollupRepository(db),
+			Prices:     NewPriceRepository(db),
+			Promotions: NewPromotionRepository(db),
+			TxManager:  NewTxManager(db),
+		}
+	})
+}
+
+func TestCachesContract(t *testing.T) {
+	contract.RunCaches(t, func(t *testing.T) contract.Caches {
+		purchases := NewPurchaseCache()
+		return contract.Caches{
+			Users:       NewUserCache(),
+			Products:    NewProductCache(),
+			Purchases:   purchases,
+			Categories:  NewCategoryCache(),
+			CoPurchases: purchases,
+			Reports:     purchases,
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sort"
+	"time"
+)
+
+type PriceRepository struct {
+	db *DB
+}
+
+func NewPriceRepository(db *DB) *PriceRepository {
+	return &PriceRepository{
+		db: db,
+	}
+}
+
+// GetHistory возвращает изменения цены товара от новых к старым; ненулевой until
+// отсекает изменения позже него, и тогда первая запись - цена, действовавшая в until
+func (r *PriceRepository) GetHistory(ctx context.Context, productID int64, until time.Time) ([]*models.PriceChange, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	history := []*models.PriceChange{}
+	for _, change := range r.db.productPrices {
+		if change.ProductID != productID || !until.IsZero() && change.ChangedAt.After(until) {
+			continue
+		}
+		change := change
+		history = append(history, &change)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if !history[i].ChangedAt.Equal(history[j].ChangedAt) {
+			return history[i].ChangedAt.After(history[j].ChangedAt)
+		}
+		return history[i].ID > history[j].ID
+	})
+	return history, nil
+}
+
+func (r *PriceRepository) CreateScheduled(ctx context.Context, scheduled *models.ScheduledPrice) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.products[scheduled.ProductID]; !ok {
+		return 0, ErrForeignKey
+	}
+	stored := *scheduled
+	stored.ID = r.db.nextID("scheduled_prices")
+	stored.CreatedAt = r.db.now()
+	stored.AppliedAt = nil
+	r.db.scheduledPrices[stored.ID] = stored
+	return stored.ID, nil
+}
+
+func (r *PriceRepository) GetScheduledByID(ctx context.Context, id int64) (*models.ScheduledPrice, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	scheduled, ok := r.db.scheduledPrices[id]
+	if !ok {
+		return nil, nil // Запланированная цена не найдена
+	}
+	return &scheduled, nil
+}
+
+// GetScheduled возвращает непримененные цены товара по времени вступления в силу
+func (r *PriceRepository) GetScheduled(ctx context.Context, productID int64) ([]*models.ScheduledPrice, error) {
+	return r.pending(func(scheduled *models.ScheduledPrice) bool { return scheduled.ProductID == productID }), nil
+}
+
+// DueScheduled возвращает непримененные цены всех товаров с effective_at не позже now
+func (r *PriceRepository) DueScheduled(ctx context.Context, now time.Time) ([]*models.ScheduledPrice, error) {
+	return r.pending(func(scheduled *models.ScheduledPrice) bool { return !scheduled.EffectiveAt.After(now) }), nil
+}
+
+// NextScheduled возвращает ближайшее время вступления в силу непримененной цены; nil - таких нет
+func (r *PriceRepository) NextScheduled(ctx context.Context) (*time.Time, error) {
+	pending := r.pending(func(*models.ScheduledPrice) bool { return true })
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	return &pending[0].EffectiveAt, nil
+}
+
+// pending возвращает непримененные цены, подходящие под match, по effective_at и id
+func (r *PriceRepository) pending(match func(scheduled *models.ScheduledPrice) bool) []*models.ScheduledPrice {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	list := []*models.ScheduledPrice{}
+	for _, scheduled := range r.db.scheduledPrices {
+		scheduled := scheduled
+		if scheduled.AppliedAt == nil && match(&scheduled) {
+			list = append(list, &scheduled)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].EffectiveAt.Equal(list[j].EffectiveAt) {
+			return list[i].EffectiveAt.Before(list[j].EffectiveAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// DeleteScheduled удаляет запланированную цену, если она еще не применена
+func (r *PriceRepository) DeleteScheduled(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if scheduled, ok := r.db.scheduledPrices[id]; ok && scheduled.AppliedAt == nil {
+		delete(r.db.scheduledPrices, id)
+	}
+	return nil
+}
+
+// MarkApplied отмечает цену примененной в at; false - она уже применена или удалена
+// (ее забрал другой экземпляр приложения)
+func (r *PriceRepository) MarkApplied(ctx context.Context, id int64, at time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	scheduled, ok := r.db.scheduledPrices[id]
+	if !ok || scheduled.AppliedAt != nil {
+		return false, nil
+	}
+	scheduled.AppliedAt = &at
+	r.db.scheduledPrices[id] = scheduled
+	return true, nil
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+// ProductCache - кеш продуктов в памяти процесса, замена redis.ProductCache без внешних сервисов
+type ProductCache struct {
+	products    *store[int64, models.Product]
+	searches    *store[string, models.ProductSearchResult]
+	suggestions *suggestIndex
+}
+
+func NewProductCache() *ProductCache {
+	return &ProductCache{
+		products:    newStore[int64, models.Product](),
+		searches:    newStore[string, models.ProductSearchResult](),
+		suggestions: newSuggestIndex(),
+	}
+}
+
+func (c *ProductCache) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	product, ok := c.products.get(id)
+	if !ok {
+		return nil, nil // Кеш пуст
+	}
+	return &product, nil
+}
+
+func (c *ProductCache) Set(ctx context.Context, product *models.Product, expiration time.Duration) error {
+	c.products.set(product.ID, *product, expiration)
+	return nil
+}
+
+func (c *ProductCache) Delete(ctx context.Context, id int64) error {
+	c.products.delete(id)
+	return nil
+}
+
+func (c *ProductCache) SetAllProducts(ctx context.Context, products []*models.Product, expiration time.Duration) error {
+	c.products.deleteExpired()
+
+	for _, product := range products {
+		c.products.set(product.ID, *product, expiration)
+	}
+	return nil
+}
+
+func (c *ProductCache) SetMany(ctx context.Context, products []*models.Product, expiration time.Duration) error {
+	for _, product := range products {
+		c.products.set(product.ID, *product, expiration)
+	}
+	return nil
+}
+
+func (c *ProductCache) DeleteMany(ctx context.Context, ids []int64) error {
+	for _, id := range ids {
+		c.products.delete(id)
+	}
+	return nil
+}
+
+func (c *ProductCache) GetSearch(ctx context.Context, key string) (*models.ProductSearchResult, error) {
+	result, ok := c.searches.get(key)
+	if !ok {
+		return nil, nil // Кеш пуст
+	}
+	return &result, nil
+}
+
+func (c *ProductCache) SetSearch(ctx context.Context, key string, result *models.ProductSearchResult, expiration time.Duration) error {
+	c.searches.set(key, *result, expiration)
+	return nil
+}
+
+func (c *ProductCache) InvalidateSearch(ctx context.Context) error {
+	c.searches.clear()
+	return nil
+}
@@ -0,0 +1,360 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/search"
+	"slices"
+	"sort"
+)
+
+type ProductRepository struct {
+	db *DB
+}
+
+func NewProductRepository(db *DB) *ProductRepository {
+	return &ProductRepository{
+		db: db,
+	}
+}
+
+func (r *ProductRepository) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	product, ok := r.db.products[id]
+	if !ok {
+		return nil, nil // Продукт не найден
+	}
+	return &product, nil
+}
+
+func (r *ProductRepository) GetAll(ctx context.Context) ([]*models.Product, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	products := make([]*models.Product, 0, len(r.db.products))
+	for _, product := range r.db.products {
+		product := product
+		products = append(products, &product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products, nil
+}
+
+func (r *ProductRepository) Create(ctx context.Context, product *models.Product) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored := *product
+	stored.ID = r.db.nextID("products")
+	stored.Version = 1
+	stored.CreatedAt = r.db.now()
+	stored.UpdatedAt = stored.CreatedAt
+	r.db.products[stored.ID] = stored
+	r.db.recordPrice(&stored)
+	return stored.ID, nil
+}
+
+// CreateBatch создает товары с идентификаторами подряд, как многострочный INSERT
+func (r *ProductRepository) CreateBatch(ctx context.Context, products []*models.Product) ([]int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ids := make([]int64, len(products))
+	for i, product := range products {
+		stored := *product
+		stored.ID = r.db.nextID("products")
+		stored.Version = 1
+		stored.CreatedAt = r.db.now()
+		stored.UpdatedAt = stored.CreatedAt
+		r.db.products[stored.ID] = stored
+		r.db.recordPrice(&stored)
+		ids[i] = stored.ID
+	}
+	return ids, nil
+}
+
+// Update перезаписывает запись и увеличивает версию; product.Version проверяется, если задана
+func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.products[product.ID]
+	if err := checkVersion(ok, stored.Version, product.Version); err != nil || !ok {
+		return err // Как и UPDATE без совпадений в SQL
+	}
+	previousPrice := stored.Price
+	stored.Version++
+	stored.Name = product.Name
+	stored.Description = product.Description
+	stored.Price = product.Price
+	stored.Quantity = product.Quantity
+	stored.UpdatedAt = r.db.now()
+	r.db.products[product.ID] = stored
+	if stored.Price != previousPrice {
+		r.db.recordPrice(&stored)
+	}
+	return nil
+}
+
+// Patch обновляет только переданные поля и увеличивает версию; patch.Version проверяется, если задана
+func (r *ProductRepository) Patch(ctx context.Context, id int64, patch *models.ProductPatch) error {
+	if patch.IsEmpty() {
+		return nil // Как и SQL-реализации: пустой патч ничего не записывает
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.products[id]
+	if err := checkVersion(ok, stored.Version, patch.Version); err != nil || !ok {
+		return err
+	}
+	stored.Version++
+	if patch.Name != nil {
+		stored.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		stored.Description = *patch.Description
+	}
+	previousPrice := stored.Price
+	if patch.Price != nil {
+		stored.Price = *patch.Price
+	}
+	if patch.Quantity != nil {
+		stored.Quantity = *patch.Quantity
+	}
+	stored.UpdatedAt = r.db.now()
+	r.db.products[id] = stored
+	if stored.Price != previousPrice {
+		r.db.recordPrice(&stored)
+	}
+	return nil
+}
+
+// DecreaseQuantity атомарно списывает товар со склада, если его достаточно
+func (r *ProductRepository) DecreaseQuantity(ctx context.Context, id int64, quantity int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.products[id]
+	if !ok || stored.Quantity < quantity {
+		return models.ErrInsufficientStock
+	}
+	stored.Quantity -= quantity
+	stored.Version++
+	stored.UpdatedAt = r.db.now()
+	r.db.products[id] = stored
+	return nil
+}
+
+// Delete удаляет запись; если version задана, только при совпадении версии
+func (r *ProductRepository) Delete(ctx context.Context, id int64, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.products[id]
+	if err := checkVersion(ok, stored.Version, version); err != nil {
+		return err
+	}
+	delete(r.db.products, id)
+	// ON DELETE CASCADE
+	delete(r.db.productCategories, id)
+	delete(r.db.productTags, id)
+	for variantID, variant := range r.db.variants {
+		if variant.ProductID == id {
+			delete(r.db.variants, variantID)
+		}
+	}
+	for purchaseID, purchase := range r.db.purchases {
+		if purchase.ProductID == id {
+			delete(r.db.purchases, purchaseID)
+		}
+	}
+	for changeID, change := range r.db.productPrices {
+		if change.ProductID == id {
+			delete(r.db.productPrices, changeID)
+		}
+	}
+	for scheduleID, scheduled := range r.db.scheduledPrices {
+		if scheduled.ProductID == id {
+			delete(r.db.scheduledPrices, scheduleID)
+		}
+	}
+	return nil
+}
+
+// ForEach передает в fn все товары в порядке id. Таблица копируется под блокировкой,
+// чтобы fn могла обращаться к репозиториям, - в отличие от SQL-курсора память не экономится.
+func (r *ProductRepository) ForEach(ctx context.Context, fn func(product *models.Product) error) error {
+	products, err := r.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByName возвращает товары с указанным названием в порядке id
+func (r *ProductRepository) GetByName(ctx context.Context, name string) ([]*models.Product, error) {
+	products, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []*models.Product{}
+	for _, product := range products {
+		if product.Name == name {
+			matched = append(matched, product)
+		}
+	}
+	return matched, nil
+}
+
+// GetByFilter возвращает товары категорий и тега фильтра в порядке id
+func (r *ProductRepository) GetByFilter(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error) {
+	products, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	filtered := []*models.Product{}
+	for _, product := range products {
+		if r.inFilter(product.ID, filter) {
+			filtered = append(filtered, product)
+		}
+	}
+	return filtered, nil
+}
+
+// inFilter проверяет, что товар входит в одну из категорий фильтра и имеет его тег; вызывается под r.db.mu
+func (r *ProductRepository) inFilter(id int64, filter models.ProductFilter) bool {
+	if len(filter.CategoryIDs) > 0 && !slices.ContainsFunc(r.db.productCategories[id], func(categoryID int64) bool {
+		return slices.Contains(filter.CategoryIDs, categoryID)
+	}) {
+		return false
+	}
+	return filter.Tag == "" || slices.Contains(r.db.productTags[id], filter.Tag)
+}
+
+// inStock сообщает, есть ли товар в наличии: у товара с вариантами - хотя бы один вариант с остатком,
+// у товара без вариантов - собственный остаток. Вызывается под r.db.mu.
+func (r *ProductRepository) inStock(product *models.Product) bool {
+	hasVariants := false
+	for _, variant := range r.db.variants {
+		if variant.ProductID != product.ID {
+			continue
+		}
+		if variant.Quantity > 0 {
+			return true
+		}
+		hasVariants = true
+	}
+	return !hasVariants && product.Quantity > 0
+}
+
+// SalesCounts возвращает, сколько единиц каждого товара продано (покупки, кроме отмененных)
+func (r *ProductRepository) SalesCounts(ctx context.Context) (map[int64]int64, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	sales := make(map[int64]int64)
+	for _, purchase := range r.db.purchases {
+		if purchase.Status != "cancelled" {
+			sales[purchase.ProductID] += int64(purchase.Quantity)
+		}
+	}
+	return sales, nil
+}
+
+// Search ищет товары перебором: терм должен быть началом слова в названии или описании,
+// релевантность - число совпавших слов, совпадения в названии весят вдвое больше
+func (r *ProductRepository) Search(ctx context.Context, query *models.ProductSearchQuery) (*models.ProductSearchResult, error) {
+	products, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := query.Filter()
+	inStock := make(map[int64]bool, len(products))
+	r.db.mu.RLock()
+	products = slices.DeleteFunc(products, func(product *models.Product) bool { return !r.inFilter(product.ID, filter) })
+	for _, product := range products {
+		inStock[product.ID] = r.inStock(product)
+	}
+	r.db.mu.RUnlock()
+
+	inStockCount := 0
+	priceCounts := make([]int, len(models.PriceBuckets))
+	scores := make(map[int64]int)
+	var matched []*models.Product
+	for _, product := range products {
+		score, ok := searchScore(product, query.Terms)
+		if !ok {
+			continue
+		}
+		// Фасеты считаются по совпадениям с текстом до фильтров
+		if inStock[product.ID] {
+			inStockCount++
+		}
+		priceCounts[models.PriceBucketIndex(product.Price)]++
+
+		if query.MinPrice != nil && product.Price < *query.MinPrice ||
+			query.MaxPrice != nil && product.Price > *query.MaxPrice ||
+			query.InStock && !inStock[product.ID] {
+			continue
+		}
+		scores[product.ID] = score
+		matched = append(matched, product)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		switch query.Sort {
+		case models.SortPriceAsc:
+			if a.Price != b.Price {
+				return a.Price < b.Price
+			}
+		case models.SortPriceDesc:
+			if a.Price != b.Price {
+				return a.Price > b.Price
+			}
+		case models.SortNewest:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID > b.ID
+		default:
+			if scores[a.ID] != scores[b.ID] {
+				return scores[a.ID] > scores[b.ID]
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(matched)
+	from := min(query.Offset, total)
+	to := min(from+query.Limit, total)
+	return models.NewProductSearchResult(query, matched[from:to], total, inStockCount, priceCounts), nil
+}
+
+func searchScore(product *models.Product, terms []string) (int, bool) {
+	score := 0
+	for _, term := range terms {
+		inName := search.Count(product.Name, term)
+		inDescription := search.Count(product.Description, term)
+		if inName+inDescription == 0 {
+			return 0, false
+		}
+		score += 2*inName + inDescription
+	}
+	return score, true
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/search"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// suggestIndex - индекс подсказок в памяти, аналог множеств по префиксам в redis.ProductCache
+type suggestIndex struct {
+	mu       sync.RWMutex
+	prefixes map[string]map[int64]struct{}
+	entries  map[int64]models.ProductSuggestion
+}
+
+func newSuggestIndex() *suggestIndex {
+	return &suggestIndex{
+		prefixes: make(map[string]map[int64]struct{}),
+		entries:  make(map[int64]models.ProductSuggestion),
+	}
+}
+
+// add индексирует подсказку; вызывается под блокировкой
+func (x *suggestIndex) add(entry models.ProductSuggestion) {
+	x.remove(entry.ID)
+	x.entries[entry.ID] = entry
+	for _, prefix := range search.Prefixes(entry.Name) {
+		ids, ok := x.prefixes[prefix]
+		if !ok {
+			ids = make(map[int64]struct{})
+			x.prefixes[prefix] = ids
+		}
+		ids[entry.ID] = struct{}{}
+	}
+}
+
+// remove удаляет подсказку из индекса; вызывается под блокировкой
+func (x *suggestIndex) remove(id int64) {
+	entry, ok := x.entries[id]
+	if !ok {
+		return
+	}
+	delete(x.entries, id)
+	for _, prefix := range search.Prefixes(entry.Name) {
+		delete(x.prefixes[prefix], id)
+		if len(x.prefixes[prefix]) == 0 {
+			delete(x.prefixes, prefix)
+		}
+	}
+}
+
+func (c *ProductCache) IndexSuggestions(ctx context.Context, products []*models.Product) error {
+	c.suggestions.mu.Lock()
+	defer c.suggestions.mu.Unlock()
+
+	for _, product := range products {
+		// Вес товара пересчитывает только RebuildSuggestions
+		sales := c.suggestions.entries[product.ID].Sales
+		c.suggestions.add(models.ProductSuggestion{ID: product.ID, Name: product.Name, Sales: sales})
+	}
+	return nil
+}
+
+func (c *ProductCache) RemoveSuggestions(ctx context.Context, ids []int64) error {
+	c.suggestions.mu.Lock()
+	defer c.suggestions.mu.Unlock()
+
+	for _, id := range ids {
+		c.suggestions.remove(id)
+	}
+	return nil
+}
+
+func (c *ProductCache) RebuildSuggestions(ctx context.Context, suggestions []models.ProductSuggestion) error {
+	index := newSuggestIndex()
+	for _, suggestion := range suggestions {
+		index.add(suggestion)
+	}
+
+	c.suggestions.mu.Lock()
+	c.suggestions.prefixes, c.suggestions.entries = index.prefixes, index.entries
+	c.suggestions.mu.Unlock()
+	return nil
+}
+
+// Suggest упорядочивает подсказки как ZREVRANGE: по убыванию продаж, при равенстве -
+// по убыванию идентификатора как строки
+func (c *ProductCache) Suggest(ctx context.Context, prefix string, limit int) ([]models.ProductSuggestion, error) {
+	c.suggestions.mu.RLock()
+	suggestions := make([]models.ProductSuggestion, 0, len(c.suggestions.prefixes[prefix]))
+	for id := range c.suggestions.prefixes[prefix] {
+		suggestions = append(suggestions, c.suggestions.entries[id])
+	}
+	c.suggestions.mu.RUnlock()
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Sales != suggestions[j].Sales {
+			return suggestions[i].Sales > suggestions[j].Sales
+		}
+		return strconv.FormatInt(suggestions[i].ID, 10) > strconv.FormatInt(suggestions[j].ID, 10)
+	})
+	return suggestions[:min(limit, len(suggestions))], nil
+}
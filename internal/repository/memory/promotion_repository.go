@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sort"
+)
+
+// couponUseKey - ключ coupon_uses: применения промокода пользователем
+type couponUseKey struct {
+	couponID int64
+	userID   int64
+}
+
+type PromotionRepository struct {
+	db *DB
+}
+
+func NewPromotionRepository(db *DB) *PromotionRepository {
+	return &PromotionRepository{
+		db: db,
+	}
+}
+
+func (r *PromotionRepository) CreateCoupon(ctx context.Context, coupon *models.Coupon) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, existing := range r.db.coupons {
+		if existing.Code == coupon.Code {
+			return 0, ErrDuplicate
+		}
+	}
+	stored := *coupon
+	stored.ID = r.db.nextID("coupons")
+	stored.Uses = 0
+	stored.CreatedAt = r.db.now()
+	r.db.coupons[stored.ID] = stored
+	return stored.ID, nil
+}
+
+func (r *PromotionRepository) GetCouponByID(ctx context.Context, id int64) (*models.Coupon, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	coupon, ok := r.db.coupons[id]
+	if !ok {
+		return nil, nil // Промокод не найден
+	}
+	return &coupon, nil
+}
+
+// GetCouponByCode возвращает промокод code; nil - промокод свободен
+func (r *PromotionRepository) GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, coupon := range r.db.coupons {
+		if coupon.Code == code {
+			return &coupon, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *PromotionRepository) GetCoupons(ctx context.Context) ([]*models.Coupon, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	coupons := []*models.Coupon{}
+	for _, coupon := range r.db.coupons {
+		coupon := coupon
+		coupons = append(coupons, &coupon)
+	}
+	sort.Slice(coupons, func(i, j int) bool { return coupons[i].ID < coupons[j].ID })
+	return coupons, nil
+}
+
+func (r *PromotionRepository) DeleteCoupon(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.coupons, id)
+	// ON DELETE CASCADE
+	for key := range r.db.couponUses {
+		if key.couponID == id {
+			delete(r.db.couponUses, key)
+		}
+	}
+	return nil
+}
+
+// ClaimCoupon увеличивает uses промокода, если не достигнут max_uses; false - достигнут или промокод удален
+func (r *PromotionRepository) ClaimCoupon(ctx context.Context, id int64) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	coupon, ok := r.db.coupons[id]
+	if !ok || coupon.MaxUses != nil && coupon.Uses >= *coupon.MaxUses {
+		return false, nil
+	}
+	coupon.Uses++
+	r.db.coupons[id] = coupon
+	return true, nil
+}
+
+// ClaimUserCoupon увеличивает счетчик применений промокода пользователем, если он меньше limit
+func (r *PromotionRepository) ClaimUserCoupon(ctx context.Context, couponID, userID int64, limit int) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.coupons[couponID]; !ok {
+		return false, ErrForeignKey
+	}
+	if _, ok := r.db.users[userID]; !ok {
+		return false, ErrForeignKey
+	}
+	key := couponUseKey{couponID: couponID, userID: userID}
+	if r.db.couponUses[key] >= limit {
+		return false, nil
+	}
+	r.db.couponUses[key]++
+	return true, nil
+}
+
+// AdjustCouponUses прибавляет delta к uses промокода и к счетчику пользователя, если он есть; ниже нуля счетчики не опускаются
+func (r *PromotionRepository) AdjustCouponUses(ctx context.Context, couponID, userID int64, delta int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if coupon, ok := r.db.coupons[couponID]; ok && coupon.Uses+delta >= 0 {
+		coupon.Uses += delta
+		r.db.coupons[couponID] = coupon
+	}
+	key := couponUseKey{couponID: couponID, userID: userID}
+	if uses, ok := r.db.couponUses[key]; ok && uses+delta >= 0 {
+		r.db.couponUses[key] = uses + delta
+	}
+	return nil
+}
+
+func (r *PromotionRepository) CreateRule(ctx context.Context, rule *models.PromotionRule) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if rule.CategoryID != nil {
+		if _, ok := r.db.categories[*rule.CategoryID]; !ok {
+			return 0, ErrForeignKey
+		}
+	}
+	stored := *rule
+	stored.ID = r.db.nextID("promotion_rules")
+	stored.CreatedAt = r.db.now()
+	r.db.promotionRules[stored.ID] = stored
+	return stored.ID, nil
+}
+
+func (r *PromotionRepository) GetRuleByID(ctx context.Context, id int64) (*models.PromotionRule, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	rule, ok := r.db.promotionRules[id]
+	if !ok {
+		return nil, nil // Правило не найдено
+	}
+	return &rule, nil
+}
+
+func (r *PromotionRepository) GetRules(ctx context.Context) ([]*models.PromotionRule, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	rules := []*models.PromotionRule{}
+	for _, rule := range r.db.promotionRules {
+		rule := rule
+		rules = append(rules, &rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+func (r *PromotionRepository) DeleteRule(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.promotionRules, id)
+	return nil
+}
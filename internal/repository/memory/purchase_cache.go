@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+// PurchaseCache - кеш покупок в памяти процесса, замена redis.PurchaseCache без внешних сервисов
+type PurchaseCache struct {
+	purchases     *store[int64, models.Purchase]
+	userPurchases *store[int64, []models.Purchase]
+	reports       *store[string, models.SalesReport]
+	sales         *salesIndex
+	coPurchases   *coPurchaseIndex
+}
+
+func NewPurchaseCache() *PurchaseCache {
+	return &PurchaseCache{
+		purchases:     newStore[int64, models.Purchase](),
+		userPurchases: newStore[int64, []models.Purchase](),
+		reports:       newStore[string, models.SalesReport](),
+		sales:         newSalesIndex(),
+		coPurchases:   &coPurchaseIndex{},
+	}
+}
+
+func (c *PurchaseCache) GetByID(ctx context.Context, id int64) (*models.Purchase, error) {
+	purchase, ok := c.purchases.get(id)
+	if !ok {
+		return nil, nil // Кеш пуст
+	}
+	return &purchase, nil
+}
+
+func (c *PurchaseCache) Set(ctx context.Context, purchase *models.Purchase, expiration time.Duration) error {
+	c.purchases.set(purchase.ID, *purchase, expiration)
+	return nil
+}
+
+func (c *PurchaseCache) Delete(ctx context.Context, id int64) error {
+	c.purchases.delete(id)
+	return nil
+}
+
+func (c *PurchaseCache) SetUserPurchases(ctx context.Context, userID int64, purchases []*models.Purchase, expiration time.Duration) error {
+	// Храним копии, чтобы изменения у вызывающего не попадали в кеш
+	copied := make([]models.Purchase, len(purchases))
+	for i, purchase := range purchases {
+		copied[i] = *purchase
+	}
+	c.userPurchases.set(userID, copied, expiration)
+	return nil
+}
+
+func (c *PurchaseCache) GetUserPurchases(ctx context.Context, userID int64) ([]*models.Purchase, error) {
+	cached, ok := c.userPurchases.get(userID)
+	if !ok {
+		return nil, nil // Кеш пуст
+	}
+
+	purchases := make([]*models.Purchase, len(cached))
+	for i := range cached {
+		purchase := cached[i]
+		purchases[i] = &purchase
+	}
+	return purchases, nil
+}
+
+func (c *PurchaseCache) GetReport(ctx context.Context, key string) (*models.SalesReport, error) {
+	report, ok := c.reports.get(key)
+	if !ok {
+		return nil, nil // Кеш пуст
+	}
+	report.Rows = append([]models.ReportRow(nil), report.Rows...)
+	return &report, nil
+}
+
+func (c *PurchaseCache) SetReport(ctx context.Context, key string, report *models.SalesReport, expiration time.Duration) error {
+	stored := *report
+	stored.Rows = append([]models.ReportRow(nil), report.Rows...)
+	c.reports.set(key, stored, expiration)
+	return nil
+}
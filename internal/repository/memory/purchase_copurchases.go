@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sync"
+)
+
+// coPurchaseIndex - совместные покупки в памяти, аналог sorted set на каждый товар в redis.PurchaseCache
+type coPurchaseIndex struct {
+	mu       sync.RWMutex
+	products map[int64][]models.CoPurchase
+}
+
+func (c *PurchaseCache) RebuildCoPurchases(ctx context.Context, coPurchases map[int64][]models.CoPurchase) error {
+	products := make(map[int64][]models.CoPurchase, len(coPurchases))
+	for id, list := range coPurchases {
+		if len(list) > 0 {
+			products[id] = append([]models.CoPurchase(nil), list...)
+		}
+	}
+
+	c.coPurchases.mu.Lock()
+	defer c.coPurchases.mu.Unlock()
+	c.coPurchases.products = products
+	return nil
+}
+
+func (c *PurchaseCache) GetCoPurchases(ctx context.Context, productIDs []int64) (map[int64][]models.CoPurchase, error) {
+	c.coPurchases.mu.RLock()
+	defer c.coPurchases.mu.RUnlock()
+
+	result := make(map[int64][]models.CoPurchase, len(productIDs))
+	for _, id := range productIDs {
+		if list, ok := c.coPurchases.products[id]; ok {
+			result[id] = append([]models.CoPurchase(nil), list...)
+		}
+	}
+	return result, nil
+}
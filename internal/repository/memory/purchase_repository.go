@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sort"
+	"time"
+)
+
+type PurchaseRepository struct {
+	db *DB
+}
+
+func NewPurchaseRepository(db *DB) *PurchaseRepository {
+	return &PurchaseRepository{
+		db: db,
+	}
+}
+
+func (r *PurchaseRepository) GetByID(ctx context.Context, id int64) (*models.Purchase, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	purchase, ok := r.db.purchases[id]
+	if !ok {
+		return nil, nil // Покупка не найдена
+	}
+	return &purchase, nil
+}
+
+// GetByIDForUpdate - то же, что GetByID: блокировок строк в памяти нет
+func (r *PurchaseRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.Purchase, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *PurchaseRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Purchase, error) {
+	return r.filter(func(p *models.Purchase) bool { return p.UserID == userID }), nil
+}
+
+// Create сохраняет запись о покупке. Пользователь, товар и вариант должны существовать (как FOREIGN KEY в SQL).
+func (r *PurchaseRepository) Create(ctx context.Context, purchase *models.Purchase) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[purchase.UserID]; !ok {
+		return 0, ErrForeignKey
+	}
+	if _, ok := r.db.products[purchase.ProductID]; !ok {
+		return 0, ErrForeignKey
+	}
+	if purchase.VariantID != nil {
+		if _, ok := r.db.variants[*purchase.VariantID]; !ok {
+			return 0, ErrForeignKey
+		}
+	}
+
+	stored := *purchase
+	stored.ID = r.db.nextID("purchases")
+	stored.Version = 1
+	stored.CreatedAt = r.db.now()
+	stored.UpdatedAt = stored.CreatedAt
+	if stored.Discounts == nil {
+		stored.Discounts = models.PurchaseDiscounts{} // Как DEFAULT '[]' в SQL
+	}
+	r.db.purchases[stored.ID] = stored
+	return stored.ID, nil
+}
+
+// UpdateStatus меняет статус и увеличивает версию; если version задана, только при совпадении версии
+func (r *PurchaseRepository) UpdateStatus(ctx context.Context, id int64, status string, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.purchases[id]
+	if err := checkVersion(ok, stored.Version, version); err != nil || !ok {
+		return err // Как и UPDATE без совпадений в SQL
+	}
+	stored.Version++
+	stored.Status = status
+	stored.UpdatedAt = r.db.now()
+	r.db.purchases[id] = stored
+	return nil
+}
+
+func (r *PurchaseRepository) GetAll(ctx context.Context) ([]*models.Purchase, error) {
+	return r.filter(func(p *models.Purchase) bool { return true }), nil
+}
+
+func (r *PurchaseRepository) filter(match func(p *models.Purchase) bool) []*models.Purchase {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	purchases := []*models.Purchase{}
+	for _, purchase := range r.db.purchases {
+		purchase := purchase
+		if match(&purchase) {
+			purchases = append(purchases, &purchase)
+		}
+	}
+	sort.Slice(purchases, func(i, j int) bool { return purchases[i].ID < purchases[j].ID })
+	return purchases
+}
+
+// ForEach передает в fn все покупки в порядке id. Таблица копируется под блокировкой,
+// чтобы fn могла обращаться к репозиториям, - в отличие от SQL-курсора память не экономится.
+func (r *PurchaseRepository) ForEach(ctx context.Context, fn func(purchase *models.Purchase) error) error {
+	purchases, err := r.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, purchase := range purchases {
+		if err := fn(purchase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachCompleted передает в fn завершенные покупки, измененные начиная с since, в порядке id
+func (r *PurchaseRepository) ForEachCompleted(ctx context.Context, since time.Time, fn func(purchase *models.Purchase) error) error {
+	purchases := r.filter(func(p *models.Purchase) bool { return p.Status == "completed" && !p.UpdatedAt.Before(since) })
+	for _, purchase := range purchases {
+		if err := fn(purchase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachCreated передает в fn покупки, созданные в [from, to), в порядке id
+func (r *PurchaseRepository) ForEachCreated(ctx context.Context, from, to time.Time, fn func(purchase *models.Purchase) error) error {
+	purchases := r.filter(func(p *models.Purchase) bool { return !p.CreatedAt.Before(from) && p.CreatedAt.Before(to) })
+	for _, purchase := range purchases {
+		if err := fn(purchase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LockCreated ничего не делает: блокировок строк в памяти нет
+func (r *PurchaseRepository) LockCreated(ctx context.Context, from, to time.Time) error {
+	return nil
+}
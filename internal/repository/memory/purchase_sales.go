@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// salesDayLayout - ключ дня в salesIndex
+const salesDayLayout = "2006-01-02"
+
+// salesIndex - дневные продажи в памяти, аналог дневных sorted set в redis.PurchaseCache
+type salesIndex struct {
+	mu   sync.RWMutex
+	days map[string]map[int64]models.ProductSales
+	now  func() time.Time
+}
+
+func newSalesIndex() *salesIndex {
+	return &salesIndex{
+		days: make(map[string]map[int64]models.ProductSales),
+		now:  time.Now,
+	}
+}
+
+// add прибавляет продажи к дню; вызывается под блокировкой
+func (x *salesIndex) add(day time.Time, sales models.ProductSales) {
+	key := models.SalesDay(day).Format(salesDayLayout)
+	products, ok := x.days[key]
+	if !ok {
+		products = make(map[int64]models.ProductSales)
+		x.days[key] = products
+	}
+	total := products[sales.ProductID]
+	total.ProductID = sales.ProductID
+	total.Units += sales.Units
+	total.Revenue += sales.Revenue
+	products[sales.ProductID] = total
+}
+
+// expire удаляет дни старше окна рейтинга, как истечение ключей в Redis; вызывается под блокировкой
+func (x *salesIndex) expire() {
+	oldest := models.SalesDay(x.now()).AddDate(0, 0, -models.MaxPopularDays).Format(salesDayLayout)
+	for key := range x.days {
+		if key < oldest {
+			delete(x.days, key)
+		}
+	}
+}
+
+func (c *PurchaseCache) AddSales(ctx context.Context, day time.Time, sales models.ProductSales) error {
+	c.sales.mu.Lock()
+	defer c.sales.mu.Unlock()
+
+	c.sales.add(day, sales)
+	c.sales.expire()
+	return nil
+}
+
+func (c *PurchaseCache) RebuildSales(ctx context.Context, sales []models.DailySales) error {
+	c.sales.mu.Lock()
+	defer c.sales.mu.Unlock()
+
+	c.sales.days = make(map[string]map[int64]models.ProductSales)
+	for _, daily := range sales {
+		c.sales.add(daily.Day, daily.ProductSales)
+	}
+	c.sales.expire()
+	return nil
+}
+
+// TopSales упорядочивает товары как ZREVRANGEBYSCORE: по убыванию критерия, при равенстве -
+// по убыванию идентификатора как строки; товары без продаж по критерию не попадают в рейтинг
+func (c *PurchaseCache) TopSales(ctx context.Context, until time.Time, days int, by string, limit int) ([]models.ProductSales, error) {
+	c.sales.mu.RLock()
+	totals := make(map[int64]models.ProductSales)
+	day := models.SalesDay(until)
+	for i := 0; i < days; i++ {
+		for id, sales := range c.sales.days[day.AddDate(0, 0, -i).Format(salesDayLayout)] {
+			total := totals[id]
+			total.ProductID = id
+			total.Units += sales.Units
+			total.Revenue += sales.Revenue
+			totals[id] = total
+		}
+	}
+	c.sales.mu.RUnlock()
+
+	score := func(sales models.ProductSales) float64 {
+		if by == models.PopularByRevenue {
+			return sales.Revenue
+		}
+		return float64(sales.Units)
+	}
+	top := make([]models.ProductSales, 0, len(totals))
+	for _, sales := range totals {
+		if score(sales) > 0 {
+			top = append(top, sales)
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if score(top[i]) != score(top[j]) {
+			return score(top[i]) > score(top[j])
+		}
+		return strconv.FormatInt(top[i].ProductID, 10) > strconv.FormatInt(top[j].ProductID, 10)
+	})
+	return top[:min(limit, len(top))], nil
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sort"
+	"time"
+)
+
+// rollupKey - первичный ключ дневного агрегата: день и id товара или пользователя
+type rollupKey struct {
+	day time.Time
+	id  int64
+}
+
+type RollupRepository struct {
+	db *DB
+}
+
+func NewRollupRepository(db *DB) *RollupRepository {
+	return &RollupRepository{
+		db: db,
+	}
+}
+
+// Apply прибавляет дельты к агрегатам товара и пользователя каждой строки, создавая недостающие строки
+func (r *RollupRepository) Apply(ctx context.Context, deltas []models.SalesRollup) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, delta := range deltas {
+		day := models.SalesDay(delta.Day)
+		product := r.db.productRollups[rollupKey{day: day, id: delta.ProductID}]
+		product.Day, product.ProductID = day, delta.ProductID
+		product.Add(delta)
+		r.db.productRollups[rollupKey{day: day, id: delta.ProductID}] = product
+
+		user := r.db.userRollups[rollupKey{day: day, id: delta.UserID}]
+		user.Day, user.UserID = day, delta.UserID
+		user.Add(delta)
+		r.db.userRollups[rollupKey{day: day, id: delta.UserID}] = user
+	}
+	return nil
+}
+
+// Replace удаляет агрегаты дней [from, to) и записывает rollups
+func (r *RollupRepository) Replace(ctx context.Context, from, to time.Time, rollups []models.SalesRollup) error {
+	r.db.mu.Lock()
+	for _, table := range []map[rollupKey]models.SalesRollup{r.db.productRollups, r.db.userRollups} {
+		for key := range table {
+			if !key.day.Before(from) && key.day.Before(to) {
+				delete(table, key)
+			}
+		}
+	}
+	r.db.mu.Unlock()
+
+	return r.Apply(ctx, rollups)
+}
+
+// ProductRollups возвращает агрегаты товаров за дни [from, to) по дню и id товара
+func (r *RollupRepository) ProductRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error) {
+	return r.rollups(r.db.productRollups, from, to), nil
+}
+
+// UserRollups возвращает агрегаты пользователей за дни [from, to) по дню и id пользователя
+func (r *RollupRepository) UserRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error) {
+	return r.rollups(r.db.userRollups, from, to), nil
+}
+
+func (r *RollupRepository) rollups(table map[rollupKey]models.SalesRollup, from, to time.Time) []models.SalesRollup {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	keys := make([]rollupKey, 0)
+	for key := range table {
+		if !key.day.Before(from) && key.day.Before(to) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].day.Equal(keys[j].day) {
+			return keys[i].day.Before(keys[j].day)
+		}
+		return keys[i].id < keys[j].id
+	})
+
+	rollups := make([]models.SalesRollup, len(keys))
+	for i, key := range keys {
+		rollups[i] = table[key]
+	}
+	return rollups
+}
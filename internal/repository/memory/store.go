@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+// store - потокобезопасное хранилище значений с временем жизни, аналог строковых ключей Redis
+type store[K comparable, V any] struct {
+	mu    sync.RWMutex
+	items map[K]item[V]
+	now   func() time.Time
+}
+
+type item[V any] struct {
+	value     V
+	expiresAt time.Time // нулевое значение - без ограничения
+}
+
+func newStore[K comparable, V any]() *store[K, V] {
+	return &store[K, V]{
+		items: make(map[K]item[V]),
+		now:   time.Now,
+	}
+}
+
+func (s *store[K, V]) get(key K) (V, bool) {
+	s.mu.RLock()
+	it, ok := s.items[key]
+	s.mu.RUnlock()
+
+	if !ok || s.expired(it) {
+		var zero V
+		return zero, false
+	}
+	return it.value, true
+}
+
+func (s *store[K, V]) set(key K, value V, expiration time.Duration) {
+	it := item[V]{value: value}
+	if expiration > 0 {
+		it.expiresAt = s.now().Add(expiration)
+	}
+
+	s.mu.Lock()
+	s.items[key] = it
+	s.mu.Unlock()
+}
+
+func (s *store[K, V]) delete(key K) {
+	s.mu.Lock()
+	delete(s.items, key)
+	s.mu.Unlock()
+}
+
+// clear удаляет все записи
+func (s *store[K, V]) clear() {
+	s.mu.Lock()
+	s.items = make(map[K]item[V])
+	s.mu.Unlock()
+}
+
+// deleteExpired удаляет просроченные записи, чтобы память не росла бесконечно
+func (s *store[K, V]) deleteExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, it := range s.items {
+		if s.expired(it) {
+			delete(s.items, key)
+		}
+	}
+}
+
+func (s *store[K, V]) expired(it item[V]) bool {
+	return !it.expiresAt.IsZero() && !s.now().Before(it.expiresAt)
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"slices"
+	"sort"
+)
+
+type TagRepository struct {
+	db *DB
+}
+
+func NewTagRepository(db *DB) *TagRepository {
+	return &TagRepository{
+		db: db,
+	}
+}
+
+// GetAll возвращает теги, которые есть хотя бы у одного товара, по алфавиту
+func (r *TagRepository) GetAll(ctx context.Context) ([]models.TagCount, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, tags := range r.db.productTags {
+		for _, tag := range tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]models.TagCount, 0, len(counts))
+	for name, products := range counts {
+		tags = append(tags, models.TagCount{Name: name, Products: products})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// GetProductTags возвращает теги товара по алфавиту
+func (r *TagRepository) GetProductTags(ctx context.Context, productID int64) ([]string, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	tags := slices.Clone(r.db.productTags[productID])
+	if tags == nil {
+		tags = []string{}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// SetProductTags заменяет теги товара; товар должен существовать
+func (r *TagRepository) SetProductTags(ctx context.Context, productID int64, tags []string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.products[productID]; !ok {
+		return ErrForeignKey
+	}
+	r.db.productTags[productID] = slices.Clone(tags)
+	return nil
+}
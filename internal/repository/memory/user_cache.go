@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+// UserCache - кеш пользователей в памяти процесса, замена redis.UserCache без внешних сервисов
+type UserCache struct {
+	users *store[int64, models.User]
+}
+
+func NewUserCache() *UserCache {
+	return &UserCache{
+		users: newStore[int64, models.User](),
+	}
+}
+
+func (c *UserCache) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	user, ok := c.users.get(id)
+	if !ok {
+		return nil, nil // Кеш пуст
+	}
+	return &user, nil
+}
+
+func (c *UserCache) Set(ctx context.Context, user *models.User, expiration time.Duration) error {
+	c.users.set(user.ID, *user, expiration)
+	return nil
+}
+
+func (c *UserCache) Delete(ctx context.Context, id int64) error {
+	c.users.delete(id)
+	return nil
+}
+
+func (c *UserCache) SetAllUsers(ctx context.Context, users []*models.User, expiration time.Duration) error {
+	c.users.deleteExpired()
+
+	for _, user := range users {
+		c.users.set(user.ID, *user, expiration)
+	}
+	return nil
+}
+
+func (c *UserCache) SetMany(ctx context.Context, users []*models.User, expiration time.Duration) error {
+	for _, user := range users {
+		c.users.set(user.ID, *user, expiration)
+	}
+	return nil
+}
+
+func (c *UserCache) DeleteMany(ctx context.Context, ids []int64) error {
+	for _, id := range ids {
+		c.users.delete(id)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"sort"
+)
+
+type UserRepository struct {
+	db *DB
+}
+
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	user, ok := r.db.users[id]
+	if !ok {
+		return nil, nil // Пользователь не найден
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetAll(ctx context.Context) ([]*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(r.db.users))
+	for _, user := range r.db.users {
+		user := user
+		users = append(users, &user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, existing := range r.db.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return 0, ErrDuplicate
+		}
+	}
+
+	stored := *user
+	stored.ID = r.db.nextID("users")
+	stored.Version = 1
+	stored.CreatedAt = r.db.now()
+	stored.UpdatedAt = stored.CreatedAt
+	r.db.users[stored.ID] = stored
+	return stored.ID, nil
+}
+
+// CreateBatch создает всех пользователей или ни одного, как многострочный INSERT
+func (r *UserRepository) CreateBatch(ctx context.Context, users []*models.User) ([]int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	usernames := make(map[string]bool, len(r.db.users)+len(users))
+	emails := make(map[string]bool, len(r.db.users)+len(users))
+	for _, existing := range r.db.users {
+		usernames[existing.Username] = true
+		emails[existing.Email] = true
+	}
+	for _, user := range users {
+		if usernames[user.Username] || emails[user.Email] {
+			return nil, ErrDuplicate
+		}
+		usernames[user.Username] = true
+		emails[user.Email] = true
+	}
+
+	ids := make([]int64, len(users))
+	for i, user := range users {
+		stored := *user
+		stored.ID = r.db.nextID("users")
+		stored.Version = 1
+		stored.CreatedAt = r.db.now()
+		stored.UpdatedAt = stored.CreatedAt
+		r.db.users[stored.ID] = stored
+		ids[i] = stored.ID
+	}
+	return ids, nil
+}
+
+// Update перезаписывает запись и увеличивает версию; user.Version проверяется, если задана
+func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[user.ID]
+	if err := checkVersion(ok, stored.Version, user.Version); err != nil || !ok {
+		return err // Как и UPDATE без совпадений в SQL
+	}
+	stored.Version++
+	stored.Username = user.Username
+	stored.Email = user.Email
+	stored.UpdatedAt = r.db.now()
+	r.db.users[user.ID] = stored
+	return nil
+}
+
+// Patch обновляет только переданные поля и увеличивает версию; patch.Version проверяется, если задана
+func (r *UserRepository) Patch(ctx context.Context, id int64, patch *models.UserPatch) error {
+	if patch.IsEmpty() {
+		return nil // Как и SQL-реализации: пустой патч ничего не записывает
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[id]
+	if err := checkVersion(ok, stored.Version, patch.Version); err != nil || !ok {
+		return err
+	}
+	stored.Version++
+	if patch.Username != nil {
+		stored.Username = *patch.Username
+	}
+	if patch.Email != nil {
+		stored.Email = *patch.Email
+	}
+	stored.UpdatedAt = r.db.now()
+	r.db.users[id] = stored
+	return nil
+}
+
+// Delete удаляет запись; если version задана, только при совпадении версии
+func (r *UserRepository) Delete(ctx context.Context, id int64, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[id]
+	if err := checkVersion(ok, stored.Version, version); err != nil {
+		return err
+	}
+	delete(r.db.users, id)
+	// ON DELETE CASCADE
+	for purchaseID, purchase := range r.db.purchases {
+		if purchase.UserID == id {
+			delete(r.db.purchases, purchaseID)
+		}
+	}
+	for key := range r.db.couponUses {
+		if key.userID == id {
+			delete(r.db.couponUses, key)
+		}
+	}
+	return nil
+}
+
+// ForEach передает в fn все пользователей в порядке id. Таблица копируется под блокировкой,
+// чтобы fn могла обращаться к репозиториям, - в отличие от SQL-курсора память не экономится.
+func (r *UserRepository) ForEach(ctx context.Context, fn func(user *models.User) error) error {
+	users, err := r.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
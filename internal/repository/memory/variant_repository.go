@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"maps"
+	"sort"
+)
+
+type VariantRepository struct {
+	db *DB
+}
+
+func NewVariantRepository(db *DB) *VariantRepository {
+	return &VariantRepository{
+		db: db,
+	}
+}
+
+// get возвращает копию варианта: характеристики не должны разделяться с хранимой записью
+func (r *VariantRepository) get(id int64) (*models.ProductVariant, bool) {
+	variant, ok := r.db.variants[id]
+	if !ok {
+		return nil, false
+	}
+	variant.Attributes = maps.Clone(variant.Attributes)
+	return &variant, true
+}
+
+func (r *VariantRepository) GetByID(ctx context.Context, id int64) (*models.ProductVariant, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	variant, _ := r.get(id)
+	return variant, nil // nil - вариант не найден
+}
+
+func (r *VariantRepository) GetBySKU(ctx context.Context, sku string) (*models.ProductVariant, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for id, variant := range r.db.variants {
+		if variant.SKU == sku {
+			stored, _ := r.get(id)
+			return stored, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetByProductID возвращает варианты товара в порядке id
+func (r *VariantRepository) GetByProductID(ctx context.Context, productID int64) ([]*models.ProductVariant, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	variants := []*models.ProductVariant{}
+	for id, variant := range r.db.variants {
+		if variant.ProductID == productID {
+			stored, _ := r.get(id)
+			variants = append(variants, stored)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants, nil
+}
+
+// Create сохраняет вариант; товар должен существовать, артикул - быть свободным
+func (r *VariantRepository) Create(ctx context.Context, variant *models.ProductVariant) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.products[variant.ProductID]; !ok {
+		return 0, ErrForeignKey
+	}
+	if r.skuTaken(variant.SKU, 0) {
+		return 0, ErrDuplicate
+	}
+
+	stored := *variant
+	stored.ID = r.db.nextID("product_variants")
+	stored.Attributes = cloneAttributes(variant.Attributes)
+	stored.Version = 1
+	stored.CreatedAt = r.db.now()
+	stored.UpdatedAt = stored.CreatedAt
+	r.db.variants[stored.ID] = stored
+	return stored.ID, nil
+}
+
+// Update перезаписывает артикул, характеристики, цену и остаток и увеличивает версию;
+// variant.Version проверяется, если задана
+func (r *VariantRepository) Update(ctx context.Context, variant *models.ProductVariant) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.variants[variant.ID]
+	if err := checkVersion(ok, stored.Version, variant.Version); err != nil || !ok {
+		return err // Как и UPDATE без совпадений в SQL
+	}
+	if r.skuTaken(variant.SKU, variant.ID) {
+		return ErrDuplicate
+	}
+	stored.Version++
+	stored.SKU = variant.SKU
+	stored.Attributes = cloneAttributes(variant.Attributes)
+	stored.Price = variant.Price
+	stored.Quantity = variant.Quantity
+	stored.UpdatedAt = r.db.now()
+	r.db.variants[variant.ID] = stored
+	return nil
+}
+
+// Delete удаляет вариант; в покупках ссылка на него обнуляется (ON DELETE SET NULL)
+func (r *VariantRepository) Delete(ctx context.Context, id int64, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.variants[id]
+	if err := checkVersion(ok, stored.Version, version); err != nil {
+		return err
+	}
+	delete(r.db.variants, id)
+	for purchaseID, purchase := range r.db.purchases {
+		if purchase.VariantID != nil && *purchase.VariantID == id {
+			purchase.VariantID = nil
+			r.db.purchases[purchaseID] = purchase
+		}
+	}
+	return nil
+}
+
+// DecreaseQuantity атомарно списывает вариант со склада, если его достаточно
+func (r *VariantRepository) DecreaseQuantity(ctx context.Context, id int64, quantity int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.variants[id]
+	if !ok || stored.Quantity < quantity {
+		return models.ErrInsufficientStock
+	}
+	stored.Quantity -= quantity
+	stored.Version++
+	stored.UpdatedAt = r.db.now()
+	r.db.variants[id] = stored
+	return nil
+}
+
+// skuTaken проверяет, что артикул занят вариантом, отличным от exceptID; вызывается под r.db.mu
+func (r *VariantRepository) skuTaken(sku string, exceptID int64) bool {
+	for id, variant := range r.db.variants {
+		if variant.SKU == sku && id != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneAttributes копирует характеристики; nil хранится как пустой объект, как в SQL
+func cloneAttributes(attributes models.VariantAttributes) models.VariantAttributes {
+	if attributes == nil {
+		return models.VariantAttributes{}
+	}
+	return maps.Clone(attributes)
+}
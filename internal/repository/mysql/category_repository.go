@@ -0,0 +1,143 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"strings"
+)
+
+type CategoryRepository struct {
+	db *DB
+}
+
+func NewCategoryRepository(db *DB) *CategoryRepository {
+	return &CategoryRepository{
+		db: db,
+	}
+}
+
+func (r *CategoryRepository) GetByID(ctx context.Context, id int64) (*models.Category, error) {
+	category := &models.Category{}
+	query := "SELECT * FROM categories WHERE id = ?"
+	err := r.db.Reader(ctx).GetContext(ctx, category, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Категория не найдена
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+func (r *CategoryRepository) GetAll(ctx context.Context) ([]*models.Category, error) {
+	categories := []*models.Category{}
+	query := "SELECT * FROM categories ORDER BY id"
+	err := r.db.Reader(ctx).SelectContext(ctx, &categories, query)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// GetByIDForUpdate читает категорию с primary и в транзакции блокирует ее строку до фиксации
+func (r *CategoryRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.Category, error) {
+	category := &models.Category{}
+	query := "SELECT * FROM categories WHERE id = ? FOR UPDATE"
+	err := r.db.Writer(ctx).GetContext(ctx, category, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Категория не найдена
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+// HasChildren сообщает, есть ли у категории подкатегории
+func (r *CategoryRepository) HasChildren(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = ?)"
+	err := r.db.Reader(ctx).GetContext(ctx, &exists, query, id)
+	return exists, err
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, category *models.Category) (int64, error) {
+	query := "INSERT INTO categories (parent_id, name, created_at, updated_at) VALUES (?, ?, NOW(), NOW())"
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, category.ParentID, category.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Update перезаписывает название и родителя и увеличивает версию; category.Version проверяется, если задана
+func (r *CategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	query, args := withVersion("UPDATE categories SET parent_id = ?, name = ?, version = version + 1, updated_at = NOW() WHERE id = ?",
+		[]interface{}{category.ParentID, category.Name, category.ID}, category.Version)
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, category.Version)
+}
+
+// Delete удаляет категорию; связи с товарами удаляются каскадно, категорию с подкатегориями удалить нельзя
+func (r *CategoryRepository) Delete(ctx context.Context, id int64, version int64) error {
+	query, args := withVersion("DELETE FROM categories WHERE id = ?", []interface{}{id}, version)
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
+}
+
+// GetProductCategories возвращает категории товара в порядке id
+func (r *CategoryRepository) GetProductCategories(ctx context.Context, productID int64) ([]*models.Category, error) {
+	categories := []*models.Category{}
+	query := `SELECT c.* FROM categories c JOIN product_categories pc ON pc.category_id = c.id
+			  WHERE pc.product_id = ? ORDER BY c.id`
+	err := r.db.Reader(ctx).SelectContext(ctx, &categories, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// SetProductCategories заменяет категории товара; вызывается в транзакции
+func (r *CategoryRepository) SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error {
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, "DELETE FROM product_categories WHERE product_id = ?", productID); err != nil {
+		return err
+	}
+	query, args := productLinks("INSERT INTO product_categories (product_id, category_id) VALUES ", productID, uniqueIDs(categoryIDs))
+	if len(args) == 0 {
+		return nil
+	}
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
+	return err
+}
+
+// productLinks строит многострочный INSERT связей товара
+func productLinks(insert string, productID int64, ids []int64) (string, []interface{}) {
+	values := make([]string, len(ids))
+	args := make([]interface{}, 0, len(ids)*2)
+	for i, id := range ids {
+		values[i] = "(?, ?)"
+		args = append(args, productID, id)
+	}
+	return insert + strings.Join(values, ", "), args
+}
+
+// uniqueIDs убирает повторы, сохраняя порядок
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
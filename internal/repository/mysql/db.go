@@ -0,0 +1,347 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqltx"
+	"github.com/jmoiron/sqlx"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Querier - общий набор методов *sqlx.DB и *sqlx.Tx, которым пользуются репозитории
+type Querier interface {
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+}
+
+// DB направляет запросы на запись в primary, а запросы только на чтение - в здоровые реплики.
+// Если ни одна реплика не доступна или запрос уже что-то записал в рамках сессии, чтение идет в primary.
+type DB struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+	// autoinc - результат проверки настроек автоинкремента, см. consecutiveAutoIncrement
+	autoinc atomic.Int32
+}
+
+const (
+	autoincUnknown int32 = iota
+	autoincConsecutive
+	autoincScattered
+)
+
+type replica struct {
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// NewDB создает DB без реплик; реплики подключаются через WithReplicas
+func NewDB(primary *sqlx.DB) *DB {
+	return &DB{primary: primary}
+}
+
+// WithReplicas добавляет реплики для чтения. maxLag - допустимое отставание реплики,
+// при превышении которого она исключается из ротации (0 - не проверять отставание).
+// Реплики попадают в ротацию только после первой успешной проверки в StartHealthChecker.
+func (d *DB) WithReplicas(maxLag time.Duration, replicas ...*sqlx.DB) *DB {
+	for _, db := range replicas {
+		d.replicas = append(d.replicas, &replica{db: db})
+	}
+	d.maxLag = maxLag
+	return d
+}
+
+// Primary возвращает соединение с primary
+func (d *DB) Primary() *sqlx.DB {
+	return d.primary
+}
+
+// Writer возвращает соединение для записи (или текущую транзакцию из контекста) и отмечает
+// в сессии запроса, что запись была, чтобы последующие чтения в этом запросе видели свои изменения
+func (d *DB) Writer(ctx context.Context) Querier {
+	d.markWrite(ctx)
+	if tx := sqltx.Tx(ctx); tx != nil {
+		return tx
+	}
+	return d.primary
+}
+
+// Reader возвращает соединение для чтения: текущую транзакцию из контекста,
+// реплику по кругу среди здоровых или primary
+func (d *DB) Reader(ctx context.Context) Querier {
+	if tx := sqltx.Tx(ctx); tx != nil {
+		return tx
+	}
+	if s := sessionFromContext(ctx); s != nil && s.wrote.Load() {
+		return d.primary
+	}
+
+	n := len(d.replicas)
+	if n == 0 {
+		return d.primary
+	}
+
+	start := d.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := d.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+
+	return d.primary
+}
+
+// writeAtomically выполняет fn на соединении для записи одной транзакцией: в текущей, если она есть в контексте,
+// иначе в собственной, которая откатывается при ошибке fn
+func (d *DB) writeAtomically(ctx context.Context, fn func(q Querier) error) error {
+	d.markWrite(ctx)
+	if tx := sqltx.Tx(ctx); tx != nil {
+		return fn(tx)
+	}
+
+	tx, err := d.primary.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertBatch вставляет n строк запросом prefix + row на каждую строку и возвращает их идентификаторы по порядку.
+// Если сервер выделяет автоинкременты многострочного INSERT подряд, строки пишутся одним INSERT
+// и идентификаторы считаются от LastInsertId, иначе - по одной в общей транзакции.
+func (d *DB) insertBatch(ctx context.Context, prefix, row string, n int, rowArgs func(i int) []interface{}) ([]int64, error) {
+	ids := make([]int64, n)
+	err := d.writeAtomically(ctx, func(q Querier) error {
+		if d.consecutiveAutoIncrement(ctx, q) {
+			values := make([]string, n)
+			var args []interface{}
+			for i := range values {
+				values[i] = row
+				args = append(args, rowArgs(i)...)
+			}
+			result, err := q.ExecContext(ctx, prefix+strings.Join(values, ", "), args...)
+			if err != nil {
+				return err
+			}
+			first, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			for i := range ids {
+				ids[i] = first + int64(i)
+			}
+			return nil
+		}
+
+		for i := range ids {
+			result, err := q.ExecContext(ctx, prefix+row, rowArgs(i)...)
+			if err != nil {
+				return err
+			}
+			if ids[i], err = result.LastInsertId(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// consecutiveAutoIncrement сообщает, что InnoDB выделяет автоинкременты многострочного INSERT одним блоком
+// подряд от LastInsertId: для этого нужны auto_increment_increment = 1 и innodb_autoinc_lock_mode 0 или 1
+// (в MySQL 8 по умолчанию 2). Результат запоминается; если настройки прочитать не удалось, проверка повторится.
+func (d *DB) consecutiveAutoIncrement(ctx context.Context, q Querier) bool {
+	switch d.autoinc.Load() {
+	case autoincConsecutive:
+		return true
+	case autoincScattered:
+		return false
+	}
+
+	var increment, lockMode int
+	if err := q.QueryRowxContext(ctx, "SELECT @@auto_increment_increment, @@innodb_autoinc_lock_mode").Scan(&increment, &lockMode); err != nil {
+		slog.Warn("Failed to read auto-increment settings, inserting batch rows one by one", "error", err)
+		return false
+	}
+
+	if increment == 1 && (lockMode == 0 || lockMode == 1) {
+		d.autoinc.Store(autoincConsecutive)
+		return true
+	}
+	slog.Debug("Auto-increment values of multi-row INSERT may be scattered, inserting batch rows one by one",
+		"auto_increment_increment", increment, "innodb_autoinc_lock_mode", lockMode)
+	d.autoinc.Store(autoincScattered)
+	return false
+}
+
+func (d *DB) markWrite(ctx context.Context) {
+	if s := sessionFromContext(ctx); s != nil {
+		s.wrote.Store(true)
+	}
+}
+
+// StartHealthChecker проверяет доступность и отставание реплик сразу при запуске, а затем периодически
+func (d *DB) StartHealthChecker(ctx context.Context, interval time.Duration) {
+	if len(d.replicas) == 0 {
+		return
+	}
+
+	d.checkReplicas(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Debug("Replica health checker stopped")
+			return
+		case <-ticker.C:
+			d.checkReplicas(ctx)
+		}
+	}
+}
+
+func (d *DB) checkReplicas(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, r := range d.replicas {
+		wg.Add(1)
+		go func(i int, r *replica) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+
+			healthy := true
+			if err := r.db.PingContext(checkCtx); err != nil {
+				slog.Warn("Replica is unavailable", "replica", i, "error", err)
+				healthy = false
+			} else if d.maxLag > 0 {
+				lag, err := replicationLag(checkCtx, r.db)
+				if err != nil {
+					slog.Warn("Failed to get replica lag", "replica", i, "error", err)
+					healthy = false
+				} else if lag > d.maxLag {
+					slog.Warn("Replica lags behind, excluding from reads", "replica", i, "lag", lag)
+					healthy = false
+				}
+			}
+
+			if r.healthy.Swap(healthy) != healthy && healthy {
+				slog.Info("Replica is back in rotation", "replica", i)
+			}
+		}(i, r)
+	}
+	wg.Wait()
+}
+
+// replicationLag возвращает отставание реплики по SHOW REPLICA STATUS (MySQL 8.0.22+)
+// с откатом на SHOW SLAVE STATUS для старых версий
+func replicationLag(ctx context.Context, db *sqlx.DB) (time.Duration, error) {
+	row := map[string]interface{}{}
+	err := db.QueryRowxContext(ctx, "SHOW REPLICA STATUS").MapScan(row)
+	if err != nil {
+		row = map[string]interface{}{}
+		err = db.QueryRowxContext(ctx, "SHOW SLAVE STATUS").MapScan(row)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		value, ok := row[column]
+		if !ok {
+			continue
+		}
+		var seconds sql.NullInt64
+		if err := seconds.Scan(value); err != nil {
+			return 0, err
+		}
+		if !seconds.Valid {
+			// NULL означает, что репликация остановлена
+			return time.Duration(1<<63 - 1), nil
+		}
+		return time.Duration(seconds.Int64) * time.Second, nil
+	}
+
+	return 0, nil
+}
+
+type sessionKey struct{}
+
+type session struct {
+	wrote atomic.Bool
+}
+
+// WithSession помечает контекст как сессию запроса: после первой записи
+// все чтения в этой сессии идут в primary (read-your-writes)
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+func sessionFromContext(ctx context.Context) *session {
+	s, _ := ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+// withVersion добавляет к запросу условие на версию записи, если она задана (0 - без проверки).
+// Запрос должен заканчиваться условием WHERE.
+func withVersion(query string, args []interface{}, version int64) (string, []interface{}) {
+	if version == 0 {
+		return query, args
+	}
+	return query + " AND version = ?", append(args, version)
+}
+
+// checkVersion превращает UPDATE или DELETE с проверкой версии, не затронувший ни одной строки,
+// в ErrVersionConflict: запись изменилась или удалена после того, как клиент прочитал версию
+func checkVersion(result sql.Result, version int64) error {
+	if version == 0 {
+		return nil
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrVersionConflict
+	}
+	return nil
+}
+
+// each выполняет запрос и передает строки в fn по одной, не загружая результат в память целиком.
+// Ошибка fn прекращает чтение и возвращается как есть.
+func each[T any](ctx context.Context, q Querier, query string, fn func(*T) error, args ...interface{}) error {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := new(T)
+		if err := rows.StructScan(item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
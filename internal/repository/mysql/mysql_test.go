@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/contract"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"testing"
+	"time"
+)
+
+// Тесты выполняются против реальной БД со схемой из demo-data-sql.sql, например:
+// APP_TEST_MYSQL_DSN="user:passwd@tcp(localhost:3306)/app_database?parseTime=true" go test ./...
+func TestRepositoriesContract(t *testing.T) {
+	dsn := os.Getenv("APP_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("APP_TEST_MYSQL_DSN не задан")
+	}
+
+	conn, err := sqlx.Connect("mysql", dsn)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	contract.RunRepositories(t, func(t *testing.T) contract.Repositories {
+		db := NewDB(conn)
+		return contract.Repositories{
+			Users:      NewUserRepository(db),
+			Products:   NewProductRepository(db),
+			Purchases:  NewPurchaseRepository(db),
+			Categories: NewCategoryRepository(db),
+			Tags:       NewTagRepository(db),
+			Variants:   NewVariantRepository(db),
+			Rollups:    NewRollupRepository(db),
+			Prices:     NewPriceRepository(db),
+			Promotions: NewPromotionRepository(db),
+			TxManager:  NewTxManager(db, 3),
+		}
+	})
+}
+
+// unreachable открывает пул к адресу, где никто не слушает: sqlx.Open не подключается сразу,
+// а Ping быстро завершается ошибкой
+func unreachable(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("mysql", "app@tcp(127.0.0.1:1)/app?timeout=200ms")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReaderRouting(t *testing.T) {
+	primary, first, second := unreachable(t), unreachable(t), unreachable(t)
+	db := NewDB(primary).WithReplicas(0, first, second)
+	ctx := context.Background()
+
+	// До первой проверки здоровья реплики не получают чтений
+	if got := db.Reader(ctx); got != primary {
+		t.Fatal("до проверки здоровья чтение ушло на реплику")
+	}
+
+	db.replicas[0].healthy.Store(true)
+	db.replicas[1].healthy.Store(true)
+	seen := map[Querier]int{}
+	for i := 0; i < 4; i++ {
+		seen[db.Reader(ctx)]++
+	}
+	if seen[first] != 2 || seen[second] != 2 {
+		t.Errorf("чтения распределены не по кругу: первая %d, вторая %d", seen[first], seen[second])
+	}
+
+	// Нездоровая реплика пропускается
+	db.replicas[0].healthy.Store(false)
+	for i := 0; i < 3; i++ {
+		if got := db.Reader(ctx); got != second {
+			t.Fatal("чтение ушло на нездоровую реплику")
+		}
+	}
+
+	// После записи в сессии чтения идут в primary
+	session := WithSession(ctx)
+	if got := db.Reader(session); got != second {
+		t.Fatal("чтение до записи в сессии ушло не на реплику")
+	}
+	if got := db.Writer(session); got != primary {
+		t.Fatal("запись ушла не в primary")
+	}
+	if got := db.Reader(session); got != primary {
+		t.Error("чтение после записи в сессии ушло на реплику")
+	}
+}
+
+func TestHealthCheckerChecksImmediately(t *testing.T) {
+	primary, replica := unreachable(t), unreachable(t)
+	db := NewDB(primary).WithReplicas(0, replica)
+	db.replicas[0].healthy.Store(true)
+
+	// Интервал больше таймаута теста: исключить реплику может только проверка при запуске
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		db.StartHealthChecker(ctx, time.Hour)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for db.replicas[0].healthy.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("недоступная реплика осталась в ротации после проверки при запуске")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := db.Reader(context.Background()); got != primary {
+		t.Error("чтение ушло на недоступную реплику")
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,140 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+)
+
+type PromotionRepository struct {
+	db *DB
+}
+
+func NewPromotionRepository(db *DB) *PromotionRepository {
+	return &PromotionRepository{
+		db: db,
+	}
+}
+
+func (r *PromotionRepository) CreateCoupon(ctx context.Context, coupon *models.Coupon) (int64, error) {
+	query := `INSERT INTO coupons (code, type, value, max_uses, per_user_limit, min_order_value, expires_at, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, NOW())`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, coupon.Code, coupon.Type, coupon.Value,
+		coupon.MaxUses, coupon.PerUserLimit, coupon.MinOrderValue, coupon.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *PromotionRepository) GetCouponByID(ctx context.Context, id int64) (*models.Coupon, error) {
+	return r.getCoupon(ctx, "SELECT * FROM coupons WHERE id = ?", id)
+}
+
+// GetCouponByCode возвращает промокод code; nil - промокод свободен
+func (r *PromotionRepository) GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	return r.getCoupon(ctx, "SELECT * FROM coupons WHERE code = ?", code)
+}
+
+func (r *PromotionRepository) getCoupon(ctx context.Context, query string, arg interface{}) (*models.Coupon, error) {
+	coupon := &models.Coupon{}
+	err := r.db.Reader(ctx).GetContext(ctx, coupon, query, arg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Промокод не найден
+		}
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (r *PromotionRepository) GetCoupons(ctx context.Context) ([]*models.Coupon, error) {
+	coupons := []*models.Coupon{}
+	err := r.db.Reader(ctx).SelectContext(ctx, &coupons, "SELECT * FROM coupons ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+func (r *PromotionRepository) DeleteCoupon(ctx context.Context, id int64) error {
+	_, err := r.db.Writer(ctx).ExecContext(ctx, "DELETE FROM coupons WHERE id = ?", id)
+	return err
+}
+
+// ClaimCoupon увеличивает uses промокода, если не достигнут max_uses; false - достигнут или промокод удален
+func (r *PromotionRepository) ClaimCoupon(ctx context.Context, id int64) (bool, error) {
+	query := "UPDATE coupons SET uses = uses + 1 WHERE id = ? AND (max_uses IS NULL OR uses < max_uses)"
+	return r.exec(ctx, query, id)
+}
+
+// ClaimUserCoupon увеличивает счетчик применений промокода пользователем, если он меньше limit
+func (r *PromotionRepository) ClaimUserCoupon(ctx context.Context, couponID, userID int64, limit int) (bool, error) {
+	query := "INSERT INTO coupon_uses (coupon_id, user_id, uses) VALUES (?, ?, 0) ON DUPLICATE KEY UPDATE uses = uses"
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, couponID, userID); err != nil {
+		return false, err
+	}
+	query = "UPDATE coupon_uses SET uses = uses + 1 WHERE coupon_id = ? AND user_id = ? AND uses < ?"
+	return r.exec(ctx, query, couponID, userID, limit)
+}
+
+// AdjustCouponUses прибавляет delta к uses промокода и к счетчику пользователя, если он есть; ниже нуля счетчики не опускаются
+func (r *PromotionRepository) AdjustCouponUses(ctx context.Context, couponID, userID int64, delta int) error {
+	query := "UPDATE coupons SET uses = uses + ? WHERE id = ? AND uses + ? >= 0"
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, delta, couponID, delta); err != nil {
+		return err
+	}
+	query = "UPDATE coupon_uses SET uses = uses + ? WHERE coupon_id = ? AND user_id = ? AND uses + ? >= 0"
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, delta, couponID, userID, delta)
+	return err
+}
+
+// exec выполняет условный UPDATE и сообщает, изменил ли он строку
+func (r *PromotionRepository) exec(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+func (r *PromotionRepository) CreateRule(ctx context.Context, rule *models.PromotionRule) (int64, error) {
+	query := `INSERT INTO promotion_rules (name, category_id, min_quantity, type, value, created_at)
+			  VALUES (?, ?, ?, ?, ?, NOW())`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, rule.Name, rule.CategoryID, rule.MinQuantity, rule.Type, rule.Value)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *PromotionRepository) GetRuleByID(ctx context.Context, id int64) (*models.PromotionRule, error) {
+	rule := &models.PromotionRule{}
+	err := r.db.Reader(ctx).GetContext(ctx, rule, "SELECT * FROM promotion_rules WHERE id = ?", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Правило не найдено
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *PromotionRepository) GetRules(ctx context.Context) ([]*models.PromotionRule, error) {
+	rules := []*models.PromotionRule{}
+	err := r.db.Reader(ctx).SelectContext(ctx, &rules, "SELECT * FROM promotion_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *PromotionRepository) DeleteRule(ctx context.Context, id int64) error {
+	_, err := r.db.Writer(ctx).ExecContext(ctx, "DELETE FROM promotion_rules WHERE id = ?", id)
+	return err
+}
@@ -5,14 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
-	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 type PurchaseRepository struct {
-	db *sqlx.DB
+	db *DB
 }
 
-func NewPurchaseRepository(db *sqlx.DB) *PurchaseRepository {
+func NewPurchaseRepository(db *DB) *PurchaseRepository {
 	return &PurchaseRepository{
 		db: db,
 	}
@@ -21,7 +21,21 @@ func NewPurchaseRepository(db *sqlx.DB) *PurchaseRepository {
 func (r *PurchaseRepository) GetByID(ctx context.Context, id int64) (*models.Purchase, error) {
 	purchase := &models.Purchase{}
 	query := "SELECT * FROM purchases WHERE id = ?"
-	err := r.db.GetContext(ctx, purchase, query, id)
+	err := r.db.Reader(ctx).GetContext(ctx, purchase, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Покупка не найдена
+		}
+		return nil, err
+	}
+	return purchase, nil
+}
+
+// GetByIDForUpdate читает покупку с primary и в транзакции блокирует ее строку до фиксации
+func (r *PurchaseRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.Purchase, error) {
+	purchase := &models.Purchase{}
+	query := "SELECT * FROM purchases WHERE id = ? FOR UPDATE"
+	err := r.db.Writer(ctx).GetContext(ctx, purchase, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // Покупка не найдена
@@ -34,76 +48,67 @@ func (r *PurchaseRepository) GetByID(ctx context.Context, id int64) (*models.Pur
 func (r *PurchaseRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Purchase, error) {
 	purchases := []*models.Purchase{}
 	query := "SELECT * FROM purchases WHERE user_id = ?"
-	err := r.db.SelectContext(ctx, &purchases, query, userID)
+	err := r.db.Reader(ctx).SelectContext(ctx, &purchases, query, userID)
 	if err != nil {
 		return nil, err
 	}
 	return purchases, nil
 }
 
+// Create сохраняет запись о покупке. Списание товара со склада выполняет сервис
+// в той же транзакции через ProductRepository.DecreaseQuantity.
 func (r *PurchaseRepository) Create(ctx context.Context, purchase *models.Purchase) (int64, error) {
-	tx, err := r.db.BeginTxx(ctx, nil)
-	if err != nil {
-		return 0, err
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Проверяем, достаточно ли товара на складе
-	var currentQuantity int
-	err = tx.GetContext(ctx, &currentQuantity, "SELECT quantity FROM products WHERE id = ?", purchase.ProductID)
+	query := `INSERT INTO purchases (user_id, product_id, variant_id, quantity, total_price, status, discounts, created_at, updated_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query,
+		purchase.UserID, purchase.ProductID, purchase.VariantID, purchase.Quantity, purchase.TotalPrice, purchase.Status, purchase.Discounts)
 	if err != nil {
 		return 0, err
 	}
+	return result.LastInsertId()
+}
 
-	if currentQuantity < purchase.Quantity {
-		return 0, errors.New("недостаточное количество товара")
-	}
-
-	// Обновляем количество товара
-	_, err = tx.ExecContext(ctx, "UPDATE products SET quantity = quantity - ?, updated_at = NOW() WHERE id = ?",
-		purchase.Quantity, purchase.ProductID)
-	if err != nil {
-		return 0, err
-	}
-
-	// Создаем запись о покупке
-	query := `INSERT INTO purchases (user_id, product_id, quantity, total_price, status, created_at, updated_at) 
-			  VALUES (?, ?, ?, ?, ?, NOW(), NOW())`
-	result, err := tx.ExecContext(ctx, query,
-		purchase.UserID, purchase.ProductID, purchase.Quantity, purchase.TotalPrice, purchase.Status)
-	if err != nil {
-		return 0, err
-	}
-
-	purchaseID, err := result.LastInsertId()
+// UpdateStatus меняет статус покупки и увеличивает версию; если version задана, только при совпадении версии
+func (r *PurchaseRepository) UpdateStatus(ctx context.Context, id int64, status string, version int64) error {
+	query, args := withVersion("UPDATE purchases SET status = ?, version = version + 1, updated_at = NOW() WHERE id = ?",
+		[]interface{}{status, id}, version)
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, err
+		return err
 	}
-
-	// Фиксируем транзакцию
-	if err := tx.Commit(); err != nil {
-		return 0, err
-	}
-
-	return purchaseID, nil
-}
-
-func (r *PurchaseRepository) UpdateStatus(ctx context.Context, id int64, status string) error {
-	query := "UPDATE purchases SET status = ?, updated_at = NOW() WHERE id = ?"
-	_, err := r.db.ExecContext(ctx, query, status, id)
-	return err
+	return checkVersion(result, version)
 }
 
 func (r *PurchaseRepository) GetAll(ctx context.Context) ([]*models.Purchase, error) {
 	purchases := []*models.Purchase{}
 	query := "SELECT * FROM purchases"
-	err := r.db.SelectContext(ctx, &purchases, query)
+	err := r.db.Reader(ctx).SelectContext(ctx, &purchases, query)
 	if err != nil {
 		return nil, err
 	}
 	return purchases, nil
 }
+
+// ForEach передает в fn все покупки в порядке id, читая их из курсора по одной строке
+func (r *PurchaseRepository) ForEach(ctx context.Context, fn func(purchase *models.Purchase) error) error {
+	return each(ctx, r.db.Reader(ctx), "SELECT * FROM purchases ORDER BY id", fn)
+}
+
+// ForEachCompleted передает в fn завершенные покупки, измененные начиная с since, в порядке id
+func (r *PurchaseRepository) ForEachCompleted(ctx context.Context, since time.Time, fn func(purchase *models.Purchase) error) error {
+	query := "SELECT * FROM purchases WHERE status = 'completed' AND updated_at >= ? ORDER BY id"
+	return each(ctx, r.db.Reader(ctx), query, fn, since)
+}
+
+// ForEachCreated передает в fn покупки, созданные в [from, to), в порядке id
+func (r *PurchaseRepository) ForEachCreated(ctx context.Context, from, to time.Time, fn func(purchase *models.Purchase) error) error {
+	query := "SELECT * FROM purchases WHERE created_at >= ? AND created_at < ? ORDER BY id"
+	return each(ctx, r.db.Reader(ctx), query, fn, from, to)
+}
+
+// LockCreated в транзакции блокирует покупки, созданные в [from, to), до ее фиксации: разделяемая
+// блокировка по индексу created_at не дает менять их статус и вставлять новые покупки в этот диапазон
+func (r *PurchaseRepository) LockCreated(ctx context.Context, from, to time.Time) error {
+	_, err := r.db.Writer(ctx).ExecContext(ctx, "SELECT id FROM purchases WHERE created_at >= ? AND created_at < ? FOR SHARE", from, to)
+	return err
+}
@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqltx"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// Коды ошибок MySQL, при которых транзакцию имеет смысл повторить
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// NewTxManager создает менеджер транзакций на primary. Транзакция отмечает запись в сессии запроса,
+// чтобы чтения после нее шли в primary.
+func NewTxManager(db *DB, maxRetries int) *sqltx.Manager {
+	begin := func(ctx context.Context) (*sqlx.Tx, error) {
+		db.markWrite(ctx)
+		return db.Primary().BeginTxx(ctx, nil)
+	}
+	return sqltx.NewManager(begin, isRetryable, maxRetries)
+}
+
+func isRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errDeadlock || mysqlErr.Number == errLockWaitTimeout
+	}
+	return false
+}
@@ -5,14 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
-	"github.com/jmoiron/sqlx"
+	"strings"
 )
 
 type UserRepository struct {
-	db *sqlx.DB
+	db *DB
 }
 
-func NewUserRepository(db *sqlx.DB) *UserRepository {
+func NewUserRepository(db *DB) *UserRepository {
 	return &UserRepository{
 		db: db,
 	}
@@ -21,7 +21,7 @@ func NewUserRepository(db *sqlx.DB) *UserRepository {
 func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
 	query := "SELECT * FROM users WHERE id = ?"
-	err := r.db.GetContext(ctx, user, query, id)
+	err := r.db.Reader(ctx).GetContext(ctx, user, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // Пользователь не найден
@@ -34,7 +34,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, e
 func (r *UserRepository) GetAll(ctx context.Context) ([]*models.User, error) {
 	users := []*models.User{}
 	query := "SELECT * FROM users"
-	err := r.db.SelectContext(ctx, &users, query)
+	err := r.db.Reader(ctx).SelectContext(ctx, &users, query)
 	if err != nil {
 		return nil, err
 	}
@@ -43,21 +43,74 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*models.User, error) {
 
 func (r *UserRepository) Create(ctx context.Context, user *models.User) (int64, error) {
 	query := "INSERT INTO users (username, email, created_at, updated_at) VALUES (?, ?, NOW(), NOW())"
-	result, err := r.db.ExecContext(ctx, query, user.Username, user.Email)
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, user.Username, user.Email)
 	if err != nil {
 		return 0, err
 	}
 	return result.LastInsertId()
 }
 
+// CreateBatch создает записи одним многострочным INSERT (или по одной, если автоинкременты
+// такого INSERT не идут подряд, см. DB.insertBatch) и возвращает их идентификаторы в порядке users.
+func (r *UserRepository) CreateBatch(ctx context.Context, users []*models.User) ([]int64, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	return r.db.insertBatch(ctx, "INSERT INTO users (username, email, created_at, updated_at) VALUES ", "(?, ?, NOW(), NOW())",
+		len(users), func(i int) []interface{} {
+			return []interface{}{users[i].Username, users[i].Email}
+		})
+}
+
+// Update перезаписывает пользователя и увеличивает версию.
+// Если user.Version задана, запись обновляется только при совпадении версии, иначе - ErrVersionConflict.
 func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
-	query := "UPDATE users SET username = ?, email = ?, updated_at = NOW() WHERE id = ?"
-	_, err := r.db.ExecContext(ctx, query, user.Username, user.Email, user.ID)
-	return err
+	query, args := withVersion("UPDATE users SET username = ?, email = ?, version = version + 1, updated_at = NOW() WHERE id = ?",
+		[]interface{}{user.Username, user.Email, user.ID}, user.Version)
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, user.Version)
+}
+
+// Patch обновляет только переданные столбцы и увеличивает версию; patch.Version проверяется как в Update
+func (r *UserRepository) Patch(ctx context.Context, id int64, patch *models.UserPatch) error {
+	var set []string
+	var args []interface{}
+	if patch.Username != nil {
+		set = append(set, "username = ?")
+		args = append(args, *patch.Username)
+	}
+	if patch.Email != nil {
+		set = append(set, "email = ?")
+		args = append(args, *patch.Email)
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	query, args := withVersion("UPDATE users SET "+strings.Join(set, ", ")+", version = version + 1, updated_at = NOW() WHERE id = ?",
+		append(args, id), patch.Version)
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, patch.Version)
+}
+
+// Delete удаляет пользователя; если version задана, только при совпадении версии
+func (r *UserRepository) Delete(ctx context.Context, id int64, version int64) error {
+	query, args := withVersion("DELETE FROM users WHERE id = ?", []interface{}{id}, version)
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
 }
 
-func (r *UserRepository) Delete(ctx context.Context, id int64) error {
-	query := "DELETE FROM users WHERE id = ?"
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+// ForEach передает в fn все пользователей в порядке id, читая их из курсора по одной строке
+func (r *UserRepository) ForEach(ctx context.Context, fn func(user *models.User) error) error {
+	return each(ctx, r.db.Reader(ctx), "SELECT * FROM users ORDER BY id", fn)
 }
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqltx"
+	"github.com/jmoiron/sqlx"
+)
+
+// Querier - общий набор методов *sqlx.DB и *sqlx.Tx, которым пользуются репозитории
+type Querier interface {
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+}
+
+// DB возвращает репозиториям текущую транзакцию из контекста или пул соединений
+type DB struct {
+	db *sqlx.DB
+}
+
+func NewDB(db *sqlx.DB) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) Conn(ctx context.Context) Querier {
+	if tx := sqltx.Tx(ctx); tx != nil {
+		return tx
+	}
+	return d.db
+}
+
+// withVersion добавляет к запросу условие на версию записи, если она задана (0 - без проверки).
+// Запрос должен заканчиваться условием WHERE.
+func withVersion(query string, args []interface{}, version int64) (string, []interface{}) {
+	if version == 0 {
+		return query, args
+	}
+	return query + fmt.Sprintf(" AND version = $%d", len(args)+1), append(args, version)
+}
+
+// checkVersion превращает UPDATE или DELETE с проверкой версии, не затронувший ни одной строки,
+// в ErrVersionConflict: запись изменилась или удалена после того, как клиент прочитал версию
+func checkVersion(result sql.Result, version int64) error {
+	if version == 0 {
+		return nil
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrVersionConflict
+	}
+	return nil
+}
+
+// each выполняет запрос и передает строки в fn по одной, не загружая результат в память целиком.
+// Ошибка fn прекращает чтение и возвращается как есть.
+func each[T any](ctx context.Context, q Querier, query string, fn func(*T) error, args ...interface{}) error {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := new(T)
+		if err := rows.StructScan(item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
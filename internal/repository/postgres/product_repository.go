@@ -0,0 +1,318 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/jmoiron/sqlx"
+	"strconv"
+	"strings"
+)
+
+type ProductRepository struct {
+	db *DB
+}
+
+func NewProductRepository(db *DB) *ProductRepository {
+	return &ProductRepository{
+		db: db,
+	}
+}
+
+func (r *ProductRepository) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	product := &models.Product{}
+	query := "SELECT * FROM products WHERE id = $1"
+	err := r.db.Conn(ctx).GetContext(ctx, product, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Продукт не найден
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *ProductRepository) GetAll(ctx context.Context) ([]*models.Product, error) {
+	products := []*models.Product{}
+	query := "SELECT * FROM products ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &products, query)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *ProductRepository) Create(ctx context.Context, product *models.Product) (int64, error) {
+	var id int64
+	query := "INSERT INTO products (name, description, price, quantity, created_at, updated_at) VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING id"
+	err := r.db.Conn(ctx).GetContext(ctx, &id, query, product.Name, product.Description, product.Price, product.Quantity)
+	return id, err
+}
+
+// CreateBatch создает записи одним многострочным INSERT и возвращает их идентификаторы в порядке products:
+// RETURNING отдает строки VALUES в порядке вставки
+func (r *ProductRepository) CreateBatch(ctx context.Context, products []*models.Product) ([]int64, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	values := make([]string, len(products))
+	args := make([]interface{}, 0, len(products)*4)
+	for i, product := range products {
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, NOW(), NOW())", n+1, n+2, n+3, n+4)
+		args = append(args, product.Name, product.Description, product.Price, product.Quantity)
+	}
+
+	ids := make([]int64, 0, len(products))
+	query := "INSERT INTO products (name, description, price, quantity, created_at, updated_at) VALUES " + strings.Join(values, ", ") + " RETURNING id"
+	if err := r.db.Conn(ctx).SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Update перезаписывает товар и увеличивает версию.
+// Если product.Version задана, запись обновляется только при совпадении версии, иначе - ErrVersionConflict.
+func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
+	query, args := withVersion("UPDATE products SET name = $1, description = $2, price = $3, quantity = $4, version = version + 1, updated_at = NOW() WHERE id = $5",
+		[]interface{}{product.Name, product.Description, product.Price, product.Quantity, product.ID}, product.Version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, product.Version)
+}
+
+// Patch обновляет только переданные столбцы и увеличивает версию; patch.Version проверяется как в Update
+func (r *ProductRepository) Patch(ctx context.Context, id int64, patch *models.ProductPatch) error {
+	var set []string
+	var args []interface{}
+	if patch.Name != nil {
+		args = append(args, *patch.Name)
+		set = append(set, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if patch.Description != nil {
+		args = append(args, *patch.Description)
+		set = append(set, fmt.Sprintf("description = $%d", len(args)))
+	}
+	if patch.Price != nil {
+		args = append(args, *patch.Price)
+		set = append(set, fmt.Sprintf("price = $%d", len(args)))
+	}
+	if patch.Quantity != nil {
+		args = append(args, *patch.Quantity)
+		set = append(set, fmt.Sprintf("quantity = $%d", len(args)))
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	args = append(args, id)
+	query, args := withVersion(fmt.Sprintf("UPDATE products SET %s, version = version + 1, updated_at = NOW() WHERE id = $%d", strings.Join(set, ", "), len(args)),
+		args, patch.Version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, patch.Version)
+}
+
+// DecreaseQuantity атомарно списывает товар со склада, если его достаточно
+func (r *ProductRepository) DecreaseQuantity(ctx context.Context, id int64, quantity int) error {
+	query := "UPDATE products SET quantity = quantity - $1, version = version + 1, updated_at = NOW() WHERE id = $2 AND quantity >= $1"
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, quantity, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrInsufficientStock
+	}
+	return nil
+}
+
+// Delete удаляет товар; если version задана, только при совпадении версии
+func (r *ProductRepository) Delete(ctx context.Context, id int64, version int64) error {
+	query, args := withVersion("DELETE FROM products WHERE id = $1", []interface{}{id}, version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
+}
+
+// ForEach передает в fn все товары в порядке id, читая их из курсора по одной строке
+func (r *ProductRepository) ForEach(ctx context.Context, fn func(product *models.Product) error) error {
+	return each(ctx, r.db.Conn(ctx), "SELECT * FROM products ORDER BY id", fn)
+}
+
+// GetByName возвращает товары с указанным названием; название не уникально, поэтому их может быть несколько
+func (r *ProductRepository) GetByName(ctx context.Context, name string) ([]*models.Product, error) {
+	products := []*models.Product{}
+	query := "SELECT * FROM products WHERE name = $1 ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &products, query, name)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// GetByFilter возвращает товары категорий и тега фильтра в порядке id
+func (r *ProductRepository) GetByFilter(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error) {
+	products := []*models.Product{}
+	scope, args := productScope(filter)
+	query := sqlx.Rebind(sqlx.DOLLAR, "SELECT p.* FROM products p WHERE "+scope+" ORDER BY p.id")
+	if err := r.db.Conn(ctx).SelectContext(ctx, &products, query, args...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// SalesCounts возвращает, сколько единиц каждого товара продано (покупки, кроме отмененных);
+// товары без продаж в результат не попадают
+func (r *ProductRepository) SalesCounts(ctx context.Context) (map[int64]int64, error) {
+	rows := []struct {
+		ProductID int64 `db:"product_id"`
+		Sales     int64 `db:"sales"`
+	}{}
+	query := "SELECT product_id, SUM(quantity) AS sales FROM purchases WHERE status <> 'cancelled' GROUP BY product_id"
+	if err := r.db.Conn(ctx).SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	sales := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		sales[row.ProductID] = row.Sales
+	}
+	return sales, nil
+}
+
+// productSearchVector - выражение индекса idx_products_search (GIN): название весит больше описания.
+// Конфигурация simple не стеммит слова - основы выделяет сервис (search.Terms), совпадение по префиксу.
+const productSearchVector = "(setweight(to_tsvector('simple', p.name), 'A') || setweight(to_tsvector('simple', p.description), 'B'))"
+
+// Search ищет товары по индексу idx_products_search: каждый терм обязателен и ищется как префикс слова,
+// релевантность - ts_rank. Всего найденных и фасеты считаются одним агрегатным запросом по тем же совпадениям.
+func (r *ProductRepository) Search(ctx context.Context, query *models.ProductSearchQuery) (*models.ProductSearchResult, error) {
+	match, score := "1 = 1", ""
+	var matchArgs []interface{}
+	if len(query.Terms) > 0 {
+		match = productSearchVector + " @@ to_tsquery('simple', ?)"
+		score = "ts_rank(" + productSearchVector + ", to_tsquery('simple', ?))"
+		matchArgs = append(matchArgs, tsQuery(query.Terms))
+	}
+	scope, scopeArgs := productScope(query.Filter())
+	filter, filterArgs := searchFilter(query)
+
+	products := []*models.Product{}
+	page := "SELECT p.* FROM products p WHERE " + match + " AND " + scope + " AND " + filter + " ORDER BY " + searchOrder(query.Sort, score) + " LIMIT ? OFFSET ?"
+	args := append(append(append([]interface{}{}, matchArgs...), scopeArgs...), filterArgs...)
+	if query.Sort == models.SortRelevance {
+		args = append(args, matchArgs...)
+	}
+	args = append(args, query.Limit, query.Offset)
+	if err := r.db.Conn(ctx).SelectContext(ctx, &products, sqlx.Rebind(sqlx.DOLLAR, page), args...); err != nil {
+		return nil, err
+	}
+
+	counts := make([]int, 2+len(models.PriceBuckets))
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	stats := "SELECT COALESCE(SUM(CASE WHEN " + filter + " THEN 1 ELSE 0 END), 0), COALESCE(SUM(CASE WHEN " + inStockCondition + " THEN 1 ELSE 0 END), 0)" +
+		priceFacetColumns() + " FROM products p WHERE " + match + " AND " + scope
+	args = append(append(append([]interface{}{}, filterArgs...), matchArgs...), scopeArgs...)
+	if err := r.db.Conn(ctx).QueryRowxContext(ctx, sqlx.Rebind(sqlx.DOLLAR, stats), args...).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	return models.NewProductSearchResult(query, products, counts[0], counts[1], counts[2:]), nil
+}
+
+// tsQuery строит tsquery: все термы как префиксы слов
+func tsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// productScope - условие на товары категорий и тега фильтра (плейсхолдеры ?)
+func productScope(filter models.ProductFilter) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	if len(filter.CategoryIDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.CategoryIDs)), ", ")
+		conditions = append(conditions, "p.id IN (SELECT product_id FROM product_categories WHERE category_id IN ("+placeholders+"))")
+		for _, id := range filter.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "p.id IN (SELECT pt.product_id FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.name = ?)")
+		args = append(args, filter.Tag)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// inStockCondition - товар в наличии: у товара с вариантами - хотя бы один вариант с остатком,
+// у товара без вариантов - собственный остаток
+const inStockCondition = "(EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.quantity > 0)" +
+	" OR p.quantity > 0 AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id))"
+
+// searchFilter - условие фильтров поиска по цене и наличию (плейсхолдеры ?, см. sqlx.Rebind)
+func searchFilter(query *models.ProductSearchQuery) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	if query.MinPrice != nil {
+		conditions = append(conditions, "p.price >= ?")
+		args = append(args, *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		conditions = append(conditions, "p.price <= ?")
+		args = append(args, *query.MaxPrice)
+	}
+	if query.InStock {
+		conditions = append(conditions, inStockCondition)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// searchOrder - порядок результатов поиска; score - выражение релевантности, пусто без термов
+func searchOrder(sort, score string) string {
+	switch sort {
+	case models.SortPriceAsc:
+		return "p.price, p.id"
+	case models.SortPriceDesc:
+		return "p.price DESC, p.id"
+	case models.SortNewest:
+		return "p.created_at DESC, p.id DESC"
+	default:
+		// Без термов релевантности нет; константа в ORDER BY читалась бы как номер столбца
+		if score == "" {
+			return "p.id"
+		}
+		return score + " DESC, p.id"
+	}
+}
+
+// priceFacetColumns - столбцы агрегатного запроса с количеством товаров в каждом диапазоне models.PriceBuckets
+func priceFacetColumns() string {
+	var b strings.Builder
+	for i, from := range models.PriceBuckets {
+		condition := "p.price >= " + strconv.FormatFloat(from, 'f', -1, 64)
+		if i+1 < len(models.PriceBuckets) {
+			condition += " AND p.price < " + strconv.FormatFloat(models.PriceBuckets[i+1], 'f', -1, 64)
+		}
+		b.WriteString(", COALESCE(SUM(CASE WHEN " + condition + " THEN 1 ELSE 0 END), 0)")
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+type PurchaseRepository struct {
+	db *DB
+}
+
+func NewPurchaseRepository(db *DB) *PurchaseRepository {
+	return &PurchaseRepository{
+		db: db,
+	}
+}
+
+func (r *PurchaseRepository) GetByID(ctx context.Context, id int64) (*models.Purchase, error) {
+	purchase := &models.Purchase{}
+	query := "SELECT * FROM purchases WHERE id = $1"
+	err := r.db.Conn(ctx).GetContext(ctx, purchase, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Покупка не найдена
+		}
+		return nil, err
+	}
+	return purchase, nil
+}
+
+// GetByIDForUpdate читает покупку и в транзакции блокирует ее строку до фиксации
+func (r *PurchaseRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.Purchase, error) {
+	purchase := &models.Purchase{}
+	query := "SELECT * FROM purchases WHERE id = $1 FOR UPDATE"
+	err := r.db.Conn(ctx).GetContext(ctx, purchase, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Покупка не найдена
+		}
+		return nil, err
+	}
+	return purchase, nil
+}
+
+func (r *PurchaseRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Purchase, error) {
+	purchases := []*models.Purchase{}
+	query := "SELECT * FROM purchases WHERE user_id = $1 ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &purchases, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return purchases, nil
+}
+
+// Create сохраняет запись о покупке. Списание товара со склада выполняет сервис
+// в той же транзакции через ProductRepository.DecreaseQuantity.
+func (r *PurchaseRepository) Create(ctx context.Context, purchase *models.Purchase) (int64, error) {
+	var id int64
+	query := `INSERT INTO purchases (user_id, product_id, variant_id, quantity, total_price, status, discounts, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW()) RETURNING id`
+	err := r.db.Conn(ctx).GetContext(ctx, &id, query,
+		purchase.UserID, purchase.ProductID, purchase.VariantID, purchase.Quantity, purchase.TotalPrice, purchase.Status, purchase.Discounts)
+	return id, err
+}
+
+// UpdateStatus меняет статус покупки и увеличивает версию; если version задана, только при совпадении версии
+func (r *PurchaseRepository) UpdateStatus(ctx context.Context, id int64, status string, version int64) error {
+	query, args := withVersion("UPDATE purchases SET status = $1, version = version + 1, updated_at = NOW() WHERE id = $2",
+		[]interface{}{status, id}, version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
+}
+
+func (r *PurchaseRepository) GetAll(ctx context.Context) ([]*models.Purchase, error) {
+	purchases := []*models.Purchase{}
+	query := "SELECT * FROM purchases ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &purchases, query)
+	if err != nil {
+		return nil, err
+	}
+	return purchases, nil
+}
+
+// ForEach передает в fn все покупки в порядке id, читая их из курсора по одной строке
+func (r *PurchaseRepository) ForEach(ctx context.Context, fn func(purchase *models.Purchase) error) error {
+	return each(ctx, r.db.Conn(ctx), "SELECT * FROM purchases ORDER BY id", fn)
+}
+
+// ForEachCompleted передает в fn завершенные покупки, измененные начиная с since, в порядке id
+func (r *PurchaseRepository) ForEachCompleted(ctx context.Context, since time.Time, fn func(purchase *models.Purchase) error) error {
+	query := "SELECT * FROM purchases WHERE status = 'completed' AND updated_at >= $1 ORDER BY id"
+	return each(ctx, r.db.Conn(ctx), query, fn, since)
+}
+
+// ForEachCreated передает в fn покупки, созданные в [from, to), в порядке id
+func (r *PurchaseRepository) ForEachCreated(ctx context.Context, from, to time.Time, fn func(purchase *models.Purchase) error) error {
+	query := "SELECT * FROM purchases WHERE created_at >= $1 AND created_at < $2 ORDER BY id"
+	return each(ctx, r.db.Conn(ctx), query, fn, from, to)
+}
+
+// LockCreated в транзакции блокирует покупки, созданные в [from, to), до ее фиксации. Диапазоны
+// Postgres блокировать не умеет, поэтому блокируется вся таблица в режиме SHARE: чтение идет,
+// а вставки и смены статуса ждут фиксации
+func (r *PurchaseRepository) LockCreated(ctx context.Context, from, to time.Time) error {
+	_, err := r.db.Conn(ctx).ExecContext(ctx, "LOCK TABLE purchases IN SHARE MODE")
+	return err
+}
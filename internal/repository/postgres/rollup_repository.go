@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+// rollupTables - таблицы дневных агрегатов и столбец id в каждой
+var rollupTables = []struct {
+	name, column string
+	id           func(rollup *models.SalesRollup) int64
+}{
+	{"sales_daily_products", "product_id", func(rollup *models.SalesRollup) int64 { return rollup.ProductID }},
+	{"sales_daily_users", "user_id", func(rollup *models.SalesRollup) int64 { return rollup.UserID }},
+}
+
+type RollupRepository struct {
+	db *DB
+}
+
+func NewRollupRepository(db *DB) *RollupRepository {
+	return &RollupRepository{
+		db: db,
+	}
+}
+
+// Apply прибавляет дельты к агрегатам товара и пользователя каждой строки, создавая недостающие строки
+func (r *RollupRepository) Apply(ctx context.Context, deltas []models.SalesRollup) error {
+	conn := r.db.Conn(ctx)
+	for _, table := range rollupTables {
+		query := `INSERT INTO ` + table.name + ` (day, ` + table.column + `, orders, completed, cancelled, units, revenue)
+				  VALUES ($1, $2, $3, $4, $5, $6, $7)
+				  ON CONFLICT (day, ` + table.column + `) DO UPDATE SET orders = ` + table.name + `.orders + excluded.orders,
+				  completed = ` + table.name + `.completed + excluded.completed, cancelled = ` + table.name + `.cancelled + excluded.cancelled,
+				  units = ` + table.name + `.units + excluded.units, revenue = ` + table.name + `.revenue + excluded.revenue`
+		for i := range deltas {
+			delta := &deltas[i]
+			_, err := conn.ExecContext(ctx, query, delta.Day.UTC().Format(models.ReportDateLayout), table.id(delta),
+				delta.Orders, delta.Completed, delta.Cancelled, delta.Units, delta.Revenue)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Replace удаляет агрегаты дней [from, to) и записывает rollups; вызывается в транзакции
+func (r *RollupRepository) Replace(ctx context.Context, from, to time.Time, rollups []models.SalesRollup) error {
+	for _, table := range rollupTables {
+		_, err := r.db.Conn(ctx).ExecContext(ctx, "DELETE FROM "+table.name+" WHERE day >= $1 AND day < $2",
+			from.UTC().Format(models.ReportDateLayout), to.UTC().Format(models.ReportDateLayout))
+		if err != nil {
+			return err
+		}
+	}
+	return r.Apply(ctx, rollups)
+}
+
+// ProductRollups возвращает агрегаты товаров за дни [from, to) по дню и id товара
+func (r *RollupRepository) ProductRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error) {
+	return r.rollups(ctx, "sales_daily_products", "product_id", from, to)
+}
+
+// UserRollups возвращает агрегаты пользователей за дни [from, to) по дню и id пользователя
+func (r *RollupRepository) UserRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error) {
+	return r.rollups(ctx, "sales_daily_users", "user_id", from, to)
+}
+
+func (r *RollupRepository) rollups(ctx context.Context, table, column string, from, to time.Time) ([]models.SalesRollup, error) {
+	rollups := []models.SalesRollup{}
+	query := "SELECT * FROM " + table + " WHERE day >= $1 AND day < $2 ORDER BY day, " + column
+	err := r.db.Conn(ctx).SelectContext(ctx, &rollups, query,
+		from.UTC().Format(models.ReportDateLayout), to.UTC().Format(models.ReportDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	return rollups, nil
+}
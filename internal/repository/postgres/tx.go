@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqltx"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Коды ошибок PostgreSQL, при которых транзакцию имеет смысл повторить
+const (
+	errSerializationFailure = "40001"
+	errDeadlockDetected     = "40P01"
+)
+
+func NewTxManager(db *DB, maxRetries int) *sqltx.Manager {
+	begin := func(ctx context.Context) (*sqlx.Tx, error) {
+		return db.db.BeginTxx(ctx, nil)
+	}
+	return sqltx.NewManager(begin, isRetryable, maxRetries)
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == errSerializationFailure || pqErr.Code == errDeadlockDetected
+	}
+	return false
+}
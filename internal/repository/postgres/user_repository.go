@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"strings"
+)
+
+type UserRepository struct {
+	db *DB
+}
+
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	user := &models.User{}
+	query := "SELECT * FROM users WHERE id = $1"
+	err := r.db.Conn(ctx).GetContext(ctx, user, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Пользователь не найден
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *UserRepository) GetAll(ctx context.Context) ([]*models.User, error) {
+	users := []*models.User{}
+	query := "SELECT * FROM users ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &users, query)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) (int64, error) {
+	var id int64
+	query := "INSERT INTO users (username, email, created_at, updated_at) VALUES ($1, $2, NOW(), NOW()) RETURNING id"
+	err := r.db.Conn(ctx).GetContext(ctx, &id, query, user.Username, user.Email)
+	return id, err
+}
+
+// CreateBatch создает записи одним многострочным INSERT и возвращает их идентификаторы в порядке users:
+// RETURNING отдает строки VALUES в порядке вставки
+func (r *UserRepository) CreateBatch(ctx context.Context, users []*models.User) ([]int64, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	values := make([]string, len(users))
+	args := make([]interface{}, 0, len(users)*2)
+	for i, user := range users {
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, NOW(), NOW())", n+1, n+2)
+		args = append(args, user.Username, user.Email)
+	}
+
+	ids := make([]int64, 0, len(users))
+	query := "INSERT INTO users (username, email, created_at, updated_at) VALUES " + strings.Join(values, ", ") + " RETURNING id"
+	if err := r.db.Conn(ctx).SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Update перезаписывает пользователя и увеличивает версию.
+// Если user.Version задана, запись обновляется только при совпадении версии, иначе - ErrVersionConflict.
+func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
+	query, args := withVersion("UPDATE users SET username = $1, email = $2, version = version + 1, updated_at = NOW() WHERE id = $3",
+		[]interface{}{user.Username, user.Email, user.ID}, user.Version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, user.Version)
+}
+
+// Patch обновляет только переданные столбцы и увеличивает версию; patch.Version проверяется как в Update
+func (r *UserRepository) Patch(ctx context.Context, id int64, patch *models.UserPatch) error {
+	var set []string
+	var args []interface{}
+	if patch.Username != nil {
+		args = append(args, *patch.Username)
+		set = append(set, fmt.Sprintf("username = $%d", len(args)))
+	}
+	if patch.Email != nil {
+		args = append(args, *patch.Email)
+		set = append(set, fmt.Sprintf("email = $%d", len(args)))
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	args = append(args, id)
+	query, args := withVersion(fmt.Sprintf("UPDATE users SET %s, version = version + 1, updated_at = NOW() WHERE id = $%d", strings.Join(set, ", "), len(args)),
+		args, patch.Version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, patch.Version)
+}
+
+// Delete удаляет пользователя; если version задана, только при совпадении версии
+func (r *UserRepository) Delete(ctx context.Context, id int64, version int64) error {
+	query, args := withVersion("DELETE FROM users WHERE id = $1", []interface{}{id}, version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
+}
+
+// ForEach передает в fn все пользователей в порядке id, читая их из курсора по одной строке
+func (r *UserRepository) ForEach(ctx context.Context, fn func(user *models.User) error) error {
+	return each(ctx, r.db.Conn(ctx), "SELECT * FROM users ORDER BY id", fn)
+}
@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// categoryTreeKey - дерево категорий целиком: оно небольшое и читается при каждом фильтре по категории
+const categoryTreeKey = "categories:tree"
+
+type CategoryCache struct {
+	client redis.UniversalClient
+}
+
+func NewCategoryCache(client redis.UniversalClient) *CategoryCache {
+	return &CategoryCache{
+		client: client,
+	}
+}
+
+func (c *CategoryCache) GetTree(ctx context.Context) ([]models.CategoryNode, error) {
+	data, err := c.client.Get(ctx, categoryTreeKey).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil // Кеш пуст
+		}
+		return nil, err
+	}
+
+	tree := []models.CategoryNode{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func (c *CategoryCache) SetTree(ctx context.Context, tree []models.CategoryNode, expiration time.Duration) error {
+	// Пустое дерево хранится как [], а не null, чтобы GetTree отличал его от промаха
+	if tree == nil {
+		tree = []models.CategoryNode{}
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, categoryTreeKey, data, expiration).Err()
+}
+
+func (c *CategoryCache) InvalidateTree(ctx context.Context) error {
+	return c.client.Del(ctx, categoryTreeKey).Err()
+}
@@ -10,10 +10,10 @@ import (
 )
 
 type ProductCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
-func NewProductCache(client *redis.Client) *ProductCache {
+func NewProductCache(client redis.UniversalClient) *ProductCache {
 	return &ProductCache{
 		client: client,
 	}
@@ -57,10 +57,8 @@ func (c *ProductCache) Delete(ctx context.Context, id int64) error {
 }
 
 func (c *ProductCache) SetAllProducts(ctx context.Context, products []*models.Product, expiration time.Duration) error {
-	for _, product := range products {
-		if err := c.Set(ctx, product, expiration); err != nil {
-			return err
-		}
+	if err := c.SetMany(ctx, products, expiration); err != nil {
+		return err
 	}
 
 	// Обновляем список всех идентификаторов продуктов
@@ -71,3 +69,87 @@ func (c *ProductCache) SetAllProducts(ctx context.Context, products []*models.Pr
 
 	return c.client.Set(ctx, "products:all", productIDs, expiration).Err()
 }
+
+// SetMany записывает записи одним конвейером (pipeline) вместо отдельного запроса на каждую.
+// В режиме cluster команды конвейера распределяются по узлам клиентом.
+func (c *ProductCache) SetMany(ctx context.Context, products []*models.Product, expiration time.Duration) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, product := range products {
+			data, err := json.Marshal(product)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, c.getProductKey(product.ID), data, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+// DeleteMany удаляет записи одним конвейером; ключи удаляются по одному, чтобы не получить CROSSSLOT в cluster
+func (c *ProductCache) DeleteMany(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.Del(ctx, c.getProductKey(id))
+		}
+		return nil
+	})
+	return err
+}
+
+// searchGenerationKey - номер поколения результатов поиска. Он входит в ключи страниц,
+// поэтому InvalidateSearch достаточно увеличить его: старые страницы больше не читаются и истекают по TTL.
+// Удаление по шаблону (SCAN + DEL) обходило бы все узлы кластера.
+const searchGenerationKey = "products:search:generation"
+
+func (c *ProductCache) getSearchKey(ctx context.Context, key string) (string, error) {
+	generation, err := c.client.Get(ctx, searchGenerationKey).Int64()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+	return fmt.Sprintf("products:search:%d:%s", generation, key), nil
+}
+
+func (c *ProductCache) GetSearch(ctx context.Context, key string) (*models.ProductSearchResult, error) {
+	searchKey, err := c.getSearchKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.client.Get(ctx, searchKey).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil // Кеш пуст
+		}
+		return nil, err
+	}
+
+	var result models.ProductSearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ProductCache) SetSearch(ctx context.Context, key string, result *models.ProductSearchResult, expiration time.Duration) error {
+	searchKey, err := c.getSearchKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, searchKey, data, expiration).Err()
+}
+
+func (c *ProductCache) InvalidateSearch(ctx context.Context) error {
+	return c.client.Incr(ctx, searchGenerationKey).Err()
+}
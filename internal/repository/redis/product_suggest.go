@@ -0,0 +1,203 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/search"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+)
+
+// Индекс подсказок: на каждый префикс названия (search.Prefixes) - sorted set идентификаторов товаров
+// с продажами в качестве веса, поэтому подсказки читаются одним ZREVRANGE. Названия и веса хранятся
+// в хеше suggestEntriesKey: по ним находятся префиксы, которые нужно очистить при переименовании и удалении.
+const (
+	suggestPrefixKey  = "products:suggest:prefix:"
+	suggestEntriesKey = "products:suggest:entries"
+	// suggestBatchSize - сколько товаров записывается одним конвейером при перестроении индекса
+	suggestBatchSize = 500
+)
+
+func (c *ProductCache) getSuggestKey(prefix string) string {
+	return suggestPrefixKey + prefix
+}
+
+// getSuggestEntries читает сохраненные подсказки; отсутствующих в индексе товаров в результате нет
+func (c *ProductCache) getSuggestEntries(ctx context.Context, ids []int64) (map[int64]models.ProductSuggestion, error) {
+	entries := make(map[int64]models.ProductSuggestion, len(ids))
+	if len(ids) == 0 {
+		return entries, nil
+	}
+
+	fields := make([]string, len(ids))
+	for i, id := range ids {
+		fields[i] = strconv.FormatInt(id, 10)
+	}
+	values, err := c.client.HMGet(ctx, suggestEntriesKey, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // Товара нет в индексе
+		}
+		var entry models.ProductSuggestion
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, err
+		}
+		entries[ids[i]] = entry
+	}
+	return entries, nil
+}
+
+// indexSuggestion добавляет в конвейер команды, заменяющие подсказку old (nil - ее не было) на entry
+func (c *ProductCache) indexSuggestion(ctx context.Context, pipe redis.Pipeliner, old *models.ProductSuggestion, entry models.ProductSuggestion) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	member := strconv.FormatInt(entry.ID, 10)
+	if old != nil && old.Name != entry.Name {
+		c.removeSuggestion(ctx, pipe, *old)
+	}
+	for _, prefix := range search.Prefixes(entry.Name) {
+		pipe.ZAdd(ctx, c.getSuggestKey(prefix), &redis.Z{Score: float64(entry.Sales), Member: member})
+	}
+	pipe.HSet(ctx, suggestEntriesKey, member, data)
+	return nil
+}
+
+// removeSuggestion добавляет в конвейер команды удаления подсказки из всех множеств ее префиксов
+func (c *ProductCache) removeSuggestion(ctx context.Context, pipe redis.Pipeliner, entry models.ProductSuggestion) {
+	member := strconv.FormatInt(entry.ID, 10)
+	for _, prefix := range search.Prefixes(entry.Name) {
+		pipe.ZRem(ctx, c.getSuggestKey(prefix), member)
+	}
+}
+
+func (c *ProductCache) IndexSuggestions(ctx context.Context, products []*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	old, err := c.getSuggestEntries(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, product := range products {
+			entry := models.ProductSuggestion{ID: product.ID, Name: product.Name}
+			var previous *models.ProductSuggestion
+			if stored, ok := old[product.ID]; ok {
+				// Вес товара пересчитывает только RebuildSuggestions
+				previous, entry.Sales = &stored, stored.Sales
+			}
+			if err := c.indexSuggestion(ctx, pipe, previous, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *ProductCache) RemoveSuggestions(ctx context.Context, ids []int64) error {
+	old, err := c.getSuggestEntries(ctx, ids)
+	if err != nil || len(old) == 0 {
+		return err
+	}
+
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for id, entry := range old {
+			c.removeSuggestion(ctx, pipe, entry)
+			pipe.HDel(ctx, suggestEntriesKey, strconv.FormatInt(id, 10))
+		}
+		return nil
+	})
+	return err
+}
+
+// RebuildSuggestions приводит индекс к переданному набору подсказок на месте, пачками по suggestBatchSize:
+// подсказки остаются доступны во время перестроения, веса и названия обновляются, лишние товары удаляются
+func (c *ProductCache) RebuildSuggestions(ctx context.Context, suggestions []models.ProductSuggestion) error {
+	stored, err := c.client.HGetAll(ctx, suggestEntriesKey).Result()
+	if err != nil {
+		return err
+	}
+	old := make(map[int64]models.ProductSuggestion, len(stored))
+	for _, data := range stored {
+		var entry models.ProductSuggestion
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return err
+		}
+		old[entry.ID] = entry
+	}
+
+	var removed []int64
+	current := make(map[int64]bool, len(suggestions))
+	for _, suggestion := range suggestions {
+		current[suggestion.ID] = true
+	}
+	for id := range old {
+		if !current[id] {
+			removed = append(removed, id)
+		}
+	}
+	if err := c.RemoveSuggestions(ctx, removed); err != nil {
+		return err
+	}
+
+	for from := 0; from < len(suggestions); from += suggestBatchSize {
+		batch := suggestions[from:min(from+suggestBatchSize, len(suggestions))]
+		_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, entry := range batch {
+				var previous *models.ProductSuggestion
+				if stored, ok := old[entry.ID]; ok {
+					previous = &stored
+				}
+				if err := c.indexSuggestion(ctx, pipe, previous, entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ProductCache) Suggest(ctx context.Context, prefix string, limit int) ([]models.ProductSuggestion, error) {
+	members, err := c.client.ZRevRange(ctx, c.getSuggestKey(prefix), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(members))
+	for i, member := range members {
+		if ids[i], err = strconv.ParseInt(member, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := c.getSuggestEntries(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]models.ProductSuggestion, 0, len(ids))
+	for _, id := range ids {
+		// Товар мог быть удален между ZREVRANGE и HMGET
+		if entry, ok := entries[id]; ok {
+			suggestions = append(suggestions, entry)
+		}
+	}
+	return suggestions, nil
+}
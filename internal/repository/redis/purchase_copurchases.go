@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+)
+
+// Совместные покупки: на каждый товар - sorted set товаров, купленных вместе с ним, с числом
+// покупателей в качестве веса, и множество товаров, для которых такой набор есть.
+// Хеш-тег {also} держит ключи в одном слоте Redis Cluster, чтобы пачка заменялась транзакцией.
+const (
+	coPurchaseKeyPrefix = "products:{also}:"
+	coPurchaseIDsKey    = coPurchaseKeyPrefix + "ids"
+	coPurchaseBatchSize = 100
+)
+
+func (c *PurchaseCache) getCoPurchasesKey(productID int64) string {
+	return coPurchaseKeyPrefix + strconv.FormatInt(productID, 10)
+}
+
+// RebuildCoPurchases заменяет наборы пачками: каждый набор меняется транзакцией и не бывает пустым
+// посередине перестроения; наборы товаров, которых нет в coPurchases, удаляются в конце
+func (c *PurchaseCache) RebuildCoPurchases(ctx context.Context, coPurchases map[int64][]models.CoPurchase) error {
+	stored, err := c.client.SMembers(ctx, coPurchaseIDsKey).Result()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0, len(coPurchases))
+	for id, list := range coPurchases {
+		if len(list) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	for from := 0; from < len(ids); from += coPurchaseBatchSize {
+		batch := ids[from:min(from+coPurchaseBatchSize, len(ids))]
+		_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, id := range batch {
+				key := c.getCoPurchasesKey(id)
+				members := make([]*redis.Z, len(coPurchases[id]))
+				for i, co := range coPurchases[id] {
+					members[i] = &redis.Z{Score: float64(co.Count), Member: strconv.FormatInt(co.ProductID, 10)}
+				}
+				pipe.Del(ctx, key)
+				pipe.ZAdd(ctx, key, members...)
+				pipe.SAdd(ctx, coPurchaseIDsKey, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var removed []string
+	for _, member := range stored {
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return err
+		}
+		if len(coPurchases[id]) == 0 {
+			removed = append(removed, member)
+		}
+	}
+	for from := 0; from < len(removed); from += coPurchaseBatchSize {
+		batch := removed[from:min(from+coPurchaseBatchSize, len(removed))]
+		_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, member := range batch {
+				pipe.Del(ctx, coPurchaseKeyPrefix+member)
+				pipe.SRem(ctx, coPurchaseIDsKey, member)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *PurchaseCache) GetCoPurchases(ctx context.Context, productIDs []int64) (map[int64][]models.CoPurchase, error) {
+	result := make(map[int64][]models.CoPurchase, len(productIDs))
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+
+	cmds := make([]*redis.ZSliceCmd, len(productIDs))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range productIDs {
+			cmds[i] = pipe.ZRevRangeWithScores(ctx, c.getCoPurchasesKey(id), 0, -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range productIDs {
+		members := cmds[i].Val()
+		if len(members) == 0 {
+			continue
+		}
+		list := make([]models.CoPurchase, len(members))
+		for j, z := range members {
+			productID, err := strconv.ParseInt(z.Member.(string), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			list[j] = models.CoPurchase{ProductID: productID, Count: int64(z.Score)}
+		}
+		result[id] = list
+	}
+	return result, nil
+}
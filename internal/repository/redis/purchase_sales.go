@@ -0,0 +1,129 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// Дневные продажи для рейтинга популярных товаров: на каждый день и критерий (units, revenue) -
+// sorted set идентификаторов товаров с продажами в качестве веса. Ключ дня живет MaxPopularDays дней
+// после него, поэтому скользящее окно складывается ZUNIONSTORE из ключей своих дней.
+// Хеш-тег {sales} держит все ключи в одном слоте Redis Cluster: ZUNIONSTORE требует этого.
+const (
+	salesKeyPrefix = "products:{sales}:"
+	salesDayLayout = "2006-01-02"
+	// salesTopTTL - сколько живет объединение окна; оно пересчитывается при каждом запросе рейтинга
+	salesTopTTL = time.Minute
+)
+
+func (c *PurchaseCache) getSalesKey(by string, day time.Time) string {
+	return salesKeyPrefix + by + ":" + day.Format(salesDayLayout)
+}
+
+func (c *PurchaseCache) getSalesTopKey(by string, until time.Time, days int) string {
+	return fmt.Sprintf("%stop:%s:%dd:%s", salesKeyPrefix, by, days, until.Format(salesDayLayout))
+}
+
+// salesExpireAt - когда ключ дня выходит за самое длинное окно рейтинга
+func salesExpireAt(day time.Time) time.Time {
+	return day.AddDate(0, 0, models.MaxPopularDays+1)
+}
+
+// addSales добавляет в конвейер команды, прибавляющие продажи товара к дню
+func (c *PurchaseCache) addSales(ctx context.Context, pipe redis.Pipeliner, day time.Time, sales models.ProductSales) {
+	member := strconv.FormatInt(sales.ProductID, 10)
+	for by, score := range map[string]float64{models.PopularByUnits: float64(sales.Units), models.PopularByRevenue: sales.Revenue} {
+		key := c.getSalesKey(by, day)
+		pipe.ZIncrBy(ctx, key, score, member)
+		pipe.ExpireAt(ctx, key, salesExpireAt(day))
+	}
+}
+
+func (c *PurchaseCache) AddSales(ctx context.Context, day time.Time, sales models.ProductSales) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		c.addSales(ctx, pipe, models.SalesDay(day), sales)
+		return nil
+	})
+	return err
+}
+
+// RebuildSales заменяет дни окна одной транзакцией: рейтинг не видит наполовину записанных дней
+func (c *PurchaseCache) RebuildSales(ctx context.Context, sales []models.DailySales) error {
+	today := models.SalesDay(time.Now())
+	oldest := today.AddDate(0, 0, -models.MaxPopularDays)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for day := oldest; !day.After(today); day = day.AddDate(0, 0, 1) {
+			pipe.Del(ctx, c.getSalesKey(models.PopularByUnits, day), c.getSalesKey(models.PopularByRevenue, day))
+		}
+		for _, daily := range sales {
+			day := models.SalesDay(daily.Day)
+			if day.Before(oldest) {
+				continue
+			}
+			c.addSales(ctx, pipe, day, daily.ProductSales)
+		}
+		return nil
+	})
+	return err
+}
+
+// TopSales складывает days дней до until включительно и возвращает до limit товаров по убыванию критерия by
+func (c *PurchaseCache) TopSales(ctx context.Context, until time.Time, days int, by string, limit int) ([]models.ProductSales, error) {
+	until = models.SalesDay(until)
+	other := models.PopularByRevenue
+	if by == models.PopularByRevenue {
+		other = models.PopularByUnits
+	}
+
+	var ranked *redis.ZSliceCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, measure := range []string{by, other} {
+			keys := make([]string, days)
+			for i := range keys {
+				keys[i] = c.getSalesKey(measure, until.AddDate(0, 0, -i))
+			}
+			dest := c.getSalesTopKey(measure, until, days)
+			pipe.ZUnionStore(ctx, dest, &redis.ZStore{Keys: keys})
+			pipe.Expire(ctx, dest, salesTopTTL)
+		}
+		ranked = pipe.ZRevRangeByScoreWithScores(ctx, c.getSalesTopKey(by, until, days), &redis.ZRangeBy{
+			Min: "(0", Max: "+inf", Count: int64(limit),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	top := ranked.Val()
+	scores := make([]*redis.FloatCmd, len(top))
+	if len(top) > 0 {
+		_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, z := range top {
+				scores[i] = pipe.ZScore(ctx, c.getSalesTopKey(other, until, days), z.Member.(string))
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+	}
+
+	sales := make([]models.ProductSales, len(top))
+	for i, z := range top {
+		id, err := strconv.ParseInt(z.Member.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		otherScore := scores[i].Val() // redis.Nil - продаж по второму критерию нет
+		sales[i] = models.ProductSales{ProductID: id, Units: int64(z.Score), Revenue: otherScore}
+		if by == models.PopularByRevenue {
+			sales[i].Units, sales[i].Revenue = int64(otherScore), z.Score
+		}
+	}
+	return sales, nil
+}
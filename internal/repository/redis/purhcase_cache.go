@@ -10,10 +10,10 @@ import (
 )
 
 type PurchaseCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
-func NewPurchaseCache(client *redis.Client) *PurchaseCache {
+func NewPurchaseCache(client redis.UniversalClient) *PurchaseCache {
 	return &PurchaseCache{
 		client: client,
 	}
@@ -27,6 +27,10 @@ func (c *PurchaseCache) getUserPurchasesKey(userID int64) string {
 	return fmt.Sprintf("user:%d:purchases", userID)
 }
 
+func (c *PurchaseCache) getReportKey(key string) string {
+	return "reports:sales:" + key
+}
+
 func (c *PurchaseCache) GetByID(ctx context.Context, id int64) (*models.Purchase, error) {
 	key := c.getPurchaseKey(id)
 	data, err := c.client.Get(ctx, key).Bytes()
@@ -87,3 +91,27 @@ func (c *PurchaseCache) GetUserPurchases(ctx context.Context, userID int64) ([]*
 
 	return purchases, nil
 }
+
+func (c *PurchaseCache) GetReport(ctx context.Context, key string) (*models.SalesReport, error) {
+	data, err := c.client.Get(ctx, c.getReportKey(key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil // Кеш пуст
+		}
+		return nil, err
+	}
+
+	var report models.SalesReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (c *PurchaseCache) SetReport(ctx context.Context, key string, report *models.SalesReport, expiration time.Duration) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, c.getReportKey(key), data, expiration).Err()
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/contract"
+	"github.com/go-redis/redis/v8"
+	"os"
+	"testing"
+)
+
+// Тесты выполняются против реального Redis, например:
+// APP_TEST_REDIS_ADDR="localhost:63792" go test ./...
+func TestCachesContract(t *testing.T) {
+	addr := os.Getenv("APP_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("APP_TEST_REDIS_ADDR не задан")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+
+	contract.RunCaches(t, func(t *testing.T) contract.Caches {
+		purchases := NewPurchaseCache(client)
+		return contract.Caches{
+			Users:       NewUserCache(client),
+			Products:    NewProductCache(client),
+			Purchases:   purchases,
+			Categories:  NewCategoryCache(client),
+			CoPurchases: purchases,
+			Reports:     purchases,
+		}
+	})
+}
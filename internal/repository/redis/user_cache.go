@@ -10,10 +10,10 @@ import (
 )
 
 type UserCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
-func NewUserCache(client *redis.Client) *UserCache {
+func NewUserCache(client redis.UniversalClient) *UserCache {
 	return &UserCache{
 		client: client,
 	}
@@ -57,10 +57,8 @@ func (c *UserCache) Delete(ctx context.Context, id int64) error {
 }
 
 func (c *UserCache) SetAllUsers(ctx context.Context, users []*models.User, expiration time.Duration) error {
-	for _, user := range users {
-		if err := c.Set(ctx, user, expiration); err != nil {
-			return err
-		}
+	if err := c.SetMany(ctx, users, expiration); err != nil {
+		return err
 	}
 
 	// Обновляем список всех идентификаторов пользователей
@@ -71,3 +69,38 @@ func (c *UserCache) SetAllUsers(ctx context.Context, users []*models.User, expir
 
 	return c.client.Set(ctx, "users:all", userIDs, expiration).Err()
 }
+
+// SetMany записывает записи одним конвейером (pipeline) вместо отдельного запроса на каждую.
+// В режиме cluster команды конвейера распределяются по узлам клиентом.
+func (c *UserCache) SetMany(ctx context.Context, users []*models.User, expiration time.Duration) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, user := range users {
+			data, err := json.Marshal(user)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, c.getUserKey(user.ID), data, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+// DeleteMany удаляет записи одним конвейером; ключи удаляются по одному, чтобы не получить CROSSSLOT в cluster
+func (c *UserCache) DeleteMany(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.Del(ctx, c.getUserKey(id))
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqltx"
+	"github.com/jmoiron/sqlx"
+)
+
+// Querier - общий набор методов *sqlx.DB и *sqlx.Tx, которым пользуются репозитории
+type Querier interface {
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+}
+
+// DB возвращает репозиториям текущую транзакцию из контекста или пул соединений
+type DB struct {
+	db *sqlx.DB
+}
+
+func NewDB(db *sqlx.DB) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) Conn(ctx context.Context) Querier {
+	if tx := sqltx.Tx(ctx); tx != nil {
+		return tx
+	}
+	return d.db
+}
+
+//go:embed schema.sql
+var schema string
+
+// addedColumns - столбцы, появившиеся в schema.sql позже; в базу, созданную раньше, они добавляются через ALTER TABLE
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"users", "version", "INTEGER NOT NULL DEFAULT 1"},
+	{"products", "version", "INTEGER NOT NULL DEFAULT 1"},
+	{"purchases", "version", "INTEGER NOT NULL DEFAULT 1"},
+	{"purchases", "variant_id", "INTEGER REFERENCES product_variants (id) ON DELETE SET NULL"},
+	{"purchases", "discounts", "TEXT NOT NULL DEFAULT '[]'"},
+}
+
+// Migrate создает таблицы, если их еще нет, и добавляет недостающие столбцы.
+// Полнотекстовый индекс и история цен, появившиеся в уже заполненной базе, заполняются по существующим товарам.
+func Migrate(ctx context.Context, db *sqlx.DB) error {
+	var hasFTS, hasPrices int
+	if err := db.GetContext(ctx, &hasFTS, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'products_fts'"); err != nil {
+		return err
+	}
+	if err := db.GetContext(ctx, &hasPrices, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'product_prices'"); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	if hasFTS == 0 {
+		if _, err := db.ExecContext(ctx, "INSERT INTO products_fts (products_fts) VALUES ('rebuild')"); err != nil {
+			return err
+		}
+	}
+	// История цен начинается с текущей цены товаров, созданных до ее появления
+	if hasPrices == 0 {
+		if _, err := db.ExecContext(ctx, "INSERT INTO product_prices (product_id, price, changed_at) SELECT id, price, created_at FROM products"); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range addedColumns {
+		var count int
+		if err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.table, c.column); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE "+c.table+" ADD COLUMN "+c.column+" "+c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withVersion добавляет к запросу условие на версию записи, если она задана (0 - без проверки).
+// Запрос должен заканчиваться условием WHERE.
+func withVersion(query string, args []interface{}, version int64) (string, []interface{}) {
+	if version == 0 {
+		return query, args
+	}
+	return query + " AND version = ?", append(args, version)
+}
+
+// checkVersion превращает UPDATE или DELETE с проверкой версии, не затронувший ни одной строки,
+// в ErrVersionConflict: запись изменилась или удалена после того, как клиент прочитал версию
+func checkVersion(result sql.Result, version int64) error {
+	if version == 0 {
+		return nil
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrVersionConflict
+	}
+	return nil
+}
+
+// consecutiveIDs возвращает n идентификаторов подряд, начиная с first
+func consecutiveIDs(first int64, n int) []int64 {
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = first + int64(i)
+	}
+	return ids
+}
+
+// each выполняет запрос и передает строки в fn по одной, не загружая результат в память целиком.
+// Ошибка fn прекращает чтение и возвращается как есть.
+func each[T any](ctx context.Context, q Querier, query string, fn func(*T) error, args ...interface{}) error {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := new(T)
+		if err := rows.StructScan(item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
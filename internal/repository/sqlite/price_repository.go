@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+type PriceRepository struct {
+	db *DB
+}
+
+func NewPriceRepository(db *DB) *PriceRepository {
+	return &PriceRepository{
+		db: db,
+	}
+}
+
+// GetHistory возвращает изменения цены товара от новых к старым; ненулевой until
+// отсекает изменения позже него, и тогда первая запись - цена, действовавшая в until
+func (r *PriceRepository) GetHistory(ctx context.Context, productID int64, until time.Time) ([]*models.PriceChange, error) {
+	query := "SELECT * FROM product_prices WHERE product_id = ?"
+	args := []interface{}{productID}
+	if !until.IsZero() {
+		query += " AND changed_at <= ?"
+		args = append(args, until.UTC().Format(timestampLayout))
+	}
+	history := []*models.PriceChange{}
+	err := r.db.Conn(ctx).SelectContext(ctx, &history, query+" ORDER BY changed_at DESC, id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *PriceRepository) CreateScheduled(ctx context.Context, scheduled *models.ScheduledPrice) (int64, error) {
+	query := `INSERT INTO scheduled_prices (product_id, price, effective_at, created_at)
+			  VALUES (?, ?, ?, CURRENT_TIMESTAMP)`
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query,
+		scheduled.ProductID, scheduled.Price, scheduled.EffectiveAt.UTC().Format(timestampLayout))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *PriceRepository) GetScheduledByID(ctx context.Context, id int64) (*models.ScheduledPrice, error) {
+	scheduled := &models.ScheduledPrice{}
+	query := "SELECT * FROM scheduled_prices WHERE id = ?"
+	err := r.db.Conn(ctx).GetContext(ctx, scheduled, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Запланированная цена не найдена
+		}
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// GetScheduled возвращает непримененные цены товара по времени вступления в силу
+func (r *PriceRepository) GetScheduled(ctx context.Context, productID int64) ([]*models.ScheduledPrice, error) {
+	scheduled := []*models.ScheduledPrice{}
+	query := "SELECT * FROM scheduled_prices WHERE product_id = ? AND applied_at IS NULL ORDER BY effective_at, id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &scheduled, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// DueScheduled возвращает непримененные цены всех товаров с effective_at не позже now
+func (r *PriceRepository) DueScheduled(ctx context.Context, now time.Time) ([]*models.ScheduledPrice, error) {
+	scheduled := []*models.ScheduledPrice{}
+	query := "SELECT * FROM scheduled_prices WHERE applied_at IS NULL AND effective_at <= ? ORDER BY effective_at, id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &scheduled, query, now.UTC().Format(timestampLayout))
+	if err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// NextScheduled возвращает ближайшее время вступления в силу непримененной цены; nil - таких нет
+func (r *PriceRepository) NextScheduled(ctx context.Context) (*time.Time, error) {
+	var next time.Time
+	query := "SELECT effective_at FROM scheduled_prices WHERE applied_at IS NULL ORDER BY effective_at LIMIT 1"
+	err := r.db.Conn(ctx).GetContext(ctx, &next, query)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &next, nil
+}
+
+// DeleteScheduled удаляет запланированную цену, если она еще не применена
+func (r *PriceRepository) DeleteScheduled(ctx context.Context, id int64) error {
+	query := "DELETE FROM scheduled_prices WHERE id = ? AND applied_at IS NULL"
+	_, err := r.db.Conn(ctx).ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkApplied отмечает цену примененной в at; false - она уже применена или удалена
+// (ее забрал другой экземпляр приложения)
+func (r *PriceRepository) MarkApplied(ctx context.Context, id int64, at time.Time) (bool, error) {
+	query := "UPDATE scheduled_prices SET applied_at = ? WHERE id = ? AND applied_at IS NULL"
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, at.UTC().Format(timestampLayout), id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
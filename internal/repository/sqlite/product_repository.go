@@ -0,0 +1,313 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"strconv"
+	"strings"
+)
+
+type ProductRepository struct {
+	db *DB
+}
+
+func NewProductRepository(db *DB) *ProductRepository {
+	return &ProductRepository{
+		db: db,
+	}
+}
+
+func (r *ProductRepository) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	product := &models.Product{}
+	query := "SELECT * FROM products WHERE id = ?"
+	err := r.db.Conn(ctx).GetContext(ctx, product, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Продукт не найден
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *ProductRepository) GetAll(ctx context.Context) ([]*models.Product, error) {
+	products := []*models.Product{}
+	query := "SELECT * FROM products ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &products, query)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *ProductRepository) Create(ctx context.Context, product *models.Product) (int64, error) {
+	var id int64
+	query := "INSERT INTO products (name, description, price, quantity, created_at, updated_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id"
+	err := r.db.Conn(ctx).GetContext(ctx, &id, query, product.Name, product.Description, product.Price, product.Quantity)
+	return id, err
+}
+
+// CreateBatch создает записи одним многострочным INSERT и возвращает их идентификаторы в порядке products.
+// Запись в SQLite идет под единственной блокировкой, поэтому AUTOINCREMENT выдает идентификаторы подряд,
+// заканчивая LastInsertId. Порядок строк RETURNING SQLite не гарантирует, поэтому он не используется.
+func (r *ProductRepository) CreateBatch(ctx context.Context, products []*models.Product) ([]int64, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	values := make([]string, len(products))
+	args := make([]interface{}, 0, len(products)*4)
+	for i, product := range products {
+		values[i] = "(?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)"
+		args = append(args, product.Name, product.Description, product.Price, product.Quantity)
+	}
+
+	query := "INSERT INTO products (name, description, price, quantity, created_at, updated_at) VALUES " + strings.Join(values, ", ")
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	last, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return consecutiveIDs(last-int64(len(products))+1, len(products)), nil
+}
+
+// Update перезаписывает товар и увеличивает версию.
+// Если product.Version задана, запись обновляется только при совпадении версии, иначе - ErrVersionConflict.
+func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
+	query, args := withVersion("UPDATE products SET name = ?, description = ?, price = ?, quantity = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		[]interface{}{product.Name, product.Description, product.Price, product.Quantity, product.ID}, product.Version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, product.Version)
+}
+
+// Patch обновляет только переданные столбцы и увеличивает версию; patch.Version проверяется как в Update
+func (r *ProductRepository) Patch(ctx context.Context, id int64, patch *models.ProductPatch) error {
+	var set []string
+	var args []interface{}
+	if patch.Name != nil {
+		set = append(set, "name = ?")
+		args = append(args, *patch.Name)
+	}
+	if patch.Description != nil {
+		set = append(set, "description = ?")
+		args = append(args, *patch.Description)
+	}
+	if patch.Price != nil {
+		set = append(set, "price = ?")
+		args = append(args, *patch.Price)
+	}
+	if patch.Quantity != nil {
+		set = append(set, "quantity = ?")
+		args = append(args, *patch.Quantity)
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	query, args := withVersion("UPDATE products SET "+strings.Join(set, ", ")+", version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		append(args, id), patch.Version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, patch.Version)
+}
+
+// DecreaseQuantity атомарно списывает товар со склада, если его достаточно
+func (r *ProductRepository) DecreaseQuantity(ctx context.Context, id int64, quantity int) error {
+	query := "UPDATE products SET quantity = quantity - ?, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND quantity >= ?"
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, quantity, id, quantity)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrInsufficientStock
+	}
+	return nil
+}
+
+// Delete удаляет товар; если version задана, только при совпадении версии
+func (r *ProductRepository) Delete(ctx context.Context, id int64, version int64) error {
+	query, args := withVersion("DELETE FROM products WHERE id = ?", []interface{}{id}, version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
+}
+
+// ForEach передает в fn все товары в порядке id, читая их из курсора по одной строке
+func (r *ProductRepository) ForEach(ctx context.Context, fn func(product *models.Product) error) error {
+	return each(ctx, r.db.Conn(ctx), "SELECT * FROM products ORDER BY id", fn)
+}
+
+// GetByName возвращает товары с указанным названием; название не уникально, поэтому их может быть несколько
+func (r *ProductRepository) GetByName(ctx context.Context, name string) ([]*models.Product, error) {
+	products := []*models.Product{}
+	query := "SELECT * FROM products WHERE name = ? ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &products, query, name)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// GetByFilter возвращает товары категорий и тега фильтра в порядке id
+func (r *ProductRepository) GetByFilter(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error) {
+	products := []*models.Product{}
+	scope, args := productScope(filter)
+	query := "SELECT p.* FROM products p WHERE " + scope + " ORDER BY p.id"
+	if err := r.db.Conn(ctx).SelectContext(ctx, &products, query, args...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// SalesCounts возвращает, сколько единиц каждого товара продано (покупки, кроме отмененных);
+// товары без продаж в результат не попадают
+func (r *ProductRepository) SalesCounts(ctx context.Context) (map[int64]int64, error) {
+	rows := []struct {
+		ProductID int64 `db:"product_id"`
+		Sales     int64 `db:"sales"`
+	}{}
+	query := "SELECT product_id, SUM(quantity) AS sales FROM purchases WHERE status <> 'cancelled' GROUP BY product_id"
+	if err := r.db.Conn(ctx).SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	sales := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		sales[row.ProductID] = row.Sales
+	}
+	return sales, nil
+}
+
+// Search ищет товары по полнотекстовому индексу products_fts: каждый терм - префикс слова,
+// релевантность - bm25 с двойным весом названия. Всего найденных и фасеты считаются
+// одним агрегатным запросом по тем же совпадениям.
+func (r *ProductRepository) Search(ctx context.Context, query *models.ProductSearchQuery) (*models.ProductSearchResult, error) {
+	from, score := "products p", ""
+	var matchArgs []interface{}
+	if len(query.Terms) > 0 {
+		from = "products p JOIN (SELECT rowid, bm25(products_fts, 2.0, 1.0) AS rank FROM products_fts WHERE products_fts MATCH ?) f ON f.rowid = p.id"
+		// bm25 тем меньше, чем лучше совпадение
+		score = "-f.rank"
+		matchArgs = append(matchArgs, ftsQuery(query.Terms))
+	}
+	scope, scopeArgs := productScope(query.Filter())
+	filter, filterArgs := searchFilter(query)
+
+	products := []*models.Product{}
+	page := "SELECT p.* FROM " + from + " WHERE " + scope + " AND " + filter + " ORDER BY " + searchOrder(query.Sort, score) + " LIMIT ? OFFSET ?"
+	args := append(append(append(append([]interface{}{}, matchArgs...), scopeArgs...), filterArgs...), query.Limit, query.Offset)
+	if err := r.db.Conn(ctx).SelectContext(ctx, &products, page, args...); err != nil {
+		return nil, err
+	}
+
+	counts := make([]int, 2+len(models.PriceBuckets))
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	stats := "SELECT COALESCE(SUM(CASE WHEN " + filter + " THEN 1 ELSE 0 END), 0), COALESCE(SUM(CASE WHEN " + inStockCondition + " THEN 1 ELSE 0 END), 0)" +
+		priceFacetColumns() + " FROM " + from + " WHERE " + scope
+	args = append(append(append([]interface{}{}, filterArgs...), matchArgs...), scopeArgs...)
+	if err := r.db.Conn(ctx).QueryRowxContext(ctx, stats, args...).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	return models.NewProductSearchResult(query, products, counts[0], counts[1], counts[2:]), nil
+}
+
+// ftsQuery строит запрос FTS5: все термы как префиксы слов
+func ftsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + term + `"*`
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// productScope - условие на товары категорий и тега фильтра (плейсхолдеры ?)
+func productScope(filter models.ProductFilter) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	if len(filter.CategoryIDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.CategoryIDs)), ", ")
+		conditions = append(conditions, "p.id IN (SELECT product_id FROM product_categories WHERE category_id IN ("+placeholders+"))")
+		for _, id := range filter.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "p.id IN (SELECT pt.product_id FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.name = ?)")
+		args = append(args, filter.Tag)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// inStockCondition - товар в наличии: у товара с вариантами - хотя бы один вариант с остатком,
+// у товара без вариантов - собственный остаток
+const inStockCondition = "(EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.quantity > 0)" +
+	" OR p.quantity > 0 AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id))"
+
+// searchFilter - условие фильтров поиска по цене и наличию
+func searchFilter(query *models.ProductSearchQuery) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	if query.MinPrice != nil {
+		conditions = append(conditions, "p.price >= ?")
+		args = append(args, *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		conditions = append(conditions, "p.price <= ?")
+		args = append(args, *query.MaxPrice)
+	}
+	if query.InStock {
+		conditions = append(conditions, inStockCondition)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// searchOrder - порядок результатов поиска; score - выражение релевантности, пусто без термов
+func searchOrder(sort, score string) string {
+	switch sort {
+	case models.SortPriceAsc:
+		return "p.price, p.id"
+	case models.SortPriceDesc:
+		return "p.price DESC, p.id"
+	case models.SortNewest:
+		return "p.created_at DESC, p.id DESC"
+	default:
+		// Без термов релевантности нет; константа в ORDER BY читалась бы как номер столбца
+		if score == "" {
+			return "p.id"
+		}
+		return score + " DESC, p.id"
+	}
+}
+
+// priceFacetColumns - столбцы агрегатного запроса с количеством товаров в каждом диапазоне models.PriceBuckets
+func priceFacetColumns() string {
+	var b strings.Builder
+	for i, from := range models.PriceBuckets {
+		condition := "p.price >= " + strconv.FormatFloat(from, 'f', -1, 64)
+		if i+1 < len(models.PriceBuckets) {
+			condition += " AND p.price < " + strconv.FormatFloat(models.PriceBuckets[i+1], 'f', -1, 64)
+		}
+		b.WriteString(", COALESCE(SUM(CASE WHEN " + condition + " THEN 1 ELSE 0 END), 0)")
+	}
+	return b.String()
+}
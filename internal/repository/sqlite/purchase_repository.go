@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+type PurchaseRepository struct {
+	db *DB
+}
+
+func NewPurchaseRepository(db *DB) *PurchaseRepository {
+	return &PurchaseRepository{
+		db: db,
+	}
+}
+
+func (r *PurchaseRepository) GetByID(ctx context.Context, id int64) (*models.Purchase, error) {
+	purchase := &models.Purchase{}
+	query := "SELECT * FROM purchases WHERE id = ?"
+	err := r.db.Conn(ctx).GetContext(ctx, purchase, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Покупка не найдена
+		}
+		return nil, err
+	}
+	return purchase, nil
+}
+
+// GetByIDForUpdate - то же, что GetByID: SQLite не блокирует строки, а пишущие транзакции
+// и так выполняются по одной (конфликт дает SQLITE_BUSY, и TxManager повторяет транзакцию)
+func (r *PurchaseRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.Purchase, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *PurchaseRepository) GetByUserID(ctx context.Context, userID int64) ([]*models.Purchase, error) {
+	purchases := []*models.Purchase{}
+	query := "SELECT * FROM purchases WHERE user_id = ? ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &purchases, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return purchases, nil
+}
+
+// Create сохраняет запись о покупке. Списание товара со склада выполняет сервис
+// в той же транзакции через ProductRepository.DecreaseQuantity.
+func (r *PurchaseRepository) Create(ctx context.Context, purchase *models.Purchase) (int64, error) {
+	var id int64
+	query := `INSERT INTO purchases (user_id, product_id, variant_id, quantity, total_price, status, discounts, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id`
+	err := r.db.Conn(ctx).GetContext(ctx, &id, query,
+		purchase.UserID, purchase.ProductID, purchase.VariantID, purchase.Quantity, purchase.TotalPrice, purchase.Status, purchase.Discounts)
+	return id, err
+}
+
+// UpdateStatus меняет статус покупки и увеличивает версию; если version задана, только при совпадении версии
+func (r *PurchaseRepository) UpdateStatus(ctx context.Context, id int64, status string, version int64) error {
+	query, args := withVersion("UPDATE purchases SET status = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		[]interface{}{status, id}, version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
+}
+
+func (r *PurchaseRepository) GetAll(ctx context.Context) ([]*models.Purchase, error) {
+	purchases := []*models.Purchase{}
+	query := "SELECT * FROM purchases ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &purchases, query)
+	if err != nil {
+		return nil, err
+	}
+	return purchases, nil
+}
+
+// ForEach передает в fn все покупки в порядке id, читая их из курсора по одной строке
+func (r *PurchaseRepository) ForEach(ctx context.Context, fn func(purchase *models.Purchase) error) error {
+	return each(ctx, r.db.Conn(ctx), "SELECT * FROM purchases ORDER BY id", fn)
+}
+
+// timestampLayout - формат CURRENT_TIMESTAMP: время хранится текстом в UTC,
+// поэтому границы выборок по времени сравниваются в том же формате
+const timestampLayout = "2006-01-02 15:04:05"
+
+// ForEachCompleted передает в fn завершенные покупки, измененные начиная с since, в порядке id
+func (r *PurchaseRepository) ForEachCompleted(ctx context.Context, since time.Time, fn func(purchase *models.Purchase) error) error {
+	query := "SELECT * FROM purchases WHERE status = 'completed' AND updated_at >= ? ORDER BY id"
+	return each(ctx, r.db.Conn(ctx), query, fn, since.UTC().Format(timestampLayout))
+}
+
+// ForEachCreated передает в fn покупки, созданные в [from, to), в порядке id
+func (r *PurchaseRepository) ForEachCreated(ctx context.Context, from, to time.Time, fn func(purchase *models.Purchase) error) error {
+	query := "SELECT * FROM purchases WHERE created_at >= ? AND created_at < ? ORDER BY id"
+	return each(ctx, r.db.Conn(ctx), query, fn, from.UTC().Format(timestampLayout), to.UTC().Format(timestampLayout))
+}
+
+// LockCreated ничего не делает: пишущие транзакции SQLite и так выполняются по одной
+// (конфликт дает SQLITE_BUSY, и TxManager повторяет транзакцию)
+func (r *PurchaseRepository) LockCreated(ctx context.Context, from, to time.Time) error {
+	return nil
+}
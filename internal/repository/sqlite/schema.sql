@@ -0,0 +1,232 @@
+-- Схема для SQLite, эквивалентная demo-data-sql.sql (MySQL); применяется при старте
+
+CREATE TABLE IF NOT EXISTS users
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    username   VARCHAR(50)  NOT NULL UNIQUE,
+    email      VARCHAR(100) NOT NULL UNIQUE,
+    version    INTEGER      NOT NULL DEFAULT 1,
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS products
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        VARCHAR(255)   NOT NULL,
+    description TEXT           NOT NULL DEFAULT '',
+    price       DECIMAL(10, 2) NOT NULL,
+    quantity    INTEGER        NOT NULL DEFAULT 0,
+    version     INTEGER        NOT NULL DEFAULT 1,
+    created_at  DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_name ON products (name);
+CREATE INDEX IF NOT EXISTS idx_price ON products (price);
+
+-- Полнотекстовый индекс товаров для поиска (FTS5). Текст хранится только в products,
+-- индекс синхронизируется триггерами; unicode61 приводит к нижнему регистру и кириллицу
+CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5
+(
+    name,
+    description,
+    content = 'products',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS products_fts_insert AFTER INSERT ON products
+BEGIN
+    INSERT INTO products_fts (rowid, name, description) VALUES (new.id, new.name, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS products_fts_delete AFTER DELETE ON products
+BEGIN
+    INSERT INTO products_fts (products_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS products_fts_update AFTER UPDATE OF name, description ON products
+BEGIN
+    INSERT INTO products_fts (products_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+    INSERT INTO products_fts (rowid, name, description) VALUES (new.id, new.name, new.description);
+END;
+
+-- История цен: триггеры пишут цену нового товара и каждое изменение цены;
+-- для базы, созданной раньше, Migrate записывает текущие цены с датой создания товара
+CREATE TABLE IF NOT EXISTS product_prices
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER        NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price      DECIMAL(10, 2) NOT NULL,
+    changed_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_prices_product_id_changed_at ON product_prices (product_id, changed_at);
+
+CREATE TRIGGER IF NOT EXISTS products_price_insert AFTER INSERT ON products
+BEGIN
+    INSERT INTO product_prices (product_id, price) VALUES (new.id, new.price);
+END;
+
+CREATE TRIGGER IF NOT EXISTS products_price_update AFTER UPDATE OF price ON products
+    WHEN new.price <> old.price
+BEGIN
+    INSERT INTO product_prices (product_id, price) VALUES (new.id, new.price);
+END;
+
+-- Запланированные цены: фоновая задача приложения применяет их в effective_at и проставляет applied_at
+CREATE TABLE IF NOT EXISTS scheduled_prices
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id   INTEGER        NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price        DECIMAL(10, 2) NOT NULL,
+    effective_at DATETIME       NOT NULL,
+    created_at   DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    applied_at   DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_product_id ON scheduled_prices (product_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_pending ON scheduled_prices (applied_at, effective_at);
+
+-- Варианты товара со своим артикулом, ценой и остатком; attributes - JSON-объект
+CREATE TABLE IF NOT EXISTS product_variants
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER        NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    sku        VARCHAR(64)    NOT NULL UNIQUE,
+    attributes TEXT           NOT NULL DEFAULT '{}',
+    price      DECIMAL(10, 2) NOT NULL,
+    quantity   INTEGER        NOT NULL DEFAULT 0,
+    version    INTEGER        NOT NULL DEFAULT 1,
+    created_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id ON product_variants (product_id);
+
+CREATE TABLE IF NOT EXISTS purchases
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id     INTEGER        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    product_id  INTEGER        NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    variant_id  INTEGER REFERENCES product_variants (id) ON DELETE SET NULL,
+    quantity    INTEGER        NOT NULL,
+    total_price DECIMAL(10, 2) NOT NULL,
+    status      VARCHAR(20)    NOT NULL,
+    version     INTEGER        NOT NULL DEFAULT 1,
+    created_at  DATETIME       NOT NULL,
+    updated_at  DATETIME       NOT NULL,
+    discounts   TEXT           NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchases_user_id ON purchases (user_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_product_id ON purchases (product_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_status ON purchases (status);
+-- Завершенные покупки за окно рейтинга популярных товаров
+CREATE INDEX IF NOT EXISTS idx_purchases_status_updated_at ON purchases (status, updated_at);
+-- Отчеты о продажах выбирают покупки по дате создания
+CREATE INDEX IF NOT EXISTS idx_purchases_created_at ON purchases (created_at);
+
+-- Дневные агрегаты покупок по товарам и пользователям для отчетов о продажах. День - дата создания
+-- покупки (UTC, текст YYYY-MM-DD); после обновления базы заполняются командой rollup-backfill
+CREATE TABLE IF NOT EXISTS sales_daily_products
+(
+    day        DATE           NOT NULL,
+    product_id INTEGER        NOT NULL,
+    orders     INTEGER        NOT NULL DEFAULT 0,
+    completed  INTEGER        NOT NULL DEFAULT 0,
+    cancelled  INTEGER        NOT NULL DEFAULT 0,
+    units      INTEGER        NOT NULL DEFAULT 0,
+    revenue    DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS sales_daily_users
+(
+    day       DATE           NOT NULL,
+    user_id   INTEGER        NOT NULL,
+    orders    INTEGER        NOT NULL DEFAULT 0,
+    completed INTEGER        NOT NULL DEFAULT 0,
+    cancelled INTEGER        NOT NULL DEFAULT 0,
+    units     INTEGER        NOT NULL DEFAULT 0,
+    revenue   DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, user_id)
+);
+
+-- Дерево категорий: категорию с подкатегориями удалить нельзя
+CREATE TABLE IF NOT EXISTS categories
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    parent_id  INTEGER      REFERENCES categories (id) ON DELETE RESTRICT,
+    name       VARCHAR(100) NOT NULL,
+    version    INTEGER      NOT NULL DEFAULT 1,
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+
+CREATE TABLE IF NOT EXISTS product_categories
+(
+    product_id  INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_categories_category_id ON product_categories (category_id);
+
+CREATE TABLE IF NOT EXISTS tags
+(
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(50) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS product_tags
+(
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    tag_id     INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_tags_tag_id ON product_tags (tag_id);
+
+-- Промокоды: uses и coupon_uses.uses увеличиваются условным UPDATE, поэтому параллельные покупки
+-- не превышают max_uses и per_user_limit. Отмена покупки возвращает использование (AdjustCouponUses)
+CREATE TABLE IF NOT EXISTS coupons
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    code            VARCHAR(32)    NOT NULL UNIQUE,
+    type            VARCHAR(10)    NOT NULL,
+    value           DECIMAL(10, 2) NOT NULL,
+    max_uses        INTEGER,
+    per_user_limit  INTEGER,
+    min_order_value DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    expires_at      DATETIME,
+    uses            INTEGER        NOT NULL DEFAULT 0,
+    created_at      DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS coupon_uses
+(
+    coupon_id INTEGER NOT NULL REFERENCES coupons (id) ON DELETE CASCADE,
+    user_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    uses      INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (coupon_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_uses_user_id ON coupon_uses (user_id);
+
+-- Автоматические скидки: от min_quantity единиц товара из категории (с подкатегориями), без категории - любого товара
+CREATE TABLE IF NOT EXISTS promotion_rules
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    name         VARCHAR(100)   NOT NULL,
+    category_id  INTEGER REFERENCES categories (id) ON DELETE CASCADE,
+    min_quantity INTEGER        NOT NULL DEFAULT 1,
+    type         VARCHAR(10)    NOT NULL,
+    value        DECIMAL(10, 2) NOT NULL,
+    created_at   DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_rules_category_id ON promotion_rules (category_id);
@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/contract"
+	sqlitepkg "github.com/SaveljevRoman/go-layout-project/pkg/sqlite"
+	"testing"
+)
+
+func TestRepositoriesContract(t *testing.T) {
+	contract.RunRepositories(t, func(t *testing.T) contract.Repositories {
+		conn, err := sqlitepkg.NewConnection(config.SQLiteConfig{Path: ":memory:", MaxOpenConns: 1, BusyTimeout: 1000})
+		if err != nil {
+			t.Fatalf("NewConnection: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		if err := Migrate(context.Background(), conn); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+
+		db := NewDB(conn)
+		return contract.Repositories{
+			Users:      NewUserRepository(db),
+			Products:   NewProductRepository(db),
+			Purchases:  NewPurchaseRepository(db),
+			Categories: NewCategoryRepository(db),
+			Tags:       NewTagRepository(db),
+			Variants:   NewVariantRepository(db),
+			Rollups:    NewRollupRepository(db),
+			Prices:     NewPriceRepository(db),
+			Promotions: NewPromotionRepository(db),
+			TxManager:  NewTxManager(db, 3),
+		}
+	})
+}
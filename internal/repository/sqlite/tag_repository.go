@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"strings"
+)
+
+type TagRepository struct {
+	db *DB
+}
+
+func NewTagRepository(db *DB) *TagRepository {
+	return &TagRepository{
+		db: db,
+	}
+}
+
+// GetAll возвращает теги, которые есть хотя бы у одного товара, по алфавиту
+func (r *TagRepository) GetAll(ctx context.Context) ([]models.TagCount, error) {
+	tags := []models.TagCount{}
+	query := `SELECT t.name, COUNT(*) AS products FROM tags t JOIN product_tags pt ON pt.tag_id = t.id
+			  GROUP BY t.name ORDER BY t.name`
+	err := r.db.Conn(ctx).SelectContext(ctx, &tags, query)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetProductTags возвращает теги товара по алфавиту
+func (r *TagRepository) GetProductTags(ctx context.Context, productID int64) ([]string, error) {
+	tags := []string{}
+	query := "SELECT t.name FROM tags t JOIN product_tags pt ON pt.tag_id = t.id WHERE pt.product_id = ? ORDER BY t.name"
+	err := r.db.Conn(ctx).SelectContext(ctx, &tags, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SetProductTags заменяет теги товара, создавая недостающие; вызывается в транзакции.
+// Теги, оставшиеся без товаров, не удаляются: GetAll их не показывает.
+func (r *TagRepository) SetProductTags(ctx context.Context, productID int64, tags []string) error {
+	conn := r.db.Conn(ctx)
+	if _, err := conn.ExecContext(ctx, "DELETE FROM product_tags WHERE product_id = ?", productID); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+	args := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		args[i] = tag
+	}
+	values := strings.TrimSuffix(strings.Repeat("(?), ", len(tags)), ", ")
+	if _, err := conn.ExecContext(ctx, "INSERT OR IGNORE INTO tags (name) VALUES "+values, args...); err != nil {
+		return err
+	}
+	query := "INSERT INTO product_tags (product_id, tag_id) SELECT ?, id FROM tags WHERE name IN (" + placeholders + ")"
+	_, err := conn.ExecContext(ctx, query, append([]interface{}{productID}, args...)...)
+	return err
+}
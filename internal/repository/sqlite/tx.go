@@ -0,0 +1,32 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqltx"
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+)
+
+// Коды ошибок SQLite, при которых транзакцию имеет смысл повторить
+const (
+	errBusy   = 5
+	errLocked = 6
+)
+
+func NewTxManager(db *DB, maxRetries int) *sqltx.Manager {
+	begin := func(ctx context.Context) (*sqlx.Tx, error) {
+		return db.db.BeginTxx(ctx, nil)
+	}
+	return sqltx.NewManager(begin, isRetryable, maxRetries)
+}
+
+func isRetryable(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// Младший байт - основной код, старшие - расширенный (например, SQLITE_BUSY_SNAPSHOT)
+		code := sqliteErr.Code() & 0xff
+		return code == errBusy || code == errLocked
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+)
+
+type VariantRepository struct {
+	db *DB
+}
+
+func NewVariantRepository(db *DB) *VariantRepository {
+	return &VariantRepository{
+		db: db,
+	}
+}
+
+func (r *VariantRepository) GetByID(ctx context.Context, id int64) (*models.ProductVariant, error) {
+	variant := &models.ProductVariant{}
+	query := "SELECT * FROM product_variants WHERE id = ?"
+	err := r.db.Conn(ctx).GetContext(ctx, variant, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Вариант не найден
+		}
+		return nil, err
+	}
+	return variant, nil
+}
+
+func (r *VariantRepository) GetBySKU(ctx context.Context, sku string) (*models.ProductVariant, error) {
+	variant := &models.ProductVariant{}
+	query := "SELECT * FROM product_variants WHERE sku = ?"
+	err := r.db.Conn(ctx).GetContext(ctx, variant, query, sku)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return variant, nil
+}
+
+// GetByProductID возвращает варианты товара в порядке id
+func (r *VariantRepository) GetByProductID(ctx context.Context, productID int64) ([]*models.ProductVariant, error) {
+	variants := []*models.ProductVariant{}
+	query := "SELECT * FROM product_variants WHERE product_id = ? ORDER BY id"
+	err := r.db.Conn(ctx).SelectContext(ctx, &variants, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (r *VariantRepository) Create(ctx context.Context, variant *models.ProductVariant) (int64, error) {
+	var id int64
+	query := `INSERT INTO product_variants (product_id, sku, attributes, price, quantity, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id`
+	err := r.db.Conn(ctx).GetContext(ctx, &id, query,
+		variant.ProductID, variant.SKU, variant.Attributes, variant.Price, variant.Quantity)
+	return id, err
+}
+
+// Update перезаписывает артикул, характеристики, цену и остаток и увеличивает версию; variant.Version проверяется, если задана
+func (r *VariantRepository) Update(ctx context.Context, variant *models.ProductVariant) error {
+	query, args := withVersion("UPDATE product_variants SET sku = ?, attributes = ?, price = ?, quantity = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		[]interface{}{variant.SKU, variant.Attributes, variant.Price, variant.Quantity, variant.ID}, variant.Version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, variant.Version)
+}
+
+// Delete удаляет вариант; в покупках ссылка на него обнуляется (ON DELETE SET NULL)
+func (r *VariantRepository) Delete(ctx context.Context, id int64, version int64) error {
+	query, args := withVersion("DELETE FROM product_variants WHERE id = ?", []interface{}{id}, version)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return checkVersion(result, version)
+}
+
+// DecreaseQuantity атомарно списывает вариант со склада, если его достаточно
+func (r *VariantRepository) DecreaseQuantity(ctx context.Context, id int64, quantity int) error {
+	query := "UPDATE product_variants SET quantity = quantity - ?, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND quantity >= ?"
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, quantity, id, quantity)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrInsufficientStock
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package sqltx - менеджер транзакций, общий для SQL-хранилищ (MySQL, PostgreSQL, SQLite).
+// Хранилища отличаются только тем, как начинается транзакция и какие ошибки считаются конфликтом блокировок.
+package sqltx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// Manager выполняет функцию в транзакции, привязанной к контексту.
+// Репозитории, получившие такой контекст, работают в этой транзакции через Tx.
+type Manager struct {
+	begin      func(ctx context.Context) (*sqlx.Tx, error)
+	retryable  func(err error) bool
+	maxRetries int
+}
+
+// NewManager создает менеджер. begin открывает транзакцию, retryable сообщает, что ошибка -
+// взаимная блокировка или таймаут ожидания блокировки и транзакцию стоит повторить.
+func NewManager(begin func(ctx context.Context) (*sqlx.Tx, error), retryable func(err error) bool, maxRetries int) *Manager {
+	return &Manager{
+		begin:      begin,
+		retryable:  retryable,
+		maxRetries: maxRetries,
+	}
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx    *sqlx.Tx
+	depth int
+	// afterCommit - функции, отложенные до фиксации внешней транзакции
+	afterCommit []func()
+}
+
+func stateFromContext(ctx context.Context) *txState {
+	state, _ := ctx.Value(txKey{}).(*txState)
+	return state
+}
+
+// Tx возвращает транзакцию из контекста или nil, если контекст не в транзакции
+func Tx(ctx context.Context) *sqlx.Tx {
+	if state := stateFromContext(ctx); state != nil {
+		return state.tx
+	}
+	return nil
+}
+
+// Do выполняет fn в транзакции. Если контекст уже содержит транзакцию,
+// fn выполняется во вложенной точке сохранения (SAVEPOINT), и ее ошибка откатывает только эту точку.
+// Внешняя транзакция повторяется целиком при взаимной блокировке или таймауте ожидания блокировки.
+func (m *Manager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if state := stateFromContext(ctx); state != nil {
+		return m.savepoint(ctx, state, fn)
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = m.run(ctx, fn)
+		if err == nil || !m.retryable(err) || attempt >= m.maxRetries {
+			return err
+		}
+
+		delay := time.Duration(10*(1<<attempt)+rand.Intn(10)) * time.Millisecond
+		slog.Warn("Transaction conflict, retrying", "attempt", attempt+1, "max_attempts", m.maxRetries+1, "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// AfterCommit откладывает fn до фиксации внешней транзакции из ctx: при откате (в том числе
+// точки сохранения, в которой fn зарегистрирована) или повторе транзакции fn не вызывается.
+// Вне транзакции fn вызывается сразу.
+func (m *Manager) AfterCommit(ctx context.Context, fn func()) {
+	if state := stateFromContext(ctx); state != nil {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+func (m *Manager) run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, context.Canceled) {
+				slog.Error("Failed to rollback transaction", "error", rbErr)
+			}
+		}
+	}()
+
+	state := &txState{tx: tx}
+	if err = fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, f := range state.afterCommit {
+		f()
+	}
+	return nil
+}
+
+func (m *Manager) savepoint(ctx context.Context, parent *txState, fn func(ctx context.Context) error) (err error) {
+	state := &txState{tx: parent.tx, depth: parent.depth + 1}
+	name := fmt.Sprintf("sp_%d", state.depth)
+
+	if _, err = state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+		if err != nil {
+			if _, rbErr := state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+				slog.Error("Failed to rollback to savepoint", "savepoint", name, "error", rbErr)
+			}
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+
+	if _, err = state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	parent.afterCommit = append(parent.afterCommit, state.afterCommit...)
+	return nil
+}
@@ -0,0 +1,223 @@
+package sqltx_test
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/sqltx"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+	"reflect"
+	"testing"
+)
+
+// errConflict имитирует взаимную блокировку, после которой транзакцию стоит повторить
+var errConflict = errors.New("deadlock")
+
+func newManager(t *testing.T, maxRetries int) (*sqltx.Manager, *sqlx.DB) {
+	t.Helper()
+	db, err := sqlx.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// Каждое соединение с :memory: - отдельная БД
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE: %v", err)
+	}
+
+	begin := func(ctx context.Context) (*sqlx.Tx, error) { return db.BeginTxx(ctx, nil) }
+	retryable := func(err error) bool { return errors.Is(err, errConflict) }
+	return sqltx.NewManager(begin, retryable, maxRetries), db
+}
+
+func insert(ctx context.Context, t *testing.T, id int) error {
+	t.Helper()
+	tx := sqltx.Tx(ctx)
+	if tx == nil {
+		t.Fatal("контекст без транзакции")
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO items (id) VALUES (?)", id)
+	return err
+}
+
+func ids(t *testing.T, db *sqlx.DB) []int {
+	t.Helper()
+	var ids []int
+	if err := db.Select(&ids, "SELECT id FROM items ORDER BY id"); err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	return ids
+}
+
+func TestDoCommitsOrRollsBack(t *testing.T) {
+	manager, db := newManager(t, 0)
+	ctx := context.Background()
+
+	if sqltx.Tx(ctx) != nil {
+		t.Error("Tx вне транзакции не nil")
+	}
+
+	if err := manager.Do(ctx, func(ctx context.Context) error { return insert(ctx, t, 1) }); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	err := manager.Do(ctx, func(ctx context.Context) error {
+		if err := insert(ctx, t, 2); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Do: ожидалась исходная ошибка, получено %v", err)
+	}
+
+	if got := ids(t, db); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("строки %v, ожидалось [1]", got)
+	}
+}
+
+func TestDoSavepoints(t *testing.T) {
+	manager, db := newManager(t, 0)
+	errAbort := errors.New("abort")
+
+	err := manager.Do(context.Background(), func(ctx context.Context) error {
+		if err := insert(ctx, t, 1); err != nil {
+			return err
+		}
+
+		// Ошибка во вложенной транзакции откатывает только ее точку сохранения
+		err := manager.Do(ctx, func(ctx context.Context) error {
+			if err := insert(ctx, t, 2); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Errorf("вложенный Do: ожидалась исходная ошибка, получено %v", err)
+		}
+
+		// Второй уровень вложенности: внутренняя точка откатывается, внешняя сохраняется
+		return manager.Do(ctx, func(ctx context.Context) error {
+			if err := insert(ctx, t, 3); err != nil {
+				return err
+			}
+			err := manager.Do(ctx, func(ctx context.Context) error {
+				if err := insert(ctx, t, 4); err != nil {
+					return err
+				}
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Errorf("Do второго уровня: ожидалась исходная ошибка, получено %v", err)
+			}
+			return insert(ctx, t, 5)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	if got := ids(t, db); !reflect.DeepEqual(got, []int{1, 3, 5}) {
+		t.Errorf("строки %v, ожидалось [1 3 5]", got)
+	}
+}
+
+func TestDoRetriesConflicts(t *testing.T) {
+	errConstraint := errors.New("constraint")
+	tests := []struct {
+		name         string
+		maxRetries   int
+		failures     int
+		failWith     error
+		wantAttempts int
+		wantErr      error
+		wantIDs      []int
+	}{
+		{"повтор после конфликта", 3, 2, errConflict, 3, nil, []int{3}},
+		{"попытки исчерпаны", 2, 5, errConflict, 3, errConflict, nil},
+		{"прочие ошибки не повторяются", 3, 1, errConstraint, 1, errConstraint, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, db := newManager(t, tt.maxRetries)
+
+			attempts := 0
+			err := manager.Do(context.Background(), func(ctx context.Context) error {
+				attempts++
+				// Записи неудачной попытки откатываются вместе с ней
+				if err := insert(ctx, t, attempts); err != nil {
+					return err
+				}
+				if attempts <= tt.failures {
+					return tt.failWith
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Do: ошибка %v, ожидалась %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("попыток %d, ожидалось %d", attempts, tt.wantAttempts)
+			}
+			if got := ids(t, db); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("строки %v, ожидалось %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestAfterCommit(t *testing.T) {
+	manager, _ := newManager(t, 1)
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	var calls []string
+	record := func(name string) func() { return func() { calls = append(calls, name) } }
+
+	// Вне транзакции функция вызывается сразу
+	manager.AfterCommit(ctx, record("outside"))
+
+	attempts := 0
+	err := manager.Do(ctx, func(ctx context.Context) error {
+		attempts++
+		manager.AfterCommit(ctx, record("attempt"))
+		if attempts == 1 {
+			return errConflict
+		}
+
+		manager.Do(ctx, func(ctx context.Context) error {
+			manager.AfterCommit(ctx, record("released savepoint"))
+			return nil
+		})
+		manager.Do(ctx, func(ctx context.Context) error {
+			manager.AfterCommit(ctx, record("rolled back savepoint"))
+			return errAbort
+		})
+
+		if len(calls) != 1 {
+			t.Errorf("функции вызваны до фиксации: %q", calls)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	// Повтор транзакции и откат точки сохранения отменяют отложенные в них функции
+	if want := []string{"outside", "attempt", "released savepoint"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("вызовы %q, ожидалось %q", calls, want)
+	}
+
+	calls = nil
+	manager.Do(ctx, func(ctx context.Context) error {
+		manager.AfterCommit(ctx, record("rolled back"))
+		return errAbort
+	})
+	if len(calls) != 0 {
+		t.Errorf("после отката вызваны %q", calls)
+	}
+}
@@ -0,0 +1,255 @@
+// Package search - разбор поискового запроса на термы и подсветка совпадений.
+// Все хранилища понимают термы одинаково: терм - начало слова (поиск по префиксу),
+// в товаре должны встретиться все термы запроса.
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTerms - сколько первых слов запроса участвует в поиске
+	MaxTerms = 8
+	// minTermLength - более короткие слова (предлоги, союзы) отбрасываются
+	minTermLength = 2
+	// minStemLength - короче основа не обрезается, иначе префикс совпадет со слишком многими словами
+	minStemLength = 3
+)
+
+// Терм подсвечивается в тексте этими тегами; остальной текст экранируется для HTML
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+	ellipsis  = "…"
+)
+
+// Terms разбирает запрос на термы: слова из букв и цифр в нижнем регистре, без повторов.
+// При stemming у русских слов отбрасывается окончание, чтобы "ноутбуки" находило "ноутбука".
+func Terms(query string, stemming bool) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words(query) {
+		term := strings.ToLower(query[word.start:word.end])
+		if utf8.RuneCountInString(term) < minTermLength {
+			continue
+		}
+		if stemming {
+			term = Stem(term)
+		}
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == MaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// russianEndings - окончания существительных и прилагательных, от длинных к коротким
+var russianEndings = []string{
+	"иями", "ями", "ами", "ией", "иям", "ием", "иях", "ого", "его", "ому", "ему", "ыми", "ими",
+	"ых", "их", "ый", "ий", "ой", "ая", "яя", "ое", "ее", "ые", "ие", "ую", "юю",
+	"ов", "ев", "ей", "ам", "ям", "ах", "ях", "ом", "ем", "ью",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// Stem отбрасывает у русского слова окончание - упрощенный стемминг без словаря.
+// Слова на латинице и числа не меняются.
+func Stem(word string) string {
+	last, _ := utf8.DecodeLastRuneInString(word)
+	if !unicode.Is(unicode.Cyrillic, last) {
+		return word
+	}
+	for _, ending := range russianEndings {
+		stem, ok := strings.CutSuffix(word, ending)
+		if ok && utf8.RuneCountInString(stem) >= minStemLength {
+			return stem
+		}
+	}
+	return word
+}
+
+// Count возвращает, сколько слов текста начинается с терма
+func Count(text, term string) int {
+	count := 0
+	for _, word := range words(text) {
+		if strings.HasPrefix(strings.ToLower(text[word.start:word.end]), term) {
+			count++
+		}
+	}
+	return count
+}
+
+// Highlight экранирует текст для HTML и выделяет слова, начинающиеся с любого из термов, тегом <mark>
+func Highlight(text string, terms []string) string {
+	return highlight(text, words(text), terms)
+}
+
+// Snippet - фрагмент длинного текста вокруг первого совпадения длиной не больше maxRunes символов,
+// с подсветкой как в Highlight. Обрезанные края помечаются многоточием.
+func Snippet(text string, terms []string, maxRunes int) string {
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return Highlight(text, terms)
+	}
+
+	all := words(text)
+	// Начинаем за четверть фрагмента до первого совпадения, чтобы был виден контекст
+	first := 0
+	for _, word := range all {
+		if matches(text[word.start:word.end], terms) {
+			first = word.start
+			break
+		}
+	}
+	start := first
+	for back := maxRunes / 4; back > 0 && start > 0; back-- {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := start
+	for n := 0; n < maxRunes && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	// Края сдвигаются к границам слов, чтобы не резать слово пополам
+	var inside []word
+	for _, word := range all {
+		if word.start >= start && word.end <= end {
+			inside = append(inside, word)
+		}
+	}
+	if len(inside) == 0 {
+		return Highlight(text[start:end], terms)
+	}
+	if start > 0 {
+		start = inside[0].start
+	}
+	if end < len(text) {
+		end = inside[len(inside)-1].end
+	}
+
+	fragment := text[start:end]
+	shifted := make([]word, len(inside))
+	for i, w := range inside {
+		shifted[i] = word{start: w.start - start, end: w.end - start}
+	}
+	result := highlight(fragment, shifted, terms)
+	if start > 0 {
+		result = ellipsis + result
+	}
+	if end < len(text) {
+		result += ellipsis
+	}
+	return result
+}
+
+type word struct {
+	start, end int
+}
+
+// words находит в тексте слова - непрерывные последовательности букв и цифр
+func words(text string) []word {
+	var result []word
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			result = append(result, word{start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		result = append(result, word{start: start, end: len(text)})
+	}
+	return result
+}
+
+func matches(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func highlight(text string, all []word, terms []string) string {
+	var b strings.Builder
+	pos := 0
+	for _, w := range all {
+		if !matches(text[w.start:w.end], terms) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:w.start]))
+		b.WriteString(markOpen)
+		b.WriteString(html.EscapeString(text[w.start:w.end]))
+		b.WriteString(markClose)
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(text[pos:]))
+	return b.String()
+}
+
+// MaxPrefixLength - длина самого длинного префикса в индексе подсказок, в символах;
+// более длинный запрос подсказок обрезается до нее
+const MaxPrefixLength = 20
+
+// Normalize приводит текст к виду, в котором хранятся префиксы подсказок:
+// слова из букв и цифр в нижнем регистре через один пробел
+func Normalize(text string) string {
+	all := words(text)
+	parts := make([]string, len(all))
+	for i, w := range all {
+		parts[i] = strings.ToLower(text[w.start:w.end])
+	}
+	return strings.Join(parts, " ")
+}
+
+// SuggestPrefix - ключ поиска подсказок для введенного текста: нормализованный и обрезанный до MaxPrefixLength
+func SuggestPrefix(query string) string {
+	return truncate(Normalize(query), MaxPrefixLength)
+}
+
+// Prefixes возвращает префиксы названия для индекса подсказок. Для каждого слова названия
+// индексируются начала фразы с этого слова до конца названия, поэтому "игровая мышь progamer"
+// находится и по "игр", и по "мышь pro", и по "progamer".
+func Prefixes(name string) []string {
+	normalized := Normalize(name)
+	var prefixes []string
+	seen := make(map[string]bool)
+	for _, w := range words(normalized) {
+		phrase := truncate(normalized[w.start:], MaxPrefixLength)
+		for i := range phrase + " " {
+			// Префикс с пробелом на конце не совпадет ни с одним запросом: SuggestPrefix их отрезает
+			if i == 0 || phrase[i-1] == ' ' || seen[phrase[:i]] {
+				continue
+			}
+			seen[phrase[:i]] = true
+			prefixes = append(prefixes, phrase[:i])
+		}
+	}
+	return prefixes
+}
+
+// truncate обрезает текст до maxRunes символов, не оставляя пробела на конце
+func truncate(text string, maxRunes int) string {
+	n := 0
+	for i := range text {
+		if n == maxRunes {
+			return strings.TrimRight(text[:i], " ")
+		}
+		n++
+	}
+	return text
+}
@@ -0,0 +1,137 @@
+package search_test
+
+import (
+	"github.com/SaveljevRoman/go-layout-project/internal/search"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		query    string
+		stemming bool
+		want     []string
+	}{
+		{"Ноутбуки для работы", true, []string{"ноутбук", "для", "работ"}},
+		{"Ноутбуки для работы", false, []string{"ноутбуки", "для", "работы"}},
+		{"RGB-подсветка, RGB и 15.6\"", true, []string{"rgb", "подсветк", "15"}},
+		{"мышь мыши МЫШЬ", true, []string{"мыш"}},
+		{"  ,. ", true, nil},
+		{"a b c d e f g h i j k l", false, nil},
+	}
+	for _, tt := range tests {
+		if got := search.Terms(tt.query, tt.stemming); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q, %t) = %q, ожидалось %q", tt.query, tt.stemming, got, tt.want)
+		}
+	}
+
+	long := "слово aa bb cc dd ee ff gg hh ii jj"
+	if got := search.Terms(long, false); len(got) != search.MaxTerms {
+		t.Errorf("Terms: %d термов, ожидалось не больше %d", len(got), search.MaxTerms)
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"ноутбуки":     "ноутбук",
+		"ноутбука":     "ноутбук",
+		"беспроводные": "беспроводн",
+		"игровая":      "игров",
+		"мышь":         "мыш",
+		"дом":          "дом",
+		"progamer":     "progamer",
+		"2k":           "2k",
+	}
+	for word, want := range tests {
+		if got := search.Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, ожидалось %q", word, got, want)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	if got := search.Count("Ноутбук и ноутбуки, но не наноутбук", "ноутбук"); got != 2 {
+		t.Errorf("Count = %d, ожидалось 2", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := search.Highlight(`Мышь <b>"Игровая"</b> и мышка`, []string{"мыш", "игров"})
+	want := `<mark>Мышь</mark> &lt;b&gt;&#34;<mark>Игровая</mark>&#34;&lt;/b&gt; и <mark>мышка</mark>`
+	if got != want {
+		t.Errorf("Highlight = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	short := "Короткое описание мыши"
+	if got := search.Snippet(short, []string{"мыш"}, 100); got != "Короткое описание <mark>мыши</mark>" {
+		t.Errorf("Snippet короткого текста = %q", got)
+	}
+
+	text := strings.Repeat("начало ", 20) + "ноутбук " + strings.Repeat("конец ", 20)
+	got := search.Snippet(text, []string{"ноутбук"}, 40)
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Snippet без многоточий: %q", got)
+	}
+	if !strings.Contains(got, "<mark>ноутбук</mark>") {
+		t.Errorf("Snippet без совпадения: %q", got)
+	}
+	plain := strings.NewReplacer("<mark>", "", "</mark>", "", "…", "").Replace(got)
+	if n := utf8.RuneCountInString(plain); n > 40 {
+		t.Errorf("Snippet длиной %d символов, ожидалось не больше 40: %q", n, got)
+	}
+	for _, w := range strings.Fields(plain) {
+		if w != "начало" && w != "ноутбук" && w != "конец" {
+			t.Errorf("Snippet разрезал слово %q: %q", w, got)
+		}
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	prefixes := search.Prefixes("Игровая мышь, ProGamer")
+	set := make(map[string]bool)
+	for _, prefix := range prefixes {
+		if set[prefix] {
+			t.Errorf("Prefixes: повтор %q", prefix)
+		}
+		set[prefix] = true
+	}
+	for _, want := range []string{"и", "игровая", "игровая м", "мышь progamer", "pro", "progamer"} {
+		if !set[want] {
+			t.Errorf("Prefixes: нет префикса %q", want)
+		}
+	}
+	for _, unwanted := range []string{"", "игровая ", "ышь", "игровая мышь progamer"} {
+		if set[unwanted] {
+			t.Errorf("Prefixes: лишний префикс %q", unwanted)
+		}
+	}
+	for _, prefix := range prefixes {
+		if utf8.RuneCountInString(prefix) > search.MaxPrefixLength {
+			t.Errorf("Prefixes: префикс длиннее %d символов: %q", search.MaxPrefixLength, prefix)
+		}
+	}
+}
+
+func TestSuggestPrefix(t *testing.T) {
+	tests := map[string]string{
+		"  Игровая   МЫШЬ ":               "игровая мышь",
+		"мышь, pro":                       "мышь pro",
+		"игровая мышь progamer black":     "игровая мышь progame",
+		"игровая мышь programmable black": "игровая мышь program",
+		" -- ": "",
+	}
+	for query, want := range tests {
+		if got := search.SuggestPrefix(query); got != want {
+			t.Errorf("SuggestPrefix(%q) = %q, ожидалось %q", query, got, want)
+		}
+	}
+	// Обрезанный запрос совпадает с префиксом, обрезанным так же
+	if prefix := search.SuggestPrefix("игровая мышь progamer"); !slices.Contains(search.Prefixes("Игровая мышь ProGamer"), prefix) {
+		t.Errorf("префикс %q длинного запроса отсутствует в индексе", prefix)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+)
+
+// bulkChunkSize - сколько элементов пакета записывается одной транзакцией (и одним CreateBatch)
+const bulkChunkSize = 500
+
+// errBulkItemFailed прерывает атомарный пакет после ошибки элемента, чтобы TxManager откатил транзакцию
+var errBulkItemFailed = errors.New("ошибка элемента пакета")
+
+// runBulk выполняет пакетную операцию над n элементами пачками по bulkChunkSize и возвращает ошибку
+// каждого элемента (nil - элемент записан). Ошибка второго результата означает, что пакет не выполнен вовсе.
+//
+// Пачка сначала пишется целиком через chunk (если он задан). Если это не удалось, элементы пачки
+// пишутся по одному через item, каждый в своей точке сохранения, - так находятся ошибочные элементы.
+//
+// В атомарном режиме все пачки выполняются в одной транзакции, и первая ошибка элемента откатывает
+// весь пакет: остальные элементы получают models.ErrBulkAborted. Иначе каждая пачка - отдельная
+// транзакция, а ошибочные элементы откатываются поодиночке и не мешают остальным.
+func runBulk(ctx context.Context, txManager TxManager, n int, atomic bool,
+	chunk func(ctx context.Context, from, to int) error,
+	item func(ctx context.Context, i int) error,
+) ([]error, error) {
+	errs := make([]error, n)
+
+	// writeChunk записывает элементы [from, to) и сообщает, все ли записаны.
+	// TxManager может повторить транзакцию, поэтому ошибки пачки каждый раз сбрасываются.
+	writeChunk := func(ctx context.Context, from, to int) bool {
+		clear(errs[from:to])
+		if chunk != nil {
+			err := txManager.Do(ctx, func(ctx context.Context) error { return chunk(ctx, from, to) })
+			if err == nil {
+				return true
+			}
+		}
+
+		ok := true
+		for i := from; i < to; i++ {
+			errs[i] = txManager.Do(ctx, func(ctx context.Context) error { return item(ctx, i) })
+			if errs[i] != nil {
+				ok = false
+				if atomic {
+					return false
+				}
+			}
+		}
+		return ok
+	}
+
+	if atomic {
+		err := txManager.Do(ctx, func(ctx context.Context) error {
+			for from := 0; from < n; from += bulkChunkSize {
+				if !writeChunk(ctx, from, min(from+bulkChunkSize, n)) {
+					return errBulkItemFailed
+				}
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBulkItemFailed) {
+			return nil, err
+		}
+		if err != nil {
+			for i := range errs {
+				if errs[i] == nil {
+					errs[i] = models.ErrBulkAborted
+				}
+			}
+		}
+		return errs, nil
+	}
+
+	for from := 0; from < n; from += bulkChunkSize {
+		to := min(from+bulkChunkSize, n)
+		err := txManager.Do(ctx, func(ctx context.Context) error {
+			writeChunk(ctx, from, to)
+			return nil
+		})
+		// Не удалось зафиксировать пачку - не записан ни один ее элемент
+		if err != nil {
+			for i := from; i < to; i++ {
+				if errs[i] == nil {
+					errs[i] = err
+				}
+			}
+		}
+	}
+	return errs, nil
+}
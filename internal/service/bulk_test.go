@@ -0,0 +1,139 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"testing"
+)
+
+type bulkFixture struct {
+	users    *service.UserService
+	products *service.ProductService
+	userRepo *memory.UserRepository
+	cache    *memory.ProductCache
+}
+
+func newBulkFixture() *bulkFixture {
+	db := memory.NewDB()
+	txManager := memory.NewTxManager(db)
+	userRepo := memory.NewUserRepository(db)
+	cache := memory.NewProductCache()
+	return &bulkFixture{
+		users:    service.NewUserService(userRepo, memory.NewUserCache(), txManager),
+		products: service.NewProductService(memory.NewProductRepository(db), cache, txManager),
+		userRepo: userRepo,
+		cache:    cache,
+	}
+}
+
+// bulkUsers - n пользователей; больше размера пачки, чтобы пакет разбивался на несколько транзакций
+func bulkUsers(n int) []*models.User {
+	users := make([]*models.User, n)
+	for i := range users {
+		users[i] = &models.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i)}
+	}
+	return users
+}
+
+func TestBulkCreateBestEffort(t *testing.T) {
+	f := newBulkFixture()
+	ctx := context.Background()
+
+	users := bulkUsers(1200)
+	// Дубликат во второй пачке: CreateBatch этой пачки не проходит, и ее элементы пишутся по одному
+	users[700].Username = users[3].Username
+
+	errs, err := f.users.BulkCreateUsers(ctx, users, false)
+	if err != nil {
+		t.Fatalf("BulkCreateUsers: %v", err)
+	}
+	for i, err := range errs {
+		if i == 700 {
+			if !errors.Is(err, memory.ErrDuplicate) || users[i].ID != 0 {
+				t.Errorf("элемент 700: ожидалась ErrDuplicate без id, получено (%v, id %d)", err, users[i].ID)
+			}
+			continue
+		}
+		if err != nil || users[i].ID == 0 || users[i].Version != 1 {
+			t.Fatalf("элемент %d: (%v, %+v)", i, err, users[i])
+		}
+	}
+
+	all, err := f.userRepo.GetAll(ctx)
+	if err != nil || len(all) != 1199 {
+		t.Fatalf("GetAll: ожидалось 1199 пользователей, получено (%d, %v)", len(all), err)
+	}
+}
+
+func TestBulkCreateAtomic(t *testing.T) {
+	f := newBulkFixture()
+	ctx := context.Background()
+
+	users := bulkUsers(600)
+	users[550].Email = users[10].Email
+
+	errs, err := f.users.BulkCreateUsers(ctx, users, true)
+	if err != nil {
+		t.Fatalf("BulkCreateUsers: %v", err)
+	}
+	if !errors.Is(errs[550], memory.ErrDuplicate) {
+		t.Errorf("элемент 550: ожидалась ErrDuplicate, получено %v", errs[550])
+	}
+	for i, err := range errs {
+		if i != 550 && !errors.Is(err, models.ErrBulkAborted) {
+			t.Fatalf("элемент %d: ожидалась ErrBulkAborted, получено %v", i, err)
+		}
+	}
+
+	// Первая пачка была записана до ошибки, но откатилась вместе с пакетом
+	all, err := f.userRepo.GetAll(ctx)
+	if err != nil || len(all) != 0 {
+		t.Fatalf("после отката: ожидалось 0 пользователей, получено (%d, %v)", len(all), err)
+	}
+}
+
+func TestBulkProductsWarmCacheAndCheckVersions(t *testing.T) {
+	f := newBulkFixture()
+	ctx := context.Background()
+
+	products := []*models.Product{{Name: "a", Price: 1, Quantity: 1}, {Name: "b", Price: 2, Quantity: 2}}
+	if errs, err := f.products.BulkCreateProducts(ctx, products, true); err != nil || errs[0] != nil || errs[1] != nil {
+		t.Fatalf("BulkCreateProducts: (%v, %v)", errs, err)
+	}
+	for _, product := range products {
+		cached, _ := f.cache.GetByID(ctx, product.ID)
+		if cached == nil || cached.Name != product.Name {
+			t.Errorf("товар %d не прогрет в кеше: %+v", product.ID, cached)
+		}
+	}
+
+	updates := []*models.Product{
+		{ID: products[0].ID, Name: "a2", Price: 10, Version: 1},
+		{ID: products[1].ID, Name: "b2", Price: 20, Version: 7},
+	}
+	errs, err := f.products.BulkUpdateProducts(ctx, updates, false)
+	if err != nil {
+		t.Fatalf("BulkUpdateProducts: %v", err)
+	}
+	if errs[0] != nil || updates[0].Version != 2 {
+		t.Errorf("обновление с верной версией: (%v, version %d)", errs[0], updates[0].Version)
+	}
+	if !errors.Is(errs[1], models.ErrVersionConflict) {
+		t.Errorf("обновление с устаревшей версией: ожидался ErrVersionConflict, получено %v", errs[1])
+	}
+	if cached, _ := f.cache.GetByID(ctx, products[0].ID); cached != nil {
+		t.Errorf("обновленный товар должен быть удален из кеша: %+v", cached)
+	}
+
+	errs, err = f.products.BulkDeleteProducts(ctx, []models.BulkDeleteItem{{ID: products[0].ID, Version: 2}, {ID: products[1].ID, Version: 1}}, false)
+	if err != nil || errs[0] != nil || errs[1] != nil {
+		t.Fatalf("BulkDeleteProducts: (%v, %v)", errs, err)
+	}
+	if cached, _ := f.cache.GetByID(ctx, products[1].ID); cached != nil {
+		t.Errorf("удаленный товар остался в кеше: %+v", cached)
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"log/slog"
+	"time"
+)
+
+type CategoryRepository interface {
+	GetByID(ctx context.Context, id int64) (*models.Category, error)
+	GetAll(ctx context.Context) ([]*models.Category, error)
+	// GetByIDForUpdate читает категорию и в транзакции блокирует ее до фиксации
+	GetByIDForUpdate(ctx context.Context, id int64) (*models.Category, error)
+	HasChildren(ctx context.Context, id int64) (bool, error)
+	Create(ctx context.Context, category *models.Category) (int64, error)
+	Update(ctx context.Context, category *models.Category) error
+	Delete(ctx context.Context, id int64, version int64) error
+	// Категории товара (таблица product_categories)
+	GetProductCategories(ctx context.Context, productID int64) ([]*models.Category, error)
+	SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error
+}
+
+type TagRepository interface {
+	// GetAll возвращает теги, которые есть хотя бы у одного товара, по алфавиту
+	GetAll(ctx context.Context) ([]models.TagCount, error)
+	GetProductTags(ctx context.Context, productID int64) ([]string, error)
+	// SetProductTags заменяет теги товара; теги уже нормализованы (models.NormalizeTags)
+	SetProductTags(ctx context.Context, productID int64, tags []string) error
+}
+
+type CategoryCache interface {
+	// GetTree возвращает дерево категорий; nil - в кеше нет
+	GetTree(ctx context.Context) ([]models.CategoryNode, error)
+	SetTree(ctx context.Context, tree []models.CategoryNode, expiration time.Duration) error
+	InvalidateTree(ctx context.Context) error
+}
+
+// ProductLister - то, что CategoryService использует из сервиса продуктов
+type ProductLister interface {
+	GetProduct(ctx context.Context, id int64) (*models.Product, error)
+	GetProductsByFilter(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error)
+	InvalidateSearch(ctx context.Context)
+}
+
+type CategoryService struct {
+	repo           CategoryRepository
+	tags           TagRepository
+	cache          CategoryCache
+	productService ProductLister
+	txManager      TxManager
+	*cacheSettings
+}
+
+func NewCategoryService(repo CategoryRepository, tags TagRepository, cache CategoryCache, productService ProductLister, txManager TxManager) *CategoryService {
+	return &CategoryService{
+		repo:           repo,
+		tags:           tags,
+		cache:          cache,
+		productService: productService,
+		txManager:      txManager,
+		cacheSettings:  newCacheSettings(),
+	}
+}
+
+// GetTree возвращает дерево категорий из кеша; при промахе строит его по хранилищу
+func (s *CategoryService) GetTree(ctx context.Context) ([]models.CategoryNode, error) {
+	tree, err := s.cache.GetTree(ctx)
+	if err != nil {
+		slog.Warn("Cache error", "error", err)
+	}
+	if tree != nil {
+		return tree, nil
+	}
+
+	categories, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree = models.NewCategoryTree(categories)
+	if err := s.cache.SetTree(ctx, tree, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to cache category tree", "error", err)
+	}
+	return tree, nil
+}
+
+// invalidateTree сбрасывает кешированное дерево после изменения категорий. Подкатегории входят
+// в ключ кеша поиска (ProductSearchQuery.CacheKey), поэтому результаты поиска сбрасывать не нужно.
+func (s *CategoryService) invalidateTree(ctx context.Context) {
+	if err := s.cache.InvalidateTree(ctx); err != nil {
+		slog.Warn("Failed to invalidate category tree cache", "error", err)
+	}
+}
+
+// SubtreeIDs возвращает категорию вместе со всеми подкатегориями; несуществующая - ErrCategoryNotFound
+func (s *CategoryService) SubtreeIDs(ctx context.Context, id int64) ([]int64, error) {
+	tree, err := s.GetTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	node := models.FindCategory(tree, id)
+	if node == nil {
+		return nil, fmt.Errorf("%w: %d", models.ErrCategoryNotFound, id)
+	}
+	return node.SubtreeIDs(), nil
+}
+
+func (s *CategoryService) GetCategory(ctx context.Context, id int64) (*models.Category, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// CreateCategory создает категорию; родитель, если задан, должен существовать
+func (s *CategoryService) CreateCategory(ctx context.Context, category *models.Category) error {
+	var id int64
+	err := s.txManager.Do(ctx, func(ctx context.Context) error {
+		if err := s.checkParent(ctx, category); err != nil {
+			return err
+		}
+		var err error
+		id, err = s.repo.Create(ctx, category)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidateTree(ctx)
+
+	stored, err := s.repo.GetByID(ctx, id)
+	if err != nil || stored == nil {
+		return err
+	}
+	*category = *stored
+	return nil
+}
+
+// UpdateCategory переименовывает категорию и переносит ее к другому родителю. category.Version - ожидаемая
+// версия (0 - без проверки). После обновления category содержит сохраненное состояние; Version = 0 - категории нет.
+func (s *CategoryService) UpdateCategory(ctx context.Context, category *models.Category) error {
+	err := s.txManager.Do(ctx, func(ctx context.Context) error {
+		if err := s.checkParent(ctx, category); err != nil {
+			return err
+		}
+		return s.repo.Update(ctx, category)
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidateTree(ctx)
+
+	stored, err := s.repo.GetByID(ctx, category.ID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		category.Version = 0
+		return nil
+	}
+	*category = *stored
+	return nil
+}
+
+// checkParent проверяет по хранилищу, что родитель существует и категория не переносится в свое поддерево.
+// Вызывается в транзакции изменения: цепочка предков блокируется до фиксации, поэтому параллельный перенос
+// или удаление этих категорий ждет ее (или откатывается взаимной блокировкой и повторяется), а не создает цикл.
+func (s *CategoryService) checkParent(ctx context.Context, category *models.Category) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	seen := make(map[int64]bool)
+	for id := *category.ParentID; ; {
+		if id == category.ID || seen[id] {
+			return models.ErrCategoryCycle
+		}
+		seen[id] = true
+
+		ancestor, err := s.repo.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if ancestor == nil {
+			return fmt.Errorf("%w: %d", models.ErrCategoryNotFound, id)
+		}
+		if ancestor.ParentID == nil {
+			return nil
+		}
+		id = *ancestor.ParentID
+	}
+}
+
+// DeleteCategory удаляет категорию без подкатегорий; товары остаются, теряя только эту категорию
+func (s *CategoryService) DeleteCategory(ctx context.Context, id int64, version int64) error {
+	err := s.txManager.Do(ctx, func(ctx context.Context) error {
+		// Блокировка строки не дает параллельно добавить подкатегорию: вставка проверяет внешний ключ на эту строку
+		if _, err := s.repo.GetByIDForUpdate(ctx, id); err != nil {
+			return err
+		}
+		hasChildren, err := s.repo.HasChildren(ctx, id)
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return models.ErrCategoryNotEmpty
+		}
+		return s.repo.Delete(ctx, id, version)
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidateTree(ctx)
+	s.productService.InvalidateSearch(ctx)
+	return nil
+}
+
+// GetCategoryProducts возвращает товары категории и всех ее подкатегорий
+func (s *CategoryService) GetCategoryProducts(ctx context.Context, id int64) ([]*models.Product, error) {
+	ids, err := s.SubtreeIDs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.productService.GetProductsByFilter(ctx, models.ProductFilter{CategoryIDs: ids})
+}
+
+// GetProductCategories возвращает категории товара; nil - товар не найден
+func (s *CategoryService) GetProductCategories(ctx context.Context, productID int64) ([]*models.Category, error) {
+	if product, err := s.productService.GetProduct(ctx, productID); err != nil || product == nil {
+		return nil, err
+	}
+	return s.repo.GetProductCategories(ctx, productID)
+}
+
+// SetProductCategories заменяет категории товара и возвращает новый набор; nil - товар не найден
+func (s *CategoryService) SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) ([]*models.Category, error) {
+	if product, err := s.productService.GetProduct(ctx, productID); err != nil || product == nil {
+		return nil, err
+	}
+	tree, err := s.GetTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range categoryIDs {
+		if models.FindCategory(tree, id) == nil {
+			return nil, fmt.Errorf("%w: %d", models.ErrCategoryNotFound, id)
+		}
+	}
+
+	err = s.txManager.Do(ctx, func(ctx context.Context) error {
+		return s.repo.SetProductCategories(ctx, productID, categoryIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.productService.InvalidateSearch(ctx)
+	return s.repo.GetProductCategories(ctx, productID)
+}
+
+// GetTags возвращает все используемые теги с числом товаров
+func (s *CategoryService) GetTags(ctx context.Context) ([]models.TagCount, error) {
+	return s.tags.GetAll(ctx)
+}
+
+// GetProductTags возвращает теги товара; nil - товар не найден
+func (s *CategoryService) GetProductTags(ctx context.Context, productID int64) ([]string, error) {
+	if product, err := s.productService.GetProduct(ctx, productID); err != nil || product == nil {
+		return nil, err
+	}
+	return s.tags.GetProductTags(ctx, productID)
+}
+
+// SetProductTags заменяет теги товара (новые теги создаются) и возвращает новый набор; nil - товар не найден
+func (s *CategoryService) SetProductTags(ctx context.Context, productID int64, tags []string) ([]string, error) {
+	if product, err := s.productService.GetProduct(ctx, productID); err != nil || product == nil {
+		return nil, err
+	}
+	err := s.txManager.Do(ctx, func(ctx context.Context) error {
+		return s.tags.SetProductTags(ctx, productID, tags)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.productService.InvalidateSearch(ctx)
+	return s.tags.GetProductTags(ctx, productID)
+}
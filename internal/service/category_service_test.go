@@ -0,0 +1,168 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"testing"
+	"time"
+)
+
+type categoryFixture struct {
+	categories *service.CategoryService
+	repo       *memory.CategoryRepository
+	products   *service.ProductService
+	cache      *memory.CategoryCache
+}
+
+func newCategoryFixture() *categoryFixture {
+	db := memory.NewDB()
+	txManager := memory.NewTxManager(db)
+	products := service.NewProductService(memory.NewProductRepository(db), memory.NewProductCache(), txManager)
+	cache := memory.NewCategoryCache()
+	repo := memory.NewCategoryRepository(db)
+	return &categoryFixture{
+		categories: service.NewCategoryService(repo, memory.NewTagRepository(db), cache, products, txManager),
+		repo:       repo,
+		products:   products,
+		cache:      cache,
+	}
+}
+
+func (f *categoryFixture) create(t *testing.T, name string, parentID *int64) int64 {
+	t.Helper()
+	category := &models.Category{Name: name, ParentID: parentID}
+	if err := f.categories.CreateCategory(context.Background(), category); err != nil {
+		t.Fatalf("CreateCategory(%s): %v", name, err)
+	}
+	return category.ID
+}
+
+func TestCategoryTreeCachedUntilChange(t *testing.T) {
+	f := newCategoryFixture()
+	ctx := context.Background()
+
+	electronics := f.create(t, "Электроника", nil)
+	peripherals := f.create(t, "Периферия", &electronics)
+	mice := f.create(t, "Мыши", &peripherals)
+
+	if _, err := f.categories.GetTree(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := f.cache.GetTree(ctx); len(cached) != 1 {
+		t.Fatalf("дерево не попало в кеш: %+v", cached)
+	}
+	ids, err := f.categories.SubtreeIDs(ctx, electronics)
+	if err != nil || len(ids) != 3 {
+		t.Errorf("SubtreeIDs: (%v, %v), ожидались три категории", ids, err)
+	}
+
+	// Категорию нельзя перенести в ее же поддерево
+	err = f.categories.UpdateCategory(ctx, &models.Category{ID: electronics, Name: "Электроника", ParentID: &mice})
+	if !errors.Is(err, models.ErrCategoryCycle) {
+		t.Errorf("перенос в подкатегорию: %v, ожидался ErrCategoryCycle", err)
+	}
+	if err := f.categories.DeleteCategory(ctx, peripherals, 0); !errors.Is(err, models.ErrCategoryNotEmpty) {
+		t.Errorf("удаление категории с подкатегорией: %v, ожидался ErrCategoryNotEmpty", err)
+	}
+
+	// Перенос сбрасывает кеш дерева
+	if err := f.categories.UpdateCategory(ctx, &models.Category{ID: mice, Name: "Мыши"}); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := f.cache.GetTree(ctx); cached != nil {
+		t.Errorf("после переноса кеш дерева не сброшен: %+v", cached)
+	}
+	tree, err := f.categories.GetTree(ctx)
+	if err != nil || len(tree) != 2 || tree[1].ID != mice {
+		t.Errorf("дерево после переноса в корень: (%+v, %v)", tree, err)
+	}
+	if _, err := f.categories.SubtreeIDs(ctx, 99); !errors.Is(err, models.ErrCategoryNotFound) {
+		t.Errorf("SubtreeIDs несуществующей: %v, ожидался ErrCategoryNotFound", err)
+	}
+}
+
+func TestProductCategoriesResetSearchCache(t *testing.T) {
+	f := newCategoryFixture()
+	ctx := context.Background()
+	f.products.SetSearchSettings(false, time.Hour)
+
+	electronics := f.create(t, "Электроника", nil)
+	peripherals := f.create(t, "Периферия", &electronics)
+	id, err := f.products.CreateProduct(ctx, &models.Product{Name: "Мышь", Price: 900, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := f.categories.SubtreeIDs(ctx, electronics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func() int {
+		t.Helper()
+		query := &models.ProductSearchQuery{Category: electronics, CategoryIDs: ids}
+		if err := query.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		result, err := f.products.SearchProducts(ctx, query)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		return result.Total
+	}
+
+	if total := search(); total != 0 {
+		t.Fatalf("товар без категорий найден в категории: total %d", total)
+	}
+	// Товар подкатегории виден в родительской, кеш поиска сброшен заменой категорий
+	if _, err := f.categories.SetProductCategories(ctx, id, []int64{peripherals}); err != nil {
+		t.Fatal(err)
+	}
+	if total := search(); total != 1 {
+		t.Errorf("после назначения подкатегории: total %d, ожидалось 1", total)
+	}
+
+	if _, err := f.categories.SetProductCategories(ctx, id, []int64{99}); !errors.Is(err, models.ErrCategoryNotFound) {
+		t.Errorf("несуществующая категория: %v, ожидался ErrCategoryNotFound", err)
+	}
+	if categories, err := f.categories.SetProductCategories(ctx, 99, []int64{electronics}); err != nil || categories != nil {
+		t.Errorf("несуществующий товар: (%v, %v), ожидалось (nil, nil)", categories, err)
+	}
+
+	if err := f.categories.DeleteCategory(ctx, peripherals, 0); err != nil {
+		t.Fatal(err)
+	}
+	if total := search(); total != 0 {
+		t.Errorf("после удаления подкатегории: total %d, ожидалось 0", total)
+	}
+}
+
+func TestCategoryChecksIgnoreStaleTree(t *testing.T) {
+	f := newCategoryFixture()
+	ctx := context.Background()
+
+	electronics := f.create(t, "Электроника", nil)
+	peripherals := f.create(t, "Периферия", nil)
+	if _, err := f.categories.GetTree(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Другой экземпляр переносит категорию, а кешированное дерево здесь еще старое
+	if err := f.repo.Update(ctx, &models.Category{ID: peripherals, Name: "Периферия", ParentID: &electronics}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.categories.UpdateCategory(ctx, &models.Category{ID: electronics, Name: "Электроника", ParentID: &peripherals})
+	if !errors.Is(err, models.ErrCategoryCycle) {
+		t.Errorf("перенос в подкатегорию по устаревшему дереву: %v, ожидался ErrCategoryCycle", err)
+	}
+	if err := f.categories.DeleteCategory(ctx, electronics, 0); !errors.Is(err, models.ErrCategoryNotEmpty) {
+		t.Errorf("удаление по устаревшему дереву: %v, ожидался ErrCategoryNotEmpty", err)
+	}
+	missing := int64(99)
+	if err := f.categories.CreateCategory(ctx, &models.Category{Name: "Мыши", ParentID: &missing}); !errors.Is(err, models.ErrCategoryNotFound) {
+		t.Errorf("создание с несуществующим родителем: %v, ожидался ErrCategoryNotFound", err)
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"log/slog"
+	"time"
+)
+
+// PriceRepository - история цен (ее пишет хранилище при изменении цены товара) и запланированные цены
+type PriceRepository interface {
+	// GetHistory возвращает изменения цены товара от новых к старым; ненулевой until отсекает изменения позже него
+	GetHistory(ctx context.Context, productID int64, until time.Time) ([]*models.PriceChange, error)
+	CreateScheduled(ctx context.Context, scheduled *models.ScheduledPrice) (int64, error)
+	GetScheduledByID(ctx context.Context, id int64) (*models.ScheduledPrice, error)
+	// GetScheduled возвращает непримененные цены товара по времени вступления в силу
+	GetScheduled(ctx context.Context, productID int64) ([]*models.ScheduledPrice, error)
+	// DueScheduled возвращает непримененные цены всех товаров с effective_at не позже now
+	DueScheduled(ctx context.Context, now time.Time) ([]*models.ScheduledPrice, error)
+	// NextScheduled возвращает ближайшее время вступления в силу непримененной цены; nil - таких нет
+	NextScheduled(ctx context.Context) (*time.Time, error)
+	// DeleteScheduled удаляет запланированную цену, если она еще не применена
+	DeleteScheduled(ctx context.Context, id int64) error
+	// MarkApplied отмечает цену примененной; false - ее уже применил другой экземпляр приложения
+	MarkApplied(ctx context.Context, id int64, at time.Time) (bool, error)
+}
+
+// ProductPatcher - то, что PriceService использует из сервиса продуктов: смена цены через
+// PatchProduct обновляет кеш товара и сбрасывает кеш поиска
+type ProductPatcher interface {
+	GetProduct(ctx context.Context, id int64) (*models.Product, error)
+	PatchProduct(ctx context.Context, id int64, patch *models.ProductPatch) (*models.Product, error)
+}
+
+// PriceService показывает историю цен и применяет запланированные цены. Фоновая задача
+// (StartScheduler) просыпается к ближайшему effective_at, но не реже чем раз в interval,
+// чтобы подхватить цены, запланированные другими экземплярами приложения.
+type PriceService struct {
+	repo            PriceRepository
+	productService  ProductPatcher
+	txManager       TxManager
+	intervalUpdates chan time.Duration
+	// scheduled будит планировщик после добавления цены, чтобы он пересчитал время пробуждения
+	scheduled chan struct{}
+}
+
+func NewPriceService(repo PriceRepository, productService ProductPatcher, txManager TxManager) *PriceService {
+	return &PriceService{
+		repo:            repo,
+		productService:  productService,
+		txManager:       txManager,
+		intervalUpdates: make(chan time.Duration, 1),
+		scheduled:       make(chan struct{}, 1),
+	}
+}
+
+// PriceHistory возвращает изменения цены товара от новых к старым; ненулевой until оставляет
+// изменения до него включительно, и первая запись - цена, действовавшая в until.
+// nil - товар не найден.
+func (s *PriceService) PriceHistory(ctx context.Context, productID int64, until time.Time) ([]*models.PriceChange, error) {
+	product, err := s.productService.GetProduct(ctx, productID)
+	if err != nil || product == nil {
+		return nil, err
+	}
+	return s.repo.GetHistory(ctx, productID, until)
+}
+
+// ScheduledPrices возвращает непримененные цены товара; nil - товар не найден
+func (s *PriceService) ScheduledPrices(ctx context.Context, productID int64) ([]*models.ScheduledPrice, error) {
+	product, err := s.productService.GetProduct(ctx, productID)
+	if err != nil || product == nil {
+		return nil, err
+	}
+	return s.repo.GetScheduled(ctx, productID)
+}
+
+// SchedulePrice планирует цену товара scheduled.ProductID; при успехе заполняет ID и дату создания.
+// Если товар не найден, scheduled.ID остается нулевым.
+func (s *PriceService) SchedulePrice(ctx context.Context, scheduled *models.ScheduledPrice) error {
+	product, err := s.productService.GetProduct(ctx, scheduled.ProductID)
+	if err != nil || product == nil {
+		return err
+	}
+
+	err = s.txManager.Do(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateScheduled(ctx, scheduled)
+		if err != nil {
+			return err
+		}
+		created, err := s.repo.GetScheduledByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		*scheduled = *created
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Оставляем в канале не больше одного сигнала
+	select {
+	case s.scheduled <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// CancelScheduled отменяет непримененную цену товара productID; false - такой цены нет
+// (не найдена, относится к другому товару или уже применена)
+func (s *PriceService) CancelScheduled(ctx context.Context, productID, id int64) (bool, error) {
+	scheduled, err := s.repo.GetScheduledByID(ctx, id)
+	if err != nil || scheduled == nil || scheduled.ProductID != productID || scheduled.AppliedAt != nil {
+		return false, err
+	}
+	return true, s.repo.DeleteScheduled(ctx, id)
+}
+
+// ApplyDue устанавливает товарам наступившие запланированные цены и возвращает число примененных.
+// Каждая цена применяется в своей транзакции; цену, которую уже забрал другой экземпляр
+// приложения, MarkApplied не отдает повторно. Цена с ошибкой пишется в лог и остается
+// непримененной, а следующие применяются; ошибка сообщает, сколько цен не удалось применить.
+func (s *PriceService) ApplyDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := s.repo.DueScheduled(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	applied, failed := 0, 0
+	for _, scheduled := range due {
+		// Транзакция может повториться, поэтому цена считается примененной только после ее фиксации
+		var claimed bool
+		err := s.txManager.Do(ctx, func(ctx context.Context) error {
+			var err error
+			claimed, err = s.repo.MarkApplied(ctx, scheduled.ID, now)
+			if err != nil || !claimed {
+				return err
+			}
+			price := scheduled.Price
+			_, err = s.productService.PatchProduct(ctx, scheduled.ProductID, &models.ProductPatch{Price: &price})
+			return err
+		})
+		if err != nil {
+			failed++
+			slog.Error("Failed to apply scheduled price", "id", scheduled.ID, "product_id", scheduled.ProductID, "error", err)
+			continue
+		}
+		if claimed {
+			applied++
+		}
+	}
+	if failed > 0 {
+		return applied, fmt.Errorf("не удалось применить запланированных цен: %d", failed)
+	}
+	return applied, nil
+}
+
+// StartScheduler применяет наступившие цены сразу и затем к каждому следующему effective_at,
+// проверяя хранилище не реже чем раз в interval
+func (s *PriceService) StartScheduler(ctx context.Context, interval time.Duration) {
+	timer := time.NewTimer(s.apply(ctx, interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Debug("Price scheduler stopped")
+			return
+		case interval = <-s.intervalUpdates:
+			timer.Reset(s.nextWakeup(ctx, interval))
+		case <-s.scheduled:
+			timer.Reset(s.nextWakeup(ctx, interval))
+		case <-timer.C:
+			timer.Reset(s.apply(ctx, interval))
+		}
+	}
+}
+
+// apply применяет наступившие цены и возвращает, через сколько проснуться в следующий раз
+func (s *PriceService) apply(ctx context.Context, interval time.Duration) time.Duration {
+	count, err := s.ApplyDue(ctx)
+	if count > 0 {
+		slog.Info("Applied scheduled prices", "count", count)
+	}
+	if err != nil {
+		// Не повторяем сразу: цена с ошибкой осталась бы ближайшей и заняла бы цикл
+		slog.Error("Failed to apply scheduled prices", "error", err)
+		return interval
+	}
+	return s.nextWakeup(ctx, interval)
+}
+
+// nextWakeup возвращает время до ближайшей запланированной цены, но не больше interval
+func (s *PriceService) nextWakeup(ctx context.Context, interval time.Duration) time.Duration {
+	next, err := s.repo.NextScheduled(ctx)
+	if err != nil {
+		slog.Error("Failed to get next scheduled price", "error", err)
+		return interval
+	}
+	if next == nil {
+		return interval
+	}
+	return max(min(time.Until(*next), interval), 0)
+}
+
+// SetScheduleInterval меняет период проверки у запущенного StartScheduler
+func (s *PriceService) SetScheduleInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	// Оставляем в канале только последнее значение
+	select {
+	case <-s.intervalUpdates:
+	default:
+	}
+	s.intervalUpdates <- interval
+}
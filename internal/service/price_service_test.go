@@ -0,0 +1,211 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"testing"
+	"time"
+)
+
+type priceFixture struct {
+	prices    *service.PriceService
+	products  *service.ProductService
+	repo      *memory.PriceRepository
+	cache     *memory.ProductCache
+	txManager *memory.TxManager
+	productID int64
+}
+
+func newPriceFixture(t *testing.T) *priceFixture {
+	t.Helper()
+	db := memory.NewDB()
+	txManager := memory.NewTxManager(db)
+	cache := memory.NewProductCache()
+	products := service.NewProductService(memory.NewProductRepository(db), cache, txManager)
+	repo := memory.NewPriceRepository(db)
+
+	productID, err := products.CreateProduct(context.Background(), &models.Product{Name: "Ноутбук", Price: 50000, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &priceFixture{
+		prices:    service.NewPriceService(repo, products, txManager),
+		products:  products,
+		repo:      repo,
+		cache:     cache,
+		txManager: txManager,
+		productID: productID,
+	}
+}
+
+// price возвращает цену товара из кеша и из истории (последнее изменение)
+func (f *priceFixture) price(t *testing.T) (cached, recorded float64) {
+	t.Helper()
+	ctx := context.Background()
+	product, err := f.cache.GetByID(ctx, f.productID)
+	if err != nil || product == nil {
+		t.Fatalf("товар в кеше: (%+v, %v)", product, err)
+	}
+	history, err := f.prices.PriceHistory(ctx, f.productID, time.Time{})
+	if err != nil || len(history) == 0 {
+		t.Fatalf("PriceHistory: (%+v, %v)", history, err)
+	}
+	return product.Price, history[0].Price
+}
+
+func TestApplyDueScheduledPrices(t *testing.T) {
+	f := newPriceFixture(t)
+	ctx := context.Background()
+
+	// Наступившая цена записывается напрямую: через API можно запланировать только будущую
+	if _, err := f.repo.CreateScheduled(ctx, &models.ScheduledPrice{ProductID: f.productID, Price: 45000, EffectiveAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	future := &models.ScheduledPrice{ProductID: f.productID, Price: 40000, EffectiveAt: time.Now().Add(time.Hour)}
+	if err := f.prices.SchedulePrice(ctx, future); err != nil || future.ID == 0 {
+		t.Fatalf("SchedulePrice: (%+v, %v)", future, err)
+	}
+
+	// Товар попадает в кеш до смены цены
+	if _, err := f.products.GetProduct(ctx, f.productID); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := f.prices.ApplyDue(ctx)
+	if err != nil || applied != 1 {
+		t.Fatalf("ApplyDue: (%d, %v), ожидалась одна цена", applied, err)
+	}
+	if cached, recorded := f.price(t); cached != 45000 || recorded != 45000 {
+		t.Errorf("после применения: в кеше %v, в истории %v, ожидалось 45000", cached, recorded)
+	}
+
+	// Примененная цена не применяется повторно и не отменяется, будущая ждет своего времени
+	if applied, err := f.prices.ApplyDue(ctx); err != nil || applied != 0 {
+		t.Errorf("повторный ApplyDue: (%d, %v), ожидалось 0", applied, err)
+	}
+	if cancelled, err := f.prices.CancelScheduled(ctx, f.productID, 1); err != nil || cancelled {
+		t.Errorf("CancelScheduled примененной цены: (%v, %v), ожидалось false", cancelled, err)
+	}
+	scheduled, err := f.prices.ScheduledPrices(ctx, f.productID)
+	if err != nil || len(scheduled) != 1 || scheduled[0].ID != future.ID {
+		t.Errorf("ScheduledPrices: (%+v, %v), ожидалась цена %d", scheduled, err, future.ID)
+	}
+}
+
+// failingPatcher отказывает в смене цены товара productID
+type failingPatcher struct {
+	service.ProductPatcher
+	productID int64
+}
+
+func (p failingPatcher) PatchProduct(ctx context.Context, id int64, patch *models.ProductPatch) (*models.Product, error) {
+	if id == p.productID {
+		return nil, errors.New("patch failed")
+	}
+	return p.ProductPatcher.PatchProduct(ctx, id, patch)
+}
+
+func TestApplyDueContinuesAfterFailedPrice(t *testing.T) {
+	f := newPriceFixture(t)
+	ctx := context.Background()
+
+	brokenID, err := f.products.CreateProduct(ctx, &models.Product{Name: "Планшет", Price: 30000, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Цена с ошибкой наступила раньше и идет первой
+	broken := &models.ScheduledPrice{ProductID: brokenID, Price: 25000, EffectiveAt: time.Now().Add(-2 * time.Minute)}
+	if broken.ID, err = f.repo.CreateScheduled(ctx, broken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.repo.CreateScheduled(ctx, &models.ScheduledPrice{ProductID: f.productID, Price: 45000, EffectiveAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.products.GetProduct(ctx, f.productID); err != nil {
+		t.Fatal(err)
+	}
+
+	prices := service.NewPriceService(f.repo, failingPatcher{ProductPatcher: f.products, productID: brokenID}, f.txManager)
+	applied, err := prices.ApplyDue(ctx)
+	if err == nil || applied != 1 {
+		t.Fatalf("ApplyDue: (%d, %v), ожидалась одна цена и ошибка", applied, err)
+	}
+	if cached, recorded := f.price(t); cached != 45000 || recorded != 45000 {
+		t.Errorf("цена после ошибки: в кеше %v, в истории %v, ожидалось 45000", cached, recorded)
+	}
+
+	// Цена с ошибкой осталась непримененной и применится при следующем запуске
+	scheduled, err := prices.ScheduledPrices(ctx, brokenID)
+	if err != nil || len(scheduled) != 1 || scheduled[0].ID != broken.ID {
+		t.Errorf("ScheduledPrices: (%+v, %v), ожидалась цена %d", scheduled, err, broken.ID)
+	}
+	if applied, err := f.prices.ApplyDue(ctx); err != nil || applied != 1 {
+		t.Errorf("повторный ApplyDue: (%d, %v), ожидалась одна цена", applied, err)
+	}
+}
+
+// failAfterPatch меняет цену товара и затем завершается ошибкой, чтобы транзакция откатила смену цены
+type failAfterPatch struct {
+	service.ProductPatcher
+}
+
+func (p failAfterPatch) PatchProduct(ctx context.Context, id int64, patch *models.ProductPatch) (*models.Product, error) {
+	if _, err := p.ProductPatcher.PatchProduct(ctx, id, patch); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("failed after patch")
+}
+
+func TestApplyDueRolledBackPriceKeepsCache(t *testing.T) {
+	f := newPriceFixture(t)
+	ctx := context.Background()
+
+	if _, err := f.repo.CreateScheduled(ctx, &models.ScheduledPrice{ProductID: f.productID, Price: 45000, EffectiveAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.products.GetProduct(ctx, f.productID); err != nil {
+		t.Fatal(err)
+	}
+
+	prices := service.NewPriceService(f.repo, failAfterPatch{ProductPatcher: f.products}, f.txManager)
+	applied, err := prices.ApplyDue(ctx)
+	if err == nil || applied != 0 {
+		t.Fatalf("ApplyDue: (%d, %v), ожидалась ошибка без примененных цен", applied, err)
+	}
+
+	// Откаченная цена не попадает ни в кеш, ни в хранилище
+	if cached, recorded := f.price(t); cached != 50000 || recorded != 50000 {
+		t.Errorf("после отката: в кеше %v, в истории %v, ожидалось 50000", cached, recorded)
+	}
+	f.cache.Delete(ctx, f.productID)
+	if product, err := f.products.GetProduct(ctx, f.productID); err != nil || product.Price != 50000 {
+		t.Errorf("товар после отката: (%+v, %v), ожидалась цена 50000", product, err)
+	}
+}
+
+func TestPriceSchedulerWakesAtEffectiveTime(t *testing.T) {
+	f := newPriceFixture(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Период проверки больше времени теста: цену применяет пробуждение к effective_at
+	go f.prices.StartScheduler(ctx, time.Hour)
+
+	scheduled := &models.ScheduledPrice{ProductID: f.productID, Price: 39999, EffectiveAt: time.Now().Add(100 * time.Millisecond)}
+	if err := f.prices.SchedulePrice(ctx, scheduled); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if cached, recorded := f.price(t); cached == 39999 && recorded == 39999 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("запланированная цена не применена к effective_at")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
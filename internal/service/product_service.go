@@ -3,7 +3,8 @@ package service
 import (
 	"context"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
-	"log"
+	"github.com/SaveljevRoman/go-layout-project/internal/search"
+	"log/slog"
 	"time"
 )
 
@@ -11,8 +12,17 @@ type ProductRepository interface {
 	GetByID(ctx context.Context, id int64) (*models.Product, error)
 	GetAll(ctx context.Context) ([]*models.Product, error)
 	Create(ctx context.Context, product *models.Product) (int64, error)
+	CreateBatch(ctx context.Context, products []*models.Product) ([]int64, error)
 	Update(ctx context.Context, product *models.Product) error
-	Delete(ctx context.Context, id int64) error
+	Patch(ctx context.Context, id int64, patch *models.ProductPatch) error
+	DecreaseQuantity(ctx context.Context, id int64, quantity int) error
+	Delete(ctx context.Context, id int64, version int64) error
+	ForEach(ctx context.Context, fn func(product *models.Product) error) error
+	GetByName(ctx context.Context, name string) ([]*models.Product, error)
+	// GetByFilter возвращает товары категорий и тега фильтра в порядке id
+	GetByFilter(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error)
+	Search(ctx context.Context, query *models.ProductSearchQuery) (*models.ProductSearchResult, error)
+	SalesCounts(ctx context.Context) (map[int64]int64, error)
 }
 
 type ProductCache interface {
@@ -20,17 +30,37 @@ type ProductCache interface {
 	Set(ctx context.Context, product *models.Product, expiration time.Duration) error
 	Delete(ctx context.Context, id int64) error
 	SetAllProducts(ctx context.Context, products []*models.Product, expiration time.Duration) error
+	SetMany(ctx context.Context, products []*models.Product, expiration time.Duration) error
+	DeleteMany(ctx context.Context, ids []int64) error
+	// Страницы результатов поиска по ключу ProductSearchQuery.CacheKey; nil - в кеше нет
+	GetSearch(ctx context.Context, key string) (*models.ProductSearchResult, error)
+	SetSearch(ctx context.Context, key string, result *models.ProductSearchResult, expiration time.Duration) error
+	// InvalidateSearch делает недействительными все сохраненные результаты поиска
+	InvalidateSearch(ctx context.Context) error
+	// Индекс подсказок по префиксам названий (search.Prefixes). IndexSuggestions добавляет или
+	// переименовывает товары, сохраняя их вес; веса (продажи) задает только RebuildSuggestions,
+	// заменяющий индекс целиком. Suggest возвращает до limit подсказок по убыванию продаж.
+	IndexSuggestions(ctx context.Context, products []*models.Product) error
+	RemoveSuggestions(ctx context.Context, ids []int64) error
+	RebuildSuggestions(ctx context.Context, suggestions []models.ProductSuggestion) error
+	Suggest(ctx context.Context, prefix string, limit int) ([]models.ProductSuggestion, error)
 }
 
 type ProductService struct {
-	repo  ProductRepository
-	cache ProductCache
+	repo      ProductRepository
+	cache     ProductCache
+	txManager TxManager
+	*cacheSettings
+	*searchSettings
 }
 
-func NewProductService(repo ProductRepository, cache ProductCache) *ProductService {
+func NewProductService(repo ProductRepository, cache ProductCache, txManager TxManager) *ProductService {
 	return &ProductService{
-		repo:  repo,
-		cache: cache,
+		repo:           repo,
+		cache:          cache,
+		txManager:      txManager,
+		cacheSettings:  newCacheSettings(),
+		searchSettings: newSearchSettings(),
 	}
 }
 
@@ -38,7 +68,7 @@ func (s *ProductService) GetProduct(ctx context.Context, id int64) (*models.Prod
 	// Сначала пытаемся получить из кеша
 	product, err := s.cache.GetByID(ctx, id)
 	if err != nil {
-		log.Printf("Cache error: %v", err)
+		slog.Warn("Cache error", "error", err)
 	}
 
 	if product != nil {
@@ -52,9 +82,9 @@ func (s *ProductService) GetProduct(ctx context.Context, id int64) (*models.Prod
 	}
 
 	if product != nil {
-		// Кешируем результат на 5 минут
-		if err := s.cache.Set(ctx, product, 5*time.Minute); err != nil {
-			log.Printf("Failed to cache product: %v", err)
+		// Кешируем результат
+		if err := s.cache.Set(ctx, product, s.cacheTTL()); err != nil {
+			slog.Warn("Failed to cache product", "error", err)
 		}
 	}
 
@@ -65,47 +95,320 @@ func (s *ProductService) GetAllProducts(ctx context.Context) ([]*models.Product,
 	return s.repo.GetAll(ctx)
 }
 
+// GetProductsByFilter возвращает товары, ограниченные категориями и тегом; пустой фильтр - все товары
+func (s *ProductService) GetProductsByFilter(ctx context.Context, filter models.ProductFilter) ([]*models.Product, error) {
+	if filter.IsEmpty() {
+		return s.GetAllProducts(ctx)
+	}
+	return s.repo.GetByFilter(ctx, filter)
+}
+
+// ExportProducts передает в fn все товары по одному, не загружая таблицу в память (в отличие от GetAllProducts)
+func (s *ProductService) ExportProducts(ctx context.Context, fn func(product *models.Product) error) error {
+	return s.repo.ForEach(ctx, fn)
+}
+
+// GetProductsByName возвращает товары с указанным названием из хранилища, минуя кеш
+func (s *ProductService) GetProductsByName(ctx context.Context, name string) ([]*models.Product, error) {
+	return s.repo.GetByName(ctx, name)
+}
+
+// searchSnippetLength - длина фрагмента описания в результатах поиска, в символах
+const searchSnippetLength = 160
+
+// SearchProducts ищет товары по тексту запроса с фильтрами и фасетами. Страницы результатов кешируются
+// на SearchCacheTTL и сбрасываются при изменении товаров, а покупкой - только когда товар закончился.
+// Результат, посчитанный одновременно с изменением, живет не дольше TTL.
+func (s *ProductService) SearchProducts(ctx context.Context, query *models.ProductSearchQuery) (*models.ProductSearchResult, error) {
+	query.Terms = search.Terms(query.Query, s.stemming())
+	key := query.CacheKey()
+
+	cached, err := s.cache.GetSearch(ctx, key)
+	if err != nil {
+		slog.Warn("Cache error", "error", err)
+	}
+	if cached != nil {
+		return cached, nil
+	}
+
+	result, err := s.repo.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Items {
+		product := result.Items[i].Product
+		result.Items[i].Highlight = models.ProductHighlight{
+			Name:        search.Highlight(product.Name, query.Terms),
+			Description: search.Snippet(product.Description, query.Terms, searchSnippetLength),
+		}
+	}
+
+	if err := s.cache.SetSearch(ctx, key, result, s.searchCacheTTL()); err != nil {
+		slog.Warn("Failed to cache product search", "error", err)
+	}
+	return result, nil
+}
+
+// InvalidateSearch сбрасывает кешированные результаты поиска после изменения товаров или их категорий и тегов
+func (s *ProductService) InvalidateSearch(ctx context.Context) {
+	if err := s.cache.InvalidateSearch(ctx); err != nil {
+		slog.Warn("Failed to invalidate product search cache", "error", err)
+	}
+}
+
+// SuggestProducts возвращает до limit подсказок для введенного текста: товары, название которых
+// или одно из слов названия начинается с него, по убыванию продаж. Индекс хранится в кеше.
+func (s *ProductService) SuggestProducts(ctx context.Context, query string, limit int) ([]models.ProductSuggestion, error) {
+	prefix := search.SuggestPrefix(query)
+	if prefix == "" {
+		return []models.ProductSuggestion{}, nil
+	}
+	return s.cache.Suggest(ctx, prefix, limit)
+}
+
+// indexSuggestions обновляет подсказки созданных и измененных товаров
+func (s *ProductService) indexSuggestions(ctx context.Context, products ...*models.Product) {
+	if len(products) == 0 {
+		return
+	}
+	if err := s.cache.IndexSuggestions(ctx, products); err != nil {
+		slog.Warn("Failed to index product suggestions", "error", err)
+	}
+}
+
+// removeSuggestions удаляет подсказки удаленных товаров
+func (s *ProductService) removeSuggestions(ctx context.Context, ids ...int64) {
+	if len(ids) == 0 {
+		return
+	}
+	if err := s.cache.RemoveSuggestions(ctx, ids); err != nil {
+		slog.Warn("Failed to remove product suggestions", "error", err)
+	}
+}
+
 func (s *ProductService) CreateProduct(ctx context.Context, product *models.Product) (int64, error) {
 	id, err := s.repo.Create(ctx, product)
 	if err != nil {
 		return 0, err
 	}
 
-	// Обновить продукт с ID
+	// Обновить продукт с ID; новая запись всегда создается с версией 1
 	product.ID = id
-	if err := s.cache.Set(ctx, product, 5*time.Minute); err != nil {
-		log.Printf("Failed to cache product: %v", err)
+	product.Version = 1
+	if err := s.cache.Set(ctx, product, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to cache product", "error", err)
 	}
+	s.InvalidateSearch(ctx)
+	s.indexSuggestions(ctx, product)
 
 	return id, nil
 }
 
+// UpdateProduct перезаписывает запись. product.Version - ожидаемая версия (0 - без проверки),
+// при несовпадении возвращается models.ErrVersionConflict. После обновления product содержит сохраненное состояние.
 func (s *ProductService) UpdateProduct(ctx context.Context, product *models.Product) error {
 	if err := s.repo.Update(ctx, product); err != nil {
 		return err
 	}
 
+	stored, err := s.repo.GetByID(ctx, product.ID)
+	if err != nil || stored == nil {
+		return err
+	}
+	*product = *stored
+
 	// Обновляем кеш
-	if err := s.cache.Set(ctx, product, 5*time.Minute); err != nil {
-		log.Printf("Failed to update product cache: %v", err)
+	if err := s.cache.Set(ctx, product, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to update product cache", "error", err)
 	}
+	s.InvalidateSearch(ctx)
+	s.indexSuggestions(ctx, product)
 
 	return nil
 }
 
-func (s *ProductService) DeleteProduct(ctx context.Context, id int64) error {
-	if err := s.repo.Delete(ctx, id); err != nil {
+// PatchProduct обновляет только переданные поля и возвращает товар после изменения.
+// Если товар не найден, возвращает nil без ошибки.
+func (s *ProductService) PatchProduct(ctx context.Context, id int64, patch *models.ProductPatch) (*models.Product, error) {
+	if err := s.repo.Patch(ctx, id, patch); err != nil {
+		return nil, err
+	}
+
+	product, err := s.repo.GetByID(ctx, id)
+	if err != nil || product == nil {
+		return nil, err
+	}
+	// Пустой патч ничего не записывает, поэтому версию проверяем по прочитанной записи
+	if patch.IsEmpty() && patch.Version != 0 && product.Version != patch.Version {
+		return nil, models.ErrVersionConflict
+	}
+
+	// Обновляем кеш; внутри TxManager.Do - только после фиксации, чтобы откат не оставил в кеше новую цену
+	s.txManager.AfterCommit(ctx, func() {
+		if err := s.cache.Set(ctx, product, s.cacheTTL()); err != nil {
+			slog.Warn("Failed to update product cache", "error", err)
+		}
+		s.InvalidateSearch(ctx)
+		if patch.Name != nil {
+			s.indexSuggestions(ctx, product)
+		}
+	})
+
+	return product, nil
+}
+
+// ReserveStock списывает товар со склада. При вызове внутри TxManager.Do
+// списание откатывается вместе с транзакцией, а кеш сбрасывается только после ее фиксации.
+func (s *ProductService) ReserveStock(ctx context.Context, id int64, quantity int) error {
+	if err := s.repo.DecreaseQuantity(ctx, id, quantity); err != nil {
+		return err
+	}
+	// Списание требует остатка не меньше quantity, поэтому до него товар был в наличии
+	product, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	soldOut := product != nil && product.Quantity == 0
+
+	// Кешированный остаток устарел. Результаты поиска живут search.cache_ttl и терпят устаревший остаток,
+	// а фильтр и фасет наличия меняются, только когда товар закончился
+	s.txManager.AfterCommit(ctx, func() {
+		if err := s.cache.Delete(ctx, id); err != nil {
+			slog.Warn("Failed to delete product from cache", "error", err)
+		}
+		if soldOut {
+			s.InvalidateSearch(ctx)
+		}
+	})
+
+	return nil
+}
+
+// DeleteProduct удаляет запись; version - ожидаемая версия (0 - без проверки)
+func (s *ProductService) DeleteProduct(ctx context.Context, id int64, version int64) error {
+	if err := s.repo.Delete(ctx, id, version); err != nil {
 		return err
 	}
 
 	// Удаляем из кеша
 	if err := s.cache.Delete(ctx, id); err != nil {
-		log.Printf("Failed to delete product from cache: %v", err)
+		slog.Warn("Failed to delete product from cache", "error", err)
 	}
+	s.InvalidateSearch(ctx)
+	s.removeSuggestions(ctx, id)
 
 	return nil
 }
 
+// BulkCreateProducts создает товары пачками (CreateBatch на пачку) и возвращает ошибку
+// каждого товара (nil - создан, ID и Version заполнены). atomic - все или ничего, см. runBulk.
+func (s *ProductService) BulkCreateProducts(ctx context.Context, products []*models.Product, atomic bool) ([]error, error) {
+	errs, err := runBulk(ctx, s.txManager, len(products), atomic,
+		func(ctx context.Context, from, to int) error {
+			ids, err := s.repo.CreateBatch(ctx, products[from:to])
+			if err != nil {
+				return err
+			}
+			for i, id := range ids {
+				products[from+i].ID = id
+			}
+			return nil
+		},
+		func(ctx context.Context, i int) error {
+			id, err := s.repo.Create(ctx, products[i])
+			products[i].ID = id
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Прогреваем кеш созданными товарами одним конвейером, а не запросом на каждый товар
+	now := time.Now()
+	created := make([]*models.Product, 0, len(products))
+	for i, product := range products {
+		if errs[i] != nil {
+			product.ID = 0
+			continue
+		}
+		product.Version = 1
+		product.CreatedAt = now
+		product.UpdatedAt = now
+		created = append(created, product)
+	}
+	if err := s.cache.SetMany(ctx, created, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to warm product cache", "error", err)
+	}
+	if len(created) > 0 {
+		s.InvalidateSearch(ctx)
+	}
+	s.indexSuggestions(ctx, created...)
+
+	return errs, nil
+}
+
+// BulkUpdateProducts перезаписывает товары пачками; Version каждого товара - ожидаемая версия.
+// Для записанных товаров Version увеличивается до сохраненной.
+func (s *ProductService) BulkUpdateProducts(ctx context.Context, products []*models.Product, atomic bool) ([]error, error) {
+	errs, err := runBulk(ctx, s.txManager, len(products), atomic, nil,
+		func(ctx context.Context, i int) error {
+			return s.repo.Update(ctx, products[i])
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Кешированные товары устарели; created_at в запросе нет, поэтому записи удаляются, а не перезаписываются
+	updated := make([]int64, 0, len(products))
+	written := make([]*models.Product, 0, len(products))
+	for i, product := range products {
+		if errs[i] == nil {
+			product.Version++
+			updated = append(updated, product.ID)
+			written = append(written, product)
+		}
+	}
+	if err := s.cache.DeleteMany(ctx, updated); err != nil {
+		slog.Warn("Failed to invalidate product cache", "error", err)
+	}
+	if len(updated) > 0 {
+		s.InvalidateSearch(ctx)
+	}
+	s.indexSuggestions(ctx, written...)
+
+	return errs, nil
+}
+
+// BulkDeleteProducts удаляет товары пачками с проверкой версии каждого
+func (s *ProductService) BulkDeleteProducts(ctx context.Context, items []models.BulkDeleteItem, atomic bool) ([]error, error) {
+	errs, err := runBulk(ctx, s.txManager, len(items), atomic, nil,
+		func(ctx context.Context, i int) error {
+			return s.repo.Delete(ctx, items[i].ID, items[i].Version)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]int64, 0, len(items))
+	for i, item := range items {
+		if errs[i] == nil {
+			deleted = append(deleted, item.ID)
+		}
+	}
+	if err := s.cache.DeleteMany(ctx, deleted); err != nil {
+		slog.Warn("Failed to delete products from cache", "error", err)
+	}
+	if len(deleted) > 0 {
+		s.InvalidateSearch(ctx)
+	}
+	s.removeSuggestions(ctx, deleted...)
+
+	return errs, nil
+}
+
 // Метод для фонового обновления кеша
 func (s *ProductService) StartCacheUpdater(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
@@ -114,8 +417,10 @@ func (s *ProductService) StartCacheUpdater(ctx context.Context, interval time.Du
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Product cache updater stopped")
+			slog.Debug("Product cache updater stopped")
 			return
+		case interval := <-s.intervalUpdates:
+			ticker.Reset(interval)
 		case <-ticker.C:
 			s.updateCache(ctx)
 		}
@@ -123,17 +428,67 @@ func (s *ProductService) StartCacheUpdater(ctx context.Context, interval time.Du
 }
 
 func (s *ProductService) updateCache(ctx context.Context) {
-	log.Println("Updating product cache...")
+	slog.Debug("Updating product cache...")
 	products, err := s.repo.GetAll(ctx)
 	if err != nil {
-		log.Printf("Failed to get products for cache update: %v", err)
+		slog.Error("Failed to get products for cache update", "error", err)
 		return
 	}
 
-	if err := s.cache.SetAllProducts(ctx, products, 10*time.Minute); err != nil {
-		log.Printf("Failed to update products cache: %v", err)
+	if err := s.cache.SetAllProducts(ctx, products, s.cacheRefreshTTL()); err != nil {
+		slog.Warn("Failed to update products cache", "error", err)
 		return
 	}
 
-	log.Printf("Product cache updated with %d products", len(products))
+	slog.Debug("Product cache updated", "products", len(products))
+}
+
+// StartSuggestRebuilder сразу строит индекс подсказок и затем перестраивает его с периодом interval:
+// пересчитывает веса по продажам и исправляет расхождения, если изменение товара не дошло до кеша
+func (s *ProductService) StartSuggestRebuilder(ctx context.Context, interval time.Duration) {
+	s.rebuildSuggestions(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Debug("Product suggest rebuilder stopped")
+			return
+		case interval := <-s.suggestIntervalUpdates:
+			ticker.Reset(interval)
+		case <-ticker.C:
+			s.rebuildSuggestions(ctx)
+		}
+	}
+}
+
+func (s *ProductService) rebuildSuggestions(ctx context.Context) {
+	if err := s.RebuildSuggestions(ctx); err != nil {
+		slog.Error("Failed to rebuild product suggestions", "error", err)
+	}
+}
+
+// RebuildSuggestions заменяет индекс подсказок всеми товарами хранилища с текущими продажами
+func (s *ProductService) RebuildSuggestions(ctx context.Context) error {
+	sales, err := s.repo.SalesCounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	var suggestions []models.ProductSuggestion
+	err = s.repo.ForEach(ctx, func(product *models.Product) error {
+		suggestions = append(suggestions, models.ProductSuggestion{ID: product.ID, Name: product.Name, Sales: sales[product.ID]})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.cache.RebuildSuggestions(ctx, suggestions); err != nil {
+		return err
+	}
+	slog.Debug("Product suggestions rebuilt", "products", len(suggestions))
+	return nil
 }
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSearchProductsCachedUntilChange(t *testing.T) {
+	f := newBulkFixture()
+	ctx := context.Background()
+	f.products.SetSearchSettings(true, time.Hour)
+
+	id, err := f.products.CreateProduct(ctx, &models.Product{Name: "Ноутбук ProBook", Description: "Легкий ноутбук", Price: 50000, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := &models.ProductSearchQuery{Query: "ноутбуки"}
+	if err := query.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	search := func() *models.ProductSearchResult {
+		t.Helper()
+		result, err := f.products.SearchProducts(ctx, query)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		return result
+	}
+
+	result := search()
+	if result.Total != 1 || result.Items[0].Highlight.Description != "Легкий <mark>ноутбук</mark>" {
+		t.Fatalf("поиск со стеммингом: %+v", result)
+	}
+	if cached, _ := f.cache.GetSearch(ctx, query.CacheKey()); cached == nil || cached.Total != 1 {
+		t.Errorf("страница результатов не попала в кеш: %+v", cached)
+	}
+
+	if _, err := f.products.CreateProduct(ctx, &models.Product{Name: "Ноутбук TravelMate", Price: 40000}); err != nil {
+		t.Fatal(err)
+	}
+	if result := search(); result.Total != 2 {
+		t.Errorf("после создания товара: total %d, ожидалось 2 (кеш поиска не сброшен)", result.Total)
+	}
+
+	if err := f.products.DeleteProduct(ctx, id, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := f.cache.GetSearch(ctx, query.CacheKey()); cached != nil {
+		t.Errorf("после удаления товара кеш поиска не сброшен: %+v", cached)
+	}
+	if result := search(); result.Total != 1 {
+		t.Errorf("после удаления товара: total %d, ожидалось 1", result.Total)
+	}
+}
+
+func TestReserveStockInvalidatesSearchWhenSoldOut(t *testing.T) {
+	f := newBulkFixture()
+	ctx := context.Background()
+	f.products.SetSearchSettings(true, time.Hour)
+
+	id, err := f.products.CreateProduct(ctx, &models.Product{Name: "Ноутбук ProBook", Price: 50000, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := &models.ProductSearchQuery{Query: "ноутбук", InStock: true}
+	if err := query.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.products.SearchProducts(ctx, query); err != nil {
+		t.Fatal(err)
+	}
+
+	// Товар остался в наличии: фильтр и фасет не изменились, страница поиска остается в кеше
+	if err := f.products.ReserveStock(ctx, id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := f.cache.GetSearch(ctx, query.CacheKey()); cached == nil {
+		t.Error("покупка без смены наличия сбросила кеш поиска")
+	}
+
+	if err := f.products.ReserveStock(ctx, id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := f.cache.GetSearch(ctx, query.CacheKey()); cached != nil {
+		t.Errorf("товар закончился, а кеш поиска не сброшен: %+v", cached)
+	}
+	if result, err := f.products.SearchProducts(ctx, query); err != nil || result.Total != 0 {
+		t.Errorf("поиск в наличии после распродажи: (%+v, %v)", result, err)
+	}
+}
+
+func TestSuggestionsFollowProductChanges(t *testing.T) {
+	f := newBulkFixture()
+	ctx := context.Background()
+
+	suggest := func(query string) []string {
+		t.Helper()
+		suggestions, err := f.products.SuggestProducts(ctx, query, models.DefaultSuggestLimit)
+		if err != nil {
+			t.Fatalf("SuggestProducts(%q): %v", query, err)
+		}
+		names := make([]string, len(suggestions))
+		for i, suggestion := range suggestions {
+			names[i] = suggestion.Name
+		}
+		return names
+	}
+
+	id, err := f.products.CreateProduct(ctx, &models.Product{Name: "Ноутбук ProBook", Price: 50000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := suggest("Pro"); len(got) != 1 || got[0] != "Ноутбук ProBook" {
+		t.Errorf("после создания: %q", got)
+	}
+
+	name := "Планшет TabMax"
+	if _, err := f.products.PatchProduct(ctx, id, &models.ProductPatch{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	if got := suggest("ноут"); len(got) != 0 {
+		t.Errorf("после переименования по старому названию: %q", got)
+	}
+	if got := suggest("tab"); len(got) != 1 || got[0] != name {
+		t.Errorf("после переименования: %q", got)
+	}
+
+	if err := f.products.DeleteProduct(ctx, id, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := suggest("tab"); len(got) != 0 {
+		t.Errorf("после удаления: %q", got)
+	}
+	if got := suggest(" "); got == nil || len(got) != 0 {
+		t.Errorf("пустой запрос: ожидался пустой список, получено %#v", got)
+	}
+}
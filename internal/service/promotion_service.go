@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"math"
+	"slices"
+	"time"
+)
+
+// PromotionRepository - промокоды, счетчики их применений и правила автоматических скидок
+type PromotionRepository interface {
+	CreateCoupon(ctx context.Context, coupon *models.Coupon) (int64, error)
+	GetCouponByID(ctx context.Context, id int64) (*models.Coupon, error)
+	// GetCouponByCode возвращает промокод code; nil - промокод свободен
+	GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error)
+	GetCoupons(ctx context.Context) ([]*models.Coupon, error)
+	DeleteCoupon(ctx context.Context, id int64) error
+	// ClaimCoupon атомарно увеличивает uses промокода; false - достигнут max_uses
+	ClaimCoupon(ctx context.Context, id int64) (bool, error)
+	// ClaimUserCoupon атомарно увеличивает счетчик применений промокода пользователем; false - достигнут limit
+	ClaimUserCoupon(ctx context.Context, couponID, userID int64, limit int) (bool, error)
+	// AdjustCouponUses прибавляет delta к uses промокода и к счетчику пользователя, не опуская их ниже нуля
+	AdjustCouponUses(ctx context.Context, couponID, userID int64, delta int) error
+	CreateRule(ctx context.Context, rule *models.PromotionRule) (int64, error)
+	GetRuleByID(ctx context.Context, id int64) (*models.PromotionRule, error)
+	GetRules(ctx context.Context) ([]*models.PromotionRule, error)
+	DeleteRule(ctx context.Context, id int64) error
+}
+
+// CategoryProvider - то, что PromotionService использует из сервиса категорий
+type CategoryProvider interface {
+	GetCategory(ctx context.Context, id int64) (*models.Category, error)
+	GetProductCategories(ctx context.Context, productID int64) ([]*models.Category, error)
+	SubtreeIDs(ctx context.Context, id int64) ([]int64, error)
+}
+
+// PromotionService управляет промокодами и правилами скидок и рассчитывает скидки покупки.
+// Сначала применяется самое выгодное подходящее правило, затем промокод - к оставшейся сумме.
+type PromotionService struct {
+	repo       PromotionRepository
+	categories CategoryProvider
+	txManager  TxManager
+}
+
+func NewPromotionService(repo PromotionRepository, categories CategoryProvider, txManager TxManager) *PromotionService {
+	return &PromotionService{
+		repo:       repo,
+		categories: categories,
+		txManager:  txManager,
+	}
+}
+
+func (s *PromotionService) GetCoupons(ctx context.Context) ([]*models.Coupon, error) {
+	return s.repo.GetCoupons(ctx)
+}
+
+func (s *PromotionService) GetCoupon(ctx context.Context, id int64) (*models.Coupon, error) {
+	return s.repo.GetCouponByID(ctx, id)
+}
+
+// CreateCoupon создает промокод; при успехе заполняет ID, счетчик и дату создания.
+// Занятый код - models.ErrDuplicateCoupon.
+func (s *PromotionService) CreateCoupon(ctx context.Context, coupon *models.Coupon) error {
+	return s.txManager.Do(ctx, func(ctx context.Context) error {
+		existing, err := s.repo.GetCouponByCode(ctx, coupon.Code)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return models.ErrDuplicateCoupon
+		}
+		id, err := s.repo.CreateCoupon(ctx, coupon)
+		if err != nil {
+			return err
+		}
+		created, err := s.repo.GetCouponByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		*coupon = *created
+		return nil
+	})
+}
+
+// DeleteCoupon удаляет промокод; false - промокод не найден. Скидки в покупках сохраняются.
+func (s *PromotionService) DeleteCoupon(ctx context.Context, id int64) (bool, error) {
+	coupon, err := s.repo.GetCouponByID(ctx, id)
+	if err != nil || coupon == nil {
+		return false, err
+	}
+	return true, s.repo.DeleteCoupon(ctx, id)
+}
+
+func (s *PromotionService) GetRules(ctx context.Context) ([]*models.PromotionRule, error) {
+	return s.repo.GetRules(ctx)
+}
+
+func (s *PromotionService) GetRule(ctx context.Context, id int64) (*models.PromotionRule, error) {
+	return s.repo.GetRuleByID(ctx, id)
+}
+
+// CreateRule создает правило скидки; категория, если задана, должна существовать
+func (s *PromotionService) CreateRule(ctx context.Context, rule *models.PromotionRule) error {
+	if rule.CategoryID != nil {
+		category, err := s.categories.GetCategory(ctx, *rule.CategoryID)
+		if err != nil {
+			return err
+		}
+		if category == nil {
+			return fmt.Errorf("%w: %d", models.ErrCategoryNotFound, *rule.CategoryID)
+		}
+	}
+
+	return s.txManager.Do(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateRule(ctx, rule)
+		if err != nil {
+			return err
+		}
+		created, err := s.repo.GetRuleByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		*rule = *created
+		return nil
+	})
+}
+
+// DeleteRule удаляет правило; false - правило не найдено
+func (s *PromotionService) DeleteRule(ctx context.Context, id int64) (bool, error) {
+	rule, err := s.repo.GetRuleByID(ctx, id)
+	if err != nil || rule == nil {
+		return false, err
+	}
+	return true, s.repo.DeleteRule(ctx, id)
+}
+
+// ApplyDiscounts рассчитывает скидки покупки, полная стоимость которой записана в purchase.TotalPrice:
+// заполняет purchase.Discounts и уменьшает TotalPrice. Вызывается в транзакции покупки - применение
+// промокода учитывается в его счетчиках и откатывается вместе с покупкой.
+// Промокод проверяется по полной стоимости (min_order_value), а скидка считается от суммы после правила.
+func (s *PromotionService) ApplyDiscounts(ctx context.Context, purchase *models.Purchase, couponCode string) error {
+	subtotal := purchase.TotalPrice
+	discounts := models.PurchaseDiscounts{}
+
+	rule, amount, err := s.bestRule(ctx, purchase, subtotal)
+	if err != nil {
+		return err
+	}
+	if rule != nil && amount > 0 {
+		discounts = append(discounts, models.PurchaseDiscount{Kind: models.DiscountKindRule, ID: rule.ID, Name: rule.Name, Amount: amount})
+	}
+
+	if code := models.NormalizeCouponCode(couponCode); code != "" {
+		coupon, err := s.claimCoupon(ctx, code, purchase.UserID, subtotal)
+		if err != nil {
+			return err
+		}
+		amount := models.DiscountAmount(coupon.Type, coupon.Value, subtotal-discounts.Total())
+		discounts = append(discounts, models.PurchaseDiscount{Kind: models.DiscountKindCoupon, ID: coupon.ID, Name: coupon.Code, Amount: amount})
+	}
+
+	purchase.Discounts = discounts
+	purchase.TotalPrice = math.Max(math.Round((subtotal-discounts.Total())*100)/100, 0)
+	return nil
+}
+
+// RecordStatusChange возвращает применение промокода, когда покупку отменяют, и снова занимает
+// его, когда отмену снимают. Пока покупка была отменена, применение могли занять другие покупки:
+// если лимит промокода исчерпан, снять отмену нельзя - ErrCouponExhausted или ErrCouponUserLimit.
+// Вызывается в транзакции смены статуса.
+func (s *PromotionService) RecordStatusChange(ctx context.Context, previous, purchase *models.Purchase) error {
+	wasCancelled, cancelled := previous.Status == "cancelled", purchase.Status == "cancelled"
+	if wasCancelled == cancelled {
+		return nil
+	}
+	for _, discount := range purchase.Discounts {
+		if discount.Kind != models.DiscountKindCoupon {
+			continue
+		}
+		if cancelled {
+			if err := s.repo.AdjustCouponUses(ctx, discount.ID, purchase.UserID, -1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		coupon, err := s.repo.GetCouponByID(ctx, discount.ID)
+		if err != nil {
+			return err
+		}
+		if coupon == nil {
+			continue // Промокод удален, учитывать применение негде
+		}
+		if err := s.claimUses(ctx, coupon, purchase.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bestRule возвращает подходящее покупке правило с наибольшей скидкой и эту скидку; nil - подходящих нет
+func (s *PromotionService) bestRule(ctx context.Context, purchase *models.Purchase, subtotal float64) (*models.PromotionRule, float64, error) {
+	rules, err := s.repo.GetRules(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		best          *models.PromotionRule
+		bestAmount    float64
+		productCatIDs []int64 // Категории товара загружаются при первом правиле с категорией
+	)
+	for _, rule := range rules {
+		if purchase.Quantity < rule.MinQuantity {
+			continue
+		}
+		if rule.CategoryID != nil {
+			if productCatIDs == nil {
+				categories, err := s.categories.GetProductCategories(ctx, purchase.ProductID)
+				if err != nil {
+					return nil, 0, err
+				}
+				productCatIDs = []int64{}
+				for _, category := range categories {
+					productCatIDs = append(productCatIDs, category.ID)
+				}
+			}
+			subtree, err := s.categories.SubtreeIDs(ctx, *rule.CategoryID)
+			if errors.Is(err, models.ErrCategoryNotFound) {
+				continue // Категорию удалили, правило удалится вместе с ней
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			if !slices.ContainsFunc(productCatIDs, func(id int64) bool { return slices.Contains(subtree, id) }) {
+				continue
+			}
+		}
+		if amount := models.DiscountAmount(rule.Type, rule.Value, subtotal); best == nil || amount > bestAmount {
+			best, bestAmount = rule, amount
+		}
+	}
+	return best, bestAmount, nil
+}
+
+// claimCoupon проверяет промокод для покупки пользователя userID на сумму subtotal и учитывает его применение
+func (s *PromotionService) claimCoupon(ctx context.Context, code string, userID int64, subtotal float64) (*models.Coupon, error) {
+	coupon, err := s.repo.GetCouponByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if coupon == nil {
+		return nil, models.ErrCouponNotFound
+	}
+	if coupon.ExpiresAt != nil && !time.Now().Before(*coupon.ExpiresAt) {
+		return nil, models.ErrCouponExpired
+	}
+	if subtotal < coupon.MinOrderValue {
+		return nil, models.ErrCouponMinOrder
+	}
+
+	if err := s.claimUses(ctx, coupon, userID); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// claimUses учитывает применение промокода пользователем userID. Счетчики увеличиваются условным UPDATE:
+// параллельные покупки не превысят лимиты. Исчерпанный лимит - ErrCouponExhausted или ErrCouponUserLimit.
+func (s *PromotionService) claimUses(ctx context.Context, coupon *models.Coupon, userID int64) error {
+	claimed, err := s.repo.ClaimCoupon(ctx, coupon.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return models.ErrCouponExhausted
+	}
+	if coupon.PerUserLimit != nil {
+		claimed, err := s.repo.ClaimUserCoupon(ctx, coupon.ID, userID, *coupon.PerUserLimit)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return models.ErrCouponUserLimit
+		}
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"testing"
+	"time"
+)
+
+// categorize кладет товар фикстуры в подкатегорию и возвращает id родительской категории
+func (f *purchaseFixture) categorize(t *testing.T) int64 {
+	t.Helper()
+	ctx := context.Background()
+	parent := &models.Category{Name: "Электроника"}
+	if err := f.categories.CreateCategory(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+	child := &models.Category{Name: "Периферия", ParentID: &parent.ID}
+	if err := f.categories.CreateCategory(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.categories.SetProductCategories(ctx, f.productID, []int64{child.ID}); err != nil {
+		t.Fatal(err)
+	}
+	return parent.ID
+}
+
+func (f *purchaseFixture) createCoupon(t *testing.T, coupon *models.Coupon) *models.Coupon {
+	t.Helper()
+	if err := f.promotions.CreateCoupon(context.Background(), coupon); err != nil {
+		t.Fatalf("CreateCoupon: %v", err)
+	}
+	return coupon
+}
+
+func (f *purchaseFixture) createRule(t *testing.T, rule *models.PromotionRule) {
+	t.Helper()
+	if err := f.promotions.CreateRule(context.Background(), rule); err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+}
+
+func TestCreatePurchaseWithDiscounts(t *testing.T) {
+	f := newPurchaseFixture(t, 10)
+	ctx := context.Background()
+	categoryID := f.categorize(t)
+
+	// Из подходящих правил применяется самое выгодное; правило родительской категории действует на подкатегории
+	f.createRule(t, &models.PromotionRule{Name: "Любой товар от 2 штук", MinQuantity: 2, Type: models.DiscountFixed, Value: 20})
+	f.createRule(t, &models.PromotionRule{Name: "Электроника от 3 штук", CategoryID: &categoryID, MinQuantity: 3, Type: models.DiscountPercent, Value: 10})
+	f.createRule(t, &models.PromotionRule{Name: "Оптом", MinQuantity: 5, Type: models.DiscountPercent, Value: 50})
+	coupon := f.createCoupon(t, &models.Coupon{Code: "MINUS100", Type: models.DiscountFixed, Value: 100})
+
+	purchase, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{
+		UserID: f.userID, ProductID: f.productID, Quantity: 3, CouponCode: " minus100 ",
+	})
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	// 450 - 10% (45) по правилу, затем 100 по промокоду от оставшихся 405
+	want := models.PurchaseDiscounts{
+		{Kind: models.DiscountKindRule, ID: 2, Name: "Электроника от 3 штук", Amount: 45},
+		{Kind: models.DiscountKindCoupon, ID: coupon.ID, Name: "MINUS100", Amount: 100},
+	}
+	if purchase.TotalPrice != 305 || len(purchase.Discounts) != 2 || purchase.Discounts[0] != want[0] || purchase.Discounts[1] != want[1] {
+		t.Errorf("покупка: сумма %v, скидки %+v; ожидалось 305, %+v", purchase.TotalPrice, purchase.Discounts, want)
+	}
+	stored, err := f.purchases.GetByID(ctx, purchase.ID)
+	if err != nil || stored.TotalPrice != 305 || len(stored.Discounts) != 2 {
+		t.Errorf("сохраненная покупка: (%+v, %v)", stored, err)
+	}
+
+	// Без правил и промокода скидок нет, список пустой
+	purchase, err = f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1})
+	if err != nil || purchase.TotalPrice != 150 || purchase.Discounts == nil || len(purchase.Discounts) != 0 {
+		t.Errorf("покупка без скидок: (%+v, %v)", purchase, err)
+	}
+
+	// Фиксированная скидка больше суммы не уводит ее в минус
+	f.createCoupon(t, &models.Coupon{Code: "FREE", Type: models.DiscountFixed, Value: 1000})
+	purchase, err = f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1, CouponCode: "FREE"})
+	if err != nil || purchase.TotalPrice != 0 || purchase.Discounts.Total() != 150 {
+		t.Errorf("покупка с промокодом больше суммы: (%+v, %v)", purchase, err)
+	}
+}
+
+func TestCreatePurchaseCouponErrors(t *testing.T) {
+	limit := 1
+	expired := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name   string
+		coupon *models.Coupon
+		// uses - сколько покупок успешно применяют промокод до проверяемой
+		uses int
+		want error
+	}{
+		{"неизвестный", nil, 0, models.ErrCouponNotFound},
+		{"истек", &models.Coupon{Code: "OLD", Type: models.DiscountPercent, Value: 5, ExpiresAt: &expired}, 0, models.ErrCouponExpired},
+		{"минимальная сумма", &models.Coupon{Code: "BIG", Type: models.DiscountPercent, Value: 5, MinOrderValue: 1000}, 0, models.ErrCouponMinOrder},
+		{"исчерпан", &models.Coupon{Code: "ONCE", Type: models.DiscountPercent, Value: 5, MaxUses: &limit}, 1, models.ErrCouponExhausted},
+		{"лимит пользователя", &models.Coupon{Code: "MINE", Type: models.DiscountPercent, Value: 5, PerUserLimit: &limit}, 1, models.ErrCouponUserLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPurchaseFixture(t, 5)
+			ctx := context.Background()
+			code := "UNKNOWN"
+			if tt.coupon != nil {
+				code = f.createCoupon(t, tt.coupon).Code
+			}
+			request := func() *models.PurchaseRequest {
+				return &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1, CouponCode: code}
+			}
+			for i := 0; i < tt.uses; i++ {
+				if _, err := f.service.CreatePurchase(ctx, request()); err != nil {
+					t.Fatalf("покупка %d: %v", i+1, err)
+				}
+			}
+
+			if _, err := f.service.CreatePurchase(ctx, request()); !errors.Is(err, tt.want) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.want)
+			}
+			// Отклоненная покупка не списывает товар и не расходует промокод
+			if got := f.stock(t); got != 5-tt.uses {
+				t.Errorf("остаток %d, ожидалось %d", got, 5-tt.uses)
+			}
+			if tt.coupon != nil {
+				stored, err := f.promotions.GetCoupon(ctx, tt.coupon.ID)
+				if err != nil || stored.Uses != tt.uses {
+					t.Errorf("промокод после отклоненной покупки: (%+v, %v), ожидалось uses %d", stored, err, tt.uses)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelPurchaseReleasesCoupon(t *testing.T) {
+	f := newPurchaseFixture(t, 5)
+	ctx := context.Background()
+	limit := 1
+	coupon := f.createCoupon(t, &models.Coupon{Code: "ONCE", Type: models.DiscountPercent, Value: 5, MaxUses: &limit, PerUserLimit: &limit})
+	request := &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1, CouponCode: coupon.Code}
+	uses := func() int {
+		t.Helper()
+		stored, err := f.promotions.GetCoupon(ctx, coupon.ID)
+		if err != nil || stored == nil {
+			t.Fatalf("GetCoupon: (%+v, %v)", stored, err)
+		}
+		return stored.Uses
+	}
+
+	first, err := f.service.CreatePurchase(ctx, request)
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "cancelled", 0); err != nil {
+		t.Fatalf("UpdatePurchaseStatus: %v", err)
+	}
+	if got := uses(); got != 0 {
+		t.Errorf("uses после отмены: %d, ожидалось 0", got)
+	}
+
+	// Освобождены и max_uses, и лимит пользователя
+	if _, err := f.service.CreatePurchase(ctx, request); err != nil {
+		t.Fatalf("покупка после отмены: %v", err)
+	}
+	// Повторная отмена ничего не возвращает
+	if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "cancelled", 0); err != nil {
+		t.Fatalf("повторная отмена: %v", err)
+	}
+	if got := uses(); got != 1 {
+		t.Errorf("uses после повторной отмены: %d, ожидалось 1", got)
+	}
+}
+
+func TestUncancelPurchaseReclaimsCoupon(t *testing.T) {
+	one := 1
+	tests := []struct {
+		name         string
+		maxUses      *int
+		perUserLimit *int
+		wantErr      error
+	}{
+		{"исчерпан max_uses", &one, nil, models.ErrCouponExhausted},
+		{"исчерпан лимит пользователя", nil, &one, models.ErrCouponUserLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPurchaseFixture(t, 5)
+			ctx := context.Background()
+			coupon := f.createCoupon(t, &models.Coupon{Code: "ONCE", Type: models.DiscountPercent, Value: 5,
+				MaxUses: tt.maxUses, PerUserLimit: tt.perUserLimit})
+			request := &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1, CouponCode: coupon.Code}
+
+			first, err := f.service.CreatePurchase(ctx, request)
+			if err != nil {
+				t.Fatalf("CreatePurchase: %v", err)
+			}
+			if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "cancelled", 0); err != nil {
+				t.Fatalf("UpdatePurchaseStatus: %v", err)
+			}
+			// Освободившееся использование занимает другая покупка
+			second, err := f.service.CreatePurchase(ctx, request)
+			if err != nil {
+				t.Fatalf("покупка после отмены: %v", err)
+			}
+
+			// Промокод на пределе - снять отмену нельзя, статус и счетчик не меняются
+			if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "pending", 0); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("снятие отмены: %v, ожидалась %v", err, tt.wantErr)
+			}
+			stored, err := f.service.GetPurchase(ctx, first.ID)
+			if err != nil || stored == nil {
+				t.Fatalf("GetPurchase: (%+v, %v)", stored, err)
+			}
+			if stored.Status != "cancelled" {
+				t.Errorf("статус после отклоненного снятия отмены: %q, ожидался cancelled", stored.Status)
+			}
+			if got, err := f.promotions.GetCoupon(ctx, coupon.ID); err != nil || got.Uses != 1 {
+				t.Errorf("uses после отклоненного снятия отмены: (%+v, %v), ожидалось 1", got, err)
+			}
+
+			// После отмены другой покупки лимит снова позволяет снять отмену
+			if err := f.service.UpdatePurchaseStatus(ctx, second.ID, "cancelled", 0); err != nil {
+				t.Fatalf("отмена второй покупки: %v", err)
+			}
+			if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "pending", 0); err != nil {
+				t.Fatalf("снятие отмены: %v", err)
+			}
+			if got, err := f.promotions.GetCoupon(ctx, coupon.ID); err != nil || got.Uses != 1 {
+				t.Errorf("uses после снятия отмены: (%+v, %v), ожидалось 1", got, err)
+			}
+		})
+	}
+}
+
+func TestCreateCouponAndRuleErrors(t *testing.T) {
+	f := newPurchaseFixture(t, 1)
+	ctx := context.Background()
+	f.createCoupon(t, &models.Coupon{Code: "SALE", Type: models.DiscountPercent, Value: 5})
+
+	if err := f.promotions.CreateCoupon(ctx, &models.Coupon{Code: "SALE", Type: models.DiscountFixed, Value: 1}); !errors.Is(err, models.ErrDuplicateCoupon) {
+		t.Errorf("повторный промокод: %v, ожидалась ErrDuplicateCoupon", err)
+	}
+	categoryID := int64(99)
+	err := f.promotions.CreateRule(ctx, &models.PromotionRule{Name: "Скидка", CategoryID: &categoryID, MinQuantity: 1, Type: models.DiscountFixed, Value: 1})
+	if !errors.Is(err, models.ErrCategoryNotFound) {
+		t.Errorf("правило с неизвестной категорией: %v, ожидалась ErrCategoryNotFound", err)
+	}
+}
@@ -4,16 +4,24 @@ import (
 	"context"
 	"errors"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
-	"log"
+	"log/slog"
+	"math"
 	"time"
 )
 
 type PurchaseRepository interface {
 	GetByID(ctx context.Context, id int64) (*models.Purchase, error)
+	// GetByIDForUpdate читает покупку и в транзакции блокирует ее до фиксации
+	GetByIDForUpdate(ctx context.Context, id int64) (*models.Purchase, error)
 	GetByUserID(ctx context.Context, userID int64) ([]*models.Purchase, error)
 	Create(ctx context.Context, purchase *models.Purchase) (int64, error)
-	UpdateStatus(ctx context.Context, id int64, status string) error
+	UpdateStatus(ctx context.Context, id int64, status string, version int64) error
 	GetAll(ctx context.Context) ([]*models.Purchase, error)
+	ForEach(ctx context.Context, fn func(purchase *models.Purchase) error) error
+	// ForEachCompleted передает в fn завершенные покупки, измененные начиная с since
+	ForEachCompleted(ctx context.Context, since time.Time, fn func(purchase *models.Purchase) error) error
+	ForEachCreated(ctx context.Context, from, to time.Time, fn func(purchase *models.Purchase) error) error
+	LockCreated(ctx context.Context, from, to time.Time) error
 }
 
 type PurchaseCache interface {
@@ -22,21 +30,71 @@ type PurchaseCache interface {
 	Delete(ctx context.Context, id int64) error
 	SetUserPurchases(ctx context.Context, userID int64, purchases []*models.Purchase, expiration time.Duration) error
 	GetUserPurchases(ctx context.Context, userID int64) ([]*models.Purchase, error)
+	// Дневные продажи для рейтинга популярных товаров (дни - models.SalesDay). AddSales прибавляет
+	// продажи товара к дню (отрицательные - вычитают), RebuildSales заменяет все дни окна,
+	// TopSales складывает days дней до until включительно и возвращает до limit товаров
+	// по убыванию критерия by (models.PopularByUnits или PopularByRevenue); товаров без продаж в нем нет.
+	AddSales(ctx context.Context, day time.Time, sales models.ProductSales) error
+	RebuildSales(ctx context.Context, sales []models.DailySales) error
+	TopSales(ctx context.Context, until time.Time, days int, by string, limit int) ([]models.ProductSales, error)
+}
+
+// UserProvider - то, что PurchaseService использует из сервиса пользователей
+type UserProvider interface {
+	GetUser(ctx context.Context, id int64) (*models.User, error)
+}
+
+// ProductProvider - то, что PurchaseService использует из сервиса продуктов
+type ProductProvider interface {
+	GetProduct(ctx context.Context, id int64) (*models.Product, error)
+	ReserveStock(ctx context.Context, id int64, quantity int) error
+}
+
+// VariantProvider - то, что PurchaseService использует из сервиса вариантов
+type VariantProvider interface {
+	GetVariant(ctx context.Context, id int64) (*models.ProductVariant, error)
+	GetProductVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error)
+	ReserveStock(ctx context.Context, id int64, quantity int) error
+}
+
+// DiscountApplier - то, что PurchaseService использует из сервиса скидок (PromotionService):
+// ApplyDiscounts вызывается в транзакции покупки до ее сохранения, RecordStatusChange - в транзакции смены статуса
+type DiscountApplier interface {
+	ApplyDiscounts(ctx context.Context, purchase *models.Purchase, couponCode string) error
+	RecordStatusChange(ctx context.Context, previous, purchase *models.Purchase) error
+}
+
+// SalesRecorder - то, что PurchaseService сообщает сервису дневных агрегатов (RollupService);
+// вызывается в транзакции, изменившей покупку
+type SalesRecorder interface {
+	RecordCreated(ctx context.Context, purchase *models.Purchase) error
+	RecordStatusChange(ctx context.Context, previous, purchase *models.Purchase) error
 }
 
 type PurchaseService struct {
 	repo           PurchaseRepository
 	cache          PurchaseCache
-	userService    *UserService
-	productService *ProductService
+	userService    UserProvider
+	productService ProductProvider
+	variantService VariantProvider
+	promotions     DiscountApplier
+	rollups        SalesRecorder
+	txManager      TxManager
+	*cacheSettings
 }
 
-func NewPurchaseService(repo PurchaseRepository, cache PurchaseCache, userService *UserService, productService *ProductService) *PurchaseService {
+func NewPurchaseService(repo PurchaseRepository, cache PurchaseCache, userService UserProvider, productService ProductProvider,
+	variantService VariantProvider, promotions DiscountApplier, rollups SalesRecorder, txManager TxManager) *PurchaseService {
 	return &PurchaseService{
 		repo:           repo,
 		cache:          cache,
 		userService:    userService,
 		productService: productService,
+		variantService: variantService,
+		promotions:     promotions,
+		rollups:        rollups,
+		txManager:      txManager,
+		cacheSettings:  newCacheSettings(),
 	}
 }
 
@@ -50,6 +108,24 @@ func (s *PurchaseService) CreatePurchase(ctx context.Context, request *models.Pu
 		return nil, errors.New("пользователь не найден")
 	}
 
+	// Вариант определяет товар, цену и склад, с которого списывается покупка
+	var variant *models.ProductVariant
+	if request.VariantID != 0 {
+		variant, err = s.variantService.GetVariant(ctx, request.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		if variant == nil {
+			return nil, errors.New("вариант товара не найден")
+		}
+		if request.ProductID == 0 {
+			request.ProductID = variant.ProductID
+		}
+		if variant.ProductID != request.ProductID {
+			return nil, models.ErrVariantProductMismatch
+		}
+	}
+
 	// Проверяем существование товара
 	product, err := s.productService.GetProduct(ctx, request.ProductID)
 	if err != nil {
@@ -59,13 +135,27 @@ func (s *PurchaseService) CreatePurchase(ctx context.Context, request *models.Pu
 		return nil, errors.New("товар не найден")
 	}
 
+	// Товар с вариантами покупается только конкретным вариантом
+	price := product.Price
+	if variant != nil {
+		price = variant.Price
+	} else {
+		variants, err := s.variantService.GetProductVariants(ctx, product.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(variants) > 0 {
+			return nil, models.ErrVariantRequired
+		}
+	}
+
 	// Проверяем, что количество товара больше нуля
 	if request.Quantity <= 0 {
 		return nil, errors.New("количество товара должно быть больше нуля")
 	}
 
 	// Рассчитываем общую стоимость
-	totalPrice := product.Price * float64(request.Quantity)
+	totalPrice := price * float64(request.Quantity)
 
 	// Создаем покупку
 	purchase := &models.Purchase{
@@ -75,21 +165,54 @@ func (s *PurchaseService) CreatePurchase(ctx context.Context, request *models.Pu
 		TotalPrice: totalPrice,
 		Status:     "pending",
 	}
+	if variant != nil {
+		purchase.VariantID = &variant.ID
+	}
+
+	// Списываем товар (или его вариант), применяем скидки, сохраняем покупку и учитываем ее
+	// в дневных агрегатах в одной транзакции
+	var id int64
+	err = s.txManager.Do(ctx, func(ctx context.Context) error {
+		// Транзакция может повториться: скидки считаются заново от полной стоимости
+		purchase.TotalPrice = totalPrice
+		if err := s.promotions.ApplyDiscounts(ctx, purchase, request.CouponCode); err != nil {
+			return err
+		}
+
+		var err error
+		if variant != nil {
+			err = s.variantService.ReserveStock(ctx, variant.ID, purchase.Quantity)
+		} else {
+			err = s.productService.ReserveStock(ctx, purchase.ProductID, purchase.Quantity)
+		}
+		if err != nil {
+			return err
+		}
 
-	// Сохраняем в БД
-	id, err := s.repo.Create(ctx, purchase)
+		id, err = s.repo.Create(ctx, purchase)
+		if err != nil {
+			return err
+		}
+		// День агрегата - дата создания, которую проставило хранилище
+		created, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.rollups.RecordCreated(ctx, created)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Обновляем покупку с ID
+	// Обновляем покупку с ID; новая запись всегда создается с версией 1
 	purchase.ID = id
+	purchase.Version = 1
 	purchase.CreatedAt = time.Now()
 	purchase.UpdatedAt = time.Now()
 
 	// Кешируем результат
-	if err := s.cache.Set(ctx, purchase, 5*time.Minute); err != nil {
-		log.Printf("Failed to cache purchase: %v", err)
+	if err := s.cache.Set(ctx, purchase, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to cache purchase", "error", err)
 	}
 
 	// Инвалидируем кеш пользовательских покупок
@@ -102,7 +225,7 @@ func (s *PurchaseService) GetPurchase(ctx context.Context, id int64) (*models.Pu
 	// Сначала пытаемся получить из кеша
 	purchase, err := s.cache.GetByID(ctx, id)
 	if err != nil {
-		log.Printf("Cache error: %v", err)
+		slog.Warn("Cache error", "error", err)
 	}
 
 	if purchase != nil {
@@ -116,9 +239,9 @@ func (s *PurchaseService) GetPurchase(ctx context.Context, id int64) (*models.Pu
 	}
 
 	if purchase != nil {
-		// Кешируем результат на 5 минут
-		if err := s.cache.Set(ctx, purchase, 5*time.Minute); err != nil {
-			log.Printf("Failed to cache purchase: %v", err)
+		// Кешируем результат
+		if err := s.cache.Set(ctx, purchase, s.cacheTTL()); err != nil {
+			slog.Warn("Failed to cache purchase", "error", err)
 		}
 	}
 
@@ -129,7 +252,7 @@ func (s *PurchaseService) GetUserPurchases(ctx context.Context, userID int64) ([
 	// Сначала пытаемся получить из кеша
 	purchases, err := s.cache.GetUserPurchases(ctx, userID)
 	if err != nil {
-		log.Printf("Cache error: %v", err)
+		slog.Warn("Cache error", "error", err)
 	}
 
 	if purchases != nil && len(purchases) > 0 {
@@ -142,46 +265,150 @@ func (s *PurchaseService) GetUserPurchases(ctx context.Context, userID int64) ([
 		return nil, err
 	}
 
-	// Кешируем результат на 5 минут
+	// Кешируем результат
 	if len(purchases) > 0 {
-		if err := s.cache.SetUserPurchases(ctx, userID, purchases, 5*time.Minute); err != nil {
-			log.Printf("Failed to cache user purchases: %v", err)
+		if err := s.cache.SetUserPurchases(ctx, userID, purchases, s.cacheTTL()); err != nil {
+			slog.Warn("Failed to cache user purchases", "error", err)
 		}
 	}
 
 	return purchases, nil
 }
 
-func (s *PurchaseService) UpdatePurchaseStatus(ctx context.Context, id int64, status string) error {
+// UpdatePurchaseStatus меняет статус покупки; version - ожидаемая версия (0 - без проверки)
+func (s *PurchaseService) UpdatePurchaseStatus(ctx context.Context, id int64, status string, version int64) error {
 	// Проверяем допустимость статуса
 	if status != "pending" && status != "completed" && status != "cancelled" {
 		return errors.New("недопустимый статус покупки")
 	}
 
-	// Обновляем статус в БД
-	if err := s.repo.UpdateStatus(ctx, id, status); err != nil {
-		return err
-	}
+	// Прежний статус нужен рейтингу популярных товаров, дневным агрегатам и счетчикам промокодов;
+	// статус, агрегаты и счетчики меняются в одной транзакции. Строка покупки блокируется до фиксации:
+	// иначе две параллельные смены статуса прочитают один и тот же прежний статус и учтут переход дважды
+	var previous, purchase *models.Purchase
+	err := s.txManager.Do(ctx, func(ctx context.Context) error {
+		var err error
+		previous, err = s.repo.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
 
-	// Обновляем кеш
-	purchase, err := s.repo.GetByID(ctx, id)
+		// Обновляем статус в БД
+		if err := s.repo.UpdateStatus(ctx, id, status, version); err != nil {
+			return err
+		}
+
+		purchase, err = s.repo.GetByID(ctx, id)
+		if err != nil || previous == nil || purchase == nil {
+			return err
+		}
+		if err := s.promotions.RecordStatusChange(ctx, previous, purchase); err != nil {
+			return err
+		}
+		return s.rollups.RecordStatusChange(ctx, previous, purchase)
+	})
 	if err != nil {
 		return err
 	}
 
+	// Обновляем кеш
 	if purchase != nil {
-		if err := s.cache.Set(ctx, purchase, 5*time.Minute); err != nil {
-			log.Printf("Failed to update purchase cache: %v", err)
+		if err := s.cache.Set(ctx, purchase, s.cacheTTL()); err != nil {
+			slog.Warn("Failed to update purchase cache", "error", err)
 		}
+		s.recordSales(ctx, previous, purchase)
 	}
 
 	return nil
 }
 
+// recordSales учитывает смену статуса в дневных продажах: завершенная покупка прибавляется к дню
+// завершения, а завершенная раньше и затем отмененная вычитается из того же дня (ее прежний updated_at)
+func (s *PurchaseService) recordSales(ctx context.Context, previous, purchase *models.Purchase) {
+	wasCompleted := previous != nil && previous.Status == "completed"
+	isCompleted := purchase.Status == "completed"
+	if wasCompleted == isCompleted {
+		return
+	}
+
+	day, sign := purchase.UpdatedAt, 1
+	if wasCompleted {
+		day, sign = previous.UpdatedAt, -1
+	}
+	sales := models.ProductSales{
+		ProductID: purchase.ProductID,
+		Units:     int64(sign * purchase.Quantity),
+		Revenue:   float64(sign) * purchase.TotalPrice,
+	}
+	if err := s.cache.AddSales(ctx, day, sales); err != nil {
+		slog.Error("Failed to record product sales", "error", err)
+	}
+}
+
+// PopularProducts возвращает рейтинг товаров по завершенным покупкам за query.Days дней, включая сегодняшний.
+// Удаленные товары пропускаются, поэтому строк может быть меньше query.Limit.
+func (s *PurchaseService) PopularProducts(ctx context.Context, query *models.PopularQuery) ([]models.PopularProduct, error) {
+	top, err := s.cache.TopSales(ctx, time.Now(), query.Days, query.By, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	popular := make([]models.PopularProduct, 0, len(top))
+	for _, sales := range top {
+		product, err := s.productService.GetProduct(ctx, sales.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if product == nil {
+			continue
+		}
+		popular = append(popular, models.PopularProduct{
+			Rank:    len(popular) + 1,
+			Product: product,
+			Units:   sales.Units,
+			Revenue: math.Round(sales.Revenue*100) / 100, // Сумма копеек в float накапливает погрешность
+		})
+	}
+	return popular, nil
+}
+
+// RebuildSales пересчитывает дневные продажи окна рейтинга по хранилищу. Вызывается при старте:
+// кеш мог быть пуст или пропустить изменения, пока приложение не работало.
+func (s *PurchaseService) RebuildSales(ctx context.Context) error {
+	type dayProduct struct {
+		day       time.Time
+		productID int64
+	}
+	since := models.SalesDay(time.Now()).AddDate(0, 0, -models.MaxPopularDays)
+	index := make(map[dayProduct]int)
+	var sales []models.DailySales
+	err := s.repo.ForEachCompleted(ctx, since, func(purchase *models.Purchase) error {
+		key := dayProduct{day: models.SalesDay(purchase.UpdatedAt), productID: purchase.ProductID}
+		i, ok := index[key]
+		if !ok {
+			i = len(sales)
+			index[key] = i
+			sales = append(sales, models.DailySales{Day: key.day, ProductSales: models.ProductSales{ProductID: key.productID}})
+		}
+		sales[i].Units += int64(purchase.Quantity)
+		sales[i].Revenue += purchase.TotalPrice
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.cache.RebuildSales(ctx, sales)
+}
+
 func (s *PurchaseService) GetAllPurchases(ctx context.Context) ([]*models.Purchase, error) {
 	return s.repo.GetAll(ctx)
 }
 
+// ExportPurchases передает в fn все покупки по одной, не загружая таблицу в память
+func (s *PurchaseService) ExportPurchases(ctx context.Context, fn func(purchase *models.Purchase) error) error {
+	return s.repo.ForEach(ctx, fn)
+}
+
 // StartCacheUpdater Метод для фонового обновления кеша покупок
 func (s *PurchaseService) StartCacheUpdater(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
@@ -190,8 +417,10 @@ func (s *PurchaseService) StartCacheUpdater(ctx context.Context, interval time.D
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Purchase cache updater stopped")
+			slog.Debug("Purchase cache updater stopped")
 			return
+		case interval := <-s.intervalUpdates:
+			ticker.Reset(interval)
 		case <-ticker.C:
 			s.updateCache(ctx)
 		}
@@ -199,7 +428,7 @@ func (s *PurchaseService) StartCacheUpdater(ctx context.Context, interval time.D
 }
 
 func (s *PurchaseService) updateCache(ctx context.Context) {
-	log.Println("Updating purchase cache...")
+	slog.Debug("Updating purchase cache...")
 	// В данной реализации мы просто логируем событие обновления кеша
 	// Полная реализация может включать получение всех покупок и их кеширование
 	// Но это может быть ресурсоемко, поэтому лучше кешировать по мере запросов
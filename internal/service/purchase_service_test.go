@@ -0,0 +1,308 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"testing"
+)
+
+type purchaseFixture struct {
+	service        *service.PurchaseService
+	db             *memory.DB
+	userService    *service.UserService
+	productService *service.ProductService
+	variantService *service.VariantService
+	rollupService  *service.RollupService
+	promotions     *service.PromotionService
+	categories     *service.CategoryService
+	products       *memory.ProductRepository
+	variants       *memory.VariantRepository
+	purchases      *memory.PurchaseRepository
+	rollups        *memory.RollupRepository
+	userID         int64
+	productID      int64
+}
+
+func newPurchaseFixture(t *testing.T, stock int) *purchaseFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	db := memory.NewDB()
+	users := memory.NewUserRepository(db)
+	products := memory.NewProductRepository(db)
+	purchases := memory.NewPurchaseRepository(db)
+
+	txManager := memory.NewTxManager(db)
+	userService := service.NewUserService(users, memory.NewUserCache(), txManager)
+	productService := service.NewProductService(products, memory.NewProductCache(), txManager)
+	variants := memory.NewVariantRepository(db)
+	variantService := service.NewVariantService(variants, productService, txManager)
+	rollups := memory.NewRollupRepository(db)
+	rollupService := service.NewRollupService(purchases, rollups, txManager)
+	categoryService := service.NewCategoryService(memory.NewCategoryRepository(db), memory.NewTagRepository(db), memory.NewCategoryCache(), productService, txManager)
+	promotionService := service.NewPromotionService(memory.NewPromotionRepository(db), categoryService, txManager)
+
+	userID, err := users.Create(ctx, &models.User{Username: "buyer", Email: "buyer@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID, err := products.Create(ctx, &models.Product{Name: "Товар", Price: 150, Quantity: stock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &purchaseFixture{
+		service:        service.NewPurchaseService(purchases, memory.NewPurchaseCache(), userService, productService, variantService, promotionService, rollupService, txManager),
+		db:             db,
+		userService:    userService,
+		productService: productService,
+		variantService: variantService,
+		rollupService:  rollupService,
+		promotions:     promotionService,
+		categories:     categoryService,
+		products:       products,
+		variants:       variants,
+		purchases:      purchases,
+		rollups:        rollups,
+		userID:         userID,
+		productID:      productID,
+	}
+}
+
+func (f *purchaseFixture) stock(t *testing.T) int {
+	t.Helper()
+	product, err := f.products.GetByID(context.Background(), f.productID)
+	if err != nil || product == nil {
+		t.Fatalf("GetByID: (%+v, %v)", product, err)
+	}
+	return product.Quantity
+}
+
+func TestCreatePurchase(t *testing.T) {
+	f := newPurchaseFixture(t, 5)
+
+	purchase, err := f.service.CreatePurchase(context.Background(), &models.PurchaseRequest{
+		UserID: f.userID, ProductID: f.productID, Quantity: 2,
+	})
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+
+	if purchase.ID == 0 || purchase.TotalPrice != 300 || purchase.Status != "pending" {
+		t.Errorf("CreatePurchase: получено %+v", purchase)
+	}
+	if got := f.stock(t); got != 3 {
+		t.Errorf("остаток %d, ожидалось 3", got)
+	}
+}
+
+func TestCreatePurchaseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(f *purchaseFixture) *models.PurchaseRequest
+		wantErr error
+	}{
+		{
+			name: "недостаточно товара",
+			request: func(f *purchaseFixture) *models.PurchaseRequest {
+				return &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 6}
+			},
+			wantErr: models.ErrInsufficientStock,
+		},
+		{
+			name: "пользователь не найден",
+			request: func(f *purchaseFixture) *models.PurchaseRequest {
+				return &models.PurchaseRequest{UserID: f.userID + 100, ProductID: f.productID, Quantity: 1}
+			},
+		},
+		{
+			name: "товар не найден",
+			request: func(f *purchaseFixture) *models.PurchaseRequest {
+				return &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID + 100, Quantity: 1}
+			},
+		},
+		{
+			name: "нулевое количество",
+			request: func(f *purchaseFixture) *models.PurchaseRequest {
+				return &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 0}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPurchaseFixture(t, 5)
+
+			_, err := f.service.CreatePurchase(context.Background(), tt.request(f))
+			if err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ошибка %v, ожидалась %v", err, tt.wantErr)
+			}
+
+			if got := f.stock(t); got != 5 {
+				t.Errorf("остаток изменился: %d, ожидалось 5", got)
+			}
+			if all, _ := f.purchases.GetAll(context.Background()); len(all) != 0 {
+				t.Errorf("покупка не должна сохраняться: %+v", all)
+			}
+		})
+	}
+}
+
+// Покупка варианта списывает его остаток и берет его цену; остаток самого товара не меняется
+func TestCreateVariantPurchase(t *testing.T) {
+	f := newPurchaseFixture(t, 5)
+	ctx := context.Background()
+
+	variant := &models.ProductVariant{ProductID: f.productID, SKU: "SKU-RED", Attributes: models.VariantAttributes{"color": "красный"}, Price: 200, Quantity: 3}
+	if err := f.variantService.CreateVariant(ctx, variant); err != nil {
+		t.Fatalf("CreateVariant: %v", err)
+	}
+	if err := f.variantService.CreateVariant(ctx, &models.ProductVariant{ProductID: f.productID, SKU: "SKU-RED", Price: 1}); !errors.Is(err, models.ErrDuplicateSKU) {
+		t.Errorf("повторный артикул: %v, ожидался ErrDuplicateSKU", err)
+	}
+
+	// Без варианта товар с вариантами купить нельзя
+	if _, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1}); !errors.Is(err, models.ErrVariantRequired) {
+		t.Errorf("покупка без варианта: %v, ожидался ErrVariantRequired", err)
+	}
+
+	purchase, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, VariantID: variant.ID, Quantity: 2})
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	if purchase.ProductID != f.productID || purchase.VariantID == nil || *purchase.VariantID != variant.ID || purchase.TotalPrice != 400 {
+		t.Errorf("покупка варианта: %+v", purchase)
+	}
+
+	if _, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, VariantID: variant.ID, Quantity: 2}); !errors.Is(err, models.ErrInsufficientStock) {
+		t.Errorf("покупка сверх остатка варианта: %v, ожидался ErrInsufficientStock", err)
+	}
+	stored, err := f.variants.GetByID(ctx, variant.ID)
+	if err != nil || stored.Quantity != 1 {
+		t.Errorf("остаток варианта: (%+v, %v), ожидалось 1", stored, err)
+	}
+	if got := f.stock(t); got != 5 {
+		t.Errorf("остаток товара %d, ожидалось 5", got)
+	}
+
+	// Удаление варианта оставляет покупку без ссылки на него
+	if err := f.variantService.DeleteVariant(ctx, variant.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := f.purchases.GetByID(ctx, purchase.ID)
+	if err != nil || stale.VariantID != nil {
+		t.Errorf("покупка после удаления варианта: (%+v, %v)", stale, err)
+	}
+}
+
+// Если сохранение покупки падает, списание товара откатывается вместе с транзакцией
+func TestCreatePurchaseRollsBackStock(t *testing.T) {
+	f := newPurchaseFixture(t, 5)
+	failing := service.NewPurchaseService(failingPurchaseRepository{f.purchases}, memory.NewPurchaseCache(),
+		f.userService, f.productService, f.variantService, f.promotions, f.rollupService, memory.NewTxManager(f.db))
+
+	if _, err := failing.CreatePurchase(context.Background(), &models.PurchaseRequest{
+		UserID: f.userID, ProductID: f.productID, Quantity: 2,
+	}); !errors.Is(err, errCreateFailed) {
+		t.Fatalf("ожидалась ошибка сохранения, получено %v", err)
+	}
+
+	if got := f.stock(t); got != 5 {
+		t.Errorf("остаток %d, ожидалось 5 после отката", got)
+	}
+}
+
+var errCreateFailed = errors.New("create failed")
+
+type failingPurchaseRepository struct {
+	*memory.PurchaseRepository
+}
+
+func (r failingPurchaseRepository) Create(ctx context.Context, purchase *models.Purchase) (int64, error) {
+	return 0, errCreateFailed
+}
+
+func TestUpdatePurchaseStatus(t *testing.T) {
+	f := newPurchaseFixture(t, 5)
+	ctx := context.Background()
+
+	purchase, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.service.UpdatePurchaseStatus(ctx, purchase.ID, "shipped", 0); err == nil {
+		t.Error("ожидалась ошибка для недопустимого статуса")
+	}
+
+	if err := f.service.UpdatePurchaseStatus(ctx, purchase.ID, "cancelled", purchase.Version+1); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("ожидалась ErrVersionConflict для чужой версии, получено %v", err)
+	}
+
+	if err := f.service.UpdatePurchaseStatus(ctx, purchase.ID, "completed", purchase.Version); err != nil {
+		t.Fatalf("UpdatePurchaseStatus: %v", err)
+	}
+	got, err := f.service.GetPurchase(ctx, purchase.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "completed" || got.Version != purchase.Version+1 {
+		t.Errorf("статус %q версия %d, ожидался completed с версией %d", got.Status, got.Version, purchase.Version+1)
+	}
+}
+
+func TestPopularProducts(t *testing.T) {
+	f := newPurchaseFixture(t, 10)
+	ctx := context.Background()
+
+	popular := func() []models.PopularProduct {
+		t.Helper()
+		query := &models.PopularQuery{}
+		if err := query.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		ranking, err := f.service.PopularProducts(ctx, query)
+		if err != nil {
+			t.Fatalf("PopularProducts: %v", err)
+		}
+		return ranking
+	}
+
+	purchase, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranking := popular(); len(ranking) != 0 {
+		t.Errorf("незавершенная покупка попала в рейтинг: %+v", ranking)
+	}
+
+	if err := f.service.UpdatePurchaseStatus(ctx, purchase.ID, "completed", 0); err != nil {
+		t.Fatalf("UpdatePurchaseStatus: %v", err)
+	}
+	ranking := popular()
+	if len(ranking) != 1 || ranking[0].Rank != 1 || ranking[0].Product.ID != f.productID ||
+		ranking[0].Units != 3 || ranking[0].Revenue != 450 {
+		t.Fatalf("рейтинг после завершения: %+v", ranking)
+	}
+
+	// Перестроение по репозиторию дает тот же рейтинг
+	if err := f.service.RebuildSales(ctx); err != nil {
+		t.Fatalf("RebuildSales: %v", err)
+	}
+	if rebuilt := popular(); len(rebuilt) != 1 || rebuilt[0].Units != 3 || rebuilt[0].Revenue != 450 {
+		t.Errorf("рейтинг после перестроения: %+v", rebuilt)
+	}
+
+	if err := f.service.UpdatePurchaseStatus(ctx, purchase.ID, "cancelled", 0); err != nil {
+		t.Fatalf("UpdatePurchaseStatus: %v", err)
+	}
+	if ranking := popular(); len(ranking) != 0 {
+		t.Errorf("отмененная покупка осталась в рейтинге: %+v", ranking)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+)
+
+// PurchaseHistory - то, что RecommendationService читает из репозитория покупок
+type PurchaseHistory interface {
+	GetByUserID(ctx context.Context, userID int64) ([]*models.Purchase, error)
+	ForEach(ctx context.Context, fn func(purchase *models.Purchase) error) error
+}
+
+// CoPurchaseCache хранит совместные покупки. RebuildCoPurchases заменяет все наборы;
+// GetCoPurchases возвращает наборы запрошенных товаров (товаров без набора в ответе нет).
+type CoPurchaseCache interface {
+	RebuildCoPurchases(ctx context.Context, coPurchases map[int64][]models.CoPurchase) error
+	GetCoPurchases(ctx context.Context, productIDs []int64) (map[int64][]models.CoPurchase, error)
+}
+
+// StockChecker сообщает, есть ли товар в наличии (с учетом вариантов)
+type StockChecker interface {
+	InStock(ctx context.Context, product *models.Product) (bool, error)
+}
+
+// RecommendationService рекомендует товары по совместным покупкам. Статистика считается
+// фоновой задачей (StartRebuilder) по всем покупкам и хранится в кеше; запросы читают только кеш.
+type RecommendationService struct {
+	purchases       PurchaseHistory
+	cache           CoPurchaseCache
+	userService     UserProvider
+	productService  ProductGetter
+	stock           StockChecker
+	intervalUpdates chan time.Duration
+}
+
+func NewRecommendationService(purchases PurchaseHistory, cache CoPurchaseCache, userService UserProvider, productService ProductGetter, stock StockChecker) *RecommendationService {
+	return &RecommendationService{
+		purchases:       purchases,
+		cache:           cache,
+		userService:     userService,
+		productService:  productService,
+		stock:           stock,
+		intervalUpdates: make(chan time.Duration, 1),
+	}
+}
+
+// ProductRecommendations возвращает до limit товаров, которые покупают вместе с productID;
+// nil - товар не найден
+func (s *RecommendationService) ProductRecommendations(ctx context.Context, productID int64, limit int) ([]models.Recommendation, error) {
+	product, err := s.productService.GetProduct(ctx, productID)
+	if err != nil || product == nil {
+		return nil, err
+	}
+
+	coPurchases, err := s.cache.GetCoPurchases(ctx, []int64{productID})
+	if err != nil {
+		return nil, err
+	}
+	return s.recommend(ctx, coPurchases, map[int64]bool{productID: true}, limit)
+}
+
+// UserRecommendations возвращает до limit товаров, которые покупают вместе с купленными
+// пользователем; сами купленные товары не рекомендуются. nil - пользователь не найден.
+func (s *RecommendationService) UserRecommendations(ctx context.Context, userID int64, limit int) ([]models.Recommendation, error) {
+	user, err := s.userService.GetUser(ctx, userID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	purchases, err := s.purchases.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	bought := make(map[int64]bool)
+	var productIDs []int64
+	for _, purchase := range purchases {
+		if purchase.Status == "cancelled" || bought[purchase.ProductID] {
+			continue
+		}
+		bought[purchase.ProductID] = true
+		productIDs = append(productIDs, purchase.ProductID)
+	}
+
+	coPurchases, err := s.cache.GetCoPurchases(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	return s.recommend(ctx, coPurchases, bought, limit)
+}
+
+// recommend складывает веса наборов и возвращает до limit товаров в наличии по убыванию веса
+// (при равенстве - по id), пропуская exclude и удаленные товары
+func (s *RecommendationService) recommend(ctx context.Context, coPurchases map[int64][]models.CoPurchase, exclude map[int64]bool, limit int) ([]models.Recommendation, error) {
+	scores := make(map[int64]int64)
+	for _, list := range coPurchases {
+		for _, co := range list {
+			if !exclude[co.ProductID] {
+				scores[co.ProductID] += co.Count
+			}
+		}
+	}
+	ranked := sortCoPurchases(scores)
+
+	recommendations := make([]models.Recommendation, 0, min(limit, len(ranked)))
+	for _, co := range ranked {
+		if len(recommendations) == limit {
+			break
+		}
+		product, err := s.productService.GetProduct(ctx, co.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if product == nil {
+			continue
+		}
+		inStock, err := s.stock.InStock(ctx, product)
+		if err != nil {
+			return nil, err
+		}
+		if !inStock {
+			continue
+		}
+		recommendations = append(recommendations, models.Recommendation{Product: product, Score: co.Count})
+	}
+	return recommendations, nil
+}
+
+// sortCoPurchases упорядочивает товары по убыванию числа покупателей, при равенстве - по id
+func sortCoPurchases(counts map[int64]int64) []models.CoPurchase {
+	list := make([]models.CoPurchase, 0, len(counts))
+	for id, count := range counts {
+		list = append(list, models.CoPurchase{ProductID: id, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].ProductID < list[j].ProductID
+	})
+	return list
+}
+
+// RebuildRecommendations пересчитывает совместные покупки по всем покупкам хранилища
+// и заменяет ими кеш. На каждый товар хранится до models.MaxCoPurchases товаров.
+// Корзина пользователя - до models.MaxBasketProducts последних купленных им разных товаров.
+func (s *RecommendationService) RebuildRecommendations(ctx context.Context) error {
+	baskets := make(map[int64][]int64)
+	err := s.purchases.ForEach(ctx, func(purchase *models.Purchase) error {
+		if purchase.Status == "cancelled" {
+			return nil
+		}
+		// Покупки идут в порядке id, поэтому повторно купленный товар переносится в конец как самый свежий
+		basket := slices.DeleteFunc(baskets[purchase.UserID], func(id int64) bool { return id == purchase.ProductID })
+		basket = append(basket, purchase.ProductID)
+		if len(basket) > models.MaxBasketProducts {
+			basket = slices.Delete(basket, 0, len(basket)-models.MaxBasketProducts)
+		}
+		baskets[purchase.UserID] = basket
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[int64]map[int64]int64)
+	for _, basket := range baskets {
+		for _, a := range basket {
+			for _, b := range basket {
+				if a == b {
+					continue
+				}
+				if counts[a] == nil {
+					counts[a] = make(map[int64]int64)
+				}
+				counts[a][b]++
+			}
+		}
+	}
+
+	coPurchases := make(map[int64][]models.CoPurchase, len(counts))
+	for id, related := range counts {
+		list := sortCoPurchases(related)
+		coPurchases[id] = list[:min(len(list), models.MaxCoPurchases)]
+	}
+	return s.cache.RebuildCoPurchases(ctx, coPurchases)
+}
+
+// StartRebuilder сразу считает совместные покупки и затем пересчитывает их с периодом interval
+func (s *RecommendationService) StartRebuilder(ctx context.Context, interval time.Duration) {
+	s.rebuild(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Debug("Recommendation rebuilder stopped")
+			return
+		case interval := <-s.intervalUpdates:
+			ticker.Reset(interval)
+		case <-ticker.C:
+			s.rebuild(ctx)
+		}
+	}
+}
+
+func (s *RecommendationService) rebuild(ctx context.Context) {
+	if err := s.RebuildRecommendations(ctx); err != nil {
+		slog.Error("Failed to rebuild recommendations", "error", err)
+	}
+}
+
+// SetRebuildInterval меняет период пересчета у запущенного StartRebuilder
+func (s *RecommendationService) SetRebuildInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	// Оставляем в канале только последнее значение
+	select {
+	case <-s.intervalUpdates:
+	default:
+	}
+	s.intervalUpdates <- interval
+}
@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"testing"
+)
+
+// recommended сводит рекомендации к парам id:вес
+func recommended(recommendations []models.Recommendation) string {
+	pairs := make([]string, len(recommendations))
+	for i, r := range recommendations {
+		pairs[i] = fmt.Sprintf("%d:%d", r.Product.ID, r.Score)
+	}
+	return fmt.Sprint(pairs)
+}
+
+func TestRecommendations(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	txManager := memory.NewTxManager(db)
+	users := memory.NewUserRepository(db)
+	products := memory.NewProductRepository(db)
+	purchases := memory.NewPurchaseRepository(db)
+	userService := service.NewUserService(users, memory.NewUserCache(), txManager)
+	productService := service.NewProductService(products, memory.NewProductCache(), txManager)
+	variants := memory.NewVariantRepository(db)
+	variantService := service.NewVariantService(variants, productService, txManager)
+	recommendations := service.NewRecommendationService(purchases, memory.NewPurchaseCache(), userService, productService, variantService)
+
+	var userIDs [3]int64
+	for i := range userIDs {
+		id, err := users.Create(ctx, &models.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		userIDs[i] = id
+	}
+	// C нет в наличии: остаток товара с вариантами считается по вариантам, а они закончились.
+	// D, наоборот, в наличии благодаря варианту.
+	var a, b, c, d int64
+	for _, p := range []struct {
+		id           *int64
+		stock        int
+		variantStock []int
+	}{{&a, 5, nil}, {&b, 5, nil}, {&c, 5, []int{0}}, {&d, 0, []int{0, 3}}} {
+		id, err := products.Create(ctx, &models.Product{Name: "Товар", Price: 10, Quantity: p.stock})
+		if err != nil {
+			t.Fatal(err)
+		}
+		*p.id = id
+		for i, stock := range p.variantStock {
+			variant := &models.ProductVariant{ProductID: id, SKU: fmt.Sprintf("SKU-%d-%d", id, i), Price: 10, Quantity: stock}
+			if _, err := variants.Create(ctx, variant); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, purchase := range []*models.Purchase{
+		{UserID: userIDs[0], ProductID: a, Status: "completed"},
+		{UserID: userIDs[0], ProductID: b, Status: "pending"},
+		{UserID: userIDs[0], ProductID: c, Status: "completed"},
+		{UserID: userIDs[1], ProductID: a, Status: "completed"},
+		{UserID: userIDs[1], ProductID: b, Status: "completed"},
+		{UserID: userIDs[2], ProductID: a, Status: "completed"},
+		{UserID: userIDs[2], ProductID: d, Status: "completed"},
+		// Отмененная покупка не считается ни совместной, ни купленной
+		{UserID: userIDs[2], ProductID: b, Status: "cancelled"},
+	} {
+		purchase.Quantity, purchase.TotalPrice = 1, 10
+		if _, err := purchases.Create(ctx, purchase); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := recommendations.RebuildRecommendations(ctx); err != nil {
+		t.Fatalf("RebuildRecommendations: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		get  func() ([]models.Recommendation, error)
+		want string
+	}{
+		{"товар", func() ([]models.Recommendation, error) { return recommendations.ProductRecommendations(ctx, a, 10) }, fmt.Sprintf("[%d:2 %d:1]", b, d)},
+		{"товар с limit", func() ([]models.Recommendation, error) { return recommendations.ProductRecommendations(ctx, a, 1) }, fmt.Sprintf("[%d:2]", b)},
+		{"пользователь", func() ([]models.Recommendation, error) {
+			return recommendations.UserRecommendations(ctx, userIDs[1], 10)
+		}, fmt.Sprintf("[%d:1]", d)},
+		{"пользователь с отмененной покупкой", func() ([]models.Recommendation, error) {
+			return recommendations.UserRecommendations(ctx, userIDs[2], 10)
+		}, fmt.Sprintf("[%d:2]", b)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recommended(got) != tt.want {
+				t.Errorf("получено %s, ожидалось %s", recommended(got), tt.want)
+			}
+		})
+	}
+
+	if got, err := recommendations.ProductRecommendations(ctx, a+100, 10); err != nil || got != nil {
+		t.Errorf("несуществующий товар: (%v, %v), ожидалось (nil, nil)", got, err)
+	}
+	if got, err := recommendations.UserRecommendations(ctx, userIDs[2]+100, 10); err != nil || got != nil {
+		t.Errorf("несуществующий пользователь: (%v, %v), ожидалось (nil, nil)", got, err)
+	}
+}
+
+func TestRebuildRecommendationsCapsBasket(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	txManager := memory.NewTxManager(db)
+	users := memory.NewUserRepository(db)
+	products := memory.NewProductRepository(db)
+	purchases := memory.NewPurchaseRepository(db)
+	productService := service.NewProductService(products, memory.NewProductCache(), txManager)
+	variantService := service.NewVariantService(memory.NewVariantRepository(db), productService, txManager)
+	recommendations := service.NewRecommendationService(purchases, memory.NewPurchaseCache(),
+		service.NewUserService(users, memory.NewUserCache(), txManager), productService, variantService)
+
+	userID, err := users.Create(ctx, &models.User{Username: "buyer", Email: "buyer@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Пользователь купил на один товар больше, чем помещается в корзину; первый товар купил еще раз в конце
+	ids := make([]int64, models.MaxBasketProducts+2)
+	for i := range ids {
+		if ids[i], err = products.Create(ctx, &models.Product{Name: fmt.Sprintf("Товар %d", i), Price: 10, Quantity: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range append(ids, ids[0]) {
+		if _, err := purchases.Create(ctx, &models.Purchase{UserID: userID, ProductID: id, Quantity: 1, TotalPrice: 10, Status: "completed"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := recommendations.RebuildRecommendations(ctx); err != nil {
+		t.Fatalf("RebuildRecommendations: %v", err)
+	}
+	// Из корзины выпадают самые давние покупки: ids[1] и ids[2]; повторная покупка ids[0] его оставляет
+	for _, tt := range []struct {
+		id   int64
+		want int
+	}{{ids[0], models.MaxBasketProducts - 1}, {ids[1], 0}, {ids[2], 0}, {ids[3], models.MaxBasketProducts - 1}} {
+		got, err := recommendations.ProductRecommendations(ctx, tt.id, models.MaxRecommendationLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("товар %d: %d рекомендаций, ожидалось %d", tt.id, len(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RollupReader - то, что ReportService читает из дневных агрегатов покупок
+type RollupReader interface {
+	ProductRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error)
+	UserRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error)
+}
+
+// ReportCache хранит готовые отчеты по ключу ReportQuery.CacheKey; nil - в кеше нет
+type ReportCache interface {
+	GetReport(ctx context.Context, key string) (*models.SalesReport, error)
+	SetReport(ctx context.Context, key string, report *models.SalesReport, expiration time.Duration) error
+}
+
+// ReportService считает отчеты о продажах по дневным агрегатам покупок (RollupService), не читая
+// сами покупки. Готовый отчет кешируется на cache_ttl: покупки, сделанные за это время, попадут
+// в отчет после истечения кеша.
+type ReportService struct {
+	rollups        RollupReader
+	cache          ReportCache
+	userService    UserProvider
+	productService ProductGetter
+	*cacheSettings
+}
+
+func NewReportService(rollups RollupReader, cache ReportCache, userService UserProvider, productService ProductGetter) *ReportService {
+	return &ReportService{
+		rollups:        rollups,
+		cache:          cache,
+		userService:    userService,
+		productService: productService,
+		cacheSettings:  newCacheSettings(),
+	}
+}
+
+// SalesReport возвращает отчет по проверенному запросу (ReportQuery.Validate)
+func (s *ReportService) SalesReport(ctx context.Context, query *models.ReportQuery) (*models.SalesReport, error) {
+	key := query.CacheKey()
+	report, err := s.cache.GetReport(ctx, key)
+	if err != nil {
+		slog.Warn("Cache error", "error", err)
+	}
+	if report != nil {
+		return report, nil
+	}
+
+	report, err = s.buildReport(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cache.SetReport(ctx, key, report, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to cache sales report", "error", err)
+	}
+	return report, nil
+}
+
+func (s *ReportService) buildReport(ctx context.Context, query *models.ReportQuery) (*models.SalesReport, error) {
+	report := &models.SalesReport{
+		From: query.From.Format(models.ReportDateLayout),
+		To:   query.To.Format(models.ReportDateLayout),
+		By:   query.By,
+		Rows: []models.ReportRow{},
+	}
+
+	groups := make(map[string]*models.ReportRow)
+	var keys []string
+	// Периоды без покупок тоже попадают в отчет, чтобы ряд по датам был непрерывным
+	if isPeriodGroup(query.By) {
+		for day := query.From; !day.After(query.To); day = day.AddDate(0, 0, 1) {
+			key := periodKey(query.By, day)
+			if groups[key] == nil {
+				groups[key] = &models.ReportRow{Key: key}
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	// Агрегаты пользователей и товаров содержат одни и те же покупки, поэтому итоги совпадают
+	rollups := s.rollups.ProductRollups
+	if query.By == models.ReportByUser {
+		rollups = s.rollups.UserRollups
+	}
+	days, err := rollups(ctx, query.From, query.To.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	for _, rollup := range days {
+		addToTotals(&report.Totals, rollup)
+
+		var key string
+		switch query.By {
+		case models.ReportByProduct:
+			key = strconv.FormatInt(rollup.ProductID, 10)
+		case models.ReportByUser:
+			key = strconv.FormatInt(rollup.UserID, 10)
+		default:
+			key = periodKey(query.By, rollup.Day)
+		}
+		row := groups[key]
+		if row == nil {
+			row = &models.ReportRow{Key: key}
+			groups[key] = row
+			keys = append(keys, key)
+		}
+		addToTotals(&row.ReportTotals, rollup)
+	}
+
+	finishTotals(&report.Totals)
+	for _, key := range keys {
+		row := groups[key]
+		finishTotals(&row.ReportTotals)
+		report.Rows = append(report.Rows, *row)
+	}
+
+	if isPeriodGroup(query.By) {
+		sort.Slice(report.Rows, func(i, j int) bool { return report.Rows[i].Key < report.Rows[j].Key })
+		return report, nil
+	}
+
+	// Товары и пользователи - по убыванию выручки, затем числа покупок
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		if a.Orders != b.Orders {
+			return a.Orders > b.Orders
+		}
+		idA, _ := strconv.ParseInt(a.Key, 10, 64)
+		idB, _ := strconv.ParseInt(b.Key, 10, 64)
+		return idA < idB
+	})
+	if query.Limit > 0 && len(report.Rows) > query.Limit {
+		report.Rows = report.Rows[:query.Limit]
+	}
+	if err := s.labelRows(ctx, query.By, report.Rows); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// labelRows подписывает строки названием товара или именем пользователя; удаленные остаются без подписи
+func (s *ReportService) labelRows(ctx context.Context, by string, rows []models.ReportRow) error {
+	for i := range rows {
+		id, err := strconv.ParseInt(rows[i].Key, 10, 64)
+		if err != nil {
+			return err
+		}
+		if by == models.ReportByProduct {
+			product, err := s.productService.GetProduct(ctx, id)
+			if err != nil {
+				return err
+			}
+			if product != nil {
+				rows[i].Label = product.Name
+			}
+			continue
+		}
+		user, err := s.userService.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			rows[i].Label = user.Username
+		}
+	}
+	return nil
+}
+
+func isPeriodGroup(by string) bool {
+	return by == models.ReportByDay || by == models.ReportByWeek || by == models.ReportByMonth
+}
+
+// periodKey - начало периода, в который попадает t (UTC): день, неделя с понедельника или месяц
+func periodKey(by string, t time.Time) string {
+	day := t.UTC().Truncate(24 * time.Hour)
+	switch by {
+	case models.ReportByWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7).Format(models.ReportDateLayout)
+	case models.ReportByMonth:
+		return day.Format("2006-01")
+	default:
+		return day.Format(models.ReportDateLayout)
+	}
+}
+
+func addToTotals(totals *models.ReportTotals, rollup models.SalesRollup) {
+	totals.Orders += rollup.Orders
+	totals.Completed += rollup.Completed
+	totals.Cancelled += rollup.Cancelled
+	totals.Units += rollup.Units
+	totals.Revenue += rollup.Revenue
+}
+
+// finishTotals считает средний чек и долю отмен и округляет суммы до копеек
+func finishTotals(totals *models.ReportTotals) {
+	totals.Revenue = roundTo(totals.Revenue, 2)
+	if totals.Completed > 0 {
+		totals.AverageOrderValue = roundTo(totals.Revenue/float64(totals.Completed), 2)
+	}
+	if totals.Orders > 0 {
+		totals.CancellationRate = roundTo(float64(totals.Cancelled)/float64(totals.Orders), 4)
+	}
+}
+
+func roundTo(value float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"github.com/SaveljevRoman/go-layout-project/internal/repository/memory"
+	"github.com/SaveljevRoman/go-layout-project/internal/service"
+	"testing"
+	"time"
+)
+
+// reportPurchases - источник покупок с заданным временем создания для пересчета агрегатов
+type reportPurchases []*models.Purchase
+
+func (p reportPurchases) ForEachCreated(ctx context.Context, from, to time.Time, fn func(purchase *models.Purchase) error) error {
+	for _, purchase := range p {
+		if !purchase.CreatedAt.Before(from) && purchase.CreatedAt.Before(to) {
+			if err := fn(purchase); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p reportPurchases) LockCreated(ctx context.Context, from, to time.Time) error {
+	return nil
+}
+
+func date(value string) time.Time {
+	t, err := time.Parse(models.ReportDateLayout, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// reportRows сводит строки к ключ:покупки/выручка
+func reportRows(report *models.SalesReport) string {
+	rows := make([]string, len(report.Rows))
+	for i, row := range report.Rows {
+		rows[i] = fmt.Sprintf("%s:%d/%v", row.Key, row.Orders, row.Revenue)
+	}
+	return fmt.Sprint(rows)
+}
+
+func TestSalesReport(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	txManager := memory.NewTxManager(db)
+	userService := service.NewUserService(memory.NewUserRepository(db), memory.NewUserCache(), txManager)
+	productService := service.NewProductService(memory.NewProductRepository(db), memory.NewProductCache(), txManager)
+	cache := memory.NewPurchaseCache()
+
+	// Среда 2026-09-30 и пятница 2026-10-02 - одна неделя, но разные месяцы; 2026-10-06 - следующая неделя
+	purchases := reportPurchases{
+		{ID: 1, UserID: 1, ProductID: 1, Quantity: 2, TotalPrice: 100.1, Status: "completed", CreatedAt: date("2026-09-30").Add(23 * time.Hour)},
+		{ID: 2, UserID: 2, ProductID: 1, Quantity: 1, TotalPrice: 50, Status: "cancelled", CreatedAt: date("2026-10-02")},
+		{ID: 3, UserID: 2, ProductID: 2, Quantity: 1, TotalPrice: 200.2, Status: "completed", CreatedAt: date("2026-10-02").Add(time.Hour)},
+		{ID: 4, UserID: 1, ProductID: 2, Quantity: 5, TotalPrice: 999, Status: "pending", CreatedAt: date("2026-10-06")},
+		// За пределами диапазона
+		{ID: 5, UserID: 1, ProductID: 1, Quantity: 1, TotalPrice: 1000, Status: "completed", CreatedAt: date("2026-10-07")},
+	}
+	// Отчет читает дневные агрегаты, поэтому они пересчитываются по покупкам, как командой rollup-backfill
+	rollups := memory.NewRollupRepository(db)
+	if _, err := service.NewRollupService(purchases, rollups, txManager).Backfill(ctx, date("2026-09-01"), date("2026-10-31")); err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	reports := service.NewReportService(rollups, cache, userService, productService)
+
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{models.ReportByDay, "[2026-09-30:1/100.1 2026-10-01:0/0 2026-10-02:2/200.2 2026-10-03:0/0 2026-10-04:0/0 2026-10-05:0/0 2026-10-06:1/0]"},
+		{models.ReportByWeek, "[2026-09-28:3/300.3 2026-10-05:1/0]"},
+		{models.ReportByMonth, "[2026-09:1/100.1 2026-10:3/200.2]"},
+		{models.ReportByProduct, "[2:2/200.2 1:2/100.1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			query := &models.ReportQuery{From: date("2026-09-30"), To: date("2026-10-06"), By: tt.by}
+			if err := query.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			report, err := reports.SalesReport(ctx, query)
+			if err != nil {
+				t.Fatalf("SalesReport: %v", err)
+			}
+			if got := reportRows(report); got != tt.want {
+				t.Errorf("строки %s, ожидалось %s", got, tt.want)
+			}
+
+			totals := report.Totals
+			if totals.Orders != 4 || totals.Completed != 2 || totals.Cancelled != 1 || totals.Units != 3 ||
+				totals.Revenue != 300.3 || totals.AverageOrderValue != 150.15 || totals.CancellationRate != 0.25 {
+				t.Errorf("итоги: %+v", totals)
+			}
+		})
+	}
+
+	// Отчет берется из кеша до истечения cache_ttl
+	query := &models.ReportQuery{From: date("2026-09-30"), To: date("2026-10-06"), By: models.ReportByUser, Limit: 1}
+	if err := query.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := reports.SalesReport(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reportRows(first); got != "[2:2/200.2]" {
+		t.Errorf("лучший покупатель: %s", got)
+	}
+	cached, err := cache.GetReport(ctx, query.CacheKey())
+	if err != nil || cached == nil || reportRows(cached) != reportRows(first) {
+		t.Errorf("отчет не попал в кеш: (%+v, %v)", cached, err)
+	}
+}
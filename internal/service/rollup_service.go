@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"time"
+)
+
+// RollupSource - то, что RollupService читает из репозитория покупок при пересчете
+type RollupSource interface {
+	// ForEachCreated передает в fn покупки, созданные в [from, to)
+	ForEachCreated(ctx context.Context, from, to time.Time, fn func(purchase *models.Purchase) error) error
+	// LockCreated в транзакции запрещает до ее фиксации создавать покупки в [from, to) и менять их статус
+	LockCreated(ctx context.Context, from, to time.Time) error
+}
+
+// RollupRepository - дневные агрегаты покупок по товарам и пользователям (models.SalesRollup)
+type RollupRepository interface {
+	// Apply прибавляет дельты к агрегатам товара и пользователя каждой строки, создавая недостающие строки
+	Apply(ctx context.Context, deltas []models.SalesRollup) error
+	// Replace удаляет агрегаты дней [from, to) и записывает rollups
+	Replace(ctx context.Context, from, to time.Time, rollups []models.SalesRollup) error
+	// ProductRollups и UserRollups возвращают агрегаты дней [from, to) по дню и id
+	ProductRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error)
+	UserRollups(ctx context.Context, from, to time.Time) ([]models.SalesRollup, error)
+}
+
+// RollupService ведет дневные агрегаты покупок: PurchaseService сообщает о новых покупках и смене
+// статуса в своей транзакции, а Backfill пересчитывает дни по таблице покупок (после обновления
+// схемы или ручной правки покупок).
+type RollupService struct {
+	purchases RollupSource
+	repo      RollupRepository
+	txManager TxManager
+}
+
+func NewRollupService(purchases RollupSource, repo RollupRepository, txManager TxManager) *RollupService {
+	return &RollupService{
+		purchases: purchases,
+		repo:      repo,
+		txManager: txManager,
+	}
+}
+
+// RecordCreated учитывает новую покупку в агрегатах дня ее создания
+func (s *RollupService) RecordCreated(ctx context.Context, purchase *models.Purchase) error {
+	return s.repo.Apply(ctx, []models.SalesRollup{models.RollupOf(purchase)})
+}
+
+// RecordStatusChange переносит покупку в агрегатах из прежнего статуса в новый; число покупок
+// и день (дата создания) не меняются
+func (s *RollupService) RecordStatusChange(ctx context.Context, previous, purchase *models.Purchase) error {
+	if previous.Status == purchase.Status {
+		return nil
+	}
+	delta := models.RollupOf(purchase)
+	delta.Sub(models.RollupOf(previous))
+	return s.repo.Apply(ctx, []models.SalesRollup{delta})
+}
+
+// Backfill пересчитывает агрегаты дней from..to включительно (даты UTC; нулевой from - с начала
+// истории) по покупкам и возвращает число учтенных покупок. Дни заменяются в одной транзакции,
+// а покупки этих дней блокируются до ее фиксации: иначе дельта покупки, созданной или измененной
+// между чтением и заменой, потерялась бы или учлась дважды. Сервер при этом может работать -
+// оформление покупок за эти дни просто ждет конца пересчета.
+func (s *RollupService) Backfill(ctx context.Context, from, to time.Time) (int, error) {
+	type dayProductUser struct {
+		day       time.Time
+		productID int64
+		userID    int64
+	}
+	from, to = models.SalesDay(from), models.SalesDay(to).AddDate(0, 0, 1)
+
+	var count int
+	err := s.txManager.Do(ctx, func(ctx context.Context) error {
+		if err := s.purchases.LockCreated(ctx, from, to); err != nil {
+			return err
+		}
+
+		count = 0
+		index := make(map[dayProductUser]int)
+		var rollups []models.SalesRollup
+		err := s.purchases.ForEachCreated(ctx, from, to, func(purchase *models.Purchase) error {
+			count++
+			rollup := models.RollupOf(purchase)
+			key := dayProductUser{day: rollup.Day, productID: rollup.ProductID, userID: rollup.UserID}
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(rollups)
+				rollups = append(rollups, rollup)
+				return nil
+			}
+			rollups[i].Add(rollup)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return s.repo.Replace(ctx, from, to, rollups)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,82 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+	"testing"
+	"time"
+)
+
+func TestRollups(t *testing.T) {
+	f := newPurchaseFixture(t, 10)
+	ctx := context.Background()
+	today := models.SalesDay(time.Now())
+
+	// rollups сводит агрегаты сегодняшнего дня к покупки/завершены/отменены/единицы/выручка
+	rollups := func() string {
+		t.Helper()
+		products, err := f.rollups.ProductRollups(ctx, today, today.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		users, err := f.rollups.UserRollups(ctx, today, today.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(products) != 1 || len(users) != 1 || products[0].ProductID != f.productID || users[0].UserID != f.userID {
+			t.Fatalf("агрегаты товаров %+v и пользователей %+v, ожидалось по одной строке", products, users)
+		}
+		p, u := products[0], users[0]
+		if p.Orders != u.Orders || p.Completed != u.Completed || p.Cancelled != u.Cancelled || p.Units != u.Units || p.Revenue != u.Revenue {
+			t.Errorf("агрегаты товара %+v и пользователя %+v расходятся", p, u)
+		}
+		return fmt.Sprintf("%d/%d/%d/%d/%v", p.Orders, p.Completed, p.Cancelled, p.Units, p.Revenue)
+	}
+
+	first, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := f.service.CreatePurchase(ctx, &models.PurchaseRequest{UserID: f.userID, ProductID: f.productID, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rollups(); got != "2/0/0/0/0" {
+		t.Errorf("после создания покупок: %s", got)
+	}
+
+	if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "completed", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.UpdatePurchaseStatus(ctx, second.ID, "cancelled", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := rollups(); got != "2/1/1/2/300" {
+		t.Errorf("после смены статусов: %s", got)
+	}
+
+	// Конфликт версий не меняет ни покупку, ни агрегаты
+	if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "cancelled", 1); !errors.Is(err, models.ErrVersionConflict) {
+		t.Fatalf("ожидалась ErrVersionConflict, получено %v", err)
+	}
+	if err := f.service.UpdatePurchaseStatus(ctx, first.ID, "cancelled", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := rollups(); got != "2/0/2/0/0" {
+		t.Errorf("после отмены завершенной покупки: %s", got)
+	}
+
+	// Пересчет заменяет испорченные агрегаты значениями по покупкам
+	if err := f.rollups.Apply(ctx, []models.SalesRollup{{Day: today, ProductID: f.productID, UserID: f.userID, Orders: 5, Revenue: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	count, err := f.rollupService.Backfill(ctx, today, today)
+	if err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	if got := rollups(); count != 2 || got != "2/0/2/0/0" {
+		t.Errorf("после пересчета: %s по %d покупкам", got, count)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCacheTTL        = 5 * time.Minute
+	defaultCacheRefreshTTL = 10 * time.Minute
+	defaultSearchCacheTTL  = time.Minute
+)
+
+// cacheSettings - параметры кеширования сервиса, которые можно менять без перезапуска
+type cacheSettings struct {
+	ttl             atomic.Int64
+	refreshTTL      atomic.Int64
+	intervalUpdates chan time.Duration
+}
+
+func newCacheSettings() *cacheSettings {
+	s := &cacheSettings{
+		intervalUpdates: make(chan time.Duration, 1),
+	}
+	s.ttl.Store(int64(defaultCacheTTL))
+	s.refreshTTL.Store(int64(defaultCacheRefreshTTL))
+	return s
+}
+
+// SetCacheTTL задает время жизни записей, кешируемых при чтении/записи (ttl)
+// и при фоновом обновлении (refreshTTL)
+func (s *cacheSettings) SetCacheTTL(ttl, refreshTTL time.Duration) {
+	if ttl > 0 {
+		s.ttl.Store(int64(ttl))
+	}
+	if refreshTTL > 0 {
+		s.refreshTTL.Store(int64(refreshTTL))
+	}
+}
+
+// SetCacheUpdateInterval меняет период фонового обновления кеша у запущенного StartCacheUpdater
+func (s *cacheSettings) SetCacheUpdateInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	// Оставляем в канале только последнее значение
+	select {
+	case <-s.intervalUpdates:
+	default:
+	}
+	s.intervalUpdates <- interval
+}
+
+func (s *cacheSettings) cacheTTL() time.Duration {
+	return time.Duration(s.ttl.Load())
+}
+
+func (s *cacheSettings) cacheRefreshTTL() time.Duration {
+	return time.Duration(s.refreshTTL.Load())
+}
+
+// searchSettings - параметры поиска товаров, которые можно менять без перезапуска
+type searchSettings struct {
+	noStemming             atomic.Bool
+	ttl                    atomic.Int64
+	suggestIntervalUpdates chan time.Duration
+}
+
+func newSearchSettings() *searchSettings {
+	s := &searchSettings{
+		suggestIntervalUpdates: make(chan time.Duration, 1),
+	}
+	s.ttl.Store(int64(defaultSearchCacheTTL))
+	return s
+}
+
+// SetSearchSettings включает стемминг русских слов запроса и задает время жизни кешированных результатов
+func (s *searchSettings) SetSearchSettings(stemming bool, cacheTTL time.Duration) {
+	s.noStemming.Store(!stemming)
+	if cacheTTL > 0 {
+		s.ttl.Store(int64(cacheTTL))
+	}
+}
+
+// SetSuggestRebuildInterval меняет период перестроения индекса подсказок у запущенного StartSuggestRebuilder
+func (s *searchSettings) SetSuggestRebuildInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	// Оставляем в канале только последнее значение
+	select {
+	case <-s.suggestIntervalUpdates:
+	default:
+	}
+	s.suggestIntervalUpdates <- interval
+}
+
+func (s *searchSettings) stemming() bool {
+	return !s.noStemming.Load()
+}
+
+func (s *searchSettings) searchCacheTTL() time.Duration {
+	return time.Duration(s.ttl.Load())
+}
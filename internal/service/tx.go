@@ -0,0 +1,13 @@
+package service
+
+import "context"
+
+// TxManager выполняет функцию в транзакции: репозитории, получившие переданный в fn контекст,
+// работают в этой транзакции. Вложенные вызовы выполняются в точках сохранения.
+type TxManager interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+	// AfterCommit откладывает fn до фиксации внешней транзакции из ctx (при откате fn не вызывается),
+	// вне транзакции вызывает fn сразу. Нужен для сброса кешей: сброшенный до фиксации кеш
+	// параллельное чтение успеет заполнить старыми данными.
+	AfterCommit(ctx context.Context, fn func())
+}
@@ -3,7 +3,7 @@ package service
 import (
 	"context"
 	"github.com/SaveljevRoman/go-layout-project/internal/models"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -11,8 +11,11 @@ type UserRepository interface {
 	GetByID(ctx context.Context, id int64) (*models.User, error)
 	GetAll(ctx context.Context) ([]*models.User, error)
 	Create(ctx context.Context, user *models.User) (int64, error)
+	CreateBatch(ctx context.Context, users []*models.User) ([]int64, error)
 	Update(ctx context.Context, user *models.User) error
-	Delete(ctx context.Context, id int64) error
+	Patch(ctx context.Context, id int64, patch *models.UserPatch) error
+	Delete(ctx context.Context, id int64, version int64) error
+	ForEach(ctx context.Context, fn func(user *models.User) error) error
 }
 
 type UserCache interface {
@@ -20,17 +23,23 @@ type UserCache interface {
 	Set(ctx context.Context, user *models.User, expiration time.Duration) error
 	Delete(ctx context.Context, id int64) error
 	SetAllUsers(ctx context.Context, users []*models.User, expiration time.Duration) error
+	SetMany(ctx context.Context, users []*models.User, expiration time.Duration) error
+	DeleteMany(ctx context.Context, ids []int64) error
 }
 
 type UserService struct {
-	repo  UserRepository
-	cache UserCache
+	repo      UserRepository
+	cache     UserCache
+	txManager TxManager
+	*cacheSettings
 }
 
-func NewUserService(repo UserRepository, cache UserCache) *UserService {
+func NewUserService(repo UserRepository, cache UserCache, txManager TxManager) *UserService {
 	return &UserService{
-		repo:  repo,
-		cache: cache,
+		repo:          repo,
+		cache:         cache,
+		txManager:     txManager,
+		cacheSettings: newCacheSettings(),
 	}
 }
 
@@ -38,7 +47,7 @@ func (s *UserService) GetUser(ctx context.Context, id int64) (*models.User, erro
 	// Сначала пытаемся получить из кеша
 	user, err := s.cache.GetByID(ctx, id)
 	if err != nil {
-		log.Printf("Cache error: %v", err)
+		slog.Warn("Cache error", "error", err)
 	}
 
 	if user != nil {
@@ -52,9 +61,9 @@ func (s *UserService) GetUser(ctx context.Context, id int64) (*models.User, erro
 	}
 
 	if user != nil {
-		// Кешируем результат на 5 минут
-		if err := s.cache.Set(ctx, user, 5*time.Minute); err != nil {
-			log.Printf("Failed to cache user: %v", err)
+		// Кешируем результат
+		if err := s.cache.Set(ctx, user, s.cacheTTL()); err != nil {
+			slog.Warn("Failed to cache user", "error", err)
 		}
 	}
 
@@ -65,47 +74,181 @@ func (s *UserService) GetAllUsers(ctx context.Context) ([]*models.User, error) {
 	return s.repo.GetAll(ctx)
 }
 
+// ExportUsers передает в fn всех пользователей по одному, не загружая таблицу в память (в отличие от GetAllUsers)
+func (s *UserService) ExportUsers(ctx context.Context, fn func(user *models.User) error) error {
+	return s.repo.ForEach(ctx, fn)
+}
+
 func (s *UserService) CreateUser(ctx context.Context, user *models.User) (int64, error) {
 	id, err := s.repo.Create(ctx, user)
 	if err != nil {
 		return 0, err
 	}
 
-	// Обновить пользователя с ID
+	// Обновить пользователя с ID; новая запись всегда создается с версией 1
 	user.ID = id
-	if err := s.cache.Set(ctx, user, 5*time.Minute); err != nil {
-		log.Printf("Failed to cache user: %v", err)
+	user.Version = 1
+	if err := s.cache.Set(ctx, user, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to cache user", "error", err)
 	}
 
 	return id, nil
 }
 
+// UpdateUser перезаписывает запись. user.Version - ожидаемая версия (0 - без проверки),
+// при несовпадении возвращается models.ErrVersionConflict. После обновления user содержит сохраненное состояние.
 func (s *UserService) UpdateUser(ctx context.Context, user *models.User) error {
 	if err := s.repo.Update(ctx, user); err != nil {
 		return err
 	}
 
+	stored, err := s.repo.GetByID(ctx, user.ID)
+	if err != nil || stored == nil {
+		return err
+	}
+	*user = *stored
+
 	// Обновляем кеш
-	if err := s.cache.Set(ctx, user, 5*time.Minute); err != nil {
-		log.Printf("Failed to update user cache: %v", err)
+	if err := s.cache.Set(ctx, user, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to update user cache", "error", err)
 	}
 
 	return nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
-	if err := s.repo.Delete(ctx, id); err != nil {
+// PatchUser обновляет только переданные поля и возвращает пользователя после изменения.
+// Если пользователь не найден, возвращает nil без ошибки.
+func (s *UserService) PatchUser(ctx context.Context, id int64, patch *models.UserPatch) (*models.User, error) {
+	if err := s.repo.Patch(ctx, id, patch); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	// Пустой патч ничего не записывает, поэтому версию проверяем по прочитанной записи
+	if patch.IsEmpty() && patch.Version != 0 && user.Version != patch.Version {
+		return nil, models.ErrVersionConflict
+	}
+
+	// Обновляем кеш
+	if err := s.cache.Set(ctx, user, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to update user cache", "error", err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser удаляет запись; version - ожидаемая версия (0 - без проверки)
+func (s *UserService) DeleteUser(ctx context.Context, id int64, version int64) error {
+	if err := s.repo.Delete(ctx, id, version); err != nil {
 		return err
 	}
 
 	// Удаляем из кеша
 	if err := s.cache.Delete(ctx, id); err != nil {
-		log.Printf("Failed to delete user from cache: %v", err)
+		slog.Warn("Failed to delete user from cache", "error", err)
 	}
 
 	return nil
 }
 
+// BulkCreateUsers создает пользователей пачками (CreateBatch на пачку) и возвращает ошибку
+// каждого пользователя (nil - создан, ID и Version заполнены). atomic - все или ничего, см. runBulk.
+func (s *UserService) BulkCreateUsers(ctx context.Context, users []*models.User, atomic bool) ([]error, error) {
+	errs, err := runBulk(ctx, s.txManager, len(users), atomic,
+		func(ctx context.Context, from, to int) error {
+			ids, err := s.repo.CreateBatch(ctx, users[from:to])
+			if err != nil {
+				return err
+			}
+			for i, id := range ids {
+				users[from+i].ID = id
+			}
+			return nil
+		},
+		func(ctx context.Context, i int) error {
+			id, err := s.repo.Create(ctx, users[i])
+			users[i].ID = id
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Прогреваем кеш созданными пользователями одним конвейером
+	now := time.Now()
+	created := make([]*models.User, 0, len(users))
+	for i, user := range users {
+		if errs[i] != nil {
+			user.ID = 0
+			continue
+		}
+		user.Version = 1
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		created = append(created, user)
+	}
+	if err := s.cache.SetMany(ctx, created, s.cacheTTL()); err != nil {
+		slog.Warn("Failed to warm user cache", "error", err)
+	}
+
+	return errs, nil
+}
+
+// BulkUpdateUsers перезаписывает пользователей пачками; Version каждого - ожидаемая версия.
+// Для записанных пользователей Version увеличивается до сохраненной.
+func (s *UserService) BulkUpdateUsers(ctx context.Context, users []*models.User, atomic bool) ([]error, error) {
+	errs, err := runBulk(ctx, s.txManager, len(users), atomic, nil,
+		func(ctx context.Context, i int) error {
+			return s.repo.Update(ctx, users[i])
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Кешированные записи устарели; created_at в запросе нет, поэтому записи удаляются, а не перезаписываются
+	updated := make([]int64, 0, len(users))
+	for i, user := range users {
+		if errs[i] == nil {
+			user.Version++
+			updated = append(updated, user.ID)
+		}
+	}
+	if err := s.cache.DeleteMany(ctx, updated); err != nil {
+		slog.Warn("Failed to invalidate user cache", "error", err)
+	}
+
+	return errs, nil
+}
+
+// BulkDeleteUsers удаляет пользователей пачками с проверкой версии каждого
+func (s *UserService) BulkDeleteUsers(ctx context.Context, items []models.BulkDeleteItem, atomic bool) ([]error, error) {
+	errs, err := runBulk(ctx, s.txManager, len(items), atomic, nil,
+		func(ctx context.Context, i int) error {
+			return s.repo.Delete(ctx, items[i].ID, items[i].Version)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]int64, 0, len(items))
+	for i, item := range items {
+		if errs[i] == nil {
+			deleted = append(deleted, item.ID)
+		}
+	}
+	if err := s.cache.DeleteMany(ctx, deleted); err != nil {
+		slog.Warn("Failed to delete users from cache", "error", err)
+	}
+
+	return errs, nil
+}
+
 // StartCacheUpdater Метод для фонового обновления кеша
 func (s *UserService) StartCacheUpdater(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
@@ -114,8 +257,10 @@ func (s *UserService) StartCacheUpdater(ctx context.Context, interval time.Durat
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Cache updater stopped")
+			slog.Debug("Cache updater stopped")
 			return
+		case interval := <-s.intervalUpdates:
+			ticker.Reset(interval)
 		case <-ticker.C:
 			s.updateCache(ctx)
 		}
@@ -123,17 +268,17 @@ func (s *UserService) StartCacheUpdater(ctx context.Context, interval time.Durat
 }
 
 func (s *UserService) updateCache(ctx context.Context) {
-	log.Println("Updating cache...")
+	slog.Debug("Updating cache...")
 	users, err := s.repo.GetAll(ctx)
 	if err != nil {
-		log.Printf("Failed to get users for cache update: %v", err)
+		slog.Error("Failed to get users for cache update", "error", err)
 		return
 	}
 
-	if err := s.cache.SetAllUsers(ctx, users, 10*time.Minute); err != nil {
-		log.Printf("Failed to update users cache: %v", err)
+	if err := s.cache.SetAllUsers(ctx, users, s.cacheRefreshTTL()); err != nil {
+		slog.Warn("Failed to update users cache", "error", err)
 		return
 	}
 
-	log.Printf("Cache updated with %d users", len(users))
+	slog.Debug("Cache updated", "users", len(users))
 }
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"github.com/SaveljevRoman/go-layout-project/internal/models"
+)
+
+type VariantRepository interface {
+	GetByID(ctx context.Context, id int64) (*models.ProductVariant, error)
+	// GetBySKU возвращает вариант с артикулом sku; nil - артикул свободен
+	GetBySKU(ctx context.Context, sku string) (*models.ProductVariant, error)
+	GetByProductID(ctx context.Context, productID int64) ([]*models.ProductVariant, error)
+	Create(ctx context.Context, variant *models.ProductVariant) (int64, error)
+	Update(ctx context.Context, variant *models.ProductVariant) error
+	Delete(ctx context.Context, id int64, version int64) error
+	// DecreaseQuantity списывает вариант со склада; models.ErrInsufficientStock - остатка не хватает
+	DecreaseQuantity(ctx context.Context, id int64, quantity int) error
+}
+
+// ProductGetter - то, что VariantService и RecommendationService используют из сервиса продуктов
+type ProductGetter interface {
+	GetProduct(ctx context.Context, id int64) (*models.Product, error)
+}
+
+// VariantProducts - то, что VariantService использует из сервиса продуктов. Наличие товара с вариантами
+// считается по вариантам, поэтому их изменения сбрасывают результаты поиска.
+type VariantProducts interface {
+	ProductGetter
+	InvalidateSearch(ctx context.Context)
+}
+
+// VariantService управляет вариантами товаров. Варианты не кешируются: их читают
+// вместе с карточкой товара и при покупке, где нужен актуальный остаток.
+type VariantService struct {
+	repo           VariantRepository
+	productService VariantProducts
+	txManager      TxManager
+}
+
+func NewVariantService(repo VariantRepository, productService VariantProducts, txManager TxManager) *VariantService {
+	return &VariantService{
+		repo:           repo,
+		productService: productService,
+		txManager:      txManager,
+	}
+}
+
+// GetProductVariants возвращает варианты товара; nil - товар не найден
+func (s *VariantService) GetProductVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error) {
+	product, err := s.productService.GetProduct(ctx, productID)
+	if err != nil || product == nil {
+		return nil, err
+	}
+	return s.repo.GetByProductID(ctx, productID)
+}
+
+func (s *VariantService) GetVariant(ctx context.Context, id int64) (*models.ProductVariant, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// CreateVariant добавляет вариант товару variant.ProductID; при успехе заполняет ID и версию.
+// Если товар не найден, variant.ID остается нулевым.
+func (s *VariantService) CreateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	product, err := s.productService.GetProduct(ctx, variant.ProductID)
+	if err != nil || product == nil {
+		return err
+	}
+
+	err = s.txManager.Do(ctx, func(ctx context.Context) error {
+		if err := s.checkSKU(ctx, variant.SKU, 0); err != nil {
+			return err
+		}
+		id, err := s.repo.Create(ctx, variant)
+		if err != nil {
+			return err
+		}
+		created, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		*variant = *created
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.productService.InvalidateSearch(ctx)
+	return nil
+}
+
+// UpdateVariant заменяет вариант; variant.Version - ожидаемая версия (0 - без проверки).
+// Если вариант не найден, variant.Version обнуляется.
+func (s *VariantService) UpdateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	err := s.txManager.Do(ctx, func(ctx context.Context) error {
+		if err := s.checkSKU(ctx, variant.SKU, variant.ID); err != nil {
+			return err
+		}
+		if err := s.repo.Update(ctx, variant); err != nil {
+			return err
+		}
+		updated, err := s.repo.GetByID(ctx, variant.ID)
+		if err != nil {
+			return err
+		}
+		if updated == nil {
+			variant.Version = 0
+			return nil
+		}
+		*variant = *updated
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.productService.InvalidateSearch(ctx)
+	return nil
+}
+
+// DeleteVariant удаляет вариант; покупки варианта остаются без ссылки на него
+func (s *VariantService) DeleteVariant(ctx context.Context, id int64, version int64) error {
+	if err := s.repo.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	s.productService.InvalidateSearch(ctx)
+	return nil
+}
+
+// ReserveStock атомарно списывает вариант со склада; вызывается в транзакции покупки.
+// Если вариант закончился, товар мог пропасть из наличия: результаты поиска сбрасываются после фиксации.
+func (s *VariantService) ReserveStock(ctx context.Context, id int64, quantity int) error {
+	if err := s.repo.DecreaseQuantity(ctx, id, quantity); err != nil {
+		return err
+	}
+	variant, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if variant != nil && variant.Quantity == 0 {
+		s.txManager.AfterCommit(ctx, func() { s.productService.InvalidateSearch(ctx) })
+	}
+	return nil
+}
+
+// InStock сообщает, есть ли товар в наличии: у товара с вариантами - хотя бы один вариант с остатком,
+// у товара без вариантов - собственный остаток
+func (s *VariantService) InStock(ctx context.Context, product *models.Product) (bool, error) {
+	variants, err := s.repo.GetByProductID(ctx, product.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(variants) == 0 {
+		return product.Quantity > 0, nil
+	}
+	for _, variant := range variants {
+		if variant.Quantity > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkSKU возвращает models.ErrDuplicateSKU, если артикул занят вариантом, отличным от exceptID
+func (s *VariantService) checkSKU(ctx context.Context, sku string, exceptID int64) error {
+	existing, err := s.repo.GetBySKU(ctx, sku)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != exceptID {
+		return models.ErrDuplicateSKU
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup настраивает slog как логгер по умолчанию (в том числе для стандартного пакета log)
+// и возвращает LevelVar, через который уровень можно менять на лету
+func Setup(level string) (*slog.LevelVar, error) {
+	levelVar := new(slog.LevelVar)
+	if err := SetLevel(levelVar, level); err != nil {
+		return nil, err
+	}
+
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: levelVar})
+	slog.SetDefault(slog.New(handler))
+
+	return levelVar, nil
+}
+
+func SetLevel(levelVar *slog.LevelVar, level string) error {
+	switch strings.ToLower(level) {
+	case "debug":
+		levelVar.Set(slog.LevelDebug)
+	case "info", "":
+		levelVar.Set(slog.LevelInfo)
+	case "warn":
+		levelVar.Set(slog.LevelWarn)
+	case "error":
+		levelVar.Set(slog.LevelError)
+	default:
+		return fmt.Errorf("неизвестный уровень логирования: %s", level)
+	}
+	return nil
+}
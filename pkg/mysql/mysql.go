@@ -1,24 +1,217 @@
 package mysql
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"github.com/SaveljevRoman/go-layout-project/internal/config"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
 )
 
-func NewConnection(cfg config.MySQLConfig) (*sqlx.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
+// NewConnection подключается к primary; отмена ctx прерывает ожидание между попытками
+func NewConnection(ctx context.Context, cfg config.MySQLConfig) (*sqlx.DB, error) {
+	db, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pingWithRetry(ctx, db, cfg); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// NewReplicaConnections подключается к репликам из cfg.Replicas с параметрами primary.
+// Недоступная при старте реплика не считается ошибкой: она будет добавлена,
+// но начнет получать чтения только после успешной проверки здоровья.
+func NewReplicaConnections(cfg config.MySQLConfig) ([]*sqlx.DB, error) {
+	replicas := make([]*sqlx.DB, 0, len(cfg.Replicas))
+	for _, address := range cfg.Replicas {
+		host, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный порт реплики %s: %w", address, err)
+		}
+
+		replicaCfg := cfg
+		replicaCfg.Host = host
+		replicaCfg.Port = port
+
+		db, err := open(replicaCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout(cfg))
+		if err := db.PingContext(ctx); err != nil {
+			slog.Warn("MySQL replica is not available yet", "address", address, "error", err)
+		}
+		cancel()
+
+		replicas = append(replicas, db)
+	}
+	return replicas, nil
+}
 
-	db, err := sqlx.Connect("mysql", dsn)
+func open(cfg config.MySQLConfig) (*sqlx.DB, error) {
+	connector, err := newConnector(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// Установка параметров подключения
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	db := sqlx.NewDb(sql.OpenDB(connector), "mysql")
+
+	// Установка параметров пула соединений
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(seconds(cfg.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(seconds(cfg.ConnMaxIdleTime))
 
 	return db, nil
 }
+
+// pingWithRetry проверяет соединение, повторяя попытки с экспоненциальной задержкой,
+// пока БД поднимается (например, при одновременном старте в docker-compose)
+func pingWithRetry(ctx context.Context, db *sqlx.DB, cfg config.MySQLConfig) error {
+	delay := seconds(cfg.ConnectRetryDelay)
+	maxDelay := seconds(cfg.ConnectRetryMaxDelay)
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout(cfg))
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return fmt.Errorf("не удалось подключиться к MySQL после %d попыток: %w", attempt+1, err)
+		}
+
+		slog.Warn("MySQL is not available, retrying", "attempt", attempt+1, "max_attempts", cfg.ConnectRetries+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("подключение к MySQL прервано: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+func pingTimeout(cfg config.MySQLConfig) time.Duration {
+	if cfg.DialTimeout > 0 {
+		return 2 * seconds(cfg.DialTimeout)
+	}
+	return 10 * time.Second
+}
+
+func newConnector(cfg config.MySQLConfig) (driver.Connector, error) {
+	driverCfg, err := driverConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewConnector(driverCfg)
+}
+
+// driverConfig собирает конфигурацию драйвера из настроек приложения
+func driverConfig(cfg config.MySQLConfig) (*mysql.Config, error) {
+	driverCfg := mysql.NewConfig()
+	driverCfg.User = cfg.User
+	driverCfg.Passwd = cfg.Password
+	driverCfg.Net = "tcp"
+	driverCfg.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	driverCfg.DBName = cfg.Database
+	driverCfg.ParseTime = true
+	driverCfg.Timeout = seconds(cfg.DialTimeout)
+	driverCfg.ReadTimeout = seconds(cfg.ReadTimeout)
+	driverCfg.WriteTimeout = seconds(cfg.WriteTimeout)
+	driverCfg.Collation = cfg.Collation
+
+	driverCfg.Params = make(map[string]string, len(cfg.Params)+1)
+	for key, value := range cfg.Params {
+		driverCfg.Params[key] = value
+	}
+	if cfg.Charset != "" {
+		driverCfg.Params["charset"] = cfg.Charset
+	}
+
+	// Проходим через DSN, чтобы драйвер разобрал параметры (charset и др.) так же, как из строки подключения
+	parsed, err := mysql.ParseDSN(driverCfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("некорректные параметры подключения к MySQL: %w", err)
+	}
+
+	if err := applyTLS(parsed, cfg.TLS, cfg.Host); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+func applyTLS(driverCfg *mysql.Config, cfg config.MySQLTLSConfig, host string) error {
+	switch cfg.Mode {
+	case "", "disabled":
+		return nil
+	case "preferred":
+		driverCfg.TLSConfig = "preferred"
+		driverCfg.AllowFallbackToPlaintext = true
+		if cfg.CAFile == "" && cfg.CertFile == "" {
+			return nil
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.Mode == "skip-verify",
+		MinVersion:         tls.VersionTLS12,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать CA сертификат MySQL: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return errors.New("файл CA сертификата MySQL не содержит сертификатов")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("не удалось загрузить клиентский сертификат MySQL: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	driverCfg.TLS = tlsConfig
+	return nil
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
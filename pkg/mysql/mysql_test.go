@@ -0,0 +1,203 @@
+package mysql
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig() config.MySQLConfig {
+	return config.MySQLConfig{
+		Host:         "db.local",
+		Port:         3307,
+		User:         "app",
+		Password:     "p@ss:word/",
+		Database:     "shop",
+		Charset:      "utf8mb4",
+		Collation:    "utf8mb4_unicode_ci",
+		DialTimeout:  3,
+		ReadTimeout:  4,
+		WriteTimeout: 5,
+		Params:       map[string]string{"interpolateParams": "true"},
+	}
+}
+
+func TestDriverConfig(t *testing.T) {
+	got, err := driverConfig(testConfig())
+	if err != nil {
+		t.Fatalf("driverConfig: %v", err)
+	}
+
+	if got.Addr != "db.local:3307" || got.Net != "tcp" || got.User != "app" || got.Passwd != "p@ss:word/" || got.DBName != "shop" {
+		t.Errorf("адрес и учетные данные: %+v", got)
+	}
+	if !got.ParseTime || got.Collation != "utf8mb4_unicode_ci" {
+		t.Errorf("ParseTime = %v, Collation = %q", got.ParseTime, got.Collation)
+	}
+	if got.Timeout != 3*time.Second || got.ReadTimeout != 4*time.Second || got.WriteTimeout != 5*time.Second {
+		t.Errorf("таймауты: %v, %v, %v", got.Timeout, got.ReadTimeout, got.WriteTimeout)
+	}
+	// Параметры из конфигурации разбираются драйвером так же, как из строки подключения
+	if !got.InterpolateParams || !strings.Contains(got.FormatDSN(), "charset=utf8mb4") {
+		t.Errorf("InterpolateParams = %v, DSN %q", got.InterpolateParams, got.FormatDSN())
+	}
+	if got.TLS != nil {
+		t.Error("TLS включен без настройки")
+	}
+
+	// Повторный разбор DSN дает ту же конфигурацию
+	parsed, err := mysql.ParseDSN(got.FormatDSN())
+	if err != nil {
+		t.Fatalf("ParseDSN: %v", err)
+	}
+	if parsed.FormatDSN() != got.FormatDSN() {
+		t.Errorf("DSN после разбора %q, ожидалось %q", parsed.FormatDSN(), got.FormatDSN())
+	}
+
+	cfg := testConfig()
+	cfg.Params = map[string]string{"parseTime": "maybe"}
+	if _, err := driverConfig(cfg); err == nil {
+		t.Error("некорректный параметр драйвера принят")
+	}
+}
+
+// writeCA создает самоподписанный сертификат в PEM и возвращает путь к файлу
+func writeCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyTLS(t *testing.T) {
+	ca := writeCA(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("не сертификат"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		tls            config.MySQLTLSConfig
+		wantTLS        bool
+		wantTLSConfig  string
+		wantFallback   bool
+		wantInsecure   bool
+		wantServerName string
+		wantRootCAs    bool
+		wantErr        bool
+	}{
+		{name: "по умолчанию", tls: config.MySQLTLSConfig{}},
+		{name: "disabled", tls: config.MySQLTLSConfig{Mode: "disabled"}},
+		{name: "preferred без сертификатов", tls: config.MySQLTLSConfig{Mode: "preferred"}, wantTLSConfig: "preferred", wantFallback: true},
+		{name: "preferred с CA", tls: config.MySQLTLSConfig{Mode: "preferred", CAFile: ca},
+			wantTLS: true, wantTLSConfig: "preferred", wantFallback: true, wantServerName: "db.local", wantRootCAs: true},
+		{name: "skip-verify", tls: config.MySQLTLSConfig{Mode: "skip-verify"}, wantTLS: true, wantInsecure: true, wantServerName: "db.local"},
+		{name: "verify с именем сервера", tls: config.MySQLTLSConfig{Mode: "verify", CAFile: ca, ServerName: "mysql.internal"},
+			wantTLS: true, wantServerName: "mysql.internal", wantRootCAs: true},
+		{name: "нет файла CA", tls: config.MySQLTLSConfig{Mode: "verify", CAFile: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: true},
+		{name: "CA без сертификатов", tls: config.MySQLTLSConfig{Mode: "verify", CAFile: notPEM}, wantErr: true},
+		{name: "нет клиентского сертификата", tls: config.MySQLTLSConfig{Mode: "verify", CertFile: "missing.crt", KeyFile: "missing.key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driverCfg := mysql.NewConfig()
+			err := applyTLS(driverCfg, tt.tls, "db.local")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyTLS: ошибка %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if driverCfg.TLSConfig != tt.wantTLSConfig || driverCfg.AllowFallbackToPlaintext != tt.wantFallback {
+				t.Errorf("TLSConfig = %q, AllowFallbackToPlaintext = %v", driverCfg.TLSConfig, driverCfg.AllowFallbackToPlaintext)
+			}
+			if (driverCfg.TLS != nil) != tt.wantTLS {
+				t.Fatalf("TLS = %+v, ожидался TLS: %v", driverCfg.TLS, tt.wantTLS)
+			}
+			if driverCfg.TLS == nil {
+				return
+			}
+			if driverCfg.TLS.InsecureSkipVerify != tt.wantInsecure || driverCfg.TLS.ServerName != tt.wantServerName ||
+				(driverCfg.TLS.RootCAs != nil) != tt.wantRootCAs {
+				t.Errorf("TLS: InsecureSkipVerify %v, ServerName %q, RootCAs %v",
+					driverCfg.TLS.InsecureSkipVerify, driverCfg.TLS.ServerName, driverCfg.TLS.RootCAs != nil)
+			}
+		})
+	}
+}
+
+// unreachable открывает пул к адресу, где никто не слушает: Ping быстро завершается ошибкой
+func unreachable(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("mysql", "app@tcp(127.0.0.1:1)/app?timeout=200ms")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPingWithRetryStopsOnCancel(t *testing.T) {
+	db := unreachable(t)
+	// Задержка между попытками больше таймаута теста: вернуться раньше можно только по отмене
+	cfg := config.MySQLConfig{DialTimeout: 1, ConnectRetries: 10, ConnectRetryDelay: 3600}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := pingWithRetry(ctx, db, cfg)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("pingWithRetry: %v, ожидалась context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("pingWithRetry вернулась через %v после отмены", elapsed)
+	}
+}
+
+func TestPingWithRetryGivesUp(t *testing.T) {
+	db := unreachable(t)
+	cfg := config.MySQLConfig{DialTimeout: 1, ConnectRetries: 0}
+
+	err := pingWithRetry(context.Background(), db, cfg)
+	if err == nil {
+		t.Fatal("pingWithRetry к недоступному серверу завершилась без ошибки")
+	}
+	if !strings.Contains(err.Error(), "после 1 попыток") {
+		t.Errorf("в ошибке %q нет числа попыток", err)
+	}
+}
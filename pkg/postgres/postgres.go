@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// NewConnection подключается к PostgreSQL; отмена ctx прерывает ожидание между попытками
+func NewConnection(ctx context.Context, cfg config.PostgresConfig) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", dsn(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	// Установка параметров пула соединений
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+
+	delay := time.Duration(cfg.ConnectRetryDelay) * time.Second
+	for attempt := 0; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			db.Close()
+			return nil, fmt.Errorf("не удалось подключиться к PostgreSQL после %d попыток: %w", attempt+1, err)
+		}
+
+		slog.Warn("PostgreSQL is not available, retrying", "attempt", attempt+1, "max_attempts", cfg.ConnectRetries+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("подключение к PostgreSQL прервано: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func dsn(cfg config.PostgresConfig) string {
+	query := url.Values{}
+	query.Set("sslmode", cfg.SSLMode)
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
+	}
+	if cfg.ConnectTimeout > 0 {
+		query.Set("connect_timeout", strconv.Itoa(cfg.ConnectTimeout))
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Path:     "/" + cfg.Database,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
@@ -1,16 +1,114 @@
 package redis
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"github.com/SaveljevRoman/go-layout-project/internal/config"
 	"github.com/go-redis/redis/v8"
+	"os"
+	"time"
 )
 
-func NewConnection(cfg config.RedisConfig) (*redis.Client, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Address,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+// NewConnection создает клиент под топологию из конфигурации:
+// одиночный сервер, мастер под управлением Sentinel или Redis Cluster
+func NewConnection(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	opts, err := universalOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case "sentinel":
+		client = redis.NewFailoverClient(opts.Failover())
+	case "cluster":
+		client = redis.NewClusterClient(opts.Cluster())
+	default:
+		client = redis.NewClient(opts.Simple())
+	}
 
 	return client, nil
 }
+
+// universalOptions собирает общие параметры клиента; для одиночного сервера адрес берется из cfg.Address
+func universalOptions(cfg config.RedisConfig) (*redis.UniversalOptions, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addresses,
+		DB:               cfg.DB,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelPassword: cfg.SentinelPassword,
+		MasterName:       cfg.MasterName,
+		MaxRetries:       cfg.MaxRetries,
+		DialTimeout:      seconds(cfg.DialTimeout),
+		ReadTimeout:      seconds(cfg.ReadTimeout),
+		WriteTimeout:     seconds(cfg.WriteTimeout),
+		PoolTimeout:      seconds(cfg.PoolTimeout),
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		TLSConfig:        tlsConfig,
+	}
+
+	switch cfg.Mode {
+	case "sentinel", "cluster":
+	case "single", "":
+		opts.Addrs = []string{cfg.Address}
+	default:
+		return nil, fmt.Errorf("неизвестный режим Redis: %s", cfg.Mode)
+	}
+
+	return opts, nil
+}
+
+// Ping проверяет доступность Redis
+func Ping(ctx context.Context, client redis.UniversalClient) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return client.Ping(ctx).Err()
+}
+
+func newTLSConfig(cfg config.RedisTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать CA сертификат Redis: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("файл CA сертификата Redis не содержит сертификатов")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить клиентский сертификат Redis: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/go-redis/redis/v8"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUniversalOptions(t *testing.T) {
+	base := config.RedisConfig{
+		Address:          "cache:6379",
+		Addresses:        []string{"node1:26379", "node2:26379"},
+		MasterName:       "mymaster",
+		Username:         "app",
+		Password:         "secret",
+		SentinelPassword: "sentinel-secret",
+		DB:               2,
+		PoolSize:         20,
+		MaxRetries:       4,
+		DialTimeout:      3,
+		TLS:              config.RedisTLSConfig{Enabled: true, ServerName: "cache.internal"},
+	}
+
+	tests := []struct {
+		mode    string
+		check   func(t *testing.T, opts *redis.UniversalOptions)
+		wantErr bool
+	}{
+		{mode: "sentinel", check: func(t *testing.T, opts *redis.UniversalOptions) {
+			failover := opts.Failover()
+			if failover.MasterName != "mymaster" || !reflect.DeepEqual(failover.SentinelAddrs, base.Addresses) {
+				t.Errorf("мастер %q, sentinel-узлы %q", failover.MasterName, failover.SentinelAddrs)
+			}
+			if failover.SentinelPassword != "sentinel-secret" || failover.Password != "secret" || failover.Username != "app" {
+				t.Errorf("пароли: sentinel %q, мастер %q, пользователь %q", failover.SentinelPassword, failover.Password, failover.Username)
+			}
+			if failover.DB != 2 || failover.PoolSize != 20 || failover.MaxRetries != 4 || failover.DialTimeout != 3*time.Second {
+				t.Errorf("параметры клиента: %+v", failover)
+			}
+			if failover.TLSConfig == nil || failover.TLSConfig.ServerName != "cache.internal" {
+				t.Errorf("TLS = %+v", failover.TLSConfig)
+			}
+		}},
+		{mode: "cluster", check: func(t *testing.T, opts *redis.UniversalOptions) {
+			cluster := opts.Cluster()
+			if !reflect.DeepEqual(cluster.Addrs, base.Addresses) {
+				t.Errorf("узлы кластера %q, ожидалось %q", cluster.Addrs, base.Addresses)
+			}
+			if cluster.Password != "secret" || cluster.Username != "app" || cluster.PoolSize != 20 || cluster.MaxRetries != 4 {
+				t.Errorf("параметры клиента: %+v", cluster)
+			}
+			if cluster.TLSConfig == nil {
+				t.Error("TLS не передан клиенту кластера")
+			}
+		}},
+		{mode: "single", check: func(t *testing.T, opts *redis.UniversalOptions) {
+			simple := opts.Simple()
+			if simple.Addr != "cache:6379" {
+				t.Errorf("адрес %q, ожидался cache:6379", simple.Addr)
+			}
+			if simple.Password != "secret" || simple.DB != 2 || simple.PoolSize != 20 || simple.DialTimeout != 3*time.Second {
+				t.Errorf("параметры клиента: %+v", simple)
+			}
+			if simple.TLSConfig == nil {
+				t.Error("TLS не передан клиенту")
+			}
+		}},
+		{mode: "", check: func(t *testing.T, opts *redis.UniversalOptions) {
+			// Режим по умолчанию - одиночный сервер по Address, список Addresses не используется
+			if simple := opts.Simple(); simple.Addr != "cache:6379" {
+				t.Errorf("адрес %q, ожидался cache:6379", simple.Addr)
+			}
+		}},
+		{mode: "replica", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := base
+			cfg.Mode = tt.mode
+
+			opts, err := universalOptions(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("universalOptions: ошибка %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := NewConnection(cfg); err == nil {
+					t.Error("NewConnection с неизвестным режимом завершился без ошибки")
+				}
+				return
+			}
+			tt.check(t, opts)
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	if tlsConfig, err := newTLSConfig(config.RedisTLSConfig{}); err != nil || tlsConfig != nil {
+		t.Errorf("выключенный TLS: (%+v, %v)", tlsConfig, err)
+	}
+	if _, err := newTLSConfig(config.RedisTLSConfig{Enabled: true, CAFile: "missing.pem"}); err == nil {
+		t.Error("отсутствующий файл CA принят")
+	}
+	tlsConfig, err := newTLSConfig(config.RedisTLSConfig{Enabled: true, InsecureSkipVerify: true})
+	if err != nil || !tlsConfig.InsecureSkipVerify {
+		t.Errorf("InsecureSkipVerify не передан: (%+v, %v)", tlsConfig, err)
+	}
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/SaveljevRoman/go-layout-project/internal/config"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+	"net/url"
+)
+
+func NewConnection(cfg config.SQLiteConfig) (*sqlx.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeout))
+	query.Set("_time_format", "sqlite")
+
+	maxOpenConns := cfg.MaxOpenConns
+	if cfg.Path == ":memory:" {
+		// Каждое соединение с :memory: - отдельная БД, поэтому держим одно
+		maxOpenConns = 1
+	} else {
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	db, err := sqlx.Open("sqlite", "file:"+cfg.Path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(maxOpenConns)
+	if cfg.Path == ":memory:" {
+		// Соединение не должно закрываться, иначе данные пропадут
+		db.SetConnMaxLifetime(0)
+		db.SetMaxIdleConns(1)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}